@@ -2,7 +2,6 @@ package main
 
 import (
 	"context"
-	"fmt"
 	"io"
 	"maps"
 	"slices"
@@ -13,21 +12,30 @@ import (
 
 type CommandHandlerFunc func(ctx context.Context, command *model.Command, r io.Reader) error
 
-func HandleCommand(ctx context.Context, command *model.Command, r io.Reader) error {
-	commandMap := map[string]CommandHandlerFunc{
-		"insert":  handlers.Insert,
-		"get":     handlers.Get,
-		"list":    handlers.List,
-		"tables":  handlers.Tables,
-		"update":  handlers.Update,
-		"query":   handlers.Query,
-		"version": handlers.Version,
-		"server":  handlers.Server,
-	}
-	commands := slices.Collect(maps.Keys(commandMap))
+var commandMap = map[string]CommandHandlerFunc{
+	"insert":  handlers.Insert,
+	"get":     handlers.Get,
+	"list":    handlers.List,
+	"tables":  handlers.Tables,
+	"update":  handlers.Update,
+	"query":   handlers.Query,
+	"version": handlers.Version,
+	"server":  handlers.Server,
+	"dump":    handlers.Dump,
+	"restore": handlers.Restore,
+	"seed":    handlers.Seed,
+
+	"connections": handlers.Connections,
+}
 
+func HandleCommand(ctx context.Context, command *model.Command, r io.Reader) error {
 	if fn, ok := commandMap[command.Name]; ok {
 		return fn(ctx, command, r)
 	}
-	return fmt.Errorf("unknown command: %s, supported %v", command.Name, commands)
+	return unknownCommand(command.Name)
+}
+
+func unknownCommand(name string) error {
+	commands := slices.Sorted(maps.Keys(commandMap))
+	return model.InputError("unknown command: %s, supported %v", name, commands)
 }
@@ -2,17 +2,19 @@ package main
 
 import (
 	"context"
-	"errors"
+	"encoding/json"
+	"fmt"
 	"io"
-	"log"
 	"os"
 	"os/signal"
+	"slices"
 	"strings"
 	"syscall"
 
 	"github.com/go-bridget/mig/db"
 	"github.com/jmoiron/sqlx"
 
+	"github.com/titpetric/etl/drivers"
 	"github.com/titpetric/etl/model"
 
 	_ "github.com/go-sql-driver/mysql"
@@ -20,44 +22,92 @@ import (
 	_ "modernc.org/sqlite"
 )
 
+// noDatabaseCommands don't use the database connection.
+var noDatabaseCommands = []string{"version", "server", "connections"}
+
 func main() {
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
-	defer stop()
 
-	if err := start(ctx); err != nil {
-		log.Fatal(err)
+	config := model.NewConfig()
+	err := start(ctx, config)
+	stop()
+
+	if err != nil {
+		os.Exit(printError(os.Stderr, config, err))
 	}
 }
 
-func start(ctx context.Context) error {
+func start(ctx context.Context, config *model.Config) error {
 	if len(os.Args) < 2 {
-		return errors.New("usage: etl <command> <tableName> [options]")
+		return model.InputError("usage: etl <command> <tableName> [options]")
 	}
 
-	config := model.NewConfig()
 	args, err := config.ParseFlags()
 	if err != nil {
 		return err
 	}
+	if len(args) == 0 {
+		return model.InputError("usage: etl <command> <tableName> [options]")
+	}
+
+	name := args[0]
+	if _, ok := commandMap[name]; !ok {
+		return unknownCommand(name)
+	}
 
-	driver, dsn := db.ParseDSN(config.DSN)
-	handle, err := sqlx.Open(driver, dsn)
+	// Only read the config file when it's used, so that a broken
+	// config file doesn't break commands like `etl version`.
+	useDatabase := !slices.Contains(noDatabaseCommands, name)
+	switch {
+	case useDatabase:
+		err = config.LoadConnection()
+	case name == "connections":
+		err = config.LoadConfigFile()
+	}
 	if err != nil {
 		return err
 	}
-	defer handle.Close()
 
 	command := model.Command{
-		DB:      handle,
-		Name:    args[0],
-		Args:    args[1:],
-		Verbose: config.Verbose,
-		Quiet:   config.Quiet,
+		Name:     name,
+		Args:     args[1:],
+		Profiles: config.Profiles,
+		Verbose:  config.Verbose,
+		Quiet:    config.Quiet,
+	}
+
+	if useDatabase {
+		if config.DSN == "" {
+			return model.InputError("no database configured, use --db, --db-dsn or ETL_DB_DSN")
+		}
+
+		driver, dsn := db.ParseDSN(config.DSN)
+		handle, err := sqlx.Open(driver, dsn)
+		if err != nil {
+			return model.NewError(model.ClassConnection, err)
+		}
+		defer handle.Close()
+
+		command.DB = handle
 	}
 
 	return HandleCommand(ctx, &command, getInput())
 }
 
+// printError writes the error to w in the configured format
+// and returns the exit code for the error class.
+func printError(w io.Writer, config *model.Config, err error) int {
+	result := model.AsError(drivers.NewError(err, ""))
+
+	if config.ErrorFormat == "json" {
+		_ = json.NewEncoder(w).Encode(result)
+		return result.ExitCode()
+	}
+
+	fmt.Fprintf(w, "etl: %s: %s\n", result.Class, result.Message)
+	return result.ExitCode()
+}
+
 func getInput() io.Reader {
 	fallback := strings.NewReader("{}")
 
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+
+	"github.com/titpetric/etl/model"
+)
+
+// run runs etl with args, and returns the exit code and stderr.
+func run(t *testing.T, args ...string) (int, string) {
+	t.Helper()
+
+	// Don't read the user's config file
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("ETL_CONFIG", "")
+	t.Setenv("ETL_DB", "")
+	t.Setenv("ETL_DB_DSN", "")
+
+	defer func(args []string) { os.Args = args }(os.Args)
+	os.Args = append([]string{"etl"}, args...)
+
+	config := model.NewConfig()
+	err := start(context.Background(), config)
+	if err == nil {
+		return model.ExitOK, ""
+	}
+
+	var stderr bytes.Buffer
+	return printError(&stderr, config, err), stderr.String()
+}
+
+// TestExitCodes verifies the exit code and the JSON error of each error class.
+func TestExitCodes(t *testing.T) {
+	dir := t.TempDir()
+	dsn := "sqlite://file:" + filepath.Join(dir, "app.db")
+
+	db, err := sqlx.Open("sqlite", filepath.Join(dir, "app.db"))
+	require.NoError(t, err)
+	db.MustExec("create table user (id integer primary key, email text not null unique)")
+	db.MustExec("insert into user (email) values ('alice@example.com')")
+	require.NoError(t, db.Close())
+
+	script := func(name, contents string) string {
+		filename := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(filename, []byte(contents), 0o644))
+		return filename
+	}
+	syntax := script("syntax.sql", "insert into;")
+	duplicate := script("duplicate.sql", "insert into user (email) values ('alice@example.com');")
+	missing := "sqlite://file:" + filepath.Join(dir, "missing", "app.db")
+
+	tests := []struct {
+		name  string
+		args  []string
+		code  int
+		class model.ErrorClass
+		keys  []string
+	}{
+		{"no command", []string{"--error-format=json"}, model.ExitInput, model.ClassInput, []string{"class", "message"}},
+		{"unknown command", []string{"--error-format=json", "--db-dsn", dsn, "frobnicate"}, model.ExitInput, model.ClassInput, []string{"class", "message"}},
+		{"no database", []string{"--error-format=json", "get", "user"}, model.ExitInput, model.ClassInput, []string{"class", "message"}},
+		{"no rows", []string{"--error-format=json", "--db-dsn", dsn, "get", "user", "id=2"}, model.ExitNoRows, model.ClassNoRows, []string{"class", "statement", "message"}},
+		{"sql", []string{"--error-format=json", "--db-dsn", dsn, "query", syntax}, model.ExitSQL, model.ClassSQL, []string{"class", "code", "statement", "message"}},
+		{"constraint", []string{"--error-format=json", "--db-dsn", dsn, "query", duplicate}, model.ExitConstraint, model.ClassConstraint, []string{"class", "code", "statement", "message"}},
+		{"connection", []string{"--error-format=json", "--db-dsn", missing, "query", syntax}, model.ExitConnection, model.ClassConnection, []string{"class", "code", "statement", "message"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, stderr := run(t, tt.args...)
+			require.Equal(t, tt.code, code, stderr)
+
+			var result map[string]any
+			require.NoError(t, json.Unmarshal([]byte(stderr), &result), stderr)
+			require.Equal(t, string(tt.class), result["class"])
+			require.NotEmpty(t, result["message"])
+
+			keys := make([]string, 0, len(result))
+			for key := range result {
+				keys = append(keys, key)
+			}
+			require.ElementsMatch(t, tt.keys, keys)
+		})
+	}
+
+	code, stderr := run(t, "--db-dsn", dsn, "get", "user", "id=1")
+	require.Equal(t, model.ExitOK, code, stderr)
+}
+
+// TestPrintError verifies the text error format, and unclassified errors.
+func TestPrintError(t *testing.T) {
+	var stderr bytes.Buffer
+	code := printError(&stderr, &model.Config{ErrorFormat: "text"}, model.InputError("usage: etl get <table>"))
+	require.Equal(t, model.ExitInput, code)
+	require.Equal(t, "etl: input: usage: etl get <table>\n", stderr.String())
+
+	stderr.Reset()
+	code = printError(&stderr, &model.Config{ErrorFormat: "json"}, errors.New("boom"))
+	require.Equal(t, model.ExitError, code)
+	require.JSONEq(t, `{"class": "error", "message": "boom"}`, stderr.String())
+}
+
+// TestBrokenConfigFile verifies that the config file is only read
+// by the commands using it.
+func TestBrokenConfigFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "config.yml")
+	require.NoError(t, os.WriteFile(filename, []byte("connections: [\n"), 0o644))
+
+	code, stderr := run(t, "--config", filename, "version")
+	require.Equal(t, model.ExitOK, code, stderr)
+
+	code, stderr = run(t, "--config", filename, "get", "user")
+	require.Equal(t, model.ExitInput, code)
+	require.Contains(t, stderr, filename)
+
+	code, stderr = run(t, "--config", filename, "connections")
+	require.Equal(t, model.ExitInput, code)
+	require.Contains(t, stderr, filename)
+}
@@ -0,0 +1,373 @@
+package drivers
+
+import (
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/titpetric/etl/model"
+)
+
+// Dialect is the SQL dialect of a database.
+type Dialect string
+
+const (
+	DialectSQLite   Dialect = "sqlite"
+	DialectPostgres Dialect = "postgres"
+	DialectMySQL    Dialect = "mysql"
+)
+
+// Dialects lists the supported dialects.
+var Dialects = []Dialect{DialectSQLite, DialectPostgres, DialectMySQL}
+
+// DialectOf returns the dialect for a database connection.
+func DialectOf(db *sqlx.DB) (Dialect, error) {
+	return ParseDialect(db.DriverName())
+}
+
+// ParseDialect returns the dialect for a dialect or driver name.
+func ParseDialect(name string) (Dialect, error) {
+	switch strings.ToLower(name) {
+	case "sqlite", "sqlite3":
+		return DialectSQLite, nil
+	case "postgres", "postgresql", "pgx":
+		return DialectPostgres, nil
+	case "mysql":
+		return DialectMySQL, nil
+	}
+	return "", fmt.Errorf("unknown dialect: %s, supported %v", name, Dialects)
+}
+
+// Column kinds are dialect independent column types.
+const (
+	KindInteger   = "integer"
+	KindBigint    = "bigint"
+	KindBoolean   = "boolean"
+	KindDecimal   = "decimal"
+	KindFloat     = "float"
+	KindText      = "text"
+	KindVarchar   = "varchar"
+	KindTimestamp = "timestamp"
+	KindDate      = "date"
+	KindBlob      = "blob"
+	KindJSON      = "json"
+)
+
+var (
+	typeSizeRe     = regexp.MustCompile(`\(\s*(\d+)\s*(?:,\s*(\d+)\s*)?\)`)
+	typeModifierRe = regexp.MustCompile(`\([^)]*\)|\b(unsigned|signed|zerofill)\b`)
+)
+
+// kinds maps the database type names to column kinds, so that
+// types like `interval` or `point` aren't matched by a substring.
+var kinds = map[string]string{
+	"bool":                        KindBoolean,
+	"boolean":                     KindBoolean,
+	"bigint":                      KindBigint,
+	"big int":                     KindBigint,
+	"int8":                        KindBigint,
+	"bigserial":                   KindBigint,
+	"serial8":                     KindBigint,
+	"int":                         KindInteger,
+	"integer":                     KindInteger,
+	"int2":                        KindInteger,
+	"int4":                        KindInteger,
+	"tinyint":                     KindInteger,
+	"smallint":                    KindInteger,
+	"mediumint":                   KindInteger,
+	"serial":                      KindInteger,
+	"serial2":                     KindInteger,
+	"serial4":                     KindInteger,
+	"smallserial":                 KindInteger,
+	"decimal":                     KindDecimal,
+	"numeric":                     KindDecimal,
+	"real":                        KindFloat,
+	"float":                       KindFloat,
+	"float4":                      KindFloat,
+	"float8":                      KindFloat,
+	"double":                      KindFloat,
+	"double precision":            KindFloat,
+	"timestamp":                   KindTimestamp,
+	"timestamptz":                 KindTimestamp,
+	"timestamp with time zone":    KindTimestamp,
+	"timestamp without time zone": KindTimestamp,
+	"datetime":                    KindTimestamp,
+	"date":                        KindDate,
+	"blob":                        KindBlob,
+	"tinyblob":                    KindBlob,
+	"mediumblob":                  KindBlob,
+	"longblob":                    KindBlob,
+	"binary":                      KindBlob,
+	"varbinary":                   KindBlob,
+	"bytea":                       KindBlob,
+	"json":                        KindJSON,
+	"jsonb":                       KindJSON,
+	"varchar":                     KindVarchar,
+	"nvarchar":                    KindVarchar,
+	"character varying":           KindVarchar,
+	"char":                        KindVarchar,
+	"nchar":                       KindVarchar,
+	"character":                   KindVarchar,
+	"bpchar":                      KindVarchar,
+}
+
+// Kind normalizes a database column type into a column kind and
+// the optional size arguments, e.g. `varchar(64)` or `numeric(10,2)`.
+// Arrays and unknown types are text.
+func Kind(columnType string) (string, []int) {
+	t := strings.ToLower(strings.TrimSpace(columnType))
+	if t == "tinyint(1)" {
+		return KindBoolean, nil
+	}
+	if strings.HasSuffix(t, "]") {
+		return KindText, nil
+	}
+
+	var size []int
+	if m := typeSizeRe.FindStringSubmatch(t); m != nil {
+		for _, v := range m[1:] {
+			if n, err := strconv.Atoi(v); err == nil {
+				size = append(size, n)
+			}
+		}
+	}
+
+	name := strings.Join(strings.Fields(typeModifierRe.ReplaceAllString(t, " ")), " ")
+	switch kind := kinds[name]; kind {
+	case "":
+		return KindText, nil
+	case KindDecimal:
+		return kind, size
+	case KindVarchar:
+		if len(size) > 0 {
+			return kind, size[:1]
+		}
+		return KindText, nil
+	default:
+		return kind, nil
+	}
+}
+
+// Quote quotes an identifier.
+func (d Dialect) Quote(name string) string {
+	if d == DialectMySQL {
+		return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+	}
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// ColumnType returns the dialect column type for a column. Keys
+// are mysql text columns used in keys, which need a length.
+func (d Dialect) ColumnType(column model.Column, key bool) string {
+	kind, size := Kind(column.Type)
+
+	if column.AutoIncrement {
+		switch d {
+		case DialectSQLite:
+			return "INTEGER"
+		case DialectPostgres:
+			if kind == KindBigint {
+				return "BIGINT GENERATED BY DEFAULT AS IDENTITY"
+			}
+			return "INTEGER GENERATED BY DEFAULT AS IDENTITY"
+		case DialectMySQL:
+			if kind == KindBigint {
+				return "BIGINT AUTO_INCREMENT"
+			}
+			return "INT AUTO_INCREMENT"
+		}
+	}
+
+	switch kind {
+	case KindInteger:
+		return map[Dialect]string{DialectSQLite: "INTEGER", DialectPostgres: "INTEGER", DialectMySQL: "INT"}[d]
+	case KindBigint:
+		return map[Dialect]string{DialectSQLite: "INTEGER", DialectPostgres: "BIGINT", DialectMySQL: "BIGINT"}[d]
+	case KindBoolean:
+		return map[Dialect]string{DialectSQLite: "BOOLEAN", DialectPostgres: "BOOLEAN", DialectMySQL: "TINYINT(1)"}[d]
+	case KindDecimal:
+		if len(size) == 2 {
+			return fmt.Sprintf("DECIMAL(%d,%d)", size[0], size[1])
+		}
+		if len(size) == 1 {
+			return fmt.Sprintf("DECIMAL(%d)", size[0])
+		}
+		return "DECIMAL"
+	case KindFloat:
+		return map[Dialect]string{DialectSQLite: "REAL", DialectPostgres: "DOUBLE PRECISION", DialectMySQL: "DOUBLE"}[d]
+	case KindVarchar:
+		return fmt.Sprintf("VARCHAR(%d)", size[0])
+	case KindTimestamp:
+		return map[Dialect]string{DialectSQLite: "TIMESTAMP", DialectPostgres: "TIMESTAMP", DialectMySQL: "DATETIME"}[d]
+	case KindDate:
+		return "DATE"
+	case KindBlob:
+		return map[Dialect]string{DialectSQLite: "BLOB", DialectPostgres: "BYTEA", DialectMySQL: "LONGBLOB"}[d]
+	case KindJSON:
+		return map[Dialect]string{DialectSQLite: "TEXT", DialectPostgres: "JSONB", DialectMySQL: "JSON"}[d]
+	}
+
+	if d == DialectMySQL && key {
+		return "VARCHAR(255)"
+	}
+	return "TEXT"
+}
+
+// ColumnDefault returns a portable column default expression, or
+// an empty string if the default can't be carried over.
+func (d Dialect) ColumnDefault(column model.Column) string {
+	if column.Default == nil {
+		return ""
+	}
+
+	value := strings.TrimSpace(*column.Default)
+	lower := strings.ToLower(value)
+
+	switch {
+	case lower == "current_timestamp", lower == "now()", strings.HasPrefix(lower, "current_timestamp("),
+		lower == "datetime('now')", strings.HasPrefix(lower, "current_timestamp::"):
+		return "CURRENT_TIMESTAMP"
+	case lower == "true", lower == "false":
+		if d == DialectMySQL {
+			return map[string]string{"true": "1", "false": "0"}[lower]
+		}
+		return strings.ToUpper(lower)
+	case isNumeric(value):
+		return value
+	case strings.HasPrefix(value, "'"):
+		// Strip postgres type casts, e.g. 'pending'::text.
+		if end := strings.LastIndex(value, "'"); end > 0 {
+			return value[:end+1]
+		}
+	}
+	return ""
+}
+
+// CreateTable returns a CREATE TABLE statement for the table.
+func (d Dialect) CreateTable(table *model.Table) string {
+	keys := map[string]bool{}
+	for _, name := range table.PrimaryKey {
+		keys[name] = true
+	}
+	for _, unique := range table.Unique {
+		for _, name := range unique {
+			keys[name] = true
+		}
+	}
+	for _, fk := range table.ForeignKeys {
+		for _, name := range fk.Columns {
+			keys[name] = true
+		}
+	}
+
+	var lines []string
+	for _, column := range table.Columns {
+		columnType := d.ColumnType(column, keys[column.Name])
+		line := d.Quote(column.Name) + " " + columnType
+		if !column.Nullable {
+			line += " NOT NULL"
+		}
+		if value := d.ColumnDefault(column); value != "" {
+			// MySQL only allows expression defaults for TEXT, BLOB and JSON.
+			if d == DialectMySQL && value != "CURRENT_TIMESTAMP" && slices.Contains([]string{"TEXT", "LONGBLOB", "JSON"}, columnType) {
+				value = "(" + value + ")"
+			}
+			line += " DEFAULT " + value
+		}
+		lines = append(lines, line)
+	}
+
+	if len(table.PrimaryKey) > 0 {
+		lines = append(lines, "PRIMARY KEY ("+d.quoteList(table.PrimaryKey)+")")
+	}
+	for _, unique := range table.Unique {
+		lines = append(lines, "UNIQUE ("+d.quoteList(unique)+")")
+	}
+	for _, fk := range table.ForeignKeys {
+		lines = append(lines, fmt.Sprintf("FOREIGN KEY (%s) REFERENCES %s (%s)", d.quoteList(fk.Columns), d.Quote(fk.Table), d.quoteList(fk.References)))
+	}
+
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n  %s\n)", d.Quote(table.Name), strings.Join(lines, ",\n  "))
+}
+
+// ResetSequences returns statements which move identity sequences
+// past the restored rows. Only postgres needs this.
+func (d Dialect) ResetSequences(table *model.Table) []string {
+	if d != DialectPostgres {
+		return nil
+	}
+
+	var result []string
+	for _, column := range table.Columns {
+		if !column.AutoIncrement {
+			continue
+		}
+		result = append(result, fmt.Sprintf("SELECT setval(pg_get_serial_sequence('%s', '%s'), coalesce(max(%s), 0) + 1, false) FROM %s",
+			strings.ReplaceAll(d.Quote(table.Name), "'", "''"), strings.ReplaceAll(column.Name, "'", "''"), d.Quote(column.Name), d.Quote(table.Name)))
+	}
+	return result
+}
+
+// Literal returns the SQL literal for a value of a column.
+func (d Dialect) Literal(value any, column model.Column) string {
+	kind, _ := Kind(column.Type)
+
+	switch v := value.(type) {
+	case nil:
+		return "NULL"
+	case bool:
+		if d == DialectPostgres {
+			return strings.ToUpper(strconv.FormatBool(v))
+		}
+		if v {
+			return "1"
+		}
+		return "0"
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprint(v)
+	case float32, float64:
+		return fmt.Sprint(v)
+	case time.Time:
+		if kind == KindDate {
+			return "'" + v.Format(time.DateOnly) + "'"
+		}
+		return "'" + v.UTC().Format("2006-01-02 15:04:05.999999") + "'"
+	case []byte:
+		if kind == KindBlob {
+			encoded := hex.EncodeToString(v)
+			if d == DialectPostgres {
+				return `'\x` + encoded + `'::bytea`
+			}
+			return "X'" + encoded + "'"
+		}
+		return d.quoteString(string(v))
+	}
+	return d.quoteString(fmt.Sprint(value))
+}
+
+func (d Dialect) quoteString(value string) string {
+	value = strings.ReplaceAll(value, "'", "''")
+	if d == DialectMySQL {
+		value = strings.ReplaceAll(value, `\`, `\\`)
+	}
+	return "'" + value + "'"
+}
+
+func (d Dialect) quoteList(names []string) string {
+	result := make([]string, 0, len(names))
+	for _, name := range names {
+		result = append(result, d.Quote(name))
+	}
+	return strings.Join(result, ", ")
+}
+
+func isNumeric(value string) bool {
+	_, err := strconv.ParseFloat(value, 64)
+	return err == nil
+}
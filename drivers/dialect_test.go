@@ -0,0 +1,93 @@
+package drivers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/titpetric/etl/model"
+)
+
+// TestKind verifies the column kinds of database types.
+func TestKind(t *testing.T) {
+	tests := []struct {
+		columnType string
+		kind       string
+		size       []int
+	}{
+		{"INTEGER", KindInteger, nil},
+		{"int(11)", KindInteger, nil},
+		{"int unsigned", KindInteger, nil},
+		{"smallint", KindInteger, nil},
+		{"int4", KindInteger, nil},
+		{"serial", KindInteger, nil},
+		{"bigint", KindBigint, nil},
+		{"bigint(20) unsigned", KindBigint, nil},
+		{"int8", KindBigint, nil},
+		{"bigserial", KindBigint, nil},
+		{"UNSIGNED BIG INT", KindBigint, nil},
+		{"tinyint(1)", KindBoolean, nil},
+		{"tinyint(4)", KindInteger, nil},
+		{"boolean", KindBoolean, nil},
+		{"numeric(10,2)", KindDecimal, []int{10, 2}},
+		{"decimal", KindDecimal, nil},
+		{"double precision", KindFloat, nil},
+		{"real", KindFloat, nil},
+		{"float8", KindFloat, nil},
+		{"timestamp with time zone", KindTimestamp, nil},
+		{"timestamp(6) without time zone", KindTimestamp, nil},
+		{"datetime", KindTimestamp, nil},
+		{"date", KindDate, nil},
+		{"bytea", KindBlob, nil},
+		{"longblob", KindBlob, nil},
+		{"varbinary(16)", KindBlob, nil},
+		{"jsonb", KindJSON, nil},
+		{"varchar(64)", KindVarchar, []int{64}},
+		{"character varying(255)", KindVarchar, []int{255}},
+		{"character varying", KindText, nil},
+		{"char(2)", KindVarchar, []int{2}},
+		{"text", KindText, nil},
+		{"interval", KindText, nil},
+		{"interval[]", KindText, nil},
+		{"integer[]", KindText, nil},
+		{"point", KindText, nil},
+		{"time", KindText, nil},
+	}
+
+	for _, tt := range tests {
+		kind, size := Kind(tt.columnType)
+		require.Equal(t, tt.kind, kind, tt.columnType)
+		require.Equal(t, tt.size, size, tt.columnType)
+	}
+}
+
+// TestLiteral verifies the SQL literals of values for each dialect.
+func TestLiteral(t *testing.T) {
+	ts := time.Date(2024, 1, 15, 10, 30, 0, 0, time.FixedZone("CET", 3600))
+
+	tests := []struct {
+		value      any
+		columnType string
+		want       map[Dialect]string
+	}{
+		{nil, "text", map[Dialect]string{DialectSQLite: "NULL", DialectPostgres: "NULL", DialectMySQL: "NULL"}},
+		{true, "boolean", map[Dialect]string{DialectSQLite: "1", DialectPostgres: "TRUE", DialectMySQL: "1"}},
+		{false, "boolean", map[Dialect]string{DialectSQLite: "0", DialectPostgres: "FALSE", DialectMySQL: "0"}},
+		{int64(42), "bigint", map[Dialect]string{DialectSQLite: "42", DialectPostgres: "42", DialectMySQL: "42"}},
+		{9.5, "real", map[Dialect]string{DialectSQLite: "9.5", DialectPostgres: "9.5", DialectMySQL: "9.5"}},
+		{`it's a \ path`, "text", map[Dialect]string{DialectSQLite: `'it''s a \ path'`, DialectPostgres: `'it''s a \ path'`, DialectMySQL: `'it''s a \\ path'`}},
+		{ts, "timestamp", map[Dialect]string{DialectSQLite: "'2024-01-15 09:30:00'", DialectPostgres: "'2024-01-15 09:30:00'", DialectMySQL: "'2024-01-15 09:30:00'"}},
+		{ts, "date", map[Dialect]string{DialectSQLite: "'2024-01-15'", DialectPostgres: "'2024-01-15'", DialectMySQL: "'2024-01-15'"}},
+		{[]byte{0xca, 0xfe}, "blob", map[Dialect]string{DialectSQLite: "X'cafe'", DialectPostgres: `'\xcafe'::bytea`, DialectMySQL: "X'cafe'"}},
+		{[]byte("text"), "varchar(10)", map[Dialect]string{DialectSQLite: "'text'", DialectPostgres: "'text'", DialectMySQL: "'text'"}},
+		{"1 day", "interval", map[Dialect]string{DialectSQLite: "'1 day'", DialectPostgres: "'1 day'", DialectMySQL: "'1 day'"}},
+	}
+
+	for _, tt := range tests {
+		column := model.Column{Name: "c", Type: tt.columnType}
+		for dialect, want := range tt.want {
+			require.Equal(t, want, dialect.Literal(tt.value, column), "%s %s %#v", dialect, tt.columnType, tt.value)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package drivers
+
+import (
+	"strings"
+)
+
+// RedactDSN hides a password if one is set in the DSN.
+func RedactDSN(dsn string) string {
+	scheme, rest, ok := strings.Cut(dsn, "://")
+	if !ok {
+		return dsn
+	}
+	userinfo, address, ok := strings.Cut(rest, "@")
+	if !ok {
+		return dsn
+	}
+	user, _, hasPassword := strings.Cut(userinfo, ":")
+	if !hasPassword {
+		return dsn
+	}
+	return scheme + "://" + user + ":***@" + address
+}
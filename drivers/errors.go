@@ -0,0 +1,209 @@
+package drivers
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+
+	"github.com/titpetric/etl/model"
+)
+
+// NewError classifies a database error and attaches the statement
+// which produced it. It returns nil for a nil error, and leaves
+// already classified errors unchanged.
+func NewError(err error, statement string) error {
+	if err == nil {
+		return nil
+	}
+
+	var result *model.Error
+	if errors.As(err, &result) {
+		return err
+	}
+
+	result = model.NewError(Classify(err), err)
+	result.Code = ErrorCode(err)
+	result.Statement = statement
+	return result
+}
+
+// Classify returns the error class for a database error.
+func Classify(err error) model.ErrorClass {
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.ClassNoRows
+	}
+
+	var (
+		pgErr      *pgconn.PgError
+		pgConnErr  *pgconn.ConnectError
+		mysqlErr   *mysql.MySQLError
+		sqliteErr  *sqlite.Error
+		networkErr net.Error
+	)
+
+	switch {
+	case errors.As(err, &pgErr):
+		switch {
+		case strings.HasPrefix(pgErr.Code, "23"):
+			return model.ClassConstraint
+		case strings.HasPrefix(pgErr.Code, "08"), strings.HasPrefix(pgErr.Code, "57P"):
+			return model.ClassConnection
+		}
+		return model.ClassSQL
+	case errors.As(err, &mysqlErr):
+		switch mysqlErr.Number {
+		case 1048, 1062, 1216, 1217, 1451, 1452, 1557, 1586, 3819:
+			return model.ClassConstraint
+		case 1040, 1044, 1045, 1049, 1129, 1130:
+			return model.ClassConnection
+		}
+		return model.ClassSQL
+	case errors.As(err, &sqliteErr):
+		switch sqliteErr.Code() & 0xff {
+		case sqlite3.SQLITE_CONSTRAINT:
+			return model.ClassConstraint
+		case sqlite3.SQLITE_CANTOPEN, sqlite3.SQLITE_NOTADB:
+			return model.ClassConnection
+		}
+		return model.ClassSQL
+	case errors.As(err, &pgConnErr),
+		errors.As(err, &networkErr),
+		errors.Is(err, mysql.ErrInvalidConn),
+		errors.Is(err, driver.ErrBadConn):
+		return model.ClassConnection
+	}
+
+	return model.ClassError
+}
+
+// ErrorCode returns the driver specific error code. For postgres
+// this is the SQLSTATE, for mysql the error number and for sqlite
+// the extended result code.
+func ErrorCode(err error) string {
+	var (
+		pgErr     *pgconn.PgError
+		mysqlErr  *mysql.MySQLError
+		sqliteErr *sqlite.Error
+	)
+
+	switch {
+	case errors.As(err, &pgErr):
+		return pgErr.Code
+	case errors.As(err, &mysqlErr):
+		return strconv.Itoa(int(mysqlErr.Number))
+	case errors.As(err, &sqliteErr):
+		return strconv.Itoa(sqliteErr.Code())
+	}
+	return ""
+}
+
+// Constraint kinds returned by ConstraintKind.
+const (
+	ConstraintUnique     = "unique"
+	ConstraintForeignKey = "foreign_key"
+	ConstraintNotNull    = "not_null"
+	ConstraintCheck      = "check"
+)
+
+// ConstraintKind returns the kind of constraint a database error
+// violates, or an empty string if it isn't a constraint violation.
+func ConstraintKind(err error) string {
+	var (
+		pgErr     *pgconn.PgError
+		mysqlErr  *mysql.MySQLError
+		sqliteErr *sqlite.Error
+	)
+
+	switch {
+	case errors.As(err, &pgErr):
+		switch pgErr.Code {
+		case "23505":
+			return ConstraintUnique
+		case "23503":
+			return ConstraintForeignKey
+		case "23502":
+			return ConstraintNotNull
+		case "23514":
+			return ConstraintCheck
+		}
+	case errors.As(err, &mysqlErr):
+		switch mysqlErr.Number {
+		case 1062, 1557, 1586:
+			return ConstraintUnique
+		case 1216, 1217, 1451, 1452:
+			return ConstraintForeignKey
+		case 1048:
+			return ConstraintNotNull
+		case 3819:
+			return ConstraintCheck
+		}
+	case errors.As(err, &sqliteErr):
+		switch sqliteErr.Code() {
+		case sqlite3.SQLITE_CONSTRAINT_UNIQUE, sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY:
+			return ConstraintUnique
+		case sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY:
+			return ConstraintForeignKey
+		case sqlite3.SQLITE_CONSTRAINT_NOTNULL:
+			return ConstraintNotNull
+		case sqlite3.SQLITE_CONSTRAINT_CHECK:
+			return ConstraintCheck
+		}
+	}
+	return ""
+}
+
+// Timeout reports if a database error is caused by a deadline or a
+// cancelled statement.
+func Timeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var (
+		pgErr     *pgconn.PgError
+		mysqlErr  *mysql.MySQLError
+		sqliteErr *sqlite.Error
+	)
+
+	switch {
+	case errors.As(err, &pgErr):
+		return pgErr.Code == "57014"
+	case errors.As(err, &mysqlErr):
+		return mysqlErr.Number == 1317 || mysqlErr.Number == 3024
+	case errors.As(err, &sqliteErr):
+		return sqliteErr.Code()&0xff == sqlite3.SQLITE_INTERRUPT
+	}
+	return false
+}
+
+// Retryable reports if a database error is transient, so that the
+// transaction can be retried: a serialization failure or a deadlock
+// on postgres, a lock wait timeout or a deadlock on mysql, and a busy
+// or locked database on sqlite.
+func Retryable(err error) bool {
+	var (
+		pgErr     *pgconn.PgError
+		mysqlErr  *mysql.MySQLError
+		sqliteErr *sqlite.Error
+	)
+
+	switch {
+	case errors.As(err, &pgErr):
+		return pgErr.Code == "40001" || pgErr.Code == "40P01"
+	case errors.As(err, &mysqlErr):
+		return mysqlErr.Number == 1205 || mysqlErr.Number == 1213
+	case errors.As(err, &sqliteErr):
+		code := sqliteErr.Code() & 0xff
+		return code == sqlite3.SQLITE_BUSY || code == sqlite3.SQLITE_LOCKED
+	}
+	return false
+}
@@ -0,0 +1,119 @@
+package drivers
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+
+	"github.com/titpetric/etl/model"
+
+	_ "modernc.org/sqlite"
+)
+
+// sqliteErrors returns real sqlite errors by name.
+func sqliteErrors(t *testing.T) map[string]error {
+	t.Helper()
+
+	db, err := sqlx.Open("sqlite", ":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	db.MustExec("create table user (id integer primary key, email text not null unique)")
+	db.MustExec("insert into user (email) values ('alice@example.com')")
+
+	result := map[string]error{}
+	_, result["unique"] = db.Exec("insert into user (email) values ('alice@example.com')")
+	_, result["syntax"] = db.Exec("insert into")
+	result["no_rows"] = db.QueryRow("select id from user where id = 2").Scan(new(int))
+
+	missing, err := sqlx.Open("sqlite", filepath.Join(t.TempDir(), "missing", "app.db"))
+	require.NoError(t, err)
+	defer missing.Close()
+	result["cantopen"] = missing.Ping()
+
+	for name, err := range result {
+		require.Error(t, err, name)
+	}
+	return result
+}
+
+// connectionErrors returns real errors from connecting to a closed port.
+func connectionErrors(t *testing.T) map[string]error {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result := map[string]error{}
+	_, result["pgx"] = pgconn.Connect(ctx, "postgres://app@127.0.0.1:1/app?connect_timeout=5")
+
+	db, err := sql.Open("mysql", "app@tcp(127.0.0.1:1)/app?timeout=5s")
+	require.NoError(t, err)
+	defer db.Close()
+	result["mysql"] = db.PingContext(ctx)
+
+	for name, err := range result {
+		require.Error(t, err, name)
+	}
+	return result
+}
+
+// TestClassify verifies the error class and code of driver errors.
+func TestClassify(t *testing.T) {
+	sqliteErr := sqliteErrors(t)
+	connErr := connectionErrors(t)
+
+	tests := []struct {
+		name  string
+		err   error
+		class model.ErrorClass
+		code  string
+	}{
+		{"sqlite unique", sqliteErr["unique"], model.ClassConstraint, "2067"},
+		{"sqlite syntax", sqliteErr["syntax"], model.ClassSQL, "1"},
+		{"sqlite no rows", sqliteErr["no_rows"], model.ClassNoRows, ""},
+		{"sqlite cantopen", sqliteErr["cantopen"], model.ClassConnection, "14"},
+		{"pgx unique", &pgconn.PgError{Code: "23505", Message: "duplicate key value"}, model.ClassConstraint, "23505"},
+		{"pgx syntax", &pgconn.PgError{Code: "42601", Message: "syntax error"}, model.ClassSQL, "42601"},
+		{"pgx admin shutdown", &pgconn.PgError{Code: "57P01", Message: "terminating connection"}, model.ClassConnection, "57P01"},
+		{"pgx connect", connErr["pgx"], model.ClassConnection, ""},
+		{"mysql duplicate", &mysql.MySQLError{Number: 1062, Message: "Duplicate entry"}, model.ClassConstraint, "1062"},
+		{"mysql syntax", &mysql.MySQLError{Number: 1064, Message: "You have an error in your SQL syntax"}, model.ClassSQL, "1064"},
+		{"mysql access denied", &mysql.MySQLError{Number: 1045, Message: "Access denied"}, model.ClassConnection, "1045"},
+		{"mysql connect", connErr["mysql"], model.ClassConnection, ""},
+		{"mysql invalid conn", mysql.ErrInvalidConn, model.ClassConnection, ""},
+		{"bad conn", fmt.Errorf("query: %w", driver.ErrBadConn), model.ClassConnection, ""},
+		{"other", errors.New("boom"), model.ClassError, ""},
+	}
+
+	for _, tt := range tests {
+		require.Equal(t, tt.class, Classify(tt.err), tt.name)
+		require.Equal(t, tt.code, ErrorCode(tt.err), tt.name)
+	}
+}
+
+// TestNewError verifies that errors are classified once, with their statement.
+func TestNewError(t *testing.T) {
+	require.NoError(t, NewError(nil, "select 1"))
+
+	err := NewError(sqliteErrors(t)["unique"], "insert into user")
+	result := model.AsError(err)
+	require.Equal(t, model.ClassConstraint, result.Class)
+	require.Equal(t, "2067", result.Code)
+	require.Equal(t, "insert into user", result.Statement)
+	require.Contains(t, result.Message, "UNIQUE constraint failed")
+
+	// Classified errors are kept
+	input := model.InputError("bad flag")
+	require.Same(t, input, NewError(input, "select 1"))
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
-	"log"
 	"maps"
 	"slices"
 	"strings"
@@ -52,7 +51,7 @@ func (m *MySQL) Insert(table string, records []model.RecordInput, params ...stri
 
 	args, err := internal.DecodeQuery(params)
 	if err != nil {
-		return count, err
+		return count, model.NewError(model.ClassInput, err)
 	}
 
 	// append with commandline args
@@ -70,8 +69,6 @@ func (m *MySQL) Insert(table string, records []model.RecordInput, params ...stri
 		count += rowsAffected
 	}
 
-	log.Printf("Done processing %d rows, %d affected", len(records), count)
-
 	return count, nil
 }
 
@@ -84,20 +81,95 @@ func (m *MySQL) insertQueryNamed(table string, data model.RecordInput) (sql.Resu
 
 	query := fmt.Sprintf(template, table, names, values)
 
-	return m.db.NamedExec(query, data)
+	result, err := m.db.NamedExec(query, data)
+	return result, NewError(err, query)
 }
 
 func (m *MySQL) Query(sql string, params ...string) ([]model.Record, error) {
 	args, err := internal.DecodeQuery(params)
 	if err != nil {
-		return nil, err
+		return nil, model.NewError(model.ClassInput, err)
 	}
 
 	rows, err := m.db.NamedQuery(sql, args)
+	if err != nil {
+		return nil, NewError(err, sql)
+	}
+	defer rows.Close()
+
+	result, err := internal.ScanAll(rows)
+	return result, NewError(err, sql)
+}
+
+// Schema returns the table schemas in the current mysql database.
+func (m *MySQL) Schema() ([]*model.Table, error) {
+	names, err := queryStrings(m.db, "SELECT table_name FROM information_schema.tables WHERE table_schema = database() AND table_type = 'BASE TABLE' ORDER BY table_name")
 	if err != nil {
 		return nil, err
 	}
+
+	result := make([]*model.Table, 0, len(names))
+	for _, name := range names {
+		table, err := m.describe(name)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, table)
+	}
+	return result, nil
+}
+
+func (m *MySQL) describe(name string) (*model.Table, error) {
+	table := &model.Table{Name: name}
+
+	query := `SELECT column_name, column_type, is_nullable = 'YES', column_default, extra
+		FROM information_schema.columns
+		WHERE table_schema = database() AND table_name = ?
+		ORDER BY ordinal_position`
+
+	rows, err := m.db.Queryx(query, name)
+	if err != nil {
+		return nil, NewError(err, query)
+	}
 	defer rows.Close()
 
-	return internal.ScanAll(rows)
+	for rows.Next() {
+		var (
+			column model.Column
+			value  sql.NullString
+			extra  string
+		)
+		if err := rows.Scan(&column.Name, &column.Type, &column.Nullable, &value, &extra); err != nil {
+			return nil, err
+		}
+
+		extra = strings.ToLower(extra)
+		column.AutoIncrement = strings.Contains(extra, "auto_increment")
+
+		// Literal defaults are reported unquoted, expressions are marked as generated.
+		if value.Valid {
+			if !strings.Contains(extra, "default_generated") && !isNumeric(value.String) && !strings.EqualFold(value.String, "NULL") {
+				value.String = "'" + strings.ReplaceAll(value.String, "'", "''") + "'"
+			}
+			column.Default = &value.String
+		}
+		table.Columns = append(table.Columns, column)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	query = `SELECT tc.constraint_type, k.constraint_name, k.column_name, coalesce(k.referenced_table_name, ''), coalesce(k.referenced_column_name, '')
+		FROM information_schema.table_constraints tc
+		JOIN information_schema.key_column_usage k ON k.constraint_schema = tc.constraint_schema AND k.constraint_name = tc.constraint_name AND k.table_name = tc.table_name
+		WHERE tc.table_schema = database() AND tc.table_name = ? AND tc.constraint_type IN ('PRIMARY KEY', 'UNIQUE', 'FOREIGN KEY')
+		ORDER BY k.constraint_name, k.ordinal_position`
+
+	constraints, err := queryConstraints(m.db, query, name)
+	if err != nil {
+		return nil, err
+	}
+	applyConstraints(table, constraints)
+
+	return table, nil
 }
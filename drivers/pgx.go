@@ -3,7 +3,6 @@ package drivers
 import (
 	"database/sql"
 	"fmt"
-	"log"
 	"maps"
 	"slices"
 	"strings"
@@ -35,7 +34,7 @@ func (d *Pgx) Insert(table string, records []model.RecordInput, params ...string
 
 	args, err := internal.DecodeQuery(params)
 	if err != nil {
-		return count, err
+		return count, model.NewError(model.ClassInput, err)
 	}
 
 	// append with commandline args
@@ -53,8 +52,6 @@ func (d *Pgx) Insert(table string, records []model.RecordInput, params ...string
 		count += rowsAffected
 	}
 
-	log.Printf("Done processing %d rows, %d affected", len(records), count)
-
 	return count, nil
 }
 
@@ -67,20 +64,94 @@ func (d *Pgx) insertQueryNamed(table string, data model.RecordInput) (sql.Result
 
 	query := fmt.Sprintf(template, table, names, values)
 
-	return d.db.NamedExec(query, data)
+	result, err := d.db.NamedExec(query, data)
+	return result, NewError(err, query)
 }
 
 func (d *Pgx) Query(sql string, params ...string) ([]model.Record, error) {
 	args, err := internal.DecodeQuery(params)
 	if err != nil {
-		return nil, err
+		return nil, model.NewError(model.ClassInput, err)
 	}
 
 	rows, err := d.db.NamedQuery(sql, args)
+	if err != nil {
+		return nil, NewError(err, sql)
+	}
+	defer rows.Close()
+
+	result, err := internal.ScanAll(rows)
+	return result, NewError(err, sql)
+}
+
+// Schema returns the table schemas in the current postgres schema.
+func (d *Pgx) Schema() ([]*model.Table, error) {
+	names, err := queryStrings(d.db, "SELECT table_name FROM information_schema.tables WHERE table_schema = current_schema() AND table_type = 'BASE TABLE' ORDER BY table_name")
 	if err != nil {
 		return nil, err
 	}
+
+	result := make([]*model.Table, 0, len(names))
+	for _, name := range names {
+		table, err := d.describe(name)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, table)
+	}
+	return result, nil
+}
+
+func (d *Pgx) describe(name string) (*model.Table, error) {
+	table := &model.Table{Name: name}
+
+	query := `SELECT a.attname, format_type(a.atttypid, a.atttypmod), NOT a.attnotnull, pg_get_expr(def.adbin, def.adrelid), a.attidentity <> ''
+		FROM pg_attribute a
+		LEFT JOIN pg_attrdef def ON def.adrelid = a.attrelid AND def.adnum = a.attnum
+		WHERE a.attrelid = quote_ident($1)::regclass AND a.attnum > 0 AND NOT a.attisdropped
+		ORDER BY a.attnum`
+
+	rows, err := d.db.Queryx(query, name)
+	if err != nil {
+		return nil, NewError(err, query)
+	}
 	defer rows.Close()
 
-	return internal.ScanAll(rows)
+	for rows.Next() {
+		var (
+			column   model.Column
+			value    sql.NullString
+			identity bool
+		)
+		if err := rows.Scan(&column.Name, &column.Type, &column.Nullable, &value, &identity); err != nil {
+			return nil, err
+		}
+		column.Default = nullString(value)
+		column.AutoIncrement = identity || strings.HasPrefix(value.String, "nextval(")
+		if column.AutoIncrement {
+			column.Default = nil
+		}
+		table.Columns = append(table.Columns, column)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	query = `SELECT CASE c.contype WHEN 'p' THEN 'PRIMARY KEY' WHEN 'u' THEN 'UNIQUE' ELSE 'FOREIGN KEY' END,
+			c.conname, a.attname, coalesce(rt.relname, ''), coalesce(ra.attname, '')
+		FROM pg_constraint c
+		CROSS JOIN LATERAL unnest(c.conkey) WITH ORDINALITY AS k(attnum, ord)
+		JOIN pg_attribute a ON a.attrelid = c.conrelid AND a.attnum = k.attnum
+		LEFT JOIN pg_class rt ON rt.oid = c.confrelid
+		LEFT JOIN pg_attribute ra ON ra.attrelid = c.confrelid AND ra.attnum = c.confkey[k.ord]
+		WHERE c.conrelid = quote_ident($1)::regclass AND c.contype IN ('p', 'u', 'f')
+		ORDER BY c.conname, k.ord`
+
+	constraints, err := queryConstraints(d.db, query, name)
+	if err != nil {
+		return nil, err
+	}
+	applyConstraints(table, constraints)
+
+	return table, nil
 }
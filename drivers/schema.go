@@ -0,0 +1,119 @@
+package drivers
+
+import (
+	"database/sql"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/titpetric/etl/model"
+)
+
+// constraint is a single column of a primary, unique or foreign key.
+type constraint struct {
+	Type      string
+	Name      string
+	Column    string
+	RefTable  string
+	RefColumn string
+}
+
+// Constraint types as used by introspection queries.
+const (
+	constraintPrimary = "PRIMARY KEY"
+	constraintUnique  = "UNIQUE"
+	constraintForeign = "FOREIGN KEY"
+)
+
+// applyConstraints groups constraint columns by name and fills
+// the table primary key, unique keys and foreign keys.
+func applyConstraints(table *model.Table, constraints []constraint) {
+	var (
+		order   []string
+		grouped = map[string][]constraint{}
+	)
+	for _, c := range constraints {
+		if _, ok := grouped[c.Name]; !ok {
+			order = append(order, c.Name)
+		}
+		grouped[c.Name] = append(grouped[c.Name], c)
+	}
+
+	for _, name := range order {
+		columns := grouped[name]
+		names := make([]string, 0, len(columns))
+		for _, c := range columns {
+			names = append(names, c.Column)
+		}
+
+		switch columns[0].Type {
+		case constraintPrimary:
+			table.PrimaryKey = names
+		case constraintUnique:
+			table.Unique = append(table.Unique, names)
+		case constraintForeign:
+			fk := model.ForeignKey{
+				Columns: names,
+				Table:   columns[0].RefTable,
+			}
+			for _, c := range columns {
+				fk.References = append(fk.References, c.RefColumn)
+			}
+			table.ForeignKeys = append(table.ForeignKeys, fk)
+		}
+	}
+}
+
+// queryStrings returns the first column of each result row.
+func queryStrings(db *sqlx.DB, query string, args ...any) ([]string, error) {
+	rows, err := db.Queryx(query, args...)
+	if err != nil {
+		return nil, NewError(err, query)
+	}
+	defer rows.Close()
+
+	var result []string
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		result = append(result, value)
+	}
+	return result, rows.Err()
+}
+
+// queryConstraints scans constraint rows with the columns
+// type, name, column, referenced table and referenced column.
+func queryConstraints(db *sqlx.DB, query string, args ...any) ([]constraint, error) {
+	rows, err := db.Queryx(query, args...)
+	if err != nil {
+		return nil, NewError(err, query)
+	}
+	defer rows.Close()
+
+	var result []constraint
+	for rows.Next() {
+		var c constraint
+		if err := rows.Scan(&c.Type, &c.Name, &c.Column, &c.RefTable, &c.RefColumn); err != nil {
+			return nil, err
+		}
+		result = append(result, c)
+	}
+	return result, rows.Err()
+}
+
+func findTable(tables []*model.Table, name string) *model.Table {
+	for _, table := range tables {
+		if table.Name == name {
+			return table
+		}
+	}
+	return nil
+}
+
+func nullString(in sql.NullString) *string {
+	if !in.Valid {
+		return nil
+	}
+	return &in.String
+}
@@ -3,7 +3,7 @@ package drivers
 import (
 	"database/sql"
 	"fmt"
-	"log"
+	"slices"
 	"sort"
 	"strings"
 
@@ -36,7 +36,7 @@ func (s *Sqlite) Insert(table string, records []model.RecordInput, params ...str
 	// Decode any additional parameters into a map.
 	args, err := internal.DecodeQuery(params)
 	if err != nil {
-		return count, err
+		return count, model.NewError(model.ClassInput, err)
 	}
 
 	// Merge the additional parameters into each record and insert.
@@ -59,7 +59,6 @@ func (s *Sqlite) Insert(table string, records []model.RecordInput, params ...str
 		count += rowsAffected
 	}
 
-	log.Printf("Done processing %d rows, %d affected", len(records), count)
 	return count, nil
 }
 
@@ -84,7 +83,8 @@ func (s *Sqlite) insertQueryNamed(table string, record model.RecordInput) (sql.R
 		strings.Join(columns, ", "),
 		strings.Join(placeholders, ", "),
 	)
-	return s.db.NamedExec(query, record)
+	result, err := s.db.NamedExec(query, record)
+	return result, NewError(err, query)
 }
 
 // Query executes the provided SQL query using named parameters (decoded via internal.DecodeQuery)
@@ -92,26 +92,28 @@ func (s *Sqlite) insertQueryNamed(table string, record model.RecordInput) (sql.R
 func (s *Sqlite) Query(sqlQuery string, params ...string) ([]model.Record, error) {
 	args, err := internal.DecodeQuery(params)
 	if err != nil {
-		return nil, err
+		return nil, model.NewError(model.ClassInput, err)
 	}
 
 	if len(args) > 0 {
 		rows, err := s.db.NamedQuery(sqlQuery, args)
 		if err != nil {
-			return nil, err
+			return nil, NewError(err, sqlQuery)
 		}
 		defer rows.Close()
 
-		return internal.ScanAll(rows)
+		result, err := internal.ScanAll(rows)
+		return result, NewError(err, sqlQuery)
 	}
 
 	rows, err := s.db.Queryx(sqlQuery)
 	if err != nil {
-		return nil, err
+		return nil, NewError(err, sqlQuery)
 	}
 	defer rows.Close()
 
-	return internal.ScanAll(rows)
+	result, err := internal.ScanAll(rows)
+	return result, NewError(err, sqlQuery)
 }
 
 // Tables returns the list of user-defined tables in the SQLite database.
@@ -120,3 +122,105 @@ func (s *Sqlite) Tables() ([]model.Record, error) {
 	// The returned column is aliased as "table_name" for consistency.
 	return s.Query("SELECT name as table_name FROM sqlite_master WHERE type='table' AND name NOT LIKE 'sqlite_%'")
 }
+
+// Schema returns the table schemas in the SQLite database.
+// It reads columns, keys and foreign keys from the pragma table functions.
+func (s *Sqlite) Schema() ([]*model.Table, error) {
+	names, err := queryStrings(s.db, "SELECT name FROM sqlite_master WHERE type='table' AND name NOT LIKE 'sqlite_%' ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*model.Table, 0, len(names))
+	for _, name := range names {
+		table, err := s.describe(name)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, table)
+	}
+
+	// Foreign keys may omit the referenced columns, referencing the primary key.
+	for _, table := range result {
+		for i, fk := range table.ForeignKeys {
+			if slices.Contains(fk.References, "") {
+				if parent := findTable(result, fk.Table); parent != nil {
+					table.ForeignKeys[i].References = parent.PrimaryKey
+				}
+			}
+		}
+	}
+	return result, nil
+}
+
+func (s *Sqlite) describe(name string) (*model.Table, error) {
+	table := &model.Table{Name: name}
+
+	query := `SELECT name, type, "notnull", dflt_value, pk FROM pragma_table_info(?) ORDER BY cid`
+	rows, err := s.db.Queryx(query, name)
+	if err != nil {
+		return nil, NewError(err, query)
+	}
+	defer rows.Close()
+
+	primary := map[int]string{}
+	for rows.Next() {
+		var (
+			column  model.Column
+			notNull bool
+			value   sql.NullString
+			pk      int
+		)
+		if err := rows.Scan(&column.Name, &column.Type, &notNull, &value, &pk); err != nil {
+			return nil, err
+		}
+		column.Nullable = !notNull && pk == 0
+		column.Default = nullString(value)
+		if pk > 0 {
+			primary[pk] = column.Name
+		}
+		table.Columns = append(table.Columns, column)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := 1; i <= len(primary); i++ {
+		table.PrimaryKey = append(table.PrimaryKey, primary[i])
+	}
+
+	// A single INTEGER PRIMARY KEY column is an alias for the rowid.
+	if len(table.PrimaryKey) == 1 {
+		for i, column := range table.Columns {
+			if column.Name == table.PrimaryKey[0] && strings.EqualFold(column.Type, "INTEGER") {
+				table.Columns[i].AutoIncrement = true
+			}
+		}
+	}
+
+	var constraints []constraint
+
+	query = `SELECT 'FOREIGN KEY', 'fk_' || id, "from", "table", coalesce("to", '') FROM pragma_foreign_key_list(?) ORDER BY id, seq`
+	fks, err := queryConstraints(s.db, query, name)
+	if err != nil {
+		return nil, err
+	}
+	constraints = append(constraints, fks...)
+
+	indexes, err := queryStrings(s.db, `SELECT name FROM pragma_index_list(?) WHERE "unique" = 1 AND origin != 'pk' ORDER BY seq`, name)
+	if err != nil {
+		return nil, err
+	}
+	for _, index := range indexes {
+		query := `SELECT 'UNIQUE', ?, name, '', '' FROM pragma_index_info(?) ORDER BY seqno`
+		unique, err := queryConstraints(s.db, query, index, index)
+		if err != nil {
+			return nil, err
+		}
+		constraints = append(constraints, unique...)
+	}
+
+	applyConstraints(table, constraints)
+
+	return table, nil
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/go-bridget/mig/db"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/titpetric/etl/drivers"
+	"github.com/titpetric/etl/model"
+)
+
+// ConnectionStatus is the result of listing or testing a named connection.
+type ConnectionStatus struct {
+	Name    string `json:"name"`
+	DSN     string `json:"dsn"`
+	Default bool   `json:"default,omitempty"`
+
+	Status  string `json:"status,omitempty"`
+	Latency string `json:"latency,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Connections lists the named connections from the config file,
+// or pings them with `etl connections test [name...]`.
+func Connections(ctx context.Context, command *model.Command, _ io.Reader) error {
+	profiles := command.Profiles
+	if profiles == nil {
+		profiles = &model.Profiles{}
+	}
+
+	args := command.Args
+	test := len(args) > 0 && args[0] == "test"
+	if len(args) > 0 && !test {
+		return model.InputError("usage: etl connections [test [name...]]")
+	}
+
+	names := profiles.Names()
+	if test && len(args) > 1 {
+		names = args[1:]
+	}
+
+	var failed int
+	results := make([]ConnectionStatus, 0, len(names))
+	for _, name := range names {
+		conn, err := profiles.Get(name)
+		if err != nil {
+			return model.NewError(model.ClassInput, err)
+		}
+
+		result := ConnectionStatus{
+			Name:    name,
+			DSN:     drivers.RedactDSN(conn.DSN),
+			Default: name == profiles.Default,
+		}
+
+		if test {
+			start := time.Now()
+			if err := pingConnection(ctx, conn); err != nil {
+				result.Status = "error"
+				result.Error = err.Error()
+				failed++
+			} else {
+				result.Status = "ok"
+			}
+			result.Latency = time.Since(start).Round(time.Millisecond).String()
+		}
+
+		results = append(results, result)
+	}
+
+	if err := json.NewEncoder(os.Stdout).Encode(results); err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return model.NewError(model.ClassConnection, fmt.Errorf("%d of %d connections failed", failed, len(results)))
+	}
+	return nil
+}
+
+func pingConnection(ctx context.Context, conn *model.Connection) error {
+	dsn, err := conn.ConnectionString()
+	if err != nil {
+		return err
+	}
+
+	driver, dsn := db.ParseDSN(dsn)
+	handle, err := sqlx.Open(driver, dsn)
+	if err != nil {
+		return err
+	}
+	defer handle.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	return drivers.NewError(handle.PingContext(ctx), "")
+}
@@ -0,0 +1,330 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/titpetric/etl/drivers"
+	"github.com/titpetric/etl/model"
+)
+
+// Dump formats.
+const (
+	DumpFormatSQL    = "sql"
+	DumpFormatNDJSON = "ndjson"
+)
+
+// DumpManifest is the schema.json file of an ndjson dump directory.
+type DumpManifest struct {
+	Version int             `json:"version"`
+	Dialect drivers.Dialect `json:"dialect"`
+	Tables  []*model.Table  `json:"tables"`
+}
+
+// dumpManifestFile is the manifest filename in an ndjson dump.
+const dumpManifestFile = "schema.json"
+
+// dumpBatchSize is the number of rows in a single INSERT statement.
+const dumpBatchSize = 100
+
+// Dump writes the database schema and data as a SQL script for a
+// target dialect, or as a directory with a schema manifest and an
+// ndjson file for each table.
+func Dump(ctx context.Context, command *model.Command, _ io.Reader) error {
+	var (
+		format, dialectName, output string
+		include, exclude            []string
+		noData, noSchema            bool
+	)
+
+	flagSet := model.NewFlagSet("Dump")
+	flagSet.StringVar(&format, "format", DumpFormatSQL, "Dump format (sql, ndjson)")
+	flagSet.StringVar(&dialectName, "dialect", "", "SQL dialect of the script (sqlite, postgres, mysql), defaults to the source")
+	flagSet.StringVarP(&output, "output", "o", "", "Output file (sql) or directory (ndjson)")
+	flagSet.StringSliceVar(&include, "tables", nil, "Tables to dump (default all)")
+	flagSet.StringSliceVar(&exclude, "exclude", nil, "Tables to skip")
+	flagSet.BoolVar(&noData, "no-data", false, "Dump only the schema")
+	flagSet.BoolVar(&noSchema, "no-schema", false, "Dump only the data")
+	if err := flagSet.Parse(command.Args); err != nil {
+		return model.InputError("error parsing flags: %w", err)
+	}
+
+	driver, err := drivers.New(command.DB)
+	if err != nil {
+		return err
+	}
+
+	source, err := drivers.DialectOf(command.DB)
+	if err != nil {
+		return err
+	}
+
+	dialect := source
+	if dialectName != "" {
+		if format == DumpFormatNDJSON {
+			return model.InputError("--dialect can't be used with --format %s, which keeps the source dialect", DumpFormatNDJSON)
+		}
+		if dialect, err = drivers.ParseDialect(dialectName); err != nil {
+			return model.NewError(model.ClassInput, err)
+		}
+	}
+
+	schema, err := driver.Schema()
+	if err != nil {
+		return err
+	}
+	tables := model.SortTables(model.FilterTables(schema, include, exclude))
+
+	dumper := &dumper{
+		command:  command,
+		source:   source,
+		dialect:  dialect,
+		tables:   tables,
+		noData:   noData,
+		noSchema: noSchema,
+	}
+
+	switch format {
+	case DumpFormatSQL:
+		return dumper.sql(output)
+	case DumpFormatNDJSON:
+		if output == "" {
+			return model.InputError("usage: etl dump --format ndjson --output <dir>")
+		}
+		return dumper.ndjson(output)
+	}
+	return model.InputError("unknown dump format: %s, supported [%s %s]", format, DumpFormatSQL, DumpFormatNDJSON)
+}
+
+type dumper struct {
+	command *model.Command
+	source  drivers.Dialect
+	dialect drivers.Dialect
+	tables  []*model.Table
+
+	noData   bool
+	noSchema bool
+}
+
+func (d *dumper) sql(output string) error {
+	out := os.Stdout
+	if output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			return model.NewError(model.ClassInput, err)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	w := bufio.NewWriter(out)
+	fmt.Fprintf(w, "-- etl dump, dialect %s, %s\n\n", d.dialect, time.Now().UTC().Format(time.RFC3339))
+
+	if !d.noSchema {
+		for _, table := range d.tables {
+			fmt.Fprintf(w, "%s;\n\n", d.dialect.CreateTable(table))
+		}
+	}
+
+	if !d.noData {
+		for _, table := range d.tables {
+			if err := d.sqlRows(w, table); err != nil {
+				return err
+			}
+		}
+	}
+
+	return w.Flush()
+}
+
+func (d *dumper) sqlRows(w io.Writer, table *model.Table) error {
+	var (
+		count  int
+		values []string
+	)
+
+	columns := make([]string, 0, len(table.Columns))
+	for _, column := range table.Columns {
+		columns = append(columns, d.dialect.Quote(column.Name))
+	}
+
+	flush := func() {
+		if len(values) == 0 {
+			return
+		}
+		fmt.Fprintf(w, "INSERT INTO %s (%s) VALUES\n  %s;\n", d.dialect.Quote(table.Name), strings.Join(columns, ", "), strings.Join(values, ",\n  "))
+		values = values[:0]
+	}
+
+	err := d.rows(table, func(row []any) error {
+		literals := make([]string, len(row))
+		for i, value := range row {
+			literals[i] = d.dialect.Literal(value, table.Columns[i])
+		}
+		values = append(values, "("+strings.Join(literals, ", ")+")")
+		count++
+		if len(values) == dumpBatchSize {
+			flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	flush()
+
+	if count > 0 {
+		for _, stmt := range d.dialect.ResetSequences(table) {
+			fmt.Fprintf(w, "%s;\n", stmt)
+		}
+		fmt.Fprintln(w)
+	}
+	return nil
+}
+
+func (d *dumper) ndjson(output string) error {
+	if err := os.MkdirAll(output, 0o755); err != nil {
+		return model.NewError(model.ClassInput, err)
+	}
+
+	manifest := DumpManifest{
+		Version: 1,
+		Dialect: d.dialect,
+		Tables:  d.tables,
+	}
+	contents, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(output, dumpManifestFile), append(contents, '\n'), 0o644); err != nil {
+		return err
+	}
+
+	if d.noData {
+		return nil
+	}
+
+	for _, table := range d.tables {
+		if err := d.ndjsonRows(filepath.Join(output, table.Name+".ndjson"), table); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *dumper) ndjsonRows(filename string, table *model.Table) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	encoder := json.NewEncoder(w)
+
+	err = d.rows(table, func(row []any) error {
+		record := make(map[string]any, len(row))
+		for i, value := range row {
+			// Blobs are []byte, which encoding/json writes as base64.
+			record[table.Columns[i].Name] = value
+		}
+		return encoder.Encode(record)
+	})
+	if err != nil {
+		return err
+	}
+
+	if !d.command.Quiet {
+		log.Printf("Dumped %s", table.Name)
+	}
+	return w.Flush()
+}
+
+// rows calls fn for each table row, ordered by primary key. Values
+// are normalized to the column kind, so that drivers returning
+// text for numeric columns produce the same dump.
+func (d *dumper) rows(table *model.Table, fn func([]any) error) error {
+	source := d.source
+
+	columns := make([]string, 0, len(table.Columns))
+	for _, column := range table.Columns {
+		columns = append(columns, source.Quote(column.Name))
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM %s", strings.Join(columns, ", "), source.Quote(table.Name))
+	if len(table.PrimaryKey) > 0 {
+		order := make([]string, 0, len(table.PrimaryKey))
+		for _, name := range table.PrimaryKey {
+			order = append(order, source.Quote(name))
+		}
+		query += " ORDER BY " + strings.Join(order, ", ")
+	}
+
+	rows, err := d.command.DB.Queryx(query)
+	if err != nil {
+		return drivers.NewError(err, query)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		row, err := rows.SliceScan()
+		if err != nil {
+			return drivers.NewError(err, query)
+		}
+		for i, value := range row {
+			row[i] = dumpValue(value, table.Columns[i])
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	return drivers.NewError(rows.Err(), query)
+}
+
+// dumpValue normalizes a scanned value for a column.
+func dumpValue(value any, column model.Column) any {
+	kind, _ := drivers.Kind(column.Type)
+
+	text, isText := value.(string)
+	if b, ok := value.([]byte); ok {
+		if kind == drivers.KindBlob {
+			return b
+		}
+		text, isText = string(b), true
+	}
+	if !isText {
+		if kind == drivers.KindBoolean {
+			if n, ok := value.(int64); ok {
+				return n != 0
+			}
+		}
+		return value
+	}
+
+	switch kind {
+	case drivers.KindInteger, drivers.KindBigint:
+		if n, err := strconv.ParseInt(text, 10, 64); err == nil {
+			return n
+		}
+	case drivers.KindFloat:
+		if n, err := strconv.ParseFloat(text, 64); err == nil {
+			return n
+		}
+	case drivers.KindBoolean:
+		if b, err := strconv.ParseBool(text); err == nil {
+			return b
+		}
+	case drivers.KindBlob:
+		return []byte(text)
+	}
+	return text
+}
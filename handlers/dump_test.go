@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+
+	"github.com/titpetric/etl/model"
+
+	_ "modernc.org/sqlite"
+)
+
+// TestRestoreValue verifies the conversion of ndjson values to query arguments.
+func TestRestoreValue(t *testing.T) {
+	tests := []struct {
+		value      any
+		columnType string
+		want       any
+	}{
+		{nil, "text", nil},
+		{json.Number("42"), "integer", int64(42)},
+		{json.Number("9.5"), "real", 9.5},
+		{"aGVsbG8=", "blob", []byte("hello")},
+		{"2024-01-15T10:30:00+01:00", "timestamp", "2024-01-15 09:30:00"},
+		{"2024-01-15T00:00:00Z", "date", "2024-01-15"},
+		{"not a time", "datetime", "not a time"},
+		{"2024-01-15T10:30:00Z", "text", "2024-01-15T10:30:00Z"},
+		{map[string]any{"a": []any{json.Number("1")}}, "json", `{"a":[1]}`},
+		{true, "boolean", true},
+	}
+
+	for _, tt := range tests {
+		got, err := restoreValue(tt.value, model.Column{Name: "c", Type: tt.columnType})
+		require.NoError(t, err, "%s %#v", tt.columnType, tt.value)
+		require.Equal(t, tt.want, got, "%s %#v", tt.columnType, tt.value)
+	}
+
+	_, err := restoreValue("not base64!", model.Column{Name: "c", Type: "blob"})
+	require.Error(t, err)
+}
+
+// testDumpSource returns a sqlite database with related tables and rows.
+func testDumpSource(t *testing.T) *sqlx.DB {
+	t.Helper()
+
+	db, err := sqlx.Open("sqlite", filepath.Join(t.TempDir(), "source.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	db.MustExec(`
+		create table users (id integer primary key autoincrement, email varchar(64) not null unique, active boolean default true);
+		create table orders (id integer primary key autoincrement, user_id integer not null references users(id), total decimal(10,2), note text, payload blob, created_at timestamp);
+		insert into users (email, active) values ('alice@example.com', 1), ('bob@example.com', 0);
+		insert into orders (user_id, total, note, payload, created_at) values
+			(1, 10.5, 'it''s; done -- really', x'cafe', '2024-01-15 09:30:00'),
+			(2, null, null, null, null);
+	`)
+	return db
+}
+
+// testDumpRows returns the rows of a table, for comparing databases.
+func testDumpRows(t *testing.T, db *sqlx.DB, table string) []map[string]any {
+	t.Helper()
+
+	rows, err := db.Queryx("select * from " + table + " order by id")
+	require.NoError(t, err)
+	defer rows.Close()
+
+	var result []map[string]any
+	for rows.Next() {
+		row := map[string]any{}
+		require.NoError(t, rows.MapScan(row))
+		result = append(result, row)
+	}
+	require.NoError(t, rows.Err())
+	return result
+}
+
+// TestDumpRestore verifies that a sqlite database is restored from both dump formats.
+func TestDumpRestore(t *testing.T) {
+	ctx := context.Background()
+	source := testDumpSource(t)
+
+	for _, format := range []string{DumpFormatSQL, DumpFormatNDJSON} {
+		t.Run(format, func(t *testing.T) {
+			output := filepath.Join(t.TempDir(), "dump."+format)
+			err := Dump(ctx, &model.Command{DB: source, Args: []string{"--format", format, "--output", output}, Quiet: true}, nil)
+			require.NoError(t, err)
+
+			target, err := sqlx.Open("sqlite", filepath.Join(t.TempDir(), "target.db"))
+			require.NoError(t, err)
+			defer target.Close()
+
+			err = Restore(ctx, &model.Command{DB: target, Args: []string{output}, Quiet: true}, nil)
+			require.NoError(t, err)
+
+			for _, table := range []string{"users", "orders"} {
+				require.Equal(t, testDumpRows(t, source, table), testDumpRows(t, target, table), table)
+			}
+
+			// Restored tables keep generating ids
+			target.MustExec("insert into users (email) values ('carol@example.com')")
+			var id int
+			require.NoError(t, target.Get(&id, "select id from users where email = 'carol@example.com'"))
+			require.Equal(t, 3, id)
+		})
+	}
+}
+
+// TestDumpFlags verifies that invalid flag combinations are rejected.
+func TestDumpFlags(t *testing.T) {
+	source := testDumpSource(t)
+
+	err := Dump(context.Background(), &model.Command{DB: source, Args: []string{"--format", DumpFormatNDJSON, "--dialect", "postgres", "--output", t.TempDir()}}, nil)
+	require.ErrorContains(t, err, "--dialect can't be used with --format ndjson")
+	require.Equal(t, model.ClassInput, model.AsError(err).Class)
+}
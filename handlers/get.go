@@ -2,13 +2,14 @@ package handlers
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
-	"os"
 	"strings"
 
+	"github.com/titpetric/etl/drivers"
 	"github.com/titpetric/etl/model"
 )
 
@@ -23,9 +24,12 @@ func Get(ctx context.Context, command *model.Command, _ io.Reader) error {
 	flagSet.IntVar(&limit, "limit", 1, "Limit the number of results")
 	flagSet.BoolVar(&all, "all", false, "Return all records")
 	if err := flagSet.Parse(command.Args); err != nil {
-		return fmt.Errorf("error parsing flags: %w", err)
+		return model.InputError("error parsing flags: %w", err)
 	}
 	args := flagSet.Args()
+	if len(args) == 0 {
+		return model.InputError("usage: etl get <table> [field=value...]")
+	}
 
 	table := args[0]
 
@@ -65,16 +69,16 @@ func Get(ctx context.Context, command *model.Command, _ io.Reader) error {
 	}
 	rows, err := command.DB.Queryx(query, values...)
 	if err != nil {
-		return err
+		return drivers.NewError(err, query)
 	}
 	defer rows.Close()
 
 	results, err := scanAllRecords(rows)
 	if err != nil {
-		return err
+		return drivers.NewError(err, query)
 	}
 	if len(results) == 0 {
-		os.Exit(1)
+		return drivers.NewError(sql.ErrNoRows, query)
 	}
 
 	var data any = results
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"log"
 	"strings"
 
 	"github.com/titpetric/etl/drivers"
@@ -47,14 +48,24 @@ func Insert(ctx context.Context, command *model.Command, r io.Reader) error {
 		return err
 	}
 
+	args := command.Args
+	if len(args) == 0 {
+		return model.InputError("usage: etl insert <table> [field=value...]")
+	}
+	table := args[0]
+
 	records, err := internal.DecodeRecords(r)
 	if err != nil {
-		return err
+		return model.NewError(model.ClassInput, err)
 	}
 
-	args := command.Args
-	table := args[0]
+	count, err := driver.Insert(table, records, args[1:]...)
+	if err != nil {
+		return err
+	}
 
-	_, err = driver.Insert(table, records, args[1:]...)
-	return err
+	if !command.Quiet {
+		log.Printf("Done processing %d rows, %d affected", len(records), count)
+	}
+	return nil
 }
@@ -8,6 +8,7 @@ import (
 
 	"github.com/jmoiron/sqlx"
 
+	"github.com/titpetric/etl/drivers"
 	"github.com/titpetric/etl/model"
 )
 
@@ -21,33 +22,39 @@ func List(ctx context.Context, command *model.Command, _ io.Reader) error {
 	flagSet.IntVar(&offset, "offset", 0, "Offset for the results")
 	flagSet.IntVar(&limit, "limit", 1000, "Limit the number of results")
 	if err := flagSet.Parse(command.Args); err != nil {
-		return fmt.Errorf("error parsing flags: %w", err)
+		return model.InputError("error parsing flags: %w", err)
 	}
 	args := flagSet.Args()
+	if len(args) == 0 {
+		return model.InputError("usage: etl list <table> [id]")
+	}
 
 	if order != "asc" {
 		order = "desc"
 	}
 
-	var err error
-	var rows *sqlx.Rows
+	var (
+		err   error
+		rows  *sqlx.Rows
+		query string
+	)
 
 	table := args[0]
 	if len(args) > 1 {
-		query := fmt.Sprintf("SELECT * FROM %s WHERE id=?", table)
+		query = fmt.Sprintf("SELECT * FROM %s WHERE id=?", table)
 		rows, err = command.DB.Queryx(query, command.Args[1])
 	} else {
-		query := fmt.Sprintf("SELECT * FROM %s ORDER BY %s %s LIMIT %d OFFSET %d", table, sortBy, order, limit, offset)
+		query = fmt.Sprintf("SELECT * FROM %s ORDER BY %s %s LIMIT %d OFFSET %d", table, sortBy, order, limit, offset)
 		rows, err = command.DB.Queryx(query)
 	}
 	if err != nil {
-		return err
+		return drivers.NewError(err, query)
 	}
 	defer rows.Close()
 
 	results, err := scanAllRecords(rows)
 	if err != nil {
-		return err
+		return drivers.NewError(err, query)
 	}
 
 	var output []byte
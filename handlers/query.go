@@ -23,13 +23,16 @@ func Query(ctx context.Context, command *model.Command, _ io.Reader) error {
 
 	flagSet := model.NewFlagSet("Query")
 	if err := flagSet.Parse(command.Args); err != nil {
-		return fmt.Errorf("error parsing flags: %w", err)
+		return model.InputError("error parsing flags: %w", err)
 	}
 	args := flagSet.Args()
+	if len(args) == 0 {
+		return model.InputError("usage: etl query <file.sql> [field=value...]")
+	}
 
 	query, err := os.ReadFile(args[0])
 	if err != nil {
-		return err
+		return model.NewError(model.ClassInput, err)
 	}
 
 	stmts := internal.Statements(query)
@@ -42,7 +45,9 @@ func Query(ctx context.Context, command *model.Command, _ io.Reader) error {
 		result, err := driver.Query(stmt, args[1:]...)
 		if err != nil {
 			if errors.Is(err, sql.ErrNoRows) {
-				log.Printf("Statement #%d OK", idx)
+				if !command.Quiet {
+					log.Printf("Statement #%d OK", idx)
+				}
 				continue
 			}
 			return err
@@ -51,7 +56,7 @@ func Query(ctx context.Context, command *model.Command, _ io.Reader) error {
 		return json.NewEncoder(os.Stdout).Encode(result)
 	}
 	if len(stmts) == 0 {
-		return sql.ErrNoRows
+		return model.NewError(model.ClassInput, fmt.Errorf("no statements in %s", args[0]))
 	}
 	return nil
 }
@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/titpetric/etl/drivers"
+	"github.com/titpetric/etl/internal"
+	"github.com/titpetric/etl/model"
+)
+
+// Restore loads a dump written by `etl dump`. A SQL script is run
+// in a single transaction. For an ndjson directory, tables are
+// created in foreign key order and each table is loaded in its own
+// transaction.
+func Restore(ctx context.Context, command *model.Command, _ io.Reader) error {
+	var (
+		include, exclude []string
+		noSchema         bool
+	)
+
+	flagSet := model.NewFlagSet("Restore")
+	flagSet.StringSliceVar(&include, "tables", nil, "Tables to restore (ndjson, default all)")
+	flagSet.StringSliceVar(&exclude, "exclude", nil, "Tables to skip (ndjson)")
+	flagSet.BoolVar(&noSchema, "no-schema", false, "Don't create tables (ndjson)")
+	if err := flagSet.Parse(command.Args); err != nil {
+		return model.InputError("error parsing flags: %w", err)
+	}
+	args := flagSet.Args()
+	if len(args) != 1 {
+		return model.InputError("usage: etl restore <file.sql|dir>")
+	}
+
+	dialect, err := drivers.DialectOf(command.DB)
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Stat(args[0])
+	if err != nil {
+		return model.NewError(model.ClassInput, err)
+	}
+	if !info.IsDir() {
+		return restoreSQL(ctx, command, dialect, args[0])
+	}
+
+	manifest, err := readManifest(filepath.Join(args[0], dumpManifestFile))
+	if err != nil {
+		return err
+	}
+
+	tables := model.SortTables(model.FilterTables(manifest.Tables, include, exclude))
+	if !noSchema {
+		for _, table := range tables {
+			stmt := dialect.CreateTable(table)
+			if command.Verbose {
+				log.Printf("-- %s", stmt)
+			}
+			if _, err := command.DB.ExecContext(ctx, stmt); err != nil {
+				return drivers.NewError(err, stmt)
+			}
+		}
+	}
+
+	for _, table := range tables {
+		count, err := restoreTable(ctx, command.DB, dialect, table, filepath.Join(args[0], table.Name+".ndjson"))
+		if err != nil {
+			return fmt.Errorf("restoring %s: %w", table.Name, err)
+		}
+		if !command.Quiet {
+			log.Printf("Restored %s, %d rows", table.Name, count)
+		}
+	}
+	return nil
+}
+
+func readManifest(filename string) (*DumpManifest, error) {
+	contents, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, model.NewError(model.ClassInput, err)
+	}
+
+	manifest := &DumpManifest{}
+	if err := json.Unmarshal(contents, manifest); err != nil {
+		return nil, model.NewError(model.ClassInput, fmt.Errorf("error decoding %s: %w", filename, err))
+	}
+	return manifest, nil
+}
+
+func restoreSQL(ctx context.Context, command *model.Command, dialect drivers.Dialect, filename string) error {
+	contents, err := os.ReadFile(filename)
+	if err != nil {
+		return model.NewError(model.ClassInput, err)
+	}
+
+	stmts := internal.SplitStatements(contents, dialect == drivers.DialectMySQL)
+	if len(stmts) == 0 {
+		return model.NewError(model.ClassInput, fmt.Errorf("no statements in %s", filename))
+	}
+
+	tx, err := command.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return drivers.NewError(err, "")
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range stmts {
+		if command.Verbose {
+			log.Printf("-- %s", stmt)
+		}
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return drivers.NewError(err, stmt)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return drivers.NewError(err, "")
+	}
+	if !command.Quiet {
+		log.Printf("Restored %s, %d statements", filename, len(stmts))
+	}
+	return nil
+}
+
+func restoreTable(ctx context.Context, db *sqlx.DB, dialect drivers.Dialect, table *model.Table, filename string) (int, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	defer f.Close()
+
+	columns := make([]string, 0, len(table.Columns))
+	placeholders := make([]string, 0, len(table.Columns))
+	for _, column := range table.Columns {
+		columns = append(columns, dialect.Quote(column.Name))
+		placeholders = append(placeholders, "?")
+	}
+	query := db.Rebind(fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", dialect.Quote(table.Name), strings.Join(columns, ", "), strings.Join(placeholders, ", ")))
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, drivers.NewError(err, "")
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PreparexContext(ctx, query)
+	if err != nil {
+		return 0, drivers.NewError(err, query)
+	}
+	defer stmt.Close()
+
+	var count int
+	decoder := json.NewDecoder(bufio.NewReader(f))
+	decoder.UseNumber()
+	for {
+		record := map[string]any{}
+		if err := decoder.Decode(&record); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return count, model.NewError(model.ClassInput, fmt.Errorf("error decoding %s: %w", filename, err))
+		}
+
+		args := make([]any, 0, len(table.Columns))
+		for _, column := range table.Columns {
+			value, err := restoreValue(record[column.Name], column)
+			if err != nil {
+				return count, model.NewError(model.ClassInput, fmt.Errorf("column %s: %w", column.Name, err))
+			}
+			args = append(args, value)
+		}
+
+		if _, err := stmt.ExecContext(ctx, args...); err != nil {
+			return count, drivers.NewError(err, query)
+		}
+		count++
+	}
+
+	if count > 0 {
+		for _, query := range dialect.ResetSequences(table) {
+			if _, err := tx.ExecContext(ctx, query); err != nil {
+				return count, drivers.NewError(err, query)
+			}
+		}
+	}
+
+	return count, drivers.NewError(tx.Commit(), "")
+}
+
+// restoreValue converts a decoded ndjson value into a query argument.
+func restoreValue(value any, column model.Column) (any, error) {
+	kind, _ := drivers.Kind(column.Type)
+
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n, nil
+		}
+		return v.Float64()
+	case string:
+		switch kind {
+		case drivers.KindBlob:
+			return base64.StdEncoding.DecodeString(v)
+		case drivers.KindTimestamp, drivers.KindDate:
+			if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+				if kind == drivers.KindDate {
+					return t.Format(time.DateOnly), nil
+				}
+				return t.UTC().Format("2006-01-02 15:04:05.999999"), nil
+			}
+		}
+		return v, nil
+	case map[string]any, []any:
+		b, err := json.Marshal(v)
+		return string(b), err
+	}
+	return value, nil
+}
@@ -0,0 +1,413 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-yaml"
+
+	"github.com/titpetric/etl/drivers"
+	"github.com/titpetric/etl/internal"
+	"github.com/titpetric/etl/model"
+)
+
+// SeedGenerators configures per-column generators, keyed by table
+// and column name. A generator of `skip` leaves the column out of
+// the insert, so the database default applies.
+//
+// Example:
+//
+//	users:
+//	  status: oneof(active, inactive)
+//	  score: int(0, 100)
+//	  nickname: skip
+type SeedGenerators map[string]map[string]string
+
+// seedGeneratorSkip leaves a column out of generated rows.
+const seedGeneratorSkip = "skip"
+
+// seedMaxAttempts bounds retries when generating unique values.
+const seedMaxAttempts = 100
+
+// Seed inserts generated rows into a table. Values are generated from
+// the column names and types, foreign keys are picked from existing
+// parent rows, and unique columns get distinct values.
+func Seed(ctx context.Context, command *model.Command, _ io.Reader) error {
+	var (
+		rows     int
+		seed     uint64
+		filename string
+		dry      bool
+	)
+
+	flagSet := model.NewFlagSet("Seed")
+	flagSet.IntVarP(&rows, "rows", "n", 10, "Number of rows to generate")
+	flagSet.Uint64Var(&seed, "seed", 0, "Random seed for reproducible values (default random)")
+	flagSet.StringVar(&filename, "generators", "", "YAML file with per-column generators")
+	flagSet.BoolVar(&dry, "json", false, "Print the generated rows as JSON instead of inserting them")
+	if err := flagSet.Parse(command.Args); err != nil {
+		return model.InputError("error parsing flags: %w", err)
+	}
+	args := flagSet.Args()
+	if len(args) != 1 || rows < 1 {
+		return model.InputError("usage: etl seed <table> [--rows N] [--seed N] [--generators generators.yml]")
+	}
+
+	driver, err := drivers.New(command.DB)
+	if err != nil {
+		return err
+	}
+
+	schema, err := driver.Schema()
+	if err != nil {
+		return err
+	}
+
+	tables := model.FilterTables(schema, args[:1], nil)
+	if len(tables) == 0 {
+		return model.InputError("unknown table: %s", args[0])
+	}
+	table := tables[0]
+
+	overrides, err := loadSeedGenerators(filename)
+	if err != nil {
+		return err
+	}
+
+	if seed == 0 {
+		seed = uint64(time.Now().UnixNano())
+		if !command.Quiet {
+			log.Printf("Using --seed %d", seed)
+		}
+	}
+
+	seeder := &seeder{
+		command: command,
+		table:   table,
+		random:  rand.New(rand.NewPCG(seed, seed)),
+	}
+	if err := seeder.plan(overrides[table.Name]); err != nil {
+		return err
+	}
+
+	records, err := seeder.generate(rows)
+	if err != nil {
+		return err
+	}
+
+	if dry {
+		return json.NewEncoder(os.Stdout).Encode(records)
+	}
+
+	count, err := driver.Insert(table.Name, records)
+	if err != nil {
+		return err
+	}
+
+	if !command.Quiet {
+		log.Printf("Seeded %s with %d rows, %d affected", table.Name, len(records), count)
+	}
+	return nil
+}
+
+func loadSeedGenerators(filename string) (SeedGenerators, error) {
+	result := SeedGenerators{}
+	if filename == "" {
+		return result, nil
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, model.NewError(model.ClassInput, err)
+	}
+	if err := yaml.Unmarshal(data, &result); err != nil {
+		return nil, model.InputError("error decoding %s: %w", filename, err)
+	}
+	return result, nil
+}
+
+type seeder struct {
+	command *model.Command
+	table   *model.Table
+	random  *rand.Rand
+
+	columns    []model.Column
+	generators map[string]internal.Generator
+
+	// unique holds the column groups which must be distinct,
+	// and seen the values already used for each group.
+	unique [][]string
+	seen   []map[string]bool
+}
+
+// plan picks a generator for each column and loads existing values
+// for foreign keys and unique constraints.
+func (s *seeder) plan(overrides map[string]string) error {
+	s.generators = map[string]internal.Generator{}
+
+	for _, column := range s.table.Columns {
+		spec, ok := overrides[column.Name]
+		if !ok && column.AutoIncrement {
+			continue
+		}
+		if spec == seedGeneratorSkip {
+			continue
+		}
+
+		var (
+			generator internal.Generator
+			err       error
+		)
+		switch fk, isFK := s.table.ForeignKey(column.Name); {
+		case ok:
+			generator, err = internal.NewGenerator(spec)
+		case isFK:
+			generator, err = s.foreignKey(column, fk)
+		default:
+			generator, err = internal.NewGenerator(inferGenerator(column))
+		}
+		if err != nil {
+			return model.NewError(model.ClassInput, fmt.Errorf("column %s: %w", column.Name, err))
+		}
+
+		s.columns = append(s.columns, column)
+		s.generators[column.Name] = generator
+	}
+
+	groups := s.table.Unique
+	if len(s.table.PrimaryKey) > 0 {
+		groups = append([][]string{s.table.PrimaryKey}, groups...)
+	}
+
+	for _, group := range groups {
+		generated := true
+		for _, name := range group {
+			if _, ok := s.generators[name]; !ok {
+				generated = false
+			}
+		}
+		if !generated {
+			continue
+		}
+
+		seen, err := s.existing(group)
+		if err != nil {
+			return err
+		}
+		s.unique = append(s.unique, group)
+		s.seen = append(s.seen, seen)
+	}
+	return nil
+}
+
+// foreignKey returns a generator picking values from the parent table.
+func (s *seeder) foreignKey(column model.Column, fk model.ForeignKey) (internal.Generator, error) {
+	dialect, err := drivers.DialectOf(s.command.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	ref := dialect.Quote(fk.References[0])
+	query := fmt.Sprintf("SELECT DISTINCT %s FROM %s WHERE %s IS NOT NULL ORDER BY %s", ref, dialect.Quote(fk.Table), ref, ref)
+
+	var values []any
+	if err := s.command.DB.Select(&values, query); err != nil {
+		return nil, drivers.NewError(err, query)
+	}
+
+	if len(values) == 0 {
+		if column.Nullable {
+			return internal.NewGenerator("null")
+		}
+		return nil, fmt.Errorf("no rows in %s to reference, seed it first", fk.Table)
+	}
+
+	return func(r *rand.Rand) any {
+		return values[r.IntN(len(values))]
+	}, nil
+}
+
+// existing returns the values of a unique column group already in the table.
+func (s *seeder) existing(group []string) (map[string]bool, error) {
+	dialect, err := drivers.DialectOf(s.command.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make([]string, 0, len(group))
+	for _, name := range group {
+		columns = append(columns, dialect.Quote(name))
+	}
+	query := fmt.Sprintf("SELECT %s FROM %s", strings.Join(columns, ", "), dialect.Quote(s.table.Name))
+
+	rows, err := s.command.DB.Queryx(query)
+	if err != nil {
+		return nil, drivers.NewError(err, query)
+	}
+	defer rows.Close()
+
+	result := map[string]bool{}
+	for rows.Next() {
+		values, err := rows.SliceScan()
+		if err != nil {
+			return nil, drivers.NewError(err, query)
+		}
+		result[seedKey(values)] = true
+	}
+	return result, drivers.NewError(rows.Err(), query)
+}
+
+func (s *seeder) generate(count int) ([]model.RecordInput, error) {
+	result := make([]model.RecordInput, 0, count)
+	for range count {
+		record := model.RecordInput{}
+		for _, column := range s.columns {
+			record[column.Name] = s.value(column, 0)
+		}
+
+		for i, group := range s.unique {
+			key, err := s.distinct(record, group, s.seen[i])
+			if err != nil {
+				return nil, err
+			}
+			s.seen[i][key] = true
+		}
+
+		result = append(result, record)
+	}
+	return result, nil
+}
+
+// distinct regenerates the group values in record until they are unused.
+// After a few attempts, a number is added to string values.
+func (s *seeder) distinct(record model.RecordInput, group []string, seen map[string]bool) (string, error) {
+	for attempt := 0; attempt < seedMaxAttempts; attempt++ {
+		values := make([]any, 0, len(group))
+		for _, name := range group {
+			values = append(values, record[name])
+		}
+
+		key := seedKey(values)
+		if !seen[key] {
+			return key, nil
+		}
+
+		for _, column := range s.columns {
+			if slices.Contains(group, column.Name) {
+				record[column.Name] = s.value(column, attempt+1)
+			}
+		}
+	}
+	return "", fmt.Errorf("can't generate unique values for %s (%s), try a wider generator", s.table.Name, strings.Join(group, ", "))
+}
+
+// value generates a column value, truncated to the column length.
+func (s *seeder) value(column model.Column, attempt int) any {
+	value := s.generators[column.Name](s.random)
+
+	text, ok := value.(string)
+	if !ok {
+		return value
+	}
+
+	if attempt > 5 {
+		text = internal.UniqueValue(text, s.random.IntN(attempt*attempt*100))
+	}
+
+	if kind, size := drivers.Kind(column.Type); kind == drivers.KindVarchar && len(text) > size[0] {
+		text = text[:size[0]]
+	}
+	return text
+}
+
+func seedKey(values []any) string {
+	parts := make([]string, 0, len(values))
+	for _, value := range values {
+		if b, ok := value.([]byte); ok {
+			value = string(b)
+		}
+		parts = append(parts, fmt.Sprint(value))
+	}
+	return strings.Join(parts, "\x00")
+}
+
+// inferGenerator picks a generator from the column name and type.
+func inferGenerator(column model.Column) string {
+	name := strings.ToLower(column.Name)
+	kind, _ := drivers.Kind(column.Type)
+
+	has := func(words ...string) bool {
+		for _, word := range words {
+			if name == word || strings.HasSuffix(name, "_"+word) || strings.HasPrefix(name, word+"_") {
+				return true
+			}
+		}
+		return false
+	}
+
+	text := kind == drivers.KindText || kind == drivers.KindVarchar
+	if text {
+		switch {
+		case strings.Contains(name, "email"):
+			return "email"
+		case has("first_name", "firstname", "given_name"):
+			return "first_name"
+		case has("last_name", "lastname", "surname", "family_name"):
+			return "last_name"
+		case has("username", "login", "handle", "nickname"):
+			return "username"
+		case has("name", "full_name", "display_name", "author"):
+			return "name"
+		case has("company", "organization", "organisation"):
+			return "company"
+		case has("city"):
+			return "city"
+		case has("country"):
+			return "country"
+		case has("phone", "mobile", "tel"):
+			return "phone"
+		case has("url", "website", "homepage", "link"):
+			return "url"
+		case has("uuid", "guid"):
+			return "uuid"
+		case has("title", "subject", "label"):
+			return "words(3)"
+		case has("description", "bio", "body", "content", "comment", "comments", "note", "notes", "summary"):
+			if kind == drivers.KindText {
+				return "paragraph"
+			}
+			return "sentence"
+		case strings.HasSuffix(name, "_at"), has("date", "time", "timestamp"):
+			return "timestamp"
+		}
+	}
+
+	switch kind {
+	case drivers.KindBoolean:
+		return "bool"
+	case drivers.KindInteger, drivers.KindBigint:
+		if strings.HasPrefix(name, "is_") || strings.HasPrefix(name, "has_") {
+			return "int(0,1)"
+		}
+		return "int(0,1000)"
+	case drivers.KindDecimal, drivers.KindFloat:
+		return "float(0,1000)"
+	case drivers.KindTimestamp:
+		return "timestamp"
+	case drivers.KindDate:
+		return "date"
+	case drivers.KindBlob:
+		return "bytes(16)"
+	case drivers.KindJSON:
+		return "const({})"
+	}
+	return "words(2)"
+}
@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+
+	"github.com/titpetric/etl/model"
+)
+
+// testSeed runs `etl seed` with args against db.
+func testSeed(t *testing.T, db *sqlx.DB, args ...string) error {
+	t.Helper()
+
+	return Seed(context.Background(), &model.Command{Name: "seed", Args: args, DB: db, Quiet: true}, nil)
+}
+
+// TestSeedReproducible verifies that the same seed generates the same rows.
+func TestSeedReproducible(t *testing.T) {
+	seed := func() (users, orders []map[string]any) {
+		db := testDumpSource(t)
+		require.NoError(t, testSeed(t, db, "users", "--rows", "20", "--seed", "42"))
+		require.NoError(t, testSeed(t, db, "orders", "--rows", "50", "--seed", "42"))
+		return testDumpRows(t, db, "users"), testDumpRows(t, db, "orders")
+	}
+
+	users, orders := seed()
+	require.Len(t, users, 22)
+	require.Len(t, orders, 52)
+
+	users2, orders2 := seed()
+	require.Equal(t, users, users2)
+	require.Equal(t, orders, orders2)
+
+	db := testDumpSource(t)
+	require.NoError(t, testSeed(t, db, "users", "--rows", "20", "--seed", "43"))
+	require.NotEqual(t, users, testDumpRows(t, db, "users"))
+}
+
+// TestSeedForeignKeys verifies that foreign keys only reference parent rows.
+func TestSeedForeignKeys(t *testing.T) {
+	db := testDumpSource(t)
+	db.MustExec(`
+		insert into users (id, email) values (10, 'carol@example.com');
+		create table reviews (id integer primary key autoincrement, user_id integer references users(id), order_id integer references orders(id), body text);
+		create table invoices (id integer primary key autoincrement, order_id integer not null references reviews(id));
+	`)
+	require.NoError(t, testSeed(t, db, "orders", "--rows", "100", "--seed", "1"))
+
+	var userIDs []int64
+	require.NoError(t, db.Select(&userIDs, "select distinct user_id from orders order by user_id"))
+	require.Equal(t, []int64{1, 2, 10}, userIDs)
+
+	// A nullable foreign key without parent rows is left null,
+	// a required one fails.
+	db.MustExec("delete from orders")
+	require.NoError(t, testSeed(t, db, "reviews", "--rows", "10", "--seed", "1"))
+
+	var count int
+	require.NoError(t, db.Get(&count, "select count(*) from reviews where order_id is null and user_id in (1, 2, 10)"))
+	require.Equal(t, 10, count)
+
+	db.MustExec("delete from reviews")
+	err := testSeed(t, db, "invoices", "--rows", "1")
+	require.ErrorContains(t, err, "no rows in reviews")
+}
+
+// TestSeedUnique verifies that unique groups get distinct values,
+// also from the rows already in the table.
+func TestSeedUnique(t *testing.T) {
+	db := testDumpSource(t)
+	db.MustExec(`
+		create table tags (id integer primary key autoincrement, name varchar(8) not null unique, a integer, b integer, unique (a, b));
+		insert into tags (name, a, b) values ('x', 0, 0);
+	`)
+
+	generators := filepath.Join(t.TempDir(), "generators.yml")
+	require.NoError(t, os.WriteFile(generators, []byte("tags:\n  name: oneof(x, y)\n  a: int(0, 3)\n  b: int(0, 3)\n"), 0o644))
+
+	require.NoError(t, testSeed(t, db, "tags", "--rows", "12", "--seed", "7", "--generators", generators))
+
+	var rows []struct {
+		Name string `db:"name"`
+		A    int    `db:"a"`
+		B    int    `db:"b"`
+	}
+	require.NoError(t, db.Select(&rows, "select name, a, b from tags"))
+	require.Len(t, rows, 13)
+
+	names, pairs := map[string]bool{}, map[[2]int]bool{}
+	for _, row := range rows {
+		require.False(t, names[row.Name], "duplicate name %s", row.Name)
+		require.False(t, pairs[[2]int{row.A, row.B}], "duplicate pair %d, %d", row.A, row.B)
+		require.LessOrEqual(t, len(row.Name), 8)
+		names[row.Name] = true
+		pairs[[2]int{row.A, row.B}] = true
+	}
+
+	// Only 3 of the 16 pairs are left
+	err := testSeed(t, db, "tags", "--rows", "4", "--seed", "7", "--generators", generators)
+	require.ErrorContains(t, err, "can't generate unique values for tags (a, b)")
+}
+
+// TestSeedFlags verifies that invalid arguments are input errors.
+func TestSeedFlags(t *testing.T) {
+	db := testDumpSource(t)
+
+	generators := filepath.Join(t.TempDir(), "generators.yml")
+	require.NoError(t, os.WriteFile(generators, []byte("users:\n  email: nope\n"), 0o644))
+
+	tests := map[string][]string{
+		"no table":          {},
+		"no rows":           {"users", "--rows", "0"},
+		"unknown table":     {"missing"},
+		"unknown generator": {"users", "--generators", generators},
+		"missing file":      {"users", "--generators", filepath.Join(t.TempDir(), "missing.yml")},
+		"file shorthand":    {"users", "-f", generators},
+	}
+
+	for name, args := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := testSeed(t, db, args...)
+			require.Error(t, err)
+			require.Equal(t, model.ClassInput, model.AsError(err).Class, err)
+		})
+	}
+}
+
+// TestInferGenerator verifies generators picked from column names and types.
+func TestInferGenerator(t *testing.T) {
+	tests := []struct {
+		name, columnType string
+		want             string
+	}{
+		{"email", "varchar(255)", "email"},
+		{"contact_email", "text", "email"},
+		{"first_name", "text", "first_name"},
+		{"surname", "text", "last_name"},
+		{"name", "text", "name"},
+		{"phone", "varchar(32)", "phone"},
+		{"description", "text", "paragraph"},
+		{"description", "varchar(255)", "sentence"},
+		{"created_at", "text", "timestamp"},
+		{"created_at", "timestamp", "timestamp"},
+		{"is_active", "integer", "int(0,1)"},
+		{"active", "boolean", "bool"},
+		{"price", "decimal(10,2)", "float(0,1000)"},
+		{"payload", "jsonb", "const({})"},
+		{"data", "blob", "bytes(16)"},
+		{"interval", "interval", "words(2)"},
+	}
+
+	for _, tt := range tests {
+		require.Equal(t, tt.want, inferGenerator(model.Column{Name: tt.name, Type: tt.columnType}), "%s %s", tt.name, tt.columnType)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"slices"
 	"strings"
 
+	"github.com/titpetric/etl/drivers"
 	"github.com/titpetric/etl/model"
 )
 
@@ -18,6 +19,9 @@ func UpdateRequest(r io.Reader, args []string) ([]model.Record, error) {
 	if err != nil {
 		return nil, err
 	}
+	if len(input) == 0 {
+		return nil, errors.New("no input records")
+	}
 
 	multi := false
 	if string(input[0:1]) == "[" {
@@ -72,9 +76,13 @@ func buildUpdateQuery(table string, data model.Record, whereKeys []string) (stri
 }
 
 func Update(ctx context.Context, command *model.Command, r io.Reader) error {
+	if len(command.Args) == 0 {
+		return model.InputError("usage: etl update <table> [field=value...]")
+	}
+
 	records, err := UpdateRequest(r, command.Args[1:])
 	if err != nil {
-		return err
+		return model.NewError(model.ClassInput, err)
 	}
 
 	table := command.Args[0]
@@ -104,12 +112,12 @@ func Update(ctx context.Context, command *model.Command, r io.Reader) error {
 	}
 
 	if len(names) == 0 {
-		return errors.New("no where condition for update")
+		return model.InputError("no where condition for update")
 	}
 
 	tx, err := command.DB.Beginx()
 	if err != nil {
-		return err
+		return drivers.NewError(err, "")
 	}
 	defer tx.Rollback()
 
@@ -128,14 +136,14 @@ func Update(ctx context.Context, command *model.Command, r io.Reader) error {
 			if strings.Contains(err.Error(), "Duplicate entry") {
 				continue // Ignore unique constraint errors
 			}
-			return err
+			return drivers.NewError(err, query)
 		}
 		rowsAffected, _ := result.RowsAffected()
 		updates += rowsAffected
 	}
 
 	if err := tx.Commit(); err != nil {
-		return err
+		return drivers.NewError(err, "")
 	}
 
 	if !command.Quiet {
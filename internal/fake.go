@@ -0,0 +1,278 @@
+package internal
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Generator returns a fake value for a column.
+type Generator func(r *rand.Rand) any
+
+var (
+	fakeFirstNames = []string{
+		"Alice", "Bob", "Carol", "David", "Emma", "Frank", "Grace", "Henry", "Iris", "Jack",
+		"Karen", "Liam", "Maya", "Noah", "Olivia", "Peter", "Quinn", "Rosa", "Sam", "Tara",
+		"Uma", "Victor", "Wendy", "Xavier", "Yara", "Zoe",
+	}
+	fakeLastNames = []string{
+		"Anderson", "Brown", "Clark", "Davis", "Evans", "Fischer", "Garcia", "Harris", "Ito", "Johnson",
+		"Kowalski", "Lee", "Martin", "Novak", "Olsen", "Patel", "Quinn", "Rossi", "Smith", "Taylor",
+		"Ueda", "Varga", "Walker", "Young", "Zimmerman",
+	}
+	fakeDomains   = []string{"example.com", "example.org", "example.net", "mail.test"}
+	fakeCompanies = []string{"Acme", "Globex", "Initech", "Umbrella", "Hooli", "Vandelay", "Wonka", "Stark", "Tyrell", "Cyberdyne"}
+	fakeSuffixes  = []string{"Inc", "LLC", "Ltd", "GmbH", "Group"}
+	fakeCities    = []string{"Amsterdam", "Berlin", "Chicago", "Dublin", "Kyoto", "Lisbon", "Ljubljana", "Madrid", "Oslo", "Paris", "Seattle", "Toronto", "Vienna"}
+	fakeCountries = []string{"Austria", "Canada", "France", "Germany", "Ireland", "Japan", "Netherlands", "Norway", "Portugal", "Slovenia", "Spain", "United States"}
+	fakeWords     = []string{
+		"alpha", "bright", "cloud", "delta", "echo", "field", "green", "harbor", "island", "jolly",
+		"kernel", "lemon", "maple", "north", "orbit", "pixel", "quiet", "river", "stone", "timber",
+		"union", "velvet", "winter", "yellow", "zephyr",
+	}
+)
+
+// fakeEpoch and fakeSpan bound generated timestamps, so that a
+// seed produces the same values regardless of the current time.
+var (
+	fakeEpoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	fakeSpan  = 2 * 365 * 24 * time.Hour
+)
+
+// Generators lists the generator names accepted by NewGenerator.
+var Generators = []string{
+	"first_name", "last_name", "name", "username", "email", "company", "city", "country",
+	"phone", "url", "uuid", "word", "words(n)", "sentence", "paragraph",
+	"int(min,max)", "float(min,max)", "bool", "timestamp(from,to)", "date(from,to)",
+	"bytes(n)", "oneof(a,b,...)", "const(value)", "null",
+}
+
+// NewGenerator parses a generator spec like `email`, `int(1,100)`
+// or `oneof(active,inactive)` and returns the generator.
+func NewGenerator(spec string) (Generator, error) {
+	name, args := parseGeneratorSpec(spec)
+
+	pick := func(values []string) Generator {
+		return func(r *rand.Rand) any {
+			return values[r.IntN(len(values))]
+		}
+	}
+
+	switch name {
+	case "first_name":
+		return pick(fakeFirstNames), nil
+	case "last_name":
+		return pick(fakeLastNames), nil
+	case "name":
+		return func(r *rand.Rand) any {
+			return fakeName(r)
+		}, nil
+	case "username":
+		return func(r *rand.Rand) any {
+			return fakeUsername(r)
+		}, nil
+	case "email":
+		return func(r *rand.Rand) any {
+			return fakeUsername(r) + "@" + fakeDomains[r.IntN(len(fakeDomains))]
+		}, nil
+	case "company":
+		return func(r *rand.Rand) any {
+			return fakeCompanies[r.IntN(len(fakeCompanies))] + " " + fakeSuffixes[r.IntN(len(fakeSuffixes))]
+		}, nil
+	case "city":
+		return pick(fakeCities), nil
+	case "country":
+		return pick(fakeCountries), nil
+	case "phone":
+		return func(r *rand.Rand) any {
+			return fmt.Sprintf("+1-555-%03d-%04d", r.IntN(1000), r.IntN(10000))
+		}, nil
+	case "url":
+		return func(r *rand.Rand) any {
+			return "https://" + fakeDomains[r.IntN(len(fakeDomains))] + "/" + fakeWords[r.IntN(len(fakeWords))]
+		}, nil
+	case "uuid":
+		return func(r *rand.Rand) any {
+			var b [16]byte
+			for i := range b {
+				b[i] = byte(r.UintN(256))
+			}
+			b[6] = b[6]&0x0f | 0x40
+			b[8] = b[8]&0x3f | 0x80
+			return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+		}, nil
+	case "word":
+		return pick(fakeWords), nil
+	case "words":
+		n, err := intArg(args, 0, 3)
+		if err != nil {
+			return nil, fmt.Errorf("generator %s: %w", spec, err)
+		}
+		return func(r *rand.Rand) any {
+			return fakeWordList(r, n)
+		}, nil
+	case "sentence":
+		return func(r *rand.Rand) any {
+			return fakeSentence(r)
+		}, nil
+	case "paragraph":
+		return func(r *rand.Rand) any {
+			sentences := make([]string, 3+r.IntN(3))
+			for i := range sentences {
+				sentences[i] = fakeSentence(r)
+			}
+			return strings.Join(sentences, " ")
+		}, nil
+	case "int":
+		lo, err := intArg(args, 0, 0)
+		if err != nil {
+			return nil, fmt.Errorf("generator %s: %w", spec, err)
+		}
+		hi, err := intArg(args, 1, 1000)
+		if err != nil || hi < lo {
+			return nil, fmt.Errorf("generator %s: invalid range", spec)
+		}
+		return func(r *rand.Rand) any {
+			return int64(lo + r.IntN(hi-lo+1))
+		}, nil
+	case "float":
+		lo, err1 := floatArg(args, 0, 0)
+		hi, err2 := floatArg(args, 1, 1000)
+		if err1 != nil || err2 != nil || hi < lo {
+			return nil, fmt.Errorf("generator %s: invalid range", spec)
+		}
+		return func(r *rand.Rand) any {
+			v := lo + r.Float64()*(hi-lo)
+			return float64(int64(v*100)) / 100
+		}, nil
+	case "bool":
+		return func(r *rand.Rand) any {
+			return r.IntN(2) == 1
+		}, nil
+	case "timestamp", "date":
+		from, to, err := timeArgs(args)
+		if err != nil {
+			return nil, fmt.Errorf("generator %s: %w", spec, err)
+		}
+		return func(r *rand.Rand) any {
+			t := from.Add(time.Duration(r.Int64N(int64(to.Sub(from))))).Truncate(time.Second)
+			if name == "date" {
+				return t.Format(time.DateOnly)
+			}
+			return t
+		}, nil
+	case "bytes":
+		n, err := intArg(args, 0, 16)
+		if err != nil {
+			return nil, fmt.Errorf("generator %s: %w", spec, err)
+		}
+		return func(r *rand.Rand) any {
+			b := make([]byte, n)
+			for i := range b {
+				b[i] = byte(r.UintN(256))
+			}
+			return b
+		}, nil
+	case "oneof":
+		if len(args) == 0 {
+			return nil, fmt.Errorf("generator %s: no values", spec)
+		}
+		return pick(args), nil
+	case "const":
+		value := strings.Join(args, ",")
+		return func(*rand.Rand) any {
+			return value
+		}, nil
+	case "null":
+		return func(*rand.Rand) any {
+			return nil
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown generator: %s, supported %v", spec, Generators)
+}
+
+// UniqueValue makes a generated string distinct by adding a number,
+// keeping the domain of email addresses.
+func UniqueValue(value string, n int) string {
+	if local, domain, ok := strings.Cut(value, "@"); ok {
+		return local + strconv.Itoa(n) + "@" + domain
+	}
+	return value + strconv.Itoa(n)
+}
+
+func parseGeneratorSpec(spec string) (string, []string) {
+	spec = strings.TrimSpace(spec)
+	name, rest, ok := strings.Cut(spec, "(")
+	if !ok {
+		return strings.ToLower(spec), nil
+	}
+
+	var args []string
+	for _, arg := range strings.Split(strings.TrimSuffix(rest, ")"), ",") {
+		if arg = strings.TrimSpace(arg); arg != "" {
+			args = append(args, arg)
+		}
+	}
+	return strings.ToLower(strings.TrimSpace(name)), args
+}
+
+func intArg(args []string, idx, fallback int) (int, error) {
+	if idx >= len(args) {
+		return fallback, nil
+	}
+	return strconv.Atoi(args[idx])
+}
+
+func floatArg(args []string, idx int, fallback float64) (float64, error) {
+	if idx >= len(args) {
+		return fallback, nil
+	}
+	return strconv.ParseFloat(args[idx], 64)
+}
+
+func timeArgs(args []string) (time.Time, time.Time, error) {
+	from, to := fakeEpoch, fakeEpoch.Add(fakeSpan)
+	if len(args) == 0 {
+		return from, to, nil
+	}
+	if len(args) != 2 {
+		return from, to, fmt.Errorf("expected (from, to) dates")
+	}
+
+	var err error
+	if from, err = time.Parse(time.DateOnly, args[0]); err != nil {
+		return from, to, err
+	}
+	if to, err = time.Parse(time.DateOnly, args[1]); err != nil {
+		return from, to, err
+	}
+	if !to.After(from) {
+		return from, to, fmt.Errorf("invalid range %s - %s", args[0], args[1])
+	}
+	return from, to, nil
+}
+
+func fakeName(r *rand.Rand) string {
+	return fakeFirstNames[r.IntN(len(fakeFirstNames))] + " " + fakeLastNames[r.IntN(len(fakeLastNames))]
+}
+
+func fakeUsername(r *rand.Rand) string {
+	first := fakeFirstNames[r.IntN(len(fakeFirstNames))]
+	last := fakeLastNames[r.IntN(len(fakeLastNames))]
+	return strings.ToLower(first + "." + last)
+}
+
+func fakeWordList(r *rand.Rand, n int) string {
+	words := make([]string, n)
+	for i := range words {
+		words[i] = fakeWords[r.IntN(len(fakeWords))]
+	}
+	return strings.Join(words, " ")
+}
+
+func fakeSentence(r *rand.Rand) string {
+	s := fakeWordList(r, 4+r.IntN(6))
+	return strings.ToUpper(s[:1]) + s[1:] + "."
+}
@@ -0,0 +1,95 @@
+package internal
+
+import (
+	"math/rand/v2"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestNewGenerator verifies the generated values and their types.
+func TestNewGenerator(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 1))
+
+	tests := []struct {
+		spec  string
+		check func(t *testing.T, value any)
+	}{
+		{"email", func(t *testing.T, value any) { require.Regexp(t, `^[a-z]+\.[a-z]+@[a-z.]+$`, value) }},
+		{"uuid", func(t *testing.T, value any) {
+			require.Regexp(t, `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, value)
+		}},
+		{"words(4)", func(t *testing.T, value any) { require.Regexp(t, `^[a-z]+( [a-z]+){3}$`, value) }},
+		{"sentence", func(t *testing.T, value any) { require.Regexp(t, `^[A-Z][a-z ]+\.$`, value) }},
+		{"int(5, 7)", func(t *testing.T, value any) {
+			require.IsType(t, int64(0), value)
+			require.GreaterOrEqual(t, value, int64(5))
+			require.LessOrEqual(t, value, int64(7))
+		}},
+		{"float(1, 2)", func(t *testing.T, value any) {
+			require.IsType(t, float64(0), value)
+			require.GreaterOrEqual(t, value, 1.0)
+			require.LessOrEqual(t, value, 2.0)
+		}},
+		{"bool", func(t *testing.T, value any) { require.IsType(t, true, value) }},
+		{"timestamp(2020-01-01, 2020-01-02)", func(t *testing.T, value any) {
+			ts, ok := value.(time.Time)
+			require.True(t, ok)
+			require.Equal(t, 2020, ts.Year())
+			require.Equal(t, time.January, ts.Month())
+			require.Equal(t, 1, ts.Day())
+		}},
+		{"date", func(t *testing.T, value any) { require.Regexp(t, `^202[45]-\d\d-\d\d$`, value) }},
+		{"bytes(4)", func(t *testing.T, value any) { require.Len(t, value, 4) }},
+		{"oneof(a, b)", func(t *testing.T, value any) { require.Contains(t, []any{"a", "b"}, value) }},
+		{"const(a, b)", func(t *testing.T, value any) { require.Equal(t, "a,b", value) }},
+		{"null", func(t *testing.T, value any) { require.Nil(t, value) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			generator, err := NewGenerator(tt.spec)
+			require.NoError(t, err)
+			for range 20 {
+				tt.check(t, generator(r))
+			}
+		})
+	}
+}
+
+// TestNewGeneratorError verifies that invalid specs fail.
+func TestNewGeneratorError(t *testing.T) {
+	for _, spec := range []string{"nope", "int(5, 1)", "int(a)", "float(x)", "words(x)", "oneof()", "timestamp(2020-01-01)", "date(2020-01-02, 2020-01-01)"} {
+		_, err := NewGenerator(spec)
+		require.Error(t, err, spec)
+	}
+}
+
+// TestGeneratorSeed verifies that the same seed generates the same values.
+func TestGeneratorSeed(t *testing.T) {
+	specs := []string{"name", "email", "company", "phone", "url", "uuid", "paragraph", "int(0, 100)", "float(0, 1)", "bool", "timestamp", "date", "bytes(8)"}
+
+	generate := func(seed uint64) []any {
+		r := rand.New(rand.NewPCG(seed, seed))
+
+		var result []any
+		for _, spec := range specs {
+			generator, err := NewGenerator(spec)
+			require.NoError(t, err, spec)
+			for range 5 {
+				result = append(result, generator(r))
+			}
+		}
+		return result
+	}
+
+	require.Equal(t, generate(42), generate(42))
+	require.NotEqual(t, generate(42), generate(43))
+}
+
+// TestUniqueValue verifies that numbers keep the email domain.
+func TestUniqueValue(t *testing.T) {
+	require.Equal(t, "jane.doe7@example.com", UniqueValue("jane.doe@example.com", 7))
+	require.Equal(t, "stone7", UniqueValue("stone", 7))
+}
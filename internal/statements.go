@@ -10,6 +10,7 @@ import (
 var (
 	stripCommentsRe   = regexp.MustCompile(`\s+--.*`)
 	splitStatementsRe = regexp.MustCompilePOSIX(`;$`)
+	dollarQuoteRe     = regexp.MustCompile(`^\$([A-Za-z_][A-Za-z0-9_]*)?\$`)
 )
 
 // builtins implements some client-side replacements:
@@ -41,3 +42,77 @@ func Statements(contents []byte) []string {
 
 	return result
 }
+
+// SplitStatements splits a SQL script on semicolons outside of
+// quoted strings, identifiers, postgres $$ bodies and comments. Unlike Statements it
+// keeps literal values intact, as written by `etl dump`. MySQL
+// strings use backslash escapes, enabled with backslashEscapes.
+func SplitStatements(contents []byte, backslashEscapes bool) []string {
+	var (
+		result  []string
+		current strings.Builder
+		quote   byte
+	)
+
+	flush := func() {
+		if stmt := strings.TrimSpace(current.String()); stmt != "" {
+			result = append(result, stmt)
+		}
+		current.Reset()
+	}
+
+	for i := 0; i < len(contents); i++ {
+		c := contents[i]
+
+		if quote != 0 {
+			current.WriteByte(c)
+			switch {
+			case c == '\\' && quote == '\'' && backslashEscapes && i+1 < len(contents):
+				i++
+				current.WriteByte(contents[i])
+			case c == quote && i+1 < len(contents) && contents[i+1] == quote:
+				i++
+				current.WriteByte(contents[i])
+			case c == quote:
+				quote = 0
+			}
+			continue
+		}
+
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '-' && i+1 < len(contents) && contents[i+1] == '-':
+			for i < len(contents) && contents[i] != '\n' {
+				i++
+			}
+			continue
+		case c == '$':
+			if tag := dollarQuoteRe.Find(contents[i:]); tag != nil {
+				stop := len(contents)
+				if end := strings.Index(string(contents[i+len(tag):]), string(tag)); end != -1 {
+					stop = i + 2*len(tag) + end
+				}
+				current.Write(contents[i:stop])
+				i = stop - 1
+				continue
+			}
+		case c == '/' && i+1 < len(contents) && contents[i+1] == '*':
+			end := strings.Index(string(contents[i+2:]), "*/")
+			if end == -1 {
+				i = len(contents)
+			} else {
+				i += end + 3
+			}
+			current.WriteByte(' ')
+			continue
+		case c == ';':
+			flush()
+			continue
+		}
+		current.WriteByte(c)
+	}
+	flush()
+
+	return result
+}
@@ -0,0 +1,64 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestSplitStatements verifies that semicolons in strings, bodies and comments don't split statements.
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		name             string
+		script           string
+		backslashEscapes bool
+		want             []string
+	}{
+		{
+			name:   "statements",
+			script: "CREATE TABLE a (id int);\nINSERT INTO a VALUES (1);\n\n",
+			want:   []string{"CREATE TABLE a (id int)", "INSERT INTO a VALUES (1)"},
+		},
+		{
+			name:   "quotes",
+			script: `INSERT INTO a VALUES ('x;y', 'it''s;'); SELECT "a;b" FROM a; SELECT ` + "`c;d`" + ` FROM a`,
+			want:   []string{`INSERT INTO a VALUES ('x;y', 'it''s;')`, `SELECT "a;b" FROM a`, "SELECT `c;d` FROM a"},
+		},
+		{
+			name:             "backslash escapes",
+			script:           `INSERT INTO a VALUES ('x\';y'); SELECT 1`,
+			backslashEscapes: true,
+			want:             []string{`INSERT INTO a VALUES ('x\';y')`, "SELECT 1"},
+		},
+		{
+			name:   "no backslash escapes",
+			script: `INSERT INTO a VALUES ('x\'); SELECT 1`,
+			want:   []string{`INSERT INTO a VALUES ('x\')`, "SELECT 1"},
+		},
+		{
+			name:   "dollar quotes",
+			script: "CREATE FUNCTION f() RETURNS int AS $$ SELECT 1; $$ LANGUAGE sql; CREATE FUNCTION g() RETURNS int AS $body$ SELECT '$$'; $body$ LANGUAGE sql; SELECT $1",
+			want: []string{
+				"CREATE FUNCTION f() RETURNS int AS $$ SELECT 1; $$ LANGUAGE sql",
+				"CREATE FUNCTION g() RETURNS int AS $body$ SELECT '$$'; $body$ LANGUAGE sql",
+				"SELECT $1",
+			},
+		},
+		{
+			name:   "line comments",
+			script: "-- etl dump; sqlite\nSELECT 1; -- done;\nSELECT 2",
+			want:   []string{"SELECT 1", "SELECT 2"},
+		},
+		{
+			name:   "block comments",
+			script: "/* first; second */ SELECT 1;\nSELECT /* a; b */ 2; /* unterminated;",
+			want:   []string{"SELECT 1", "SELECT   2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, SplitStatements([]byte(tt.script), tt.backslashEscapes))
+		})
+	}
+}
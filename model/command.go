@@ -9,6 +9,9 @@ type Command struct {
 
 	DB *sqlx.DB
 
+	// Profiles holds the named connections from the CLI config file.
+	Profiles *Profiles
+
 	Verbose bool
 	Quiet   bool
 }
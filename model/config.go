@@ -1,6 +1,7 @@
 package model
 
 import (
+	"fmt"
 	"os"
 	"strings"
 
@@ -8,11 +9,22 @@ import (
 )
 
 type Config struct {
-	DSN    string
-	Folder string
+	DSN string
+
+	// Connection selects a named connection from the config file.
+	Connection string
+	// ConfigFile is the CLI config file holding named connections.
+	ConfigFile string
+	// Profiles holds the connections loaded from ConfigFile.
+	Profiles *Profiles
 
 	Verbose bool
 	Quiet   bool
+
+	// ErrorFormat controls how errors are printed (text, json).
+	ErrorFormat string
+
+	flagSet *pflag.FlagSet
 }
 
 func NewConfig() *Config {
@@ -29,22 +41,98 @@ func NewFlagSet(name string) *pflag.FlagSet {
 func (c *Config) ParseFlags() ([]string, error) {
 	flagSet := NewFlagSet("Config")
 	flagSet.StringVar(&c.DSN, "db-dsn", os.Getenv("ETL_DB_DSN"), "Database DSN (mysql://, postgres://, sqlite://, or driver-specific format)")
-	flagSet.StringVarP(&c.Folder, "folder", "f", "output", "Folder with outputs")
-	flagSet.BoolVarP(&c.Verbose, "verbose", "v", false, "Folder with outputs")
+	flagSet.StringVar(&c.Connection, "db", os.Getenv("ETL_DB"), "Named connection from the config file")
+	flagSet.StringVar(&c.ConfigFile, "config", os.Getenv("ETL_CONFIG"), "Config file with named connections (default "+DefaultProfilesPath()+")")
+	flagSet.BoolVarP(&c.Verbose, "verbose", "v", false, "Verbose output")
 	flagSet.BoolVarP(&c.Quiet, "quiet", "q", false, "Quiet output")
+	flagSet.StringVar(&c.ErrorFormat, "error-format", "text", "Error output format (text, json)")
 
 	k, u := filterKnownArgs(flagSet, os.Args[1:])
 
 	err := flagSet.Parse(k)
 	if err != nil {
-		return nil, err
+		return nil, NewError(ClassInput, err)
 	}
+	c.flagSet = flagSet
 
 	result := flagSet.Args()
 
 	return append(result, u...), nil
 }
 
+// LoadConfigFile loads the named connections from the config file.
+// The default config file is optional, one given with --config isn't.
+func (c *Config) LoadConfigFile() error {
+	filename, optional := c.ConfigFile, false
+	if filename == "" {
+		filename, optional = DefaultProfilesPath(), true
+	}
+
+	profiles, err := LoadProfiles(filename, optional)
+	if err != nil {
+		return NewError(ClassInput, err)
+	}
+	c.Profiles = profiles
+	return nil
+}
+
+// LoadConnection loads the config file and applies the selected
+// connection profile. The DSN is resolved in order of precedence:
+// the --db-dsn flag, the --db connection, ETL_DB_DSN and finally
+// the default connection from the config file. Connection defaults
+// only apply to flags which weren't set explicitly.
+func (c *Config) LoadConnection() error {
+	if err := c.LoadConfigFile(); err != nil {
+		return err
+	}
+	if err := c.loadConnection(); err != nil {
+		return NewError(ClassInput, err)
+	}
+	return nil
+}
+
+func (c *Config) loadConnection() error {
+	flagSet, profiles := c.flagSet, c.Profiles
+	if flagSet == nil {
+		flagSet = NewFlagSet("Config")
+	}
+
+	name := c.Connection
+	switch {
+	case flagSet.Changed("db-dsn"):
+		return nil
+	case name == "" && c.DSN != "":
+		return nil
+	case name == "":
+		name = profiles.Default
+	}
+	if name == "" {
+		return nil
+	}
+
+	conn, err := profiles.Get(name)
+	if err != nil {
+		return err
+	}
+
+	c.Connection = name
+	c.DSN, err = conn.ConnectionString()
+	if err != nil {
+		return fmt.Errorf("connection %s: %w", name, err)
+	}
+
+	if !flagSet.Changed("verbose") {
+		c.Verbose = conn.Verbose
+	}
+	if !flagSet.Changed("quiet") {
+		c.Quiet = conn.Quiet
+	}
+	if !flagSet.Changed("error-format") && conn.ErrorFormat != "" {
+		c.ErrorFormat = conn.ErrorFormat
+	}
+	return nil
+}
+
 // filterKnownArgs separates known flags from unknown ones
 func filterKnownArgs(flagSet *pflag.FlagSet, args []string) (knownArgs, unknownArgs []string) {
 	isKnownFlag := func(f string) bool {
@@ -65,7 +153,7 @@ func filterKnownArgs(flagSet *pflag.FlagSet, args []string) (knownArgs, unknownA
 			continue
 		}
 
-		flagName := strings.TrimPrefix(arg, "-")
+		flagName := strings.TrimLeft(arg, "-")
 		if strings.Contains(flagName, "=") {
 			flagName = strings.SplitN(flagName, "=", 2)[0]
 		}
@@ -0,0 +1,98 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrorClass describes the category of an error returned by a command.
+type ErrorClass string
+
+const (
+	// ClassError is used for errors which could not be classified.
+	ClassError ErrorClass = "error"
+	// ClassInput is used for invalid arguments, flags or input data.
+	ClassInput ErrorClass = "input"
+	// ClassNoRows is used when a query returned no rows.
+	ClassNoRows ErrorClass = "no_rows"
+	// ClassSQL is used for errors reported by the database for a statement.
+	ClassSQL ErrorClass = "sql"
+	// ClassConstraint is used for unique, foreign key, not null and check violations.
+	ClassConstraint ErrorClass = "constraint"
+	// ClassConnection is used when the database can't be reached.
+	ClassConnection ErrorClass = "connection"
+)
+
+// Exit codes returned by the etl binary for each error class.
+const (
+	ExitOK         = 0
+	ExitError      = 1
+	ExitInput      = 2
+	ExitNoRows     = 3
+	ExitSQL        = 4
+	ExitConstraint = 5
+	ExitConnection = 6
+)
+
+var exitCodes = map[ErrorClass]int{
+	ClassError:      ExitError,
+	ClassInput:      ExitInput,
+	ClassNoRows:     ExitNoRows,
+	ClassSQL:        ExitSQL,
+	ClassConstraint: ExitConstraint,
+	ClassConnection: ExitConnection,
+}
+
+// Error is a classified command error. It carries the driver error
+// code (SQLSTATE, MySQL error number or SQLite result code) and the
+// statement that caused it, if known.
+type Error struct {
+	Class     ErrorClass `json:"class"`
+	Code      string     `json:"code,omitempty"`
+	Statement string     `json:"statement,omitempty"`
+	Message   string     `json:"message"`
+
+	Err error `json:"-"`
+}
+
+// NewError creates a new *Error of the given class.
+func NewError(class ErrorClass, err error) *Error {
+	return &Error{
+		Class:   class,
+		Message: err.Error(),
+		Err:     err,
+	}
+}
+
+// InputError creates a new ClassInput error from a format string.
+func InputError(format string, args ...any) *Error {
+	return NewError(ClassInput, fmt.Errorf(format, args...))
+}
+
+// Error returns the underlying error message.
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Unwrap returns the underlying error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// ExitCode returns the process exit code for the error class.
+func (e *Error) ExitCode() int {
+	if code, ok := exitCodes[e.Class]; ok {
+		return code
+	}
+	return ExitError
+}
+
+// AsError returns err as an *Error. Unclassified errors are
+// returned with ClassError.
+func AsError(err error) *Error {
+	var result *Error
+	if errors.As(err, &result) {
+		return result
+	}
+	return NewError(ClassError, err)
+}
@@ -0,0 +1,39 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestExitCode verifies the exit code of each error class.
+func TestExitCode(t *testing.T) {
+	tests := map[ErrorClass]int{
+		ClassError:      1,
+		ClassInput:      2,
+		ClassNoRows:     3,
+		ClassSQL:        4,
+		ClassConstraint: 5,
+		ClassConnection: 6,
+		"unknown":       1,
+	}
+
+	for class, code := range tests {
+		require.Equal(t, code, NewError(class, errors.New("failed")).ExitCode(), class)
+	}
+}
+
+// TestAsError verifies that wrapped errors keep their class.
+func TestAsError(t *testing.T) {
+	input := InputError("usage: %s", "etl")
+	require.Same(t, input, AsError(fmt.Errorf("command: %w", input)))
+	require.Equal(t, "usage: etl", input.Error())
+
+	err := errors.New("boom")
+	result := AsError(err)
+	require.Equal(t, ClassError, result.Class)
+	require.Equal(t, "boom", result.Message)
+	require.ErrorIs(t, result, err)
+}
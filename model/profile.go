@@ -0,0 +1,174 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+)
+
+// Profiles is the CLI configuration file, holding named connections.
+//
+// Example `~/.config/etl/config.yml`:
+//
+//	default: dev
+//	connections:
+//	  dev:
+//	    dsn: sqlite://file:dev.db
+//	  staging:
+//	    dsn: postgres://app@staging:5432/app
+//	    passwordFile: ~/.secrets/staging
+//	    errorFormat: json
+//	  replica:
+//	    dsn: mysql://app@tcp(replica:3306)/app
+//	    passwordEnv: REPLICA_PASSWORD
+//	    quiet: true
+type Profiles struct {
+	// Default is the connection used when none is selected with --db.
+	Default string `yaml:"default"`
+
+	// Connections holds the named connection profiles.
+	Connections map[string]*Connection `yaml:"connections"`
+}
+
+// Connection is a named connection profile with per-connection defaults.
+type Connection struct {
+	// DSN is the database connection string, usually without a password.
+	DSN string `yaml:"dsn"`
+
+	// PasswordFile reads the password from a file (trailing whitespace is trimmed).
+	PasswordFile string `yaml:"passwordFile,omitempty"`
+
+	// PasswordEnv reads the password from an environment variable.
+	PasswordEnv string `yaml:"passwordEnv,omitempty"`
+
+	// Verbose enables verbose output by default for this connection.
+	Verbose bool `yaml:"verbose,omitempty"`
+
+	// Quiet enables quiet output by default for this connection.
+	Quiet bool `yaml:"quiet,omitempty"`
+
+	// ErrorFormat sets the default error format (text, json).
+	ErrorFormat string `yaml:"errorFormat,omitempty"`
+}
+
+// DefaultProfilesPath returns the default CLI config file location.
+func DefaultProfilesPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "etl", "config.yml")
+}
+
+// LoadProfiles reads the CLI config file. A missing file returns
+// empty profiles if optional is true.
+func LoadProfiles(filename string, optional bool) (*Profiles, error) {
+	result := &Profiles{
+		Connections: map[string]*Connection{},
+	}
+	if filename == "" {
+		return result, nil
+	}
+
+	data, err := os.ReadFile(expandHome(filename))
+	if err != nil {
+		if optional && errors.Is(err, fs.ErrNotExist) {
+			return result, nil
+		}
+		return nil, err
+	}
+
+	if err := yaml.Unmarshal(data, result); err != nil {
+		return nil, fmt.Errorf("error decoding %s: %w", filename, err)
+	}
+	if result.Connections == nil {
+		result.Connections = map[string]*Connection{}
+	}
+	return result, nil
+}
+
+// Names returns the sorted connection names.
+func (p *Profiles) Names() []string {
+	result := make([]string, 0, len(p.Connections))
+	for name := range p.Connections {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// Get returns a named connection.
+func (p *Profiles) Get(name string) (*Connection, error) {
+	conn, ok := p.Connections[name]
+	if !ok || conn == nil {
+		return nil, fmt.Errorf("unknown connection: %s, configured %v", name, p.Names())
+	}
+	return conn, nil
+}
+
+// ConnectionString returns the DSN with the password filled in
+// from PasswordFile or PasswordEnv.
+func (c *Connection) ConnectionString() (string, error) {
+	var password string
+	switch {
+	case c.PasswordFile != "":
+		contents, err := os.ReadFile(expandHome(c.PasswordFile))
+		if err != nil {
+			return "", err
+		}
+		password = strings.TrimSpace(string(contents))
+	case c.PasswordEnv != "":
+		password = os.Getenv(c.PasswordEnv)
+		if password == "" {
+			return "", fmt.Errorf("password environment variable %s is empty", c.PasswordEnv)
+		}
+	default:
+		return c.DSN, nil
+	}
+
+	return withPassword(c.DSN, password), nil
+}
+
+// withPassword sets the password in the userinfo part of the DSN.
+func withPassword(dsn, password string) string {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		u, err := url.Parse(dsn)
+		if err == nil && u.User != nil {
+			u.User = url.UserPassword(u.User.Username(), password)
+			return u.String()
+		}
+	}
+
+	scheme, rest, ok := strings.Cut(dsn, "://")
+	if !ok {
+		scheme, rest = "", dsn
+	}
+
+	userinfo, address, ok := strings.Cut(rest, "@")
+	if !ok {
+		return dsn
+	}
+	user, _, _ := strings.Cut(userinfo, ":")
+
+	result := user + ":" + password + "@" + address
+	if scheme != "" {
+		result = scheme + "://" + result
+	}
+	return result
+}
+
+func expandHome(filename string) string {
+	if rest, ok := strings.CutPrefix(filename, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, rest)
+		}
+	}
+	return filename
+}
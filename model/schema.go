@@ -0,0 +1,148 @@
+package model
+
+import (
+	"slices"
+	"sort"
+)
+
+// Table describes a table schema as returned by driver introspection.
+type Table struct {
+	Name        string       `json:"name"`
+	Columns     []Column     `json:"columns"`
+	PrimaryKey  []string     `json:"primaryKey,omitempty"`
+	Unique      [][]string   `json:"unique,omitempty"`
+	ForeignKeys []ForeignKey `json:"foreignKeys,omitempty"`
+}
+
+// Column describes a table column.
+type Column struct {
+	Name string `json:"name"`
+
+	// Type is the column type as reported by the database.
+	Type string `json:"type"`
+
+	Nullable      bool    `json:"nullable,omitempty"`
+	Default       *string `json:"default,omitempty"`
+	AutoIncrement bool    `json:"autoIncrement,omitempty"`
+}
+
+// ForeignKey describes a foreign key constraint.
+type ForeignKey struct {
+	Columns    []string `json:"columns"`
+	Table      string   `json:"table"`
+	References []string `json:"references"`
+}
+
+// Column returns a column by name.
+func (t *Table) Column(name string) (Column, bool) {
+	for _, column := range t.Columns {
+		if column.Name == name {
+			return column, true
+		}
+	}
+	return Column{}, false
+}
+
+// ColumnNames returns the column names in table order.
+func (t *Table) ColumnNames() []string {
+	result := make([]string, 0, len(t.Columns))
+	for _, column := range t.Columns {
+		result = append(result, column.Name)
+	}
+	return result
+}
+
+// IsUnique returns true if the column alone is a primary or unique key.
+func (t *Table) IsUnique(name string) bool {
+	if len(t.PrimaryKey) == 1 && t.PrimaryKey[0] == name {
+		return true
+	}
+	for _, unique := range t.Unique {
+		if len(unique) == 1 && unique[0] == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ForeignKey returns the single column foreign key for a column.
+func (t *Table) ForeignKey(name string) (ForeignKey, bool) {
+	for _, fk := range t.ForeignKeys {
+		if len(fk.Columns) == 1 && fk.Columns[0] == name {
+			return fk, true
+		}
+	}
+	return ForeignKey{}, false
+}
+
+// SortTables orders tables so that referenced tables come before the
+// tables referencing them. Tables in a reference cycle are appended
+// in name order after the tables which could be ordered.
+func SortTables(tables []*Table) []*Table {
+	byName := make(map[string]*Table, len(tables))
+	for _, table := range tables {
+		byName[table.Name] = table
+	}
+
+	names := make([]string, 0, len(tables))
+	for _, table := range tables {
+		names = append(names, table.Name)
+	}
+	sort.Strings(names)
+
+	result := make([]*Table, 0, len(tables))
+	done := make(map[string]bool, len(tables))
+
+	for len(result) < len(tables) {
+		progress := false
+		for _, name := range names {
+			if done[name] {
+				continue
+			}
+
+			ready := true
+			for _, fk := range byName[name].ForeignKeys {
+				if fk.Table == name || done[fk.Table] {
+					continue
+				}
+				if _, ok := byName[fk.Table]; ok {
+					ready = false
+					break
+				}
+			}
+
+			if ready {
+				result = append(result, byName[name])
+				done[name] = true
+				progress = true
+			}
+		}
+
+		if !progress {
+			for _, name := range names {
+				if !done[name] {
+					result = append(result, byName[name])
+					done[name] = true
+				}
+			}
+		}
+	}
+
+	return result
+}
+
+// FilterTables returns the tables matching include, minus the tables in exclude.
+// An empty include list matches all tables.
+func FilterTables(tables []*Table, include, exclude []string) []*Table {
+	result := make([]*Table, 0, len(tables))
+	for _, table := range tables {
+		if len(include) > 0 && !slices.Contains(include, table.Name) {
+			continue
+		}
+		if slices.Contains(exclude, table.Name) {
+			continue
+		}
+		result = append(result, table)
+	}
+	return result
+}
@@ -0,0 +1,58 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestSortTables verifies that referenced tables come first, and that cycles are kept.
+func TestSortTables(t *testing.T) {
+	table := func(name string, refs ...string) *Table {
+		result := &Table{Name: name}
+		for _, ref := range refs {
+			result.ForeignKeys = append(result.ForeignKeys, ForeignKey{Columns: []string{ref + "_id"}, Table: ref, References: []string{"id"}})
+		}
+		return result
+	}
+	names := func(tables []*Table) []string {
+		result := make([]string, 0, len(tables))
+		for _, table := range tables {
+			result = append(result, table.Name)
+		}
+		return result
+	}
+
+	tests := []struct {
+		name   string
+		tables []*Table
+		want   []string
+	}{
+		{
+			name:   "foreign key order",
+			tables: []*Table{table("order_item", "orders", "product"), table("orders", "users"), table("users"), table("product")},
+			want:   []string{"product", "users", "orders", "order_item"},
+		},
+		{
+			name:   "self reference",
+			tables: []*Table{table("category", "category"), table("post", "category")},
+			want:   []string{"category", "post"},
+		},
+		{
+			name:   "missing reference",
+			tables: []*Table{table("orders", "users")},
+			want:   []string{"orders"},
+		},
+		{
+			name:   "cycle",
+			tables: []*Table{table("b", "a"), table("a", "b"), table("c", "a"), table("d")},
+			want:   []string{"d", "a", "b", "c"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, names(SortTables(tt.tables)))
+		})
+	}
+}
@@ -11,17 +11,31 @@ import (
 
 // Decode unmarshals YAML data into a Config struct.
 // If fsys is provided, it will also process any included files.
+// Storage references are resolved against the storages map.
 // Environment variables (ETL_DB_DSN) override YAML config.
 func Decode(fsys fs.FS, data []byte) (*Config, error) {
-	cfg := &Config{}
-	err := yaml.Unmarshal(data, cfg)
+	cfg, err := decode(fsys, data)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := cfg.resolveStorages(); err != nil {
+		return nil, err
+	}
+
 	// Apply environment variable overrides for storage config
 	applyStorageEnvOverrides(cfg)
 
+	return cfg, nil
+}
+
+func decode(fsys fs.FS, data []byte) (*Config, error) {
+	cfg := &Config{}
+	err := yaml.Unmarshal(data, cfg)
+	if err != nil {
+		return nil, err
+	}
+
 	// Process includes if fsys is provided
 	if fsys != nil && len(cfg.Include) > 0 {
 		for _, includePath := range cfg.Include {
@@ -32,7 +46,7 @@ func Decode(fsys fs.FS, data []byte) (*Config, error) {
 			}
 
 			// Decode the included config recursively
-			includeCfg, err := Decode(fsys, includeData)
+			includeCfg, err := decode(fsys, includeData)
 			if err != nil {
 				return nil, fmt.Errorf("failed to decode include file %s: %w", includePath, err)
 			}
@@ -56,14 +70,34 @@ func Decode(fsys fs.FS, data []byte) (*Config, error) {
 				}
 			}
 
+			if includeCfg.Server.Dev {
+				cfg.Server.Dev = true
+			}
+			if includeCfg.Server.Timeout != "" {
+				cfg.Server.Timeout = includeCfg.Server.Timeout
+			}
+
+			if includeCfg.Server.Cache != nil {
+				cfg.Server.Cache = includeCfg.Server.Cache
+			}
+			if includeCfg.Server.RateLimit != nil {
+				cfg.Server.RateLimit = includeCfg.Server.RateLimit
+			}
+
+			// Merge named storages (later includes override)
+			for name, storage := range includeCfg.Storages {
+				if cfg.Storages == nil {
+					cfg.Storages = make(map[string]*Storage)
+				}
+				cfg.Storages[name] = storage
+			}
+
 			// Merge storage (later includes override)
 			if includeCfg.Storage != nil {
 				if cfg.Storage == nil {
 					cfg.Storage = clone.Clone(includeCfg.Storage).(*Storage)
 				} else {
-					if includeCfg.Storage.DSN != "" {
-						cfg.Storage.DSN = includeCfg.Storage.DSN
-					}
+					cfg.Storage.merge(includeCfg.Storage)
 				}
 			}
 		}
@@ -72,7 +106,73 @@ func Decode(fsys fs.FS, data []byte) (*Config, error) {
 	return cfg, nil
 }
 
+// merge copies the fields set in other. A reference
+// to a named storage replaces the storage.
+func (s *Storage) merge(other *Storage) {
+	if other.Ref != "" {
+		*s = Storage{Ref: other.Ref}
+		return
+	}
+	if other.DSN != "" {
+		s.Ref = ""
+		s.DSN = other.DSN
+	}
+	if other.MaxOpenConns != 0 {
+		s.MaxOpenConns = other.MaxOpenConns
+	}
+	if other.MaxIdleConns != 0 {
+		s.MaxIdleConns = other.MaxIdleConns
+	}
+	if other.ConnMaxLifetime != "" {
+		s.ConnMaxLifetime = other.ConnMaxLifetime
+	}
+	if other.ConnectTimeout != "" {
+		s.ConnectTimeout = other.ConnectTimeout
+	}
+	if other.Replica != "" {
+		s.Replica = other.Replica
+	}
+}
+
+// resolveStorages replaces storage references with the named storages.
+func (c *Config) resolveStorages() error {
+	for name, storage := range c.Storages {
+		if storage == nil || storage.Ref != "" {
+			return fmt.Errorf("storage %s: named storages must configure a dsn", name)
+		}
+		if storage.Replica != "" {
+			if _, ok := c.Storages[storage.Replica]; !ok {
+				return fmt.Errorf("storage %s: unknown replica storage %q", name, storage.Replica)
+			}
+		}
+	}
+
+	var err error
+	if c.Storage, err = c.ResolveStorage(c.Storage); err != nil {
+		return err
+	}
+
+	for _, endpoint := range c.Endpoints {
+		if endpoint.Handler.Storage, err = c.ResolveStorage(endpoint.Handler.Storage); err != nil {
+			return fmt.Errorf("endpoint %s: %w", endpoint.Path, err)
+		}
+	}
+
+	if c.Server.Cache != nil {
+		if c.Server.Cache.Storage, err = c.ResolveStorage(c.Server.Cache.Storage); err != nil {
+			return fmt.Errorf("server cache: %w", err)
+		}
+	}
+	if c.Server.RateLimit != nil {
+		if c.Server.RateLimit.Storage, err = c.ResolveStorage(c.Server.RateLimit.Storage); err != nil {
+			return fmt.Errorf("server rateLimit: %w", err)
+		}
+	}
+	return nil
+}
+
 // applyStorageEnvOverrides applies environment variable overrides for storage configuration.
+// If the default storage references a named storage, the named storage is overridden.
 // Environment variable: ETL_DB_DSN
 func applyStorageEnvOverrides(cfg *Config) {
 	if cfg.Storage == nil {
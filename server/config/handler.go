@@ -16,6 +16,9 @@ type Handler struct {
 	// Function is an optional function name parameter for a handler.
 	Function string `yaml:"function,omitempty"`
 
+	// Storage overrides the default storage for the endpoint.
+	Storage *Storage `yaml:"storage,omitempty"`
+
 	// Request specifies the upstream request handler path to invoke (for request type handlers).
 	// Supports path parameters with brace syntax: /api/users/{id}
 	Request []*Request `yaml:"request,omitempty"`
@@ -32,25 +35,53 @@ type Handler struct {
 	// Parameters are static parameters merged with request parameters.
 	Parameters map[string]interface{} `yaml:"parameters,omitempty"`
 
+	// Filter allows requests to filter and sort the results of the endpoint query.
+	Filter *Filter `yaml:"filter,omitempty"`
+
+	// Pagination pages the results of the endpoint query.
+	Pagination *Pagination `yaml:"pagination,omitempty"`
+
+	// Columns overrides the JSON types of result columns by name, where
+	// the driver reports them ambiguously, e.g. {"active": "bool"}.
+	Columns map[string]string `yaml:"columns,omitempty"`
+
+	// Inputs declare and validate the request parameters. Invalid
+	// requests get a 400 response listing the errors for each field.
+	Inputs []*Input `yaml:"inputs,omitempty"`
+
 	// Transaction configures transactional behavior for write operations.
 	Transaction *Transaction `yaml:"transaction,omitempty"`
 
+	// Timeout limits the time the endpoint takes to respond, e.g. "5s",
+	// overriding the server timeout. Running queries are cancelled, and
+	// the request gets a 504 response. "0" doesn't limit the time.
+	Timeout string `yaml:"timeout,omitempty"`
+
 	// Cache configures response caching behavior.
 	Cache *Cache `yaml:"cache,omitempty"`
 
+	// Invalidates lists cache tags to delete after a successful write,
+	// e.g. "user:{id}" or "users:all". Placeholders are filled like the
+	// cache keyPattern. Tables written by the queries are invalidated
+	// without being listed.
+	Invalidates []string `yaml:"invalidates,omitempty"`
+
 	// RateLimit configures rate limiting for the endpoint.
 	RateLimit *RateLimit `yaml:"rateLimit,omitempty"`
 
 	// Response configures the response format and headers.
 	Response *Response `yaml:"response,omitempty"`
 
+	// Errors overrides the error responses by kind, e.g. "unique" or "not_found".
+	Errors map[string]*ErrorResponse `yaml:"errors,omitempty"`
+
 	decoder func(interface{}) error
 }
 
 // QueryDef represents a single query in a query pipeline.
 type QueryDef struct {
 	// Query is the SQL query to execute.
-	Query string `yaml:"query"`
+	Query string `yaml:"query,omitempty"`
 
 	// As is the path where the result should be stored in the response.
 	// If empty, result is merged into the current scope.
@@ -61,21 +92,211 @@ type QueryDef struct {
 	If string `yaml:"if,omitempty"`
 
 	// For is a loop expression to execute the query for each item.
-	// Format: (idx, item) in items
+	// Format: (idx, item) in items, where items is a name in the
+	// scope or an expression, e.g. split(tags, ",").
 	// Results are placed at the path specified in As.
 	For string `yaml:"for,omitempty"`
+
+	// Set assigns the results of expressions to paths in the scope,
+	// e.g. `email: lower(trim(email))`, instead of running a query.
+	// The expressions see the scope from before the step.
+	Set map[string]string `yaml:"set,omitempty"`
+
+	// Assert is a condition expression which must be true, e.g.
+	// `user != nil`. Otherwise the pipeline stops with an error
+	// response, and a pipeline transaction is rolled back.
+	Assert string `yaml:"assert,omitempty"`
+
+	// Fail stops the pipeline with an error response, if the step
+	// runs. It's used with If.
+	Fail bool `yaml:"fail,omitempty"`
+
+	// Status is the HTTP status of the error response of Assert or
+	// Fail. Defaults to 400.
+	Status int `yaml:"status,omitempty"`
+
+	// Message is the detail of the error response of Assert or Fail.
+	Message string `yaml:"message,omitempty"`
+
+	// Batch runs the query of a For loop once for all the items,
+	// instead of once for each item.
+	Batch *Batch `yaml:"batch,omitempty"`
+}
+
+// Batch loads the results of a loop query for all the items at once,
+// and stores the rows on the items with a matching key.
+type Batch struct {
+	// Key is an expression evaluated for each item, e.g. `order.id`.
+	// The distinct keys are bound in place of :keys in the query, e.g.
+	// `WHERE order_id IN (:keys)`. Items with a nil key are skipped.
+	Key string `yaml:"key"`
+
+	// Column is the result column matching the key, e.g. "order_id".
+	Column string `yaml:"column"`
+
+	// Single stores the first matching row on each item, or nil,
+	// instead of the list of matching rows.
+	Single bool `yaml:"single,omitempty"`
+
+	// Size is the maximum number of keys in a query, 500 by default.
+	// More keys are loaded with several queries.
+	Size int `yaml:"size,omitempty"`
+}
+
+// ErrorResponse overrides the problem response for a kind of error.
+// Empty fields keep the default values.
+type ErrorResponse struct {
+	// Status is the HTTP status code.
+	Status int `yaml:"status,omitempty"`
+
+	// Type is a URI identifying the problem type.
+	Type string `yaml:"type,omitempty"`
+
+	// Title is a short summary of the problem type.
+	Title string `yaml:"title,omitempty"`
+
+	// Detail explains the problem to the client.
+	Detail string `yaml:"detail,omitempty"`
 }
 
+// Input sources for request parameters.
+const (
+	InputPath   = "path"
+	InputQuery  = "query"
+	InputBody   = "body"
+	InputHeader = "header"
+)
+
+// Input types for request parameters.
+const (
+	InputString = "string"
+	InputInt    = "int"
+	InputFloat  = "float"
+	InputBool   = "bool"
+)
+
+// Input declares a request parameter, where it is read from, its type
+// and the constraints on its value.
+type Input struct {
+	// Name is the parameter name used in queries, e.g. `:id`.
+	Name string `yaml:"name"`
+
+	// Source is where the value is read from: "path", "query", "body" or "header".
+	// If empty, the path, query and body are searched like for undeclared parameters.
+	Source string `yaml:"source,omitempty"`
+
+	// Key is the name of the value in the source, e.g. a header name. Defaults to Name.
+	Key string `yaml:"key,omitempty"`
+
+	// Type is the value type: "string" (default), "int", "float" or "bool".
+	// Values are converted to the type before they are bound to queries.
+	Type string `yaml:"type,omitempty"`
+
+	// Required rejects requests without the value.
+	Required bool `yaml:"required,omitempty"`
+
+	// Default is the value used if the request has none.
+	Default any `yaml:"default,omitempty"`
+
+	// Enum lists the allowed values.
+	Enum []any `yaml:"enum,omitempty"`
+
+	// Regex is a pattern the value must match.
+	Regex string `yaml:"regex,omitempty"`
+
+	// Min and Max bound a number, or the length of a string.
+	Min *float64 `yaml:"min,omitempty"`
+	Max *float64 `yaml:"max,omitempty"`
+}
+
+// Transaction modes.
+const (
+	TransactionQuery    = "query"
+	TransactionPipeline = "pipeline"
+)
+
 // Transaction configures transactional behavior for write operations.
 type Transaction struct {
 	// Enabled indicates whether transactions should be used.
 	Enabled bool `yaml:"enabled"`
 
-	// Retries specifies the number of times to retry a failed transaction commit.
+	// Mode is "query" to run each write query in its own transaction,
+	// or "pipeline" to run all the queries of the endpoint in one
+	// transaction. Defaults to "query".
+	Mode string `yaml:"mode,omitempty"`
+
+	// Isolation sets the isolation level, e.g. "read committed" or
+	// "serializable". Defaults to the database default.
+	Isolation string `yaml:"isolation,omitempty"`
+
+	// ReadOnly starts read only transactions. In pipeline mode, they
+	// run on the replica storage if configured.
+	ReadOnly bool `yaml:"readOnly,omitempty"`
+
+	// Retries specifies the number of times to retry a transaction which
+	// failed with a transient error, like a deadlock or a serialization
+	// failure. In pipeline mode, the whole pipeline is replayed.
 	Retries int `yaml:"retries"`
 
-	// RetryDelayMs specifies the delay in milliseconds between retry attempts.
+	// RetryDelayMs specifies the delay in milliseconds before the first
+	// retry. The delay doubles for each retry, with random jitter.
 	RetryDelayMs int `yaml:"retryDelayMs"`
+
+	// MaxElapsedMs stops retrying once the time in milliseconds since
+	// the first attempt would pass it. Zero doesn't limit the time.
+	MaxElapsedMs int `yaml:"maxElapsedMs,omitempty"`
+}
+
+// Filter declares the columns requests can filter and sort by, e.g.
+// `?status=eq:active&age=gte:3&sort=-created_at,name`.
+type Filter struct {
+	// Fields maps the filterable columns to the operators allowed,
+	// e.g. status: [eq, in]. An empty list allows all operators.
+	Fields map[string][]string `yaml:"fields,omitempty"`
+
+	// Sort lists the columns requests can sort by.
+	Sort []string `yaml:"sort,omitempty"`
+
+	// DefaultSort is the sort order if the request doesn't set one, e.g. [-created_at].
+	DefaultSort []string `yaml:"defaultSort,omitempty"`
+}
+
+// Pagination modes.
+const (
+	PaginationOffset = "offset"
+	PaginationCursor = "cursor"
+)
+
+// Pagination configures paging of the endpoint results. Requests
+// select a page with the `limit` and `offset` or `cursor` parameters.
+type Pagination struct {
+	// Mode is "offset" for limit/offset paging, or "cursor" for keyset
+	// paging. Defaults to offset.
+	Mode string `yaml:"mode,omitempty"`
+
+	// Keys are the columns the pages are sorted by, e.g. [created_at, id].
+	// Prefix a column with "-" to sort descending. Keys are required,
+	// and the last key should be unique and not null.
+	Keys []string `yaml:"keys,omitempty"`
+
+	// Limit is the default page size. Defaults to 20.
+	Limit int `yaml:"limit,omitempty"`
+
+	// MaxLimit is the largest page size a request can ask for. Defaults to 100.
+	MaxLimit int `yaml:"maxLimit,omitempty"`
+
+	// Count runs a count query to return the total number of rows.
+	Count bool `yaml:"count,omitempty"`
+
+	// Envelope returns an object with the rows in `data`, and the `next`,
+	// `prev` and `total` values. Otherwise the rows are returned as an
+	// array, with Link and X-Total-Count headers.
+	Envelope bool `yaml:"envelope,omitempty"`
+
+	// Secret signs the cursors, and is required in cursor mode. Use
+	// the same secret on all instances, so cursors stay valid between
+	// them and over restarts.
+	Secret string `yaml:"secret,omitempty"`
 }
 
 // Cache configures response caching behavior.
@@ -87,8 +308,18 @@ type Cache struct {
 	// Supports all time.Duration formats. Defaults to 5 minutes if not specified.
 	Expire string `yaml:"expire"`
 
-	// KeyPattern specifies the cache key pattern (supports path and query parameters).
+	// KeyPattern specifies the cache key pattern, e.g. "user:{id}". Placeholders
+	// are filled from path parameters, falling back to query parameters.
+	// Defaults to a hash of the endpoint path and request URI.
 	KeyPattern string `yaml:"keyPattern"`
+
+	// StaleWhileRevalidate is how long after expire a cached response is
+	// still served, while one background request refreshes it (e.g., "1m").
+	StaleWhileRevalidate string `yaml:"staleWhileRevalidate,omitempty"`
+
+	// StaleIfError is how long after expire a cached response is served
+	// in place of a server error (e.g., "1h").
+	StaleIfError string `yaml:"staleIfError,omitempty"`
 }
 
 // RateLimit configures rate limiting for the endpoint.
@@ -107,6 +338,23 @@ type RateLimit struct {
 	// Supports all time.Duration formats. Defaults to "1m" (one minute) if not specified.
 	// Example: "30s" means 30 seconds, "5m" means 5 minutes
 	Per string `yaml:"per" json:"per"`
+
+	// Key selects how clients are told apart: "ip" (default), "header",
+	// "subject" (the sub claim of a JWT bearer token signed with Secret)
+	// or "global" for a single limit shared by all clients. Requests
+	// without the header or a valid token are limited by IP.
+	Key string `yaml:"key,omitempty" json:"key,omitempty"`
+
+	// Header is the request header name used with key "header", e.g. "X-Api-Key".
+	Header string `yaml:"header,omitempty" json:"header,omitempty"`
+
+	// Secret is the HS256 key verifying JWT bearer tokens with key "subject".
+	Secret string `yaml:"secret,omitempty" json:"-"`
+
+	// TrustedProxies lists the proxies (CIDR ranges or addresses) allowed
+	// to set X-Forwarded-For and X-Real-IP. Without it, clients are told
+	// apart by the address they connect from.
+	TrustedProxies []string `yaml:"trustedProxies,omitempty" json:"trustedProxies,omitempty"`
 }
 
 // Request configures the request format and headers.
@@ -2,14 +2,21 @@ package loader
 
 import (
 	"fmt"
+	"io/fs"
 )
 
 // Cache is the interface any loader must implement.
-type Cache interface {
+type Cache = FileCache[Config]
+
+// FileCache loads files decoded as T, caching them by its policy.
+type FileCache[T any] interface {
 	fmt.Stringer
-	Get(filename string) (*Config, error)
+	Get(filename string) (*T, error)
 }
 
+// DecodeFunc decodes a file, reading the files it includes from storage.
+type DecodeFunc[T any] func(storage fs.FS, data []byte) (*T, error)
+
 type internalCache interface {
 	set(filename string, data []byte) error
 }
@@ -8,32 +8,39 @@ import (
 )
 
 // CacheClone holds the cached configuration data as a decoded value.
-type CacheClone struct {
-	config *Config
+type CacheClone[T any] struct {
+	config *T
 }
 
 // CacheCloneManager manages a cache that clones the configuration data on retrieval.
-type CacheCloneManager struct {
+type CacheCloneManager[T any] struct {
 	mu      sync.RWMutex
 	storage fs.FS
-	entries map[string]CacheClone
+	entries map[string]CacheClone[T]
+	decode  DecodeFunc[T]
 }
 
 // NewCacheCloneManager creates a new CacheCloneManager, which caches the configuration data and clones it on retrieval.
-func NewCacheCloneManager(storage fs.FS) *CacheCloneManager {
-	return &CacheCloneManager{
+func NewCacheCloneManager(storage fs.FS) *CacheCloneManager[Config] {
+	return NewCacheCloneManagerFor(storage, Decode)
+}
+
+// NewCacheCloneManagerFor creates a new CacheCloneManager for files decoded with decode.
+func NewCacheCloneManagerFor[T any](storage fs.FS, decode DecodeFunc[T]) *CacheCloneManager[T] {
+	return &CacheCloneManager[T]{
 		storage: storage,
-		entries: make(map[string]CacheClone),
+		entries: make(map[string]CacheClone[T]),
+		decode:  decode,
 	}
 }
 
 // Get retrieves the cached configuration if available, or loads it if not, and returns a cloned copy.
-func (c *CacheCloneManager) Get(filename string) (*Config, error) {
+func (c *CacheCloneManager[T]) Get(filename string) (*T, error) {
 	c.mu.RLock()
 	cacheEntry, exists := c.entries[filename]
 	if exists {
 		defer c.mu.RUnlock()
-		return clone.Clone(cacheEntry.config).(*Config), nil
+		return clone.Clone(cacheEntry.config).(*T), nil
 	}
 	c.mu.RUnlock()
 
@@ -41,22 +48,22 @@ func (c *CacheCloneManager) Get(filename string) (*Config, error) {
 }
 
 // Set stores the configuration in the cache.
-func (c *CacheCloneManager) set(filename string, data []byte) error {
-	cfg, err := Decode(c.storage, data)
+func (c *CacheCloneManager[T]) set(filename string, data []byte) error {
+	cfg, err := c.decode(c.storage, data)
 	if err != nil {
 		return err
 	}
 
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	c.entries[filename] = CacheClone{
+	c.entries[filename] = CacheClone[T]{
 		config: cfg,
 	}
 	return nil
 }
 
 // loadAndSet loads the configuration file and updates the cache.
-func (c *CacheCloneManager) loadAndSet(filename string) (*Config, error) {
+func (c *CacheCloneManager[T]) loadAndSet(filename string) (*T, error) {
 	data, err := fs.ReadFile(c.storage, filename)
 	if err != nil {
 		return nil, err
@@ -70,6 +77,6 @@ func (c *CacheCloneManager) loadAndSet(filename string) (*Config, error) {
 }
 
 // String returns the name of the cache implementation.
-func (c *CacheCloneManager) String() string {
+func (c *CacheCloneManager[T]) String() string {
 	return "CacheCloneManager"
 }
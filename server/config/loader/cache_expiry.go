@@ -13,38 +13,45 @@ type CacheExpiry struct {
 }
 
 // CacheExpiryManager manages a cache that invalidates entries based on a time-to-live (TTL) value.
-type CacheExpiryManager struct {
+type CacheExpiryManager[T any] struct {
 	mu      sync.RWMutex
 	entries map[string]CacheExpiry
 	storage fs.FS
+	decode  DecodeFunc[T]
 
 	ttl time.Duration
 }
 
 // NewCacheExpiryManager creates a new CacheExpiryManager with the given TTL.
-func NewCacheExpiryManager(storage fs.FS, ttl time.Duration) *CacheExpiryManager {
-	return &CacheExpiryManager{
+func NewCacheExpiryManager(storage fs.FS, ttl time.Duration) *CacheExpiryManager[Config] {
+	return NewCacheExpiryManagerFor(storage, ttl, Decode)
+}
+
+// NewCacheExpiryManagerFor creates a new CacheExpiryManager for files decoded with decode.
+func NewCacheExpiryManagerFor[T any](storage fs.FS, ttl time.Duration, decode DecodeFunc[T]) *CacheExpiryManager[T] {
+	return &CacheExpiryManager[T]{
 		storage: storage,
 		entries: make(map[string]CacheExpiry),
 		ttl:     ttl,
+		decode:  decode,
 	}
 }
 
 // Get retrieves the cached configuration if available and valid based on expiry time, or loads it if not.
-func (c *CacheExpiryManager) Get(filename string) (*Config, error) {
+func (c *CacheExpiryManager[T]) Get(filename string) (*T, error) {
 	c.mu.RLock()
 	cacheEntry, exists := c.entries[filename]
 	c.mu.RUnlock()
 
 	if exists && time.Now().Before(cacheEntry.expiry) {
-		return Decode(c.storage, cacheEntry.data)
+		return c.decode(c.storage, cacheEntry.data)
 	}
 
 	return c.loadAndSet(filename)
 }
 
 // Set stores the configuration in the cache with an expiry time and filesystem.
-func (c *CacheExpiryManager) set(filename string, data []byte) error {
+func (c *CacheExpiryManager[T]) set(filename string, data []byte) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
@@ -56,7 +63,7 @@ func (c *CacheExpiryManager) set(filename string, data []byte) error {
 }
 
 // loadAndSet loads the configuration file and updates the cache.
-func (c *CacheExpiryManager) loadAndSet(filename string) (*Config, error) {
+func (c *CacheExpiryManager[T]) loadAndSet(filename string) (*T, error) {
 	data, err := fs.ReadFile(c.storage, filename)
 	if err != nil {
 		return nil, err
@@ -66,10 +73,10 @@ func (c *CacheExpiryManager) loadAndSet(filename string) (*Config, error) {
 		return nil, err
 	}
 
-	return Decode(c.storage, data)
+	return c.decode(c.storage, data)
 }
 
 // String returns the name of the cache implementation.
-func (c *CacheExpiryManager) String() string {
+func (c *CacheExpiryManager[T]) String() string {
 	return "CacheExpiryManager"
 }
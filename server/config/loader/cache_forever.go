@@ -11,35 +11,42 @@ type CacheForever struct {
 }
 
 // CacheForeverManager manages a cache that caches configuration data indefinitely.
-type CacheForeverManager struct {
+type CacheForeverManager[T any] struct {
 	mu      sync.RWMutex
 	storage fs.FS
 	entries map[string]CacheForever
+	decode  DecodeFunc[T]
 }
 
 // NewCacheForeverManager creates a new CacheForeverManager, which caches the configuration data indefinitely.
-func NewCacheForeverManager(storage fs.FS) *CacheForeverManager {
-	return &CacheForeverManager{
+func NewCacheForeverManager(storage fs.FS) *CacheForeverManager[Config] {
+	return NewCacheForeverManagerFor(storage, Decode)
+}
+
+// NewCacheForeverManagerFor creates a new CacheForeverManager for files decoded with decode.
+func NewCacheForeverManagerFor[T any](storage fs.FS, decode DecodeFunc[T]) *CacheForeverManager[T] {
+	return &CacheForeverManager[T]{
 		storage: storage,
 		entries: make(map[string]CacheForever),
+		decode:  decode,
 	}
 }
 
 // Get retrieves the cached configuration if available, or loads it if not.
-func (c *CacheForeverManager) Get(filename string) (*Config, error) {
+func (c *CacheForeverManager[T]) Get(filename string) (*T, error) {
 	c.mu.RLock()
 	cacheEntry, exists := c.entries[filename]
 	c.mu.RUnlock()
 
 	if exists {
-		return Decode(c.storage, cacheEntry.data)
+		return c.decode(c.storage, cacheEntry.data)
 	}
 
 	return c.loadAndSet(filename)
 }
 
 // Set stores the configuration in the cache with the filesystem.
-func (c *CacheForeverManager) set(filename string, data []byte) error {
+func (c *CacheForeverManager[T]) set(filename string, data []byte) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
@@ -50,7 +57,7 @@ func (c *CacheForeverManager) set(filename string, data []byte) error {
 }
 
 // loadAndSet loads the configuration file and updates the cache.
-func (c *CacheForeverManager) loadAndSet(filename string) (*Config, error) {
+func (c *CacheForeverManager[T]) loadAndSet(filename string) (*T, error) {
 	data, err := fs.ReadFile(c.storage, filename)
 	if err != nil {
 		return nil, err
@@ -60,10 +67,10 @@ func (c *CacheForeverManager) loadAndSet(filename string) (*Config, error) {
 		return nil, err
 	}
 
-	return Decode(c.storage, data)
+	return c.decode(c.storage, data)
 }
 
 // String returns the name of the cache implementation.
-func (c *CacheForeverManager) String() string {
+func (c *CacheForeverManager[T]) String() string {
 	return "CacheForever"
 }
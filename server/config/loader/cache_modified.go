@@ -13,22 +13,29 @@ type CacheModified struct {
 }
 
 // CacheModifiedManager manages a cache that invalidates entries based on file modification time.
-type CacheModifiedManager struct {
+type CacheModifiedManager[T any] struct {
 	mu      sync.RWMutex
 	entries map[string]CacheModified
 	storage fs.FS
+	decode  DecodeFunc[T]
 }
 
 // NewCacheModifiedManager creates a new CacheModifiedManager, which caches the configuration data and invalidates it based on file modification time.
-func NewCacheModifiedManager(storage fs.FS) *CacheModifiedManager {
-	return &CacheModifiedManager{
+func NewCacheModifiedManager(storage fs.FS) *CacheModifiedManager[Config] {
+	return NewCacheModifiedManagerFor(storage, Decode)
+}
+
+// NewCacheModifiedManagerFor creates a new CacheModifiedManager for files decoded with decode.
+func NewCacheModifiedManagerFor[T any](storage fs.FS, decode DecodeFunc[T]) *CacheModifiedManager[T] {
+	return &CacheModifiedManager[T]{
 		storage: storage,
 		entries: make(map[string]CacheModified),
+		decode:  decode,
 	}
 }
 
 // Get retrieves the cached configuration if available and valid based on file modification time, or loads it if not.
-func (c *CacheModifiedManager) Get(filename string) (*Config, error) {
+func (c *CacheModifiedManager[T]) Get(filename string) (*T, error) {
 	c.mu.RLock()
 	cacheEntry, exists := c.entries[filename]
 	c.mu.RUnlock()
@@ -43,14 +50,14 @@ func (c *CacheModifiedManager) Get(filename string) (*Config, error) {
 			return c.loadAndSet(filename)
 		}
 
-		return Decode(c.storage, cacheEntry.data)
+		return c.decode(c.storage, cacheEntry.data)
 	}
 
 	return c.loadAndSet(filename)
 }
 
 // Set stores the configuration in the cache with the file modification time and filesystem.
-func (c *CacheModifiedManager) set(filename string, data []byte) error {
+func (c *CacheModifiedManager[T]) set(filename string, data []byte) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
@@ -63,7 +70,7 @@ func (c *CacheModifiedManager) set(filename string, data []byte) error {
 }
 
 // loadAndSet loads the configuration file and updates the cache.
-func (c *CacheModifiedManager) loadAndSet(filename string) (*Config, error) {
+func (c *CacheModifiedManager[T]) loadAndSet(filename string) (*T, error) {
 	data, err := fs.ReadFile(c.storage, filename)
 	if err != nil {
 		return nil, err
@@ -73,10 +80,10 @@ func (c *CacheModifiedManager) loadAndSet(filename string) (*Config, error) {
 		return nil, err
 	}
 
-	return Decode(c.storage, data)
+	return c.decode(c.storage, data)
 }
 
 // String returns the name of the cache implementation.
-func (c *CacheModifiedManager) String() string {
+func (c *CacheModifiedManager[T]) String() string {
 	return "CacheModifiedManager"
 }
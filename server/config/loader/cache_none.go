@@ -3,34 +3,41 @@ package loader
 import "io/fs"
 
 // CacheNone is a cache implementation that does not cache configuration data.
-type CacheNone struct {
+type CacheNone[T any] struct {
 	storage fs.FS
+	decode  DecodeFunc[T]
 }
 
 // NewCacheNone creates a new CacheNone, which does not cache configuration data and reads directly from the file each time.
-func NewCacheNone(storage fs.FS) *CacheNone {
-	return &CacheNone{
+func NewCacheNone(storage fs.FS) *CacheNone[Config] {
+	return NewCacheNoneFor(storage, Decode)
+}
+
+// NewCacheNoneFor creates a new CacheNone for files decoded with decode.
+func NewCacheNoneFor[T any](storage fs.FS, decode DecodeFunc[T]) *CacheNone[T] {
+	return &CacheNone[T]{
 		storage: storage,
+		decode:  decode,
 	}
 }
 
 // Get reads the configuration file directly without caching.
-func (c *CacheNone) Get(filename string) (*Config, error) {
+func (c *CacheNone[T]) Get(filename string) (*T, error) {
 	data, err := fs.ReadFile(c.storage, filename)
 	if err != nil {
 		return nil, err
 	}
 
-	return Decode(c.storage, data)
+	return c.decode(c.storage, data)
 }
 
 // Set is a no-op for CacheNone.
-func (c *CacheNone) set(_ string, _ []byte) error {
+func (c *CacheNone[T]) set(_ string, _ []byte) error {
 	// No-op for CacheNone
 	return nil
 }
 
 // String returns the name of the cache implementation.
-func (c *CacheNone) String() string {
+func (c *CacheNone[T]) String() string {
 	return "CacheNone"
 }
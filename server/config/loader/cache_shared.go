@@ -6,28 +6,35 @@ import (
 )
 
 // CacheShared holds the cached configuration data as a decoded value.
-type CacheShared struct {
-	config  *Config
+type CacheShared[T any] struct {
+	config  *T
 	storage fs.FS
 }
 
 // CacheSharedManager manages a cache that clones the configuration data on retrieval.
-type CacheSharedManager struct {
+type CacheSharedManager[T any] struct {
 	mu      sync.RWMutex
-	entries map[string]CacheShared
+	entries map[string]CacheShared[T]
 	storage fs.FS
+	decode  DecodeFunc[T]
 }
 
 // NewCacheSharedManager creates a new CacheSharedManager, which caches the configuration data and clones it on retrieval.
-func NewCacheSharedManager(storage fs.FS) *CacheSharedManager {
-	return &CacheSharedManager{
+func NewCacheSharedManager(storage fs.FS) *CacheSharedManager[Config] {
+	return NewCacheSharedManagerFor(storage, Decode)
+}
+
+// NewCacheSharedManagerFor creates a new CacheSharedManager for files decoded with decode.
+func NewCacheSharedManagerFor[T any](storage fs.FS, decode DecodeFunc[T]) *CacheSharedManager[T] {
+	return &CacheSharedManager[T]{
 		storage: storage,
-		entries: make(map[string]CacheShared),
+		entries: make(map[string]CacheShared[T]),
+		decode:  decode,
 	}
 }
 
 // Get retrieves the cached configuration if available, or loads it if not, and returns a cloned copy.
-func (c *CacheSharedManager) Get(filename string) (*Config, error) {
+func (c *CacheSharedManager[T]) Get(filename string) (*T, error) {
 	c.mu.RLock()
 	cacheEntry, exists := c.entries[filename]
 	c.mu.RUnlock()
@@ -40,22 +47,22 @@ func (c *CacheSharedManager) Get(filename string) (*Config, error) {
 }
 
 // Set stores the configuration in the cache.
-func (c *CacheSharedManager) set(filename string, data []byte) error {
-	cfg, err := Decode(c.storage, data)
+func (c *CacheSharedManager[T]) set(filename string, data []byte) error {
+	cfg, err := c.decode(c.storage, data)
 	if err != nil {
 		return err
 	}
 
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	c.entries[filename] = CacheShared{
+	c.entries[filename] = CacheShared[T]{
 		config: cfg,
 	}
 	return nil
 }
 
 // loadAndSet loads the configuration file and updates the cache.
-func (c *CacheSharedManager) loadAndSet(filename string) (*Config, error) {
+func (c *CacheSharedManager[T]) loadAndSet(filename string) (*T, error) {
 	data, err := fs.ReadFile(c.storage, filename)
 	if err != nil {
 		return nil, err
@@ -69,6 +76,6 @@ func (c *CacheSharedManager) loadAndSet(filename string) (*Config, error) {
 }
 
 // String returns the name of the cache implementation.
-func (c *CacheSharedManager) String() string {
+func (c *CacheSharedManager[T]) String() string {
 	return "CacheSharedManager"
 }
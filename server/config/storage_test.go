@@ -0,0 +1,112 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const storagesConfig = `
+storages:
+  primary:
+    dsn: "postgres://primary/db"
+    maxOpenConns: 20
+    replica: replica
+  replica:
+    dsn: "postgres://replica/db"
+storage: primary
+endpoints:
+  - path: /users
+    handler:
+      type: sql
+      storage: replica
+  - path: /inline
+    handler:
+      type: sql
+      storage:
+        dsn: "sqlite://file:inline.db"
+  - path: /default
+    handler:
+      type: sql
+`
+
+// TestDecodeStorages verifies that storage names resolve to the named storages.
+func TestDecodeStorages(t *testing.T) {
+	t.Setenv("ETL_DB_DSN", "")
+
+	cfg, err := Decode(nil, []byte(storagesConfig))
+	require.NoError(t, err)
+
+	require.Same(t, cfg.Storages["primary"], cfg.Storage)
+	require.Equal(t, 20, cfg.Storage.MaxOpenConns)
+	require.Equal(t, "replica", cfg.Storage.Replica)
+
+	require.Same(t, cfg.Storages["replica"], cfg.Endpoints[0].Handler.Storage)
+	require.Equal(t, "sqlite://file:inline.db", cfg.Endpoints[1].Handler.Storage.DSN)
+	require.Nil(t, cfg.Endpoints[2].Handler.Storage)
+}
+
+// TestDecodeStoragesEnvOverride verifies that ETL_DB_DSN overrides the named default storage.
+func TestDecodeStoragesEnvOverride(t *testing.T) {
+	t.Setenv("ETL_DB_DSN", "sqlite://file:env.db")
+
+	cfg, err := Decode(nil, []byte(storagesConfig))
+	require.NoError(t, err)
+
+	require.Equal(t, "sqlite://file:env.db", cfg.Storages["primary"].DSN)
+	require.Equal(t, "postgres://replica/db", cfg.Storages["replica"].DSN)
+}
+
+// TestDecodeStoragesUnknown verifies that unknown storage names are rejected.
+func TestDecodeStoragesUnknown(t *testing.T) {
+	_, err := Decode(nil, []byte("storage: missing\n"))
+	require.ErrorContains(t, err, `unknown storage "missing"`)
+
+	_, err = Decode(nil, []byte("storages:\n  primary:\n    dsn: x\n    replica: missing\n"))
+	require.ErrorContains(t, err, `unknown replica storage "missing"`)
+
+	_, err = Decode(nil, []byte("storages:\n  primary:\n    dsn: x\nendpoints:\n  - path: /x\n    handler:\n      storage: other\n"))
+	require.ErrorContains(t, err, "endpoint /x")
+}
+
+// TestResolveStorage verifies that inline storages are returned as is.
+func TestResolveStorage(t *testing.T) {
+	cfg := &Config{}
+	inline := &Storage{DSN: "sqlite://file:test.db"}
+
+	storage, err := cfg.ResolveStorage(inline)
+	require.NoError(t, err)
+	require.Same(t, inline, storage)
+
+	storage, err = cfg.ResolveStorage(nil)
+	require.NoError(t, err)
+	require.Nil(t, storage)
+}
+
+// TestDecodeServerStores verifies that server store storages are resolved.
+func TestDecodeServerStores(t *testing.T) {
+	t.Setenv("ETL_DB_DSN", "")
+
+	cfg, err := Decode(nil, []byte(storagesConfig+`
+server:
+  cache:
+    type: sql
+    storage: replica
+  rateLimit:
+    type: redis
+    addr: localhost:6379
+`))
+	require.NoError(t, err)
+
+	require.Same(t, cfg.Storages["replica"], cfg.Server.Cache.Storage)
+	require.Equal(t, StoreRedis, cfg.Server.RateLimit.Type)
+	require.Nil(t, cfg.Server.RateLimit.Storage)
+
+	_, err = Decode(nil, []byte(storagesConfig+`
+server:
+  cache:
+    type: sql
+    storage: missing
+`))
+	require.ErrorContains(t, err, `server cache: unknown storage "missing"`)
+}
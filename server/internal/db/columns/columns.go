@@ -0,0 +1,311 @@
+// Package columns converts scanned database values into JSON values,
+// using the column types reported by the driver.
+package columns
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// JSON types of result columns.
+const (
+	TypeString  = "string"
+	TypeInt     = "int"
+	TypeFloat   = "float"
+	TypeDecimal = "decimal"
+	TypeBool    = "bool"
+	TypeJSON    = "json"
+	TypeTime    = "time"
+	TypeDate    = "date"
+)
+
+// Types lists the supported column types.
+var Types = []string{TypeString, TypeInt, TypeFloat, TypeDecimal, TypeBool, TypeJSON, TypeTime, TypeDate}
+
+// Date formats of the time and date types.
+const (
+	TimeFormat = time.RFC3339Nano
+	DateFormat = time.DateOnly
+)
+
+// databaseTypes maps the database type names to column types. Types
+// which aren't listed, and expressions without a type, are converted
+// by the scanned value.
+var databaseTypes = map[string]string{
+	"INT":              TypeInt,
+	"INTEGER":          TypeInt,
+	"TINYINT":          TypeInt,
+	"SMALLINT":         TypeInt,
+	"MEDIUMINT":        TypeInt,
+	"BIGINT":           TypeInt,
+	"INT2":             TypeInt,
+	"INT4":             TypeInt,
+	"INT8":             TypeInt,
+	"SERIAL":           TypeInt,
+	"BIGSERIAL":        TypeInt,
+	"UNSIGNED INT":     TypeInt,
+	"UNSIGNED TINYINT": TypeInt,
+	"UNSIGNED BIGINT":  TypeInt,
+	"REAL":             TypeFloat,
+	"FLOAT":            TypeFloat,
+	"FLOAT4":           TypeFloat,
+	"FLOAT8":           TypeFloat,
+	"DOUBLE":           TypeFloat,
+	"DOUBLE PRECISION": TypeFloat,
+	"DECIMAL":          TypeDecimal,
+	"NUMERIC":          TypeDecimal,
+	"BOOL":             TypeBool,
+	"BOOLEAN":          TypeBool,
+	"JSON":             TypeJSON,
+	"JSONB":            TypeJSON,
+	"DATETIME":         TypeTime,
+	"TIMESTAMP":        TypeTime,
+	"TIMESTAMPTZ":      TypeTime,
+	"DATE":             TypeDate,
+	"TEXT":             TypeString,
+	"VARCHAR":          TypeString,
+	"CHAR":             TypeString,
+	"BPCHAR":           TypeString,
+	"UUID":             TypeString,
+}
+
+// timeLayouts are tried to parse times returned as text.
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	time.DateOnly,
+}
+
+// Check returns an error if a column has an unknown type.
+func Check(columns map[string]string) error {
+	for name, typ := range columns {
+		if !slices.Contains(Types, typ) {
+			return fmt.Errorf("column %s: unknown type %q, supported %v", name, typ, Types)
+		}
+	}
+	return nil
+}
+
+// Columns holds the types of result columns, by lowercase name.
+type Columns map[string]string
+
+// Scan reads the rows, returning them with lowercase column names and
+// the values as scanned, along with the column types. The types are
+// read from the driver, and columns overrides them by name.
+func Scan(rows *sqlx.Rows, columns map[string]string) ([]map[string]any, Columns, error) {
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	result := Columns(make(map[string]string, len(types)))
+	for _, ct := range types {
+		result[strings.ToLower(ct.Name())] = databaseType(ct)
+	}
+	for name, typ := range columns {
+		result[strings.ToLower(name)] = typ
+	}
+
+	var scanned []map[string]any
+	for rows.Next() {
+		row := map[string]any{}
+		if err := rows.MapScan(row); err != nil {
+			return nil, nil, err
+		}
+
+		res := make(map[string]any, len(row))
+		for k, v := range row {
+			res[strings.ToLower(k)] = v
+		}
+		scanned = append(scanned, res)
+	}
+	return scanned, result, rows.Err()
+}
+
+// Rows reads the rows and converts them to JSON values.
+func Rows(rows *sqlx.Rows, columns map[string]string) ([]map[string]any, error) {
+	scanned, types, err := Scan(rows, columns)
+	if err != nil {
+		return nil, err
+	}
+	return types.Rows(scanned), nil
+}
+
+// Rows converts the values of the rows.
+func (c Columns) Rows(rows []map[string]any) []map[string]any {
+	if rows == nil {
+		return nil
+	}
+
+	result := make([]map[string]any, 0, len(rows))
+	for _, row := range rows {
+		result = append(result, c.Row(row))
+	}
+	return result
+}
+
+// Row converts the values of a row.
+func (c Columns) Row(row map[string]any) map[string]any {
+	result := make(map[string]any, len(row))
+	for k, v := range row {
+		result[k] = Value(c[k], v)
+	}
+	return result
+}
+
+// databaseType returns the column type for the database type name.
+func databaseType(ct *sql.ColumnType) string {
+	name := strings.ToUpper(ct.DatabaseTypeName())
+	if idx := strings.IndexByte(name, '('); idx != -1 {
+		name = strings.TrimSpace(name[:idx])
+	}
+	return databaseTypes[name]
+}
+
+// Value converts a scanned value to a JSON value of the column type.
+// NULL is nil. Values which don't convert are returned as strings. With
+// an empty type, the value is converted by its Go type.
+func Value(typ string, in any) any {
+	if in == nil {
+		return nil
+	}
+	if b, ok := in.([]byte); ok {
+		in = string(b)
+	}
+	in = widen(in)
+
+	switch typ {
+	case TypeInt:
+		switch v := in.(type) {
+		case int64, uint64:
+			return v
+		case float64:
+			if v == float64(int64(v)) {
+				return int64(v)
+			}
+			return v
+		case string:
+			if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+				return n
+			}
+		}
+	case TypeFloat:
+		switch v := in.(type) {
+		case float64, int64, uint64:
+			return v
+		case string:
+			if n, err := strconv.ParseFloat(v, 64); err == nil {
+				return n
+			}
+		}
+	case TypeDecimal:
+		// Decimals are returned as numbers without losing precision.
+		switch v := in.(type) {
+		case float64, int64, uint64:
+			return v
+		case string:
+			if _, err := strconv.ParseFloat(v, 64); err == nil {
+				return json.Number(v)
+			}
+		}
+	case TypeBool:
+		switch v := in.(type) {
+		case bool:
+			return v
+		case int64:
+			return v != 0
+		case uint64:
+			return v != 0
+		case string:
+			if b, err := strconv.ParseBool(v); err == nil {
+				return b
+			}
+		}
+	case TypeJSON:
+		if s, ok := in.(string); ok {
+			var v any
+			if err := json.Unmarshal([]byte(s), &v); err == nil {
+				return v
+			}
+			return s
+		}
+		return in
+	case TypeTime, TypeDate:
+		layout := TimeFormat
+		if typ == TypeDate {
+			layout = DateFormat
+		}
+		switch v := in.(type) {
+		case time.Time:
+			return formatTime(v, layout)
+		case string:
+			for _, l := range timeLayouts {
+				if t, err := time.Parse(l, v); err == nil {
+					return formatTime(t, layout)
+				}
+			}
+		}
+	case TypeString:
+		if t, ok := in.(time.Time); ok {
+			return formatTime(t, TimeFormat)
+		}
+	default:
+		switch v := in.(type) {
+		case string, bool, int64, uint64, float64:
+			return v
+		case time.Time:
+			return formatTime(v, TimeFormat)
+		}
+	}
+
+	return fmt.Sprint(in)
+}
+
+// widen converts the integer and float types drivers scan, like the
+// uint64 of mysql unsigned columns, to int64 and float64. Unsigned
+// values over the int64 range stay uint64.
+func widen(in any) any {
+	switch v := in.(type) {
+	case int:
+		return int64(v)
+	case int8:
+		return int64(v)
+	case int16:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case uint:
+		return widen(uint64(v))
+	case uint8:
+		return int64(v)
+	case uint16:
+		return int64(v)
+	case uint32:
+		return int64(v)
+	case uint64:
+		if v <= math.MaxInt64 {
+			return int64(v)
+		}
+	case float32:
+		return float64(v)
+	}
+	return in
+}
+
+// formatTime formats times in UTC. Dates are formatted as is.
+func formatTime(t time.Time, layout string) string {
+	if layout == DateFormat {
+		return t.Format(layout)
+	}
+	return t.UTC().Format(layout)
+}
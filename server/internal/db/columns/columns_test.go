@@ -0,0 +1,114 @@
+package columns
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+
+	_ "modernc.org/sqlite"
+)
+
+// TestValue verifies the conversion of scanned values by column type.
+func TestValue(t *testing.T) {
+	ts := time.Date(2024, 1, 15, 10, 30, 0, 0, time.FixedZone("CET", 3600))
+
+	tests := []struct {
+		typ  string
+		in   any
+		want any
+	}{
+		{TypeInt, nil, nil},
+		{TypeInt, int64(7), int64(7)},
+		{TypeInt, []byte("42"), int64(42)},
+		{TypeInt, "x", "x"},
+		{TypeFloat, []byte("9.99"), 9.99},
+		{TypeDecimal, []byte("10.50"), json.Number("10.50")},
+		{TypeDecimal, 10.5, 10.5},
+		{TypeBool, int64(1), true},
+		{TypeBool, []byte("0"), false},
+		{TypeBool, "t", true},
+		{TypeJSON, []byte(`{"a": [1, 2]}`), map[string]any{"a": []any{1.0, 2.0}}},
+		{TypeJSON, "not json", "not json"},
+		{TypeTime, ts, "2024-01-15T09:30:00Z"},
+		{TypeTime, "2024-01-15 09:30:00", "2024-01-15T09:30:00Z"},
+		{TypeDate, ts, "2024-01-15"},
+		{TypeDate, "2024-01-15", "2024-01-15"},
+		{TypeString, int64(1), "1"},
+		{TypeString, []byte("abc"), "abc"},
+		{"", int64(1), int64(1)},
+		{"", []byte("abc"), "abc"},
+		{"", []byte("42"), "42"},
+		{"", int32(-3), int64(-3)},
+		{"", int8(1), int64(1)},
+		{"", uint8(255), int64(255)},
+		{"", uint32(7), int64(7)},
+		{"", uint64(7), int64(7)},
+		{"", uint64(math.MaxUint64), uint64(math.MaxUint64)},
+		{"", float32(1.5), 1.5},
+		{TypeInt, uint64(42), int64(42)},
+		{TypeInt, uint64(math.MaxUint64), uint64(math.MaxUint64)},
+		{TypeInt, int16(-2), int64(-2)},
+		{TypeInt, uint(3), int64(3)},
+		{TypeFloat, uint32(2), int64(2)},
+		{TypeFloat, float32(0.5), 0.5},
+		{TypeDecimal, uint64(10), int64(10)},
+		{TypeBool, uint8(1), true},
+		{TypeBool, uint64(0), false},
+		{"", ts, "2024-01-15T09:30:00Z"},
+	}
+
+	for _, tt := range tests {
+		require.Equal(t, tt.want, Value(tt.typ, tt.in), "%s %#v", tt.typ, tt.in)
+	}
+}
+
+// TestRows verifies the column types read from sqlite, and the overrides.
+func TestRows(t *testing.T) {
+	db, err := sqlx.Open("sqlite", ":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	db.MustExec(`
+		create table product (id integer primary key, name text, price decimal(10,2), active boolean, meta json, flag integer, created_at datetime);
+		insert into product values (1, 'Tea', 9.99, 1, '{"tags": ["green"]}', 0, '2024-01-15 10:30:00'), (2, null, null, 0, null, 1, null);
+	`)
+
+	rows, err := db.Queryx("select id, name, price, active, meta, flag, created_at, count(*) over () as total from product order by id")
+	require.NoError(t, err)
+	defer rows.Close()
+
+	result, err := Rows(rows, map[string]string{"flag": TypeBool})
+	require.NoError(t, err)
+	require.Equal(t, []map[string]any{
+		{
+			"id":         int64(1),
+			"name":       "Tea",
+			"price":      9.99,
+			"active":     true,
+			"meta":       map[string]any{"tags": []any{"green"}},
+			"flag":       false,
+			"created_at": "2024-01-15T10:30:00Z",
+			"total":      int64(2),
+		},
+		{
+			"id":         int64(2),
+			"name":       nil,
+			"price":      nil,
+			"active":     false,
+			"meta":       nil,
+			"flag":       true,
+			"created_at": nil,
+			"total":      int64(2),
+		},
+	}, result)
+}
+
+// TestCheck verifies that unknown types are rejected.
+func TestCheck(t *testing.T) {
+	require.NoError(t, Check(map[string]string{"id": TypeInt}))
+	require.ErrorContains(t, Check(map[string]string{"id": "integer"}), `column id: unknown type "integer"`)
+}
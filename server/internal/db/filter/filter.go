@@ -0,0 +1,240 @@
+// Package filter compiles allow-listed request filters and sort orders,
+// like `?status=eq:active&age=gte:3&sort=-created_at`, into SQL.
+package filter
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/titpetric/etl/drivers"
+	"github.com/titpetric/etl/server/config"
+	"github.com/titpetric/etl/server/internal/db/order"
+	"github.com/titpetric/etl/server/internal/input"
+)
+
+// ParamSort is the request parameter with the sort order.
+const ParamSort = "sort"
+
+// Filter operators.
+const (
+	OpEq   = "eq"
+	OpNe   = "ne"
+	OpGt   = "gt"
+	OpGte  = "gte"
+	OpLt   = "lt"
+	OpLte  = "lte"
+	OpLike = "like"
+	OpIn   = "in"
+	OpNull = "null"
+)
+
+// Operators lists the supported operators.
+var Operators = []string{OpEq, OpNe, OpGt, OpGte, OpLt, OpLte, OpLike, OpIn, OpNull}
+
+// comparisons holds the SQL operators for the comparison operators.
+var comparisons = map[string]string{
+	OpEq:  "=",
+	OpNe:  "<>",
+	OpGt:  ">",
+	OpGte: ">=",
+	OpLt:  "<",
+	OpLte: "<=",
+}
+
+var columnRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Filter checks request filters and sort orders against the allowed columns.
+type Filter struct {
+	fields      map[string][]string
+	sort        []string
+	defaultSort []order.Order
+}
+
+// New creates a filter, checking the columns and operators.
+func New(conf *config.Filter) (*Filter, error) {
+	f := &Filter{
+		fields: make(map[string][]string, len(conf.Fields)),
+		sort:   conf.Sort,
+	}
+
+	for field, operators := range conf.Fields {
+		if !columnRe.MatchString(field) {
+			return nil, fmt.Errorf("invalid filter field %q", field)
+		}
+		if field == ParamSort {
+			return nil, fmt.Errorf("filter field can't be named %s", ParamSort)
+		}
+		for _, op := range operators {
+			if !slices.Contains(Operators, op) {
+				return nil, fmt.Errorf("filter field %s: unknown operator %q, supported %v", field, op, Operators)
+			}
+		}
+		if len(operators) == 0 {
+			operators = Operators
+		}
+		f.fields[field] = operators
+	}
+
+	for _, field := range conf.Sort {
+		if !columnRe.MatchString(field) {
+			return nil, fmt.Errorf("invalid sort field %q", field)
+		}
+	}
+
+	var err error
+	if f.defaultSort, err = order.Parse(strings.Join(conf.DefaultSort, ","), conf.Sort); err != nil {
+		return nil, fmt.Errorf("invalid defaultSort: %w", err)
+	}
+	return f, nil
+}
+
+// DefaultSort returns the sort order of requests which don't set one.
+func (f *Filter) DefaultSort() []order.Order {
+	return f.defaultSort
+}
+
+// Condition is a filter on a column.
+type Condition struct {
+	Field    string
+	Operator string
+	Values   []string
+}
+
+// Query holds the filters and sort order of a request.
+type Query struct {
+	Conditions []Condition
+	Order      []order.Order
+}
+
+// Parse reads the filters and the sort order from the query string.
+// A filter value is `operator:value`, or a value to compare with eq.
+// Unknown operators return an input error. Parameters which aren't
+// filter fields are left alone, and so is sort when no sort fields
+// are configured.
+func (f *Filter) Parse(values url.Values) (*Query, error) {
+	q := &Query{Order: f.defaultSort}
+
+	errs := &input.Error{}
+	invalid := func(name, message string) {
+		errs.Fields = append(errs.Fields, input.FieldError{Field: name, Source: config.InputQuery, Message: message})
+	}
+
+	// Sort the parameters for a stable query.
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if name == ParamSort && len(f.sort) > 0 {
+			orders, err := order.Parse(values.Get(name), f.sort)
+			if err != nil {
+				invalid(name, err.Error())
+			}
+			if len(orders) > 0 {
+				q.Order = orders
+			}
+			continue
+		}
+
+		allowed, filterable := f.fields[name]
+		if !filterable {
+			continue
+		}
+		for _, value := range values[name] {
+			op, operand, found := strings.Cut(value, ":")
+			if !found || !slices.Contains(Operators, op) {
+				op, operand = OpEq, value
+			}
+
+			if !slices.Contains(allowed, op) {
+				invalid(name, "operator must be one of: "+strings.Join(allowed, ", "))
+				break
+			}
+
+			c := Condition{Field: name, Operator: op, Values: []string{operand}}
+			switch op {
+			case OpIn:
+				c.Values = strings.Split(operand, ",")
+			case OpNull:
+				if operand != "true" && operand != "false" {
+					invalid(name, "must be null:true or null:false")
+					continue
+				}
+			}
+			q.Conditions = append(q.Conditions, c)
+		}
+	}
+
+	if len(errs.Fields) > 0 {
+		return nil, errs
+	}
+	return q, nil
+}
+
+// Empty reports if the query has no filters and no sort order.
+func (q *Query) Empty() bool {
+	return len(q.Conditions) == 0 && len(q.Order) == 0
+}
+
+// Apply wraps the query to filter and sort its results. The filter
+// values are bound as parameters, added to a copy of params.
+func (q *Query) Apply(dialect drivers.Dialect, query string, params map[string]any) (string, map[string]any) {
+	if q.Empty() {
+		return query, params
+	}
+
+	args := make(map[string]any, len(params)+len(q.Conditions))
+	for k, v := range params {
+		args[k] = v
+	}
+
+	var sb strings.Builder
+	sb.WriteString("SELECT * FROM (\n" + strings.TrimRight(strings.TrimSpace(query), "; \t\n") + "\n) filtered")
+
+	where := make([]string, 0, len(q.Conditions))
+	for i, c := range q.Conditions {
+		column := dialect.Quote(c.Field)
+		name := fmt.Sprintf("_filter%d", i)
+
+		switch c.Operator {
+		case OpNull:
+			if c.Values[0] == "true" {
+				where = append(where, column+" IS NULL")
+			} else {
+				where = append(where, column+" IS NOT NULL")
+			}
+		case OpIn:
+			names := make([]string, 0, len(c.Values))
+			for j, value := range c.Values {
+				names = append(names, fmt.Sprintf(":%s_%d", name, j))
+				args[fmt.Sprintf("%s_%d", name, j)] = value
+			}
+			where = append(where, column+" IN ("+strings.Join(names, ", ")+")")
+		case OpLike:
+			// Match case-insensitively, like sqlite and mysql do by default.
+			like := "LIKE"
+			if dialect == drivers.DialectPostgres {
+				like = "ILIKE"
+			}
+			where = append(where, column+" "+like+" :"+name)
+			args[name] = c.Values[0]
+		default:
+			where = append(where, column+" "+comparisons[c.Operator]+" :"+name)
+			args[name] = c.Values[0]
+		}
+	}
+
+	if len(where) > 0 {
+		sb.WriteString("\nWHERE " + strings.Join(where, " AND "))
+	}
+	if len(q.Order) > 0 {
+		sb.WriteString("\nORDER BY " + order.SQL(dialect, q.Order))
+	}
+	return sb.String(), args
+}
@@ -0,0 +1,181 @@
+package filter
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+
+	"github.com/titpetric/etl/drivers"
+	"github.com/titpetric/etl/server/config"
+	"github.com/titpetric/etl/server/internal/db/order"
+	"github.com/titpetric/etl/server/internal/input"
+
+	_ "modernc.org/sqlite"
+)
+
+func testFilter(t *testing.T) *Filter {
+	t.Helper()
+
+	f, err := New(&config.Filter{
+		Fields: map[string][]string{
+			"status": {OpEq, OpIn},
+			"age":    nil,
+			"name":   {OpLike},
+		},
+		Sort:        []string{"created_at", "name"},
+		DefaultSort: []string{"-created_at"},
+	})
+	require.NoError(t, err)
+	return f
+}
+
+func parse(t *testing.T, f *Filter, query string) (*Query, error) {
+	t.Helper()
+
+	values, err := url.ParseQuery(query)
+	require.NoError(t, err)
+	return f.Parse(values)
+}
+
+// TestParse verifies that filters and sort orders are read from the query string.
+func TestParse(t *testing.T) {
+	f := testFilter(t)
+
+	q, err := parse(t, f, "status=active&age=gte:3&age=lt:10&sort=name,-created_at&limit=20&time=10:30")
+	require.NoError(t, err)
+	require.Equal(t, &Query{
+		Conditions: []Condition{
+			{Field: "age", Operator: OpGte, Values: []string{"3"}},
+			{Field: "age", Operator: OpLt, Values: []string{"10"}},
+			{Field: "status", Operator: OpEq, Values: []string{"active"}},
+		},
+		Order: []order.Order{order.Asc("name"), order.Desc("created_at")},
+	}, q)
+
+	q, err = parse(t, f, "status=in:active,pending&age=null:false")
+	require.NoError(t, err)
+	require.Equal(t, []Condition{
+		{Field: "age", Operator: OpNull, Values: []string{"false"}},
+		{Field: "status", Operator: OpIn, Values: []string{"active", "pending"}},
+	}, q.Conditions)
+	require.Equal(t, []order.Order{order.Desc("created_at")}, q.Order)
+}
+
+// TestParseOther verifies that parameters which aren't filter fields
+// are left alone, also if their value looks like a filter, and that
+// sort is left alone when no sort fields are configured.
+func TestParseOther(t *testing.T) {
+	q, err := parse(t, testFilter(t), "q=in:stock&note=like:x&email=eq:a@example.com")
+	require.NoError(t, err)
+	require.Empty(t, q.Conditions)
+
+	f, err := New(&config.Filter{Fields: map[string][]string{"status": nil}})
+	require.NoError(t, err)
+
+	q, err = parse(t, f, "status=active&sort=-anything")
+	require.NoError(t, err)
+	require.Equal(t, []Condition{{Field: "status", Operator: OpEq, Values: []string{"active"}}}, q.Conditions)
+	require.Empty(t, q.Order)
+}
+
+// TestParseInvalid verifies that unknown sort fields and operators are rejected.
+func TestParseInvalid(t *testing.T) {
+	f := testFilter(t)
+
+	_, err := parse(t, f, "status=gt:1&age=null:maybe&sort=password")
+	var inputErr *input.Error
+	require.ErrorAs(t, err, &inputErr)
+	require.Equal(t, []input.FieldError{
+		{Field: "age", Source: "query", Message: "must be null:true or null:false"},
+		{Field: "sort", Source: "query", Message: "can't sort by password"},
+		{Field: "status", Source: "query", Message: "operator must be one of: eq, in"},
+	}, inputErr.Fields)
+}
+
+// TestApply verifies the generated SQL for each dialect.
+func TestApply(t *testing.T) {
+	f := testFilter(t)
+	q, err := parse(t, f, "status=in:a,b&name=like:%25x%25&age=null:true&sort=name")
+	require.NoError(t, err)
+
+	query, args := q.Apply(drivers.DialectPostgres, "SELECT * FROM users WHERE tenant = :tenant;", map[string]any{"tenant": 1})
+	require.Equal(t, `SELECT * FROM (
+SELECT * FROM users WHERE tenant = :tenant
+) filtered
+WHERE "age" IS NULL AND "name" ILIKE :_filter1 AND "status" IN (:_filter2_0, :_filter2_1)
+ORDER BY "name" ASC`, query)
+	require.Equal(t, map[string]any{
+		"tenant":     1,
+		"_filter1":   "%x%",
+		"_filter2_0": "a",
+		"_filter2_1": "b",
+	}, args)
+
+	query, _ = q.Apply(drivers.DialectMySQL, "SELECT * FROM users", nil)
+	require.Contains(t, query, "WHERE `age` IS NULL AND `name` LIKE :_filter1")
+
+	empty := &Query{}
+	query, _ = empty.Apply(drivers.DialectSQLite, "SELECT * FROM users", nil)
+	require.Equal(t, "SELECT * FROM users", query)
+}
+
+// TestApplyQuery verifies the filtered query against sqlite.
+func TestApplyQuery(t *testing.T) {
+	db, err := sqlx.Open("sqlite", ":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	db.MustExec(`
+		create table user (id integer primary key, name text, status text, age integer, created_at text);
+		insert into user values
+			(1, 'Alice', 'active', 30, '2024-01-01'),
+			(2, 'Bob', 'inactive', 25, '2024-01-02'),
+			(3, 'Carol', 'active', 2, '2024-01-03'),
+			(4, 'Dave', 'active', null, '2024-01-04');
+	`)
+
+	f := testFilter(t)
+	q, err := parse(t, f, "status=active&age=gte:3")
+	require.NoError(t, err)
+
+	query, args := q.Apply(drivers.DialectSQLite, "SELECT id, name, status, age, created_at FROM user", nil)
+	rows, err := db.NamedQuery(query, args)
+	require.NoError(t, err)
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var row struct {
+			ID        int64
+			Name      string
+			Status    string
+			Age       *int64
+			CreatedAt string `db:"created_at"`
+		}
+		require.NoError(t, rows.StructScan(&row))
+		names = append(names, row.Name)
+	}
+	require.NoError(t, rows.Err())
+	require.Equal(t, []string{"Alice"}, names)
+}
+
+// TestNewInvalid verifies that invalid configuration is rejected at mount time.
+func TestNewInvalid(t *testing.T) {
+	tests := []struct {
+		conf *config.Filter
+		err  string
+	}{
+		{&config.Filter{Fields: map[string][]string{"a b": nil}}, `invalid filter field "a b"`},
+		{&config.Filter{Fields: map[string][]string{"sort": nil}}, "filter field can't be named sort"},
+		{&config.Filter{Fields: map[string][]string{"age": {"between"}}}, `unknown operator "between"`},
+		{&config.Filter{Sort: []string{"name;"}}, `invalid sort field "name;"`},
+		{&config.Filter{Sort: []string{"name"}, DefaultSort: []string{"-age"}}, "invalid defaultSort: can't sort by age"},
+	}
+
+	for _, tt := range tests {
+		_, err := New(tt.conf)
+		require.ErrorContains(t, err, tt.err)
+	}
+}
@@ -0,0 +1,110 @@
+// Package named reads the named parameters of queries, like `:id`.
+package named
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/titpetric/etl/server/internal/input"
+)
+
+// literalRe matches comments and string literals.
+var literalRe = regexp.MustCompile(`(?s)--[^\n]*|/\*.*?\*/|'(?:[^']|'')*'`)
+
+// Names returns the distinct parameter names of a query, in order.
+// Like sqlx, names in string literals and comments are included, and
+// `::` is a literal colon, as in postgres casts like `'{}'::jsonb`.
+func Names(query string) []string {
+	var (
+		result []string
+		seen   = map[string]bool{}
+	)
+	scan(query, func(start, end int) {
+		if name := query[start+1 : end]; !seen[name] {
+			seen[name] = true
+			result = append(result, name)
+		}
+	})
+	return result
+}
+
+// Check returns an input error listing the parameters of the query
+// which aren't set in params.
+func Check(query string, params map[string]any) error {
+	errs := &input.Error{}
+	for _, name := range Names(query) {
+		if _, ok := params[name]; !ok {
+			errs.Fields = append(errs.Fields, input.FieldError{Field: name, Message: "is required"})
+		}
+	}
+	if len(errs.Fields) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Uses reports if the query uses the parameter outside string
+// literals and comments.
+func Uses(query, name string) bool {
+	return Replace(query, name, "") != query
+}
+
+// Replace replaces the parameter with replacement, outside string
+// literals and comments, e.g. `:keys` with a list of parameters.
+func Replace(query, name, replacement string) string {
+	var (
+		sb   strings.Builder
+		last int
+	)
+	for _, loc := range literalRe.FindAllStringIndex(query, -1) {
+		sb.WriteString(replace(query[last:loc[0]], name, replacement))
+		sb.WriteString(query[loc[0]:loc[1]])
+		last = loc[1]
+	}
+	sb.WriteString(replace(query[last:], name, replacement))
+	return sb.String()
+}
+
+// replace replaces the parameter in code without literals.
+func replace(code, name, replacement string) string {
+	var (
+		sb   strings.Builder
+		last int
+	)
+	scan(code, func(start, end int) {
+		if code[start+1:end] == name {
+			sb.WriteString(code[last:start] + replacement)
+			last = end
+		}
+	})
+	sb.WriteString(code[last:])
+	return sb.String()
+}
+
+// scan calls fn with the offsets of each parameter in the query,
+// including the colon.
+func scan(query string, fn func(start, end int)) {
+	for i := 0; i < len(query); i++ {
+		if query[i] != ':' {
+			continue
+		}
+		if i+1 < len(query) && query[i+1] == ':' {
+			i++
+			continue
+		}
+
+		j := i + 1
+		for j < len(query) && isNameByte(query[j]) {
+			j++
+		}
+		if j > i+1 {
+			fn(i, j)
+		}
+		i = j - 1
+	}
+}
+
+// isNameByte reports if b can be part of a parameter name.
+func isNameByte(b byte) bool {
+	return b == '_' || b == '.' || '0' <= b && b <= '9' || 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z'
+}
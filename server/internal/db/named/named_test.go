@@ -0,0 +1,60 @@
+package named
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/titpetric/etl/server/internal/input"
+)
+
+// TestNames verifies that parameters are found like sqlx binds them.
+func TestNames(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"SELECT * FROM users WHERE id = :id AND tenant = :tenant OR id = :id", []string{"id", "tenant"}},
+		{"SELECT * FROM users WHERE id = :user.id", []string{"user.id"}},
+		{"SELECT '10::30' AS t, '{}'::jsonb, x FROM t WHERE a = :a", []string{"a"}},
+		{"SELECT '10:30' AS t /* :note */ FROM t", []string{"30", "note"}},
+		{"SELECT :a:b, x := 1", []string{"a", "b"}},
+		{"SELECT 1", nil},
+	}
+
+	for _, tt := range tests {
+		require.Equal(t, tt.want, Names(tt.query), tt.query)
+	}
+}
+
+// TestCheck verifies that missing parameters return an input error.
+func TestCheck(t *testing.T) {
+	query := "SELECT * FROM users WHERE id = :id AND tenant = :tenant"
+	require.NoError(t, Check(query, map[string]any{"id": 1, "tenant": nil}))
+
+	err := Check(query, map[string]any{"id": 1})
+	var inputErr *input.Error
+	require.ErrorAs(t, err, &inputErr)
+	require.Equal(t, []input.FieldError{{Field: "tenant", Message: "is required"}}, inputErr.Fields)
+}
+
+// TestReplace verifies that parameters are replaced outside string literals and comments.
+func TestReplace(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"WHERE id IN (:keys)", "WHERE id IN (:k0, :k1)"},
+		{"WHERE id IN (:keys) OR parent IN (:keys)", "WHERE id IN (:k0, :k1) OR parent IN (:k0, :k1)"},
+		{"WHERE id IN (:keys_list) AND x = '{}'::keys", "WHERE id IN (:keys_list) AND x = '{}'::keys"},
+		{"SELECT ':keys' AS note -- :keys\nWHERE id IN (:keys) /* :keys */", "SELECT ':keys' AS note -- :keys\nWHERE id IN (:k0, :k1) /* :keys */"},
+	}
+
+	for _, tt := range tests {
+		require.Equal(t, tt.want, Replace(tt.query, "keys", ":k0, :k1"), tt.query)
+	}
+
+	require.True(t, Uses("WHERE id IN (:keys)", "keys"))
+	require.False(t, Uses("WHERE id IN (:keys_list)", "keys"))
+	require.False(t, Uses("SELECT ':keys', '{}'::keys -- :keys", "keys"))
+}
@@ -1,5 +1,13 @@
 package order
 
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/titpetric/etl/drivers"
+)
+
 // Order represents a field and the sorting order (ascending or descending).
 type Order struct {
 	Field string
@@ -21,3 +29,36 @@ func Desc(field string) Order {
 		Order: "DESC",
 	}
 }
+
+// Parse parses a comma separated sort list like `-created_at,name`.
+// A "-" prefix sorts descending. Fields which aren't allowed return
+// an error.
+func Parse(value string, allowed []string) ([]Order, error) {
+	var result []Order
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		o := Asc(strings.TrimPrefix(field, "+"))
+		if name, ok := strings.CutPrefix(field, "-"); ok {
+			o = Desc(name)
+		}
+		if !slices.Contains(allowed, o.Field) {
+			return nil, fmt.Errorf("can't sort by %s", o.Field)
+		}
+		result = append(result, o)
+	}
+	return result, nil
+}
+
+// SQL returns the ORDER BY list for the orders, quoting the fields
+// for the dialect.
+func SQL(dialect drivers.Dialect, orders []Order) string {
+	result := make([]string, 0, len(orders))
+	for _, o := range orders {
+		result = append(result, dialect.Quote(o.Field)+" "+o.Order)
+	}
+	return strings.Join(result, ", ")
+}
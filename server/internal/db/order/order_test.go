@@ -4,6 +4,8 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/require"
+
+	"github.com/titpetric/etl/drivers"
 )
 
 // TestAscOrder verifies that Asc creates an ascending order with the correct field and order value.
@@ -76,3 +78,26 @@ func TestOrderEmptyField(t *testing.T) {
 	require.Equal(t, "", desc.Field)
 	require.Equal(t, "DESC", desc.Order)
 }
+
+// TestParse verifies that sort lists are parsed and checked against the allowed fields.
+func TestParse(t *testing.T) {
+	allowed := []string{"created_at", "name"}
+
+	orders, err := Parse("-created_at, name,+name,", allowed)
+	require.NoError(t, err)
+	require.Equal(t, []Order{Desc("created_at"), Asc("name"), Asc("name")}, orders)
+
+	orders, err = Parse("", allowed)
+	require.NoError(t, err)
+	require.Empty(t, orders)
+
+	_, err = Parse("name,-password", allowed)
+	require.EqualError(t, err, "can't sort by password")
+}
+
+// TestSQL verifies that fields are quoted for the dialect.
+func TestSQL(t *testing.T) {
+	orders := []Order{Desc("created_at"), Asc("name")}
+	require.Equal(t, `"created_at" DESC, "name" ASC`, SQL(drivers.DialectPostgres, orders))
+	require.Equal(t, "`created_at` DESC, `name` ASC", SQL(drivers.DialectMySQL, orders))
+}
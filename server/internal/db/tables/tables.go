@@ -0,0 +1,78 @@
+package tables
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+)
+
+const identifier = `((?:"[^"]+"|` + "`[^`]+`" + `|\[[^\]]+\]|[\w$]+)(?:\s*\.\s*(?:"[^"]+"|` + "`[^`]+`" + `|\[[^\]]+\]|[\w$]+))*)`
+
+var (
+	// literalRe matches comments and string literals, which are removed before matching tables.
+	literalRe = regexp.MustCompile(`(?s)--[^\n]*|/\*.*?\*/|'(?:[^']|'')*'`)
+
+	// clauseRe matches UPDATE and FROM keywords which aren't followed by
+	// a table, like upserts and `IS DISTINCT FROM`.
+	clauseRe = regexp.MustCompile(`(?i)\b(?:DO\s+UPDATE|DUPLICATE\s+KEY\s+UPDATE|DISTINCT\s+FROM)\b`)
+
+	// functionRe matches functions using FROM between their arguments,
+	// like `EXTRACT(YEAR FROM created_at)`, up to the FROM.
+	functionRe = regexp.MustCompile(`(?i)\b(EXTRACT|SUBSTRING|TRIM|OVERLAY|POSITION)\s*\(([^()]*?)\bFROM\b`)
+
+	readRe  = regexp.MustCompile(`(?i)\b(?:FROM|JOIN|INTO|UPDATE)\s+(?:ONLY\s+)?` + identifier)
+	writeRe = regexp.MustCompile(`(?i)\b(?:INSERT\s+(?:OR\s+\w+\s+)?INTO|REPLACE\s+INTO|UPDATE(?:\s+OR\s+\w+)?|DELETE\s+FROM)\s+(?:ONLY\s+)?` + identifier)
+)
+
+// Referenced returns the tables a query reads from or writes to.
+// The result is sorted and lowercased, and schema names are dropped.
+func Referenced(query string) []string {
+	return match(readRe, query)
+}
+
+// Written returns the tables modified by INSERT, UPDATE, DELETE or
+// REPLACE statements in the query.
+func Written(query string) []string {
+	return match(writeRe, query)
+}
+
+// Name returns the table name of an identifier, like the names
+// returned by Referenced, e.g. `users` for `"public"."Users"`.
+func Name(identifier string) string {
+	return normalize(identifier)
+}
+
+func match(re *regexp.Regexp, query string) []string {
+	query = literalRe.ReplaceAllString(query, " ")
+	query = clauseRe.ReplaceAllString(query, " ")
+	query = functionRe.ReplaceAllString(query, "${1}(${2} ")
+
+	var result []string
+	for _, m := range re.FindAllStringSubmatch(query, -1) {
+		name := normalize(m[1])
+		if name == "" || isKeyword(name) || slices.Contains(result, name) {
+			continue
+		}
+		result = append(result, name)
+	}
+	slices.Sort(result)
+	return result
+}
+
+// normalize unquotes an identifier and returns the table name without the schema.
+func normalize(name string) string {
+	if idx := strings.LastIndex(name, "."); idx != -1 {
+		name = name[idx+1:]
+	}
+	name = strings.Trim(strings.TrimSpace(name), "\"`[]")
+	return strings.ToLower(name)
+}
+
+// isKeyword skips keywords following FROM, e.g. `DELETE FROM ONLY users`.
+func isKeyword(name string) bool {
+	switch name {
+	case "only", "lateral", "select", "unnest", "dual":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,56 @@
+package tables
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestReferenced verifies that tables are found in FROM and JOIN clauses.
+func TestReferenced(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"SELECT id FROM users ORDER BY id", []string{"users"}},
+		{"SELECT * FROM users u JOIN orders o ON o.user_id = u.id", []string{"orders", "users"}},
+		{`SELECT * FROM "public"."Users"`, []string{"users"}},
+		{"SELECT * FROM `users` LEFT JOIN `teams` ON 1=1", []string{"teams", "users"}},
+		{"SELECT * FROM (SELECT id FROM users) AS t", []string{"users"}},
+		{"SELECT 'from orders' AS note FROM users -- join teams", []string{"users"}},
+		{"INSERT INTO audit (msg) SELECT name FROM users", []string{"audit", "users"}},
+		{"SELECT EXTRACT(YEAR FROM created_at) AS year FROM orders", []string{"orders"}},
+		{"SELECT SUBSTRING(name FROM 2 FOR 3), TRIM(BOTH ' ' FROM name) FROM users", []string{"users"}},
+		{"SELECT * FROM users WHERE a IS DISTINCT FROM b", []string{"users"}},
+		{"INSERT INTO users (id) VALUES (1) ON CONFLICT (id) DO UPDATE SET name = excluded.name", []string{"users"}},
+		{"SELECT 1", nil},
+	}
+
+	for _, tt := range tests {
+		require.Equal(t, tt.want, Referenced(tt.query), tt.query)
+	}
+}
+
+// TestWritten verifies that the target tables of write statements are found.
+func TestWritten(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"INSERT INTO users (name) VALUES (:name) RETURNING id", []string{"users"}},
+		{"INSERT OR REPLACE INTO users (id) VALUES (1)", []string{"users"}},
+		{"REPLACE INTO users (id) VALUES (1)", []string{"users"}},
+		{"UPDATE users SET name = :name WHERE id = :id", []string{"users"}},
+		{"DELETE FROM users WHERE id = :id", []string{"users"}},
+		{"DELETE FROM ONLY users WHERE id = 1", []string{"users"}},
+		{"UPDATE orders SET total = 0; DELETE FROM items", []string{"items", "orders"}},
+		{"INSERT INTO users (id) VALUES (1) ON CONFLICT (id) DO UPDATE SET name = excluded.name", []string{"users"}},
+		{"INSERT INTO users (id) VALUES (1) ON DUPLICATE KEY UPDATE name = VALUES(name)", []string{"users"}},
+		{"SELECT * FROM users", nil},
+		{"SELECT 'update users set x' FROM t", nil},
+	}
+
+	for _, tt := range tests {
+		require.Equal(t, tt.want, Written(tt.query), tt.query)
+	}
+}
@@ -0,0 +1,136 @@
+// Package eval compiles and runs expr expressions, with the helper
+// functions available to pipeline steps and query placeholders.
+package eval
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+	"github.com/gofrs/uuid"
+)
+
+// functions are the helpers added to the expr builtins, like lower(),
+// trim(), now(), duration() and fromJSON().
+var functions = []expr.Option{
+	// Strings
+	expr.Function("slug", func(args ...any) (any, error) {
+		return slug(toString(args[0])), nil
+	}, new(func(string) string)),
+	expr.Function("truncate", func(args ...any) (any, error) {
+		return truncate(toString(args[0]), toInt(args[1])), nil
+	}, new(func(string, int) string)),
+
+	// Time
+	expr.Function("timestamp", func(args ...any) (any, error) {
+		return args[0].(time.Time).UTC().Format(time.RFC3339Nano), nil
+	}, new(func(time.Time) string)),
+	expr.Function("unix", func(args ...any) (any, error) {
+		return args[0].(time.Time).Unix(), nil
+	}, new(func(time.Time) int64)),
+	expr.Function("fromUnix", func(args ...any) (any, error) {
+		return time.Unix(int64(toInt(args[0])), 0).UTC(), nil
+	}, new(func(int) time.Time)),
+
+	// Hashing
+	expr.Function("md5", digest(md5.New), new(func(string) string)),
+	expr.Function("sha1", digest(sha1.New), new(func(string) string)),
+	expr.Function("sha256", digest(sha256.New), new(func(string) string)),
+	expr.Function("sha512", digest(sha512.New), new(func(string) string)),
+	expr.Function("hmac", func(args ...any) (any, error) {
+		mac := hmac.New(sha256.New, []byte(toString(args[0])))
+		mac.Write([]byte(toString(args[1])))
+		return hex.EncodeToString(mac.Sum(nil)), nil
+	}, new(func(string, string) string)),
+
+	// UUID
+	expr.Function("uuid", func(args ...any) (any, error) {
+		id, err := uuid.NewV4()
+		return id.String(), err
+	}, new(func() string)),
+	expr.Function("uuidv7", func(args ...any) (any, error) {
+		id, err := uuid.NewV7()
+		return id.String(), err
+	}, new(func() string)),
+
+	// JSON
+	expr.Function("json", func(args ...any) (any, error) {
+		b, err := json.Marshal(args[0])
+		return string(b), err
+	}, new(func(any) string)),
+}
+
+// Compile compiles an expression with the helper functions.
+func Compile(expression string) (*vm.Program, error) {
+	return expr.Compile(expression, functions...)
+}
+
+// Eval compiles an expression and runs it against the scope.
+func Eval(expression string, scope map[string]any) (any, error) {
+	program, err := Compile(expression)
+	if err != nil {
+		return nil, err
+	}
+	return expr.Run(program, scope)
+}
+
+// digest returns a function hashing a string to hex.
+func digest(fn func() hash.Hash) func(args ...any) (any, error) {
+	return func(args ...any) (any, error) {
+		h := fn()
+		h.Write([]byte(toString(args[0])))
+		return hex.EncodeToString(h.Sum(nil)), nil
+	}
+}
+
+// slugRe matches the characters replaced by dashes in slugs.
+var slugRe = regexp.MustCompile(`[^\p{L}\p{N}]+`)
+
+// slug lowercases s and joins its words with dashes.
+func slug(s string) string {
+	return strings.Trim(slugRe.ReplaceAllString(strings.ToLower(s), "-"), "-")
+}
+
+// truncate shortens s to at most n characters.
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if n < 0 || len(runes) <= n {
+		return s
+	}
+	return string(runes[:n])
+}
+
+// toString converts request values to strings.
+func toString(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case nil:
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+// toInt converts request values to ints. JSON numbers are float64.
+func toInt(v any) int {
+	switch v := v.(type) {
+	case int:
+		return v
+	case int64:
+		return int(v)
+	case float64:
+		return int(v)
+	}
+	return 0
+}
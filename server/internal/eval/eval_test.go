@@ -0,0 +1,68 @@
+package eval
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestEval verifies the helper functions.
+func TestEval(t *testing.T) {
+	scope := map[string]any{
+		"email": " Jane@Example.COM ",
+		"title": "Hello, World! 2024",
+		"ts":    time.Date(2024, 1, 15, 10, 30, 0, 0, time.FixedZone("CET", 3600)),
+		"n":     float64(1705310000),
+		"tags":  []any{"a", "b"},
+	}
+
+	tests := []struct {
+		expression string
+		want       any
+	}{
+		{`lower(trim(email))`, "jane@example.com"},
+		{`slug(title)`, "hello-world-2024"},
+		{`slug("Čaj & kava")`, "čaj-kava"},
+		{`truncate(title, 5)`, "Hello"},
+		{`truncate(title, 100)`, "Hello, World! 2024"},
+		{`timestamp(ts)`, "2024-01-15T09:30:00Z"},
+		{`timestamp(ts + duration("24h"))`, "2024-01-16T09:30:00Z"},
+		{`unix(ts)`, int64(1705311000)},
+		{`timestamp(fromUnix(n))`, "2024-01-15T09:13:20Z"},
+		{`md5("abc")`, "900150983cd24fb0d6963f7d28e17f72"},
+		{`sha1("abc")`, "a9993e364706816aba3e25717850c26c9cd0d89d"},
+		{`sha256("abc")`, "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+		{`hmac("key", "The quick brown fox jumps over the lazy dog")`, "f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8"},
+		{`json(tags)`, `["a","b"]`},
+		{`fromJSON("[1]")`, []any{1.0}},
+	}
+
+	for _, tt := range tests {
+		result, err := Eval(tt.expression, scope)
+		require.NoError(t, err, tt.expression)
+		require.Equal(t, tt.want, result, tt.expression)
+	}
+}
+
+// TestUUID verifies that uuids are generated.
+func TestUUID(t *testing.T) {
+	for _, expression := range []string{"uuid()", "uuidv7()"} {
+		a, err := Eval(expression, nil)
+		require.NoError(t, err)
+		b, err := Eval(expression, nil)
+		require.NoError(t, err)
+
+		require.Regexp(t, `^[0-9a-f]{8}-[0-9a-f]{4}-[47][0-9a-f]{3}-[0-9a-f]{4}-[0-9a-f]{12}$`, a)
+		require.NotEqual(t, a, b)
+	}
+}
+
+// TestEvalError verifies that invalid expressions fail.
+func TestEvalError(t *testing.T) {
+	_, err := Eval(`lower(`, nil)
+	require.Error(t, err)
+
+	_, err = Eval(`timestamp(email)`, map[string]any{"email": "x"})
+	require.Error(t, err)
+}
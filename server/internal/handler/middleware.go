@@ -0,0 +1,234 @@
+package handler
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/titpetric/etl/server/config"
+	"github.com/titpetric/etl/server/internal/db/tables"
+	"github.com/titpetric/etl/server/internal/handler/model"
+	"github.com/titpetric/etl/server/internal/input"
+	"github.com/titpetric/etl/server/internal/problem"
+	"github.com/titpetric/etl/server/middleware/cache"
+	"github.com/titpetric/etl/server/middleware/ratelimit"
+)
+
+const (
+	// DefaultCacheExpire is the cache TTL if an endpoint doesn't set expire.
+	DefaultCacheExpire = 5 * time.Minute
+
+	// DefaultRateLimitPer is the rate limit window if an endpoint doesn't set per.
+	DefaultRateLimitPer = time.Minute
+)
+
+// withTimeout wraps the handler to cancel the request context after the
+// endpoint timeout, or the server timeout if the endpoint doesn't set one.
+// Handlers pass the context to the queries, which are cancelled with it.
+func withTimeout(opts *model.Options, endpoint *config.Endpoint, next http.Handler) (http.Handler, error) {
+	timeout, err := endpointTimeout(opts, endpoint)
+	if err != nil || timeout == 0 {
+		return next, err
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	}), nil
+}
+
+// endpointTimeout returns the endpoint timeout, or the server timeout
+// if the endpoint doesn't set one. Zero means no timeout.
+func endpointTimeout(opts *model.Options, endpoint *config.Endpoint) (time.Duration, error) {
+	value := endpoint.Handler.Timeout
+	if value == "" {
+		value = opts.Config.Server.Timeout
+	}
+	if value == "" {
+		return 0, nil
+	}
+
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout < 0 {
+		return 0, fmt.Errorf("invalid timeout %q", value)
+	}
+	return timeout, nil
+}
+
+// withInputs wraps the handler to validate the request parameters,
+// if the endpoint declares inputs.
+func withInputs(opts *model.Options, endpoint *config.Endpoint, next http.Handler) (http.Handler, error) {
+	schema, err := input.NewSchema(endpoint.Handler.Inputs)
+	if err != nil || schema == nil {
+		return next, err
+	}
+
+	errors, err := problem.NewMapper(endpoint.Handler.Errors, opts.Config.Server.Dev)
+	if err != nil {
+		return nil, err
+	}
+	return schema.WithErrors(errors).Wrap(next), nil
+}
+
+// withCache wraps the handler with the shared cache middleware,
+// if caching is enabled for the endpoint.
+func withCache(opts *model.Options, endpoint *config.Endpoint, read []string, next http.Handler) (http.Handler, error) {
+	conf := endpoint.Handler.Cache
+	if conf == nil || !conf.Enabled || opts.Cache == nil {
+		return next, nil
+	}
+
+	ttl := DefaultCacheExpire
+	if conf.Expire != "" {
+		var err error
+		if ttl, err = time.ParseDuration(conf.Expire); err != nil {
+			return nil, fmt.Errorf("invalid cache expire %q: %w", conf.Expire, err)
+		}
+	}
+
+	var staleWhileRevalidate, staleIfError time.Duration
+	if conf.StaleWhileRevalidate != "" {
+		var err error
+		if staleWhileRevalidate, err = time.ParseDuration(conf.StaleWhileRevalidate); err != nil {
+			return nil, fmt.Errorf("invalid cache staleWhileRevalidate %q: %w", conf.StaleWhileRevalidate, err)
+		}
+	}
+	if conf.StaleIfError != "" {
+		var err error
+		if staleIfError, err = time.ParseDuration(conf.StaleIfError); err != nil {
+			return nil, fmt.Errorf("invalid cache staleIfError %q: %w", conf.StaleIfError, err)
+		}
+	}
+
+	// Header inputs are passed to the queries, so responses vary by them.
+	var headers []string
+	for _, in := range endpoint.Handler.Inputs {
+		if in.Source == config.InputHeader {
+			headers = append(headers, cmp.Or(in.Key, in.Name))
+		}
+	}
+
+	var keys cache.KeyBuilder = cache.NewDefaultKeyBuilder().WithPattern(endpoint.Path).WithHeaders(headers...)
+	if conf.KeyPattern != "" {
+		keys = cache.NewPatternKeyBuilder(conf.KeyPattern, chi.URLParam).WithHeaders(headers...).WithRoute(func(r *http.Request) string {
+			if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+				return rctx.RoutePattern()
+			}
+			return endpoint.Path
+		})
+	}
+
+	// Tag entries with the key pattern and the tables queried,
+	// so that write endpoints can invalidate them.
+	tableTags := tableTags(read)
+	tags := func(r *http.Request) []string {
+		result := slices.Clone(tableTags)
+		if conf.KeyPattern != "" {
+			result = append(result, cache.FillPattern(conf.KeyPattern, r, chi.URLParam))
+		}
+		return result
+	}
+
+	// Shared and background handler calls aren't cancelled
+	// with the client request, only by the endpoint timeout.
+	timeout, err := endpointTimeout(opts, endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	middleware := cache.NewMiddleware(opts.Cache, keys).
+		WithTTL(ttl).
+		WithTimeout(timeout).
+		WithTags(tags).
+		WithGenerations(opts.Generations).
+		WithStaleWhileRevalidate(staleWhileRevalidate).
+		WithStaleIfError(staleIfError)
+	return middleware.Wrap(next), nil
+}
+
+// withInvalidate wraps the handler to delete cache entries after writes.
+// The endpoint invalidates tags it lists and the tables it writes to.
+func withInvalidate(opts *model.Options, endpoint *config.Endpoint, written []string, next http.Handler) http.Handler {
+	if opts.Cache == nil {
+		return next
+	}
+
+	tableTags := tableTags(written)
+
+	patterns := endpoint.Handler.Invalidates
+	if len(patterns) == 0 && len(tableTags) == 0 {
+		return next
+	}
+
+	tags := func(r *http.Request) []string {
+		result := slices.Clone(tableTags)
+		for _, pattern := range patterns {
+			result = append(result, cache.FillPattern(pattern, r, chi.URLParam))
+		}
+		return result
+	}
+
+	return cache.NewInvalidator(opts.Cache, tags).WithGenerations(opts.Generations).Wrap(next)
+}
+
+// endpointTables returns the tables read and written by the inline
+// SQL queries of an endpoint, and by the handler.
+func endpointTables(endpoint *config.Endpoint, handler http.Handler) (read, written []string) {
+	if t, ok := handler.(model.Tables); ok {
+		read, written = t.Tables()
+	}
+
+	queries := []string{endpoint.Handler.Query}
+	for _, query := range endpoint.Handler.Queries {
+		queries = append(queries, query.Query)
+	}
+	for _, query := range queries {
+		read = append(read, tables.Referenced(query)...)
+		written = append(written, tables.Written(query)...)
+	}
+	return read, written
+}
+
+// tableTags returns the cache tags of the tables, without duplicates.
+func tableTags(names []string) []string {
+	var result []string
+	for _, table := range names {
+		if tag := cache.TableTag(table); !slices.Contains(result, tag) {
+			result = append(result, tag)
+		}
+	}
+	return result
+}
+
+// withRateLimit wraps the handler with the shared rate limit middleware,
+// if rate limiting is enabled for the endpoint. Each endpoint limits
+// clients separately.
+func withRateLimit(opts *model.Options, endpoint *config.Endpoint, next http.Handler) (http.Handler, error) {
+	conf := endpoint.Handler.RateLimit
+	if conf == nil || !conf.Enabled || conf.Rate <= 0 || opts.RateLimit == nil {
+		return next, nil
+	}
+
+	per := DefaultRateLimitPer
+	if conf.Per != "" {
+		var err error
+		if per, err = time.ParseDuration(conf.Per); err != nil || per <= 0 {
+			return nil, fmt.Errorf("invalid rate limit per %q", conf.Per)
+		}
+	}
+
+	keys, err := ratelimit.NewSourceKeyBuilder(endpoint.Path, conf.Key, conf.Header, conf.Secret, conf.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := int64(conf.Rate)
+	return ratelimit.NewMiddleware(opts.RateLimit, keys, limit, limit).WithWindow(per).Wrap(next), nil
+}
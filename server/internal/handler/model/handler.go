@@ -4,8 +4,30 @@ import (
 	"net/http"
 
 	"github.com/titpetric/etl/server/config"
+	"github.com/titpetric/etl/server/internal/storage"
+	"github.com/titpetric/etl/server/middleware/cache"
+	"github.com/titpetric/etl/server/middleware/ratelimit"
 )
 
+// Options holds the server state shared with handlers at mount time.
+type Options struct {
+	// Config is the global server config.
+	Config *config.Config
+
+	// Storage holds the database connection pools.
+	Storage *storage.Registry
+
+	// Cache is the response cache store shared by all endpoints.
+	Cache cache.Store
+
+	// Generations track the invalidations of the Cache store, so
+	// responses read during a write aren't cached.
+	Generations *cache.Generations
+
+	// RateLimit is the rate limit store shared by all endpoints.
+	RateLimit ratelimit.Store
+}
+
 // Handler is an interface that all endpoint handlers must implement.
 type Handler interface {
 	// Type will return the registered type name of a handler.
@@ -13,7 +35,20 @@ type Handler interface {
 
 	// Handler needs the global config for settings optional to endpoints.
 	// Particularly storage credentials can be customized on endpoints.
-	Handler(*config.Config, *config.Endpoint) (http.Handler, error)
+	Handler(*Options, *config.Endpoint) (http.Handler, error)
+}
+
+// Routes is implemented by handlers which declare routes of their own,
+// which are mounted in addition to the endpoint path.
+type Routes interface {
+	Routes() []config.Path
+}
+
+// Tables is implemented by handlers which generate their queries,
+// so the cache middleware can tag and invalidate by table. It returns
+// the tables the handler reads from and writes to.
+type Tables interface {
+	Tables() (read, written []string)
 }
 
 var registeredHandlers = make(map[string]Handler)
@@ -20,7 +20,7 @@ func (m *MockHandler) Type() string {
 }
 
 // Handler returns a simple HTTP handler.
-func (m *MockHandler) Handler(conf *config.Config, endpoint *config.Endpoint) (http.Handler, error) {
+func (m *MockHandler) Handler(opts *Options, endpoint *config.Endpoint) (http.Handler, error) {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 	}), nil
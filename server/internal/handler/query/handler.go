@@ -1,18 +1,27 @@
 package query
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"path/filepath"
 	"strings"
 
-	"github.com/go-bridget/mig/db"
 	"github.com/go-chi/chi/v5"
 	"github.com/jmoiron/sqlx"
 
+	"github.com/titpetric/etl/drivers"
 	"github.com/titpetric/etl/server/config"
+	"github.com/titpetric/etl/server/config/loader"
+	"github.com/titpetric/etl/server/internal/db/columns"
+	"github.com/titpetric/etl/server/internal/db/named"
+	handlermodel "github.com/titpetric/etl/server/internal/handler/model"
 	"github.com/titpetric/etl/server/internal/handler/query/model"
+	"github.com/titpetric/etl/server/internal/input"
+	"github.com/titpetric/etl/server/internal/problem"
 )
 
 // Handler represents a handler that executes a SQL query based on configuration.
@@ -23,6 +32,30 @@ type Handler struct {
 	Query    string
 
 	Parameters map[string]any
+
+	// Columns overrides the JSON types of result columns.
+	Columns map[string]string
+
+	db         *sqlx.DB
+	replica    *sqlx.DB
+	conf       *model.Config
+	inputs     []*config.Input
+	defaults   map[string]any
+	statements []*statement
+	errors     *problem.Mapper
+}
+
+// files caches the decoded query files by absolute path. The endpoints
+// declared in a file share it, so it's read and decoded once.
+var files = loader.NewCacheSharedManagerFor(os.DirFS("/"), model.DecodeFile)
+
+// loadFile returns the decoded query file from the loader cache.
+func loadFile(filename string) (*model.Config, error) {
+	path, err := filepath.Abs(filename)
+	if err != nil {
+		return nil, err
+	}
+	return files.Get(strings.TrimPrefix(filepath.ToSlash(path), "/"))
 }
 
 // NewHandler creates a new Handler instance.
@@ -32,20 +65,11 @@ func NewHandler() *Handler {
 
 // ServeHTTP handles the HTTP request, executes the SQL query, and writes the response.
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	// Load the configuration from the provided file
-	conf, err := model.Load(h.Filename)
-	if err != nil {
-		log.Println(err)
-		http.Error(w, "Error loading config", http.StatusInternalServerError)
-		return
-	}
-
 	// Process the SQL queries and gather results
 	queryParams := h.prepareQueryParams(r)
-	results, err := h.eval(conf, queryParams)
+	results, err := h.eval(r.Context(), h.conf, queryParams)
 	if err != nil {
-		log.Println(err)
-		http.Error(w, "Error executing SQL query", http.StatusInternalServerError)
+		h.errors.Write(w, err)
 		return
 	}
 
@@ -62,62 +86,241 @@ func (h *Handler) Type() string {
 	return "query"
 }
 
+// Routes returns the endpoints declared in the query file.
+func (h *Handler) Routes() []config.Path {
+	var result []config.Path
+	for _, endpoint := range h.conf.Config.Endpoints {
+		path := config.Path{Path: endpoint.Path}
+		if endpoint.Method != "" {
+			path.Methods = []string{endpoint.Method}
+		}
+		result = append(result, path)
+	}
+	return result
+}
+
 // Handler creates a new instance of Handler and returns the http.Handler.
-func (h *Handler) Handler(conf *config.Config, endpoint *config.Endpoint) (http.Handler, error) {
+func (h *Handler) Handler(opts *handlermodel.Options, endpoint *config.Endpoint) (http.Handler, error) {
+	conf := opts.Config
+
 	handle := NewHandler()
 	err := endpoint.Handler.Decode(handle)
 	if err != nil {
 		return nil, fmt.Errorf("error decoding config: %w", err)
 	}
 
+	if err := columns.Check(handle.Columns); err != nil {
+		return nil, err
+	}
+
+	// Load the query file through the shared loader cache
+	if err := handle.load(); err != nil {
+		return nil, err
+	}
+
 	// Copy global storage config if endpoint has it unset.
 	if handle.Storage == nil {
 		handle.Storage = conf.Storage
 	}
 
+	// Resolve a storage given by name
+	handle.Storage, err = conf.ResolveStorage(handle.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	// Use the shared connection pool for the storage
+	handle.db, err = opts.Storage.Get(handle.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	// Route reads to the replica storage if configured
+	if handle.replica, err = opts.Storage.Replica(conf, handle.Storage); err != nil {
+		return nil, err
+	}
+
+	// Map errors to problem responses, with the endpoint overrides
+	handle.errors, err = problem.NewMapper(endpoint.Handler.Errors, conf.Server.Dev)
+	if err != nil {
+		return nil, err
+	}
+
 	return handle, nil
 }
 
-func (h *Handler) eval(conf *model.Config, queryParams map[string]any) (map[string]any, error) {
-	driver, dsn := db.ParseDSN(h.Storage.DSN)
+// load reads the query file and compiles its queries. The input
+// defaults are converted to the input types.
+func (h *Handler) load() error {
+	if h.Filename == "" {
+		return fmt.Errorf("query handler requires filename")
+	}
 
-	sqldb, err := sqlx.Open(driver, dsn)
-	if err != nil {
-		return nil, fmt.Errorf("error connecting to the database: %w", err)
+	var err error
+	if h.conf, err = loadFile(h.Filename); err != nil {
+		return err
 	}
-	defer sqldb.Close()
 
-	results := make(map[string]any)
+	h.inputs = h.inputs[:0]
+	for _, in := range h.conf.Inputs {
+		field := &config.Input{Name: in.Name, Type: in.Type}
+		if in.Default != "" {
+			field.Default = in.Default
+		}
+		h.inputs = append(h.inputs, field)
+	}
+	if _, err := input.NewSchema(h.inputs); err != nil {
+		return fmt.Errorf("%s: %w", h.Filename, err)
+	}
+
+	h.defaults = make(map[string]any, len(h.inputs))
+	for _, in := range h.inputs {
+		if in.Default != nil {
+			h.defaults[in.Name], _ = input.Convert(in, in.Default)
+		}
+	}
+
+	produced := make(map[string]bool)
+	for idx, response := range h.conf.Response {
+		if response.With != "" && !produced[response.With] {
+			return fmt.Errorf("%s: response %d: with %q is not produced by an earlier query", h.Filename, idx, response.With)
+		}
 
-	for _, response := range conf.Response {
-		// Execute the SQL query with proper parameter handling
-		rows, err := sqldb.NamedQuery(response.Query.SQL, queryParams)
+		stmt, err := compile(response.Query.SQL)
 		if err != nil {
-			return nil, fmt.Errorf("error executing SQL query: %w", err)
+			return fmt.Errorf("%s: response %d: %w", h.Filename, idx, err)
 		}
-		defer rows.Close()
+		h.statements = append(h.statements, stmt)
+		produced[response.Produces] = true
+	}
+	return nil
+}
 
-		var result []map[string]string
-		for rows.Next() {
-			row := make(map[string]any)
-			if err := rows.MapScan(row); err != nil {
-				return nil, fmt.Errorf("error processing query results: %w", err)
-			}
+// eval runs the queries in order. Each result is added to the scope
+// under its `produces` name, so later queries can reference it. Reads
+// go to the replica until a query writes, after which all queries use
+// the primary, so the request reads its own writes.
+func (h *Handler) eval(ctx context.Context, conf *model.Config, queryParams map[string]any) (map[string]any, error) {
+	// Apply the input defaults, and convert values to the input types
+	inputs := make(map[string]any, len(queryParams))
+	for k, v := range queryParams {
+		inputs[k] = v
+	}
+	errs := &input.Error{}
+	for _, in := range h.inputs {
+		v := inputs[in.Name]
+		if v == nil || v == "" {
+			inputs[in.Name] = h.defaults[in.Name]
+			continue
+		}
+		value, err := input.Convert(in, v)
+		if err != nil {
+			errs.Fields = append(errs.Fields, input.FieldError{Field: in.Name, Message: err.Error()})
+			continue
+		}
+		inputs[in.Name] = value
+	}
+	if len(errs.Fields) > 0 {
+		return nil, errs
+	}
+
+	scope := map[string]any{
+		"inputs": inputs,
+	}
+	results := make(map[string]any)
 
-			res := make(map[string]string, len(row))
-			for k, v := range row {
-				res[strings.ToLower(k)] = dbValue(v)
+	db, wrote := h.db, false
+	for idx, response := range conf.Response {
+		stmt := h.statements[idx]
+		wrote = wrote || stmt.write
+		if h.replica != nil {
+			db = h.replica
+			if wrote {
+				db = h.db
 			}
+		}
 
-			result = append(result, res)
+		rows, err := h.query(ctx, db, stmt, scope, inputs, response.With)
+		if err != nil {
+			return nil, fmt.Errorf("error executing SQL query for %s: %w", response.Produces, err)
 		}
 
+		var result any = rows
+		switch {
+		case response.Query.Mode == "get":
+			result = nil
+			if len(rows) > 0 {
+				result = rows[0]
+			}
+		case response.Want == "array":
+			if rows == nil {
+				result = []map[string]any{}
+			}
+		}
+
+		scope[response.Produces] = result
 		results[response.Produces] = result
 	}
 
 	return results, nil
 }
 
+// query runs a statement. With `with`, the statement runs for each row
+// of the named result, which is in scope under its name. It doesn't
+// run if the named result is empty.
+func (h *Handler) query(ctx context.Context, db *sqlx.DB, stmt *statement, scope, params map[string]any, with string) ([]map[string]any, error) {
+	if with == "" {
+		return h.queryRows(ctx, db, stmt, scope, params)
+	}
+
+	var items []any
+	switch v := scope[with].(type) {
+	case map[string]any:
+		items = append(items, v)
+	case []map[string]any:
+		for _, item := range v {
+			items = append(items, item)
+		}
+	}
+
+	var result []map[string]any
+	for _, item := range items {
+		itemScope := make(map[string]any, len(scope))
+		for k, v := range scope {
+			itemScope[k] = v
+		}
+		itemScope[with] = item
+
+		rows, err := h.queryRows(ctx, db, stmt, itemScope, params)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, rows...)
+	}
+	return result, nil
+}
+
+// queryRows binds the statement placeholders from the scope and returns the rows.
+func (h *Handler) queryRows(ctx context.Context, db *sqlx.DB, stmt *statement, scope, params map[string]any) ([]map[string]any, error) {
+	args, err := stmt.bind(scope, params)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := named.Check(stmt.query, args); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.NamedQueryContext(ctx, stmt.query, args)
+	if err != nil {
+		return nil, drivers.NewError(err, stmt.query)
+	}
+	defer rows.Close()
+
+	result, err := columns.Rows(rows, h.Columns)
+	return result, drivers.NewError(err, stmt.query)
+}
+
 // prepareQueryParams prepares the query parameters from the request.
 func (h *Handler) prepareQueryParams(r *http.Request) map[string]any {
 	queryParams := make(map[string]any)
@@ -150,5 +353,10 @@ func (h *Handler) prepareQueryParams(r *http.Request) map[string]any {
 		}
 	}
 
+	// Validated inputs, converted to their declared types
+	for k, v := range input.Values(r.Context()) {
+		queryParams[k] = v
+	}
+
 	return queryParams
 }
@@ -2,15 +2,12 @@ package model
 
 import (
 	"fmt"
-	"io/ioutil"
+	"io/fs"
 )
 
-func Load(filepath string) (*Config, error) {
-	data, err := ioutil.ReadFile(filepath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read file: %w", err)
-	}
-
+// DecodeFile decodes a query file, for the loader caches. Query files
+// don't include other files, so the storage isn't read.
+func DecodeFile(_ fs.FS, data []byte) (*Config, error) {
 	cfg := &Config{}
 	if err := Decode(data, cfg); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal yaml: %w", err)
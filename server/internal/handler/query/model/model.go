@@ -22,6 +22,7 @@ type Endpoint struct {
 type Input struct {
 	Name    string `yaml:"name"`
 	Title   string `yaml:"title"`
+	Type    string `yaml:"type,omitempty"`
 	Default string `yaml:"default,omitempty"`
 }
 
@@ -1,19 +1,215 @@
 package query
 
 import (
+	"encoding/json"
 	"fmt"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/go-chi/chi/v5"
+	"github.com/jmoiron/sqlx"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
+	"github.com/titpetric/etl/server/config"
 	"github.com/titpetric/etl/server/internal/handler/query/model"
+
+	_ "modernc.org/sqlite"
 )
 
 func TestQueryLoadConfig(t *testing.T) {
-	cfg, err := model.Load("testdata/user.GetByID.yml")
+	cfg, err := loadFile("testdata/user.GetByID.yml")
 	assert.NoError(t, err)
 	assert.NotNil(t, cfg)
 
 	b, err := model.Encode(cfg)
 	fmt.Println(string(b))
 }
+
+// TestQueryLoadCached verifies that query files are decoded once, and
+// shared by the handlers which use them.
+func TestQueryLoadCached(t *testing.T) {
+	cfg, err := loadFile("testdata/user.GetByID.yml")
+	require.NoError(t, err)
+
+	path, err := filepath.Abs("testdata/user.GetByID.yml")
+	require.NoError(t, err)
+	cached, err := loadFile(path)
+	require.NoError(t, err)
+	require.Same(t, cfg, cached)
+
+	_, err = loadFile("testdata/missing.yml")
+	require.Error(t, err)
+}
+
+// TestQueryLoadInputs verifies that the input defaults are converted
+// to the input types, and invalid inputs are rejected.
+func TestQueryLoadInputs(t *testing.T) {
+	h := NewHandler()
+	h.Filename = "testdata/user.GetByID.yml"
+	require.NoError(t, h.load())
+	require.Equal(t, map[string]any{"comment_limit": int64(10)}, h.defaults)
+
+	dir := t.TempDir()
+	for name, inputs := range map[string]string{
+		"default": "  - name: limit\n    type: int\n    default: ten\n",
+		"type":    "  - name: limit\n    type: number\n",
+	} {
+		h := NewHandler()
+		h.Filename = filepath.Join(dir, name+".yml")
+		require.NoError(t, os.WriteFile(h.Filename, []byte("inputs:\n"+inputs), 0o644))
+		require.ErrorContains(t, h.load(), "input limit", name)
+	}
+}
+
+func testHandler(t *testing.T) *Handler {
+	t.Helper()
+
+	db, err := sqlx.Open("sqlite", ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	db.MustExec(`
+		create table user (id integer primary key, name text);
+		create table user_group (id integer primary key, name text);
+		create table user_group_member (group_id integer, user_id integer);
+		create table comment (id integer primary key, user_id integer, body text);
+
+		insert into user values (1, 'Alice'), (2, 'Bob');
+		insert into user_group values (1, 'admins'), (2, 'staff');
+		insert into user_group_member values (1, 1), (2, 1);
+		insert into comment (user_id, body) values (1, 'first'), (1, 'second'), (1, 'third');
+	`)
+
+	h := NewHandler()
+	h.Filename = "testdata/user.GetByID.yml"
+	h.db = db
+	require.NoError(t, h.load())
+	return h
+}
+
+func serve(t *testing.T, h *Handler, path string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	router := chi.NewRouter()
+	for _, route := range h.Routes() {
+		for _, method := range route.Methods {
+			router.Method(method, route.Path, h)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+	return w
+}
+
+func get(t *testing.T, h *Handler, path string) map[string]any {
+	t.Helper()
+
+	w := serve(t, h, path)
+	require.Equal(t, 200, w.Code, w.Body.String())
+
+	var result map[string]any
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&result))
+	return result
+}
+
+func TestQueryHandler(t *testing.T) {
+	h := testHandler(t)
+
+	require.Equal(t, []config.Path{
+		{Methods: []string{"GET"}, Path: "/users/{id}"},
+		{Methods: []string{"POST"}, Path: "/api/user.GetByID"},
+	}, h.Routes())
+
+	result := get(t, h, "/users/1?comment_limit=2")
+	require.Equal(t, map[string]any{"id": float64(1), "name": "Alice"}, result["user"])
+	require.Equal(t, []any{
+		map[string]any{"id": float64(1), "name": "admins"},
+		map[string]any{"id": float64(2), "name": "staff"},
+	}, result["groups"])
+	require.Len(t, result["comments"], 2)
+
+	// The comment limit defaults to 10
+	result = get(t, h, "/users/1")
+	require.Len(t, result["comments"], 3)
+
+	// Without a user, the groups query doesn't run
+	result = get(t, h, "/users/3")
+	require.Nil(t, result["user"])
+	require.Equal(t, []any{}, result["groups"])
+	require.Nil(t, result["comments"])
+}
+
+func TestQueryHandlerBindsValues(t *testing.T) {
+	h := testHandler(t)
+
+	// The input is converted to its type, and rejected if it isn't an integer
+	w := serve(t, h, "/users/1%20or%201=1")
+	require.Equal(t, 400, w.Code, w.Body.String())
+	require.Contains(t, w.Body.String(), `"field":"id"`)
+
+	w = serve(t, h, "/users/1?comment_limit=all")
+	require.Equal(t, 400, w.Code, w.Body.String())
+	require.Contains(t, w.Body.String(), `"field":"comment_limit"`)
+}
+
+// TestQueryHandlerReplica verifies that reads go to the replica until
+// a query writes, and later queries use the primary.
+func TestQueryHandlerReplica(t *testing.T) {
+	h := testHandler(t)
+
+	replica, err := sqlx.Open("sqlite", ":memory:")
+	require.NoError(t, err)
+	replica.SetMaxOpenConns(1)
+	t.Cleanup(func() { replica.Close() })
+
+	replica.MustExec(`
+		create table user (id integer primary key, name text);
+		create table user_group (id integer primary key, name text);
+		create table user_group_member (group_id integer, user_id integer);
+		create table comment (id integer primary key, user_id integer, body text);
+
+		insert into user values (1, 'Replica');
+	`)
+	h.replica = replica
+
+	result := get(t, h, "/users/1")
+	require.Equal(t, "Replica", result["user"].(map[string]any)["name"])
+	require.Equal(t, []any{}, result["groups"])
+	require.Nil(t, result["comments"])
+
+	// The statements after a write read from the primary
+	h.statements[1].write = true
+	result = get(t, h, "/users/1")
+	require.Equal(t, "Replica", result["user"].(map[string]any)["name"])
+	require.Len(t, result["groups"], 2)
+	require.Len(t, result["comments"], 3)
+}
+
+func TestCompileStatement(t *testing.T) {
+	stmt, err := compile("select * from t where a={{ inputs.a }} and b = :b and c={{user.id}}")
+	require.NoError(t, err)
+	require.Equal(t, "select * from t where a=:_p0 and b = :b and c=:_p1", stmt.query)
+
+	args, err := stmt.bind(map[string]any{
+		"inputs": map[string]any{"a": "x"},
+		"user":   map[string]string{"id": "7"},
+	}, map[string]any{"b": "y"})
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{"_p0": "x", "_p1": "7", "b": "y"}, args)
+	require.False(t, stmt.write)
+
+	stmt, err = compile("update t set a = {{ inputs.a }} returning *")
+	require.NoError(t, err)
+	require.True(t, stmt.write)
+
+	_, err = compile("select {{ }}")
+	require.ErrorContains(t, err, "empty placeholder")
+
+	_, err = compile("select {{ inputs.a")
+	require.ErrorContains(t, err, "unterminated placeholder")
+}
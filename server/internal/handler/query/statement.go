@@ -0,0 +1,83 @@
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+
+	"github.com/titpetric/etl/server/internal/db/tables"
+	"github.com/titpetric/etl/server/internal/eval"
+)
+
+// placeholderRe matches `{{ expression }}` references in a query.
+var placeholderRe = regexp.MustCompile(`\{\{\s*(.*?)\s*\}\}`)
+
+// statement is a query with its `{{...}}` references replaced by bind
+// parameters. The values are never interpolated into the SQL.
+type statement struct {
+	query    string
+	names    []string
+	programs []*vm.Program
+
+	// write is set for statements modifying tables.
+	write bool
+}
+
+// compile replaces each `{{ expression }}` in the query with a named
+// parameter, and compiles the expression for evaluation.
+func compile(query string) (*statement, error) {
+	stmt := &statement{}
+
+	var err error
+	stmt.query = placeholderRe.ReplaceAllStringFunc(query, func(match string) string {
+		expression := placeholderRe.FindStringSubmatch(match)[1]
+		if err != nil {
+			return match
+		}
+		if expression == "" {
+			err = fmt.Errorf("empty placeholder %s", match)
+			return match
+		}
+
+		program, compileErr := eval.Compile(expression)
+		if compileErr != nil {
+			err = fmt.Errorf("invalid placeholder %s: %w", match, compileErr)
+			return match
+		}
+
+		name := fmt.Sprintf("_p%d", len(stmt.names))
+		stmt.names = append(stmt.names, name)
+		stmt.programs = append(stmt.programs, program)
+		return ":" + name
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.Contains(stmt.query, "{{") {
+		return nil, fmt.Errorf("unterminated placeholder in query")
+	}
+	stmt.write = len(tables.Written(stmt.query)) > 0
+	return stmt, nil
+}
+
+// bind evaluates the placeholders against the scope and returns the
+// named arguments, which include the params for `:name` references.
+func (s *statement) bind(scope, params map[string]any) (map[string]any, error) {
+	args := make(map[string]any, len(params)+len(s.names))
+	for k, v := range params {
+		args[k] = v
+	}
+
+	for i, program := range s.programs {
+		value, err := expr.Run(program, scope)
+		if err != nil {
+			return nil, fmt.Errorf("error evaluating placeholder: %w", err)
+		}
+		args[s.names[i]] = value
+	}
+	return args, nil
+}
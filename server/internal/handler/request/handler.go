@@ -18,7 +18,6 @@ import (
 
 	"github.com/titpetric/etl/server/config"
 	"github.com/titpetric/etl/server/internal/handler/model"
-	"github.com/titpetric/etl/server/internal/handler/sql"
 )
 
 // Handler represents a handler that invokes an upstream request and formats the response.
@@ -26,12 +25,9 @@ type Handler struct {
 	RequestPath string
 	Request     []*config.Request
 	Response    *config.Response
-	RateLimit   *config.RateLimit
-	Cache       *config.Cache
 
 	// Internal state
 	template vuego.Template
-	router   http.Handler
 }
 
 // NewHandler creates a new Handler.
@@ -98,12 +94,6 @@ func (h *Handler) EvaluateRequest(r *http.Request) (map[string]any, error) {
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	// Check rate limiting if enabled
-	if h.RateLimit != nil && h.RateLimit.Enabled {
-		// Rate limiting would be applied here if needed
-		// For now, we delegate to the upstream handler
-	}
-
 	// Build the upstream request URL by substituting path parameters
 	data, err := h.EvaluateRequest(r)
 	if err != nil {
@@ -188,7 +178,7 @@ func (h *Handler) Type() string {
 }
 
 // Handler creates a new handler instance from endpoint config.
-func (h *Handler) Handler(conf *config.Config, endpoint *config.Endpoint) (http.Handler, error) {
+func (h *Handler) Handler(_ *model.Options, endpoint *config.Endpoint) (http.Handler, error) {
 	handle := NewHandler()
 	err := endpoint.Handler.Decode(handle)
 	if err != nil {
@@ -198,23 +188,11 @@ func (h *Handler) Handler(conf *config.Config, endpoint *config.Endpoint) (http.
 	// Copy handler configuration
 	handle.RequestPath = endpoint.Path
 	handle.Response = endpoint.Handler.Response
-	handle.RateLimit = endpoint.Handler.RateLimit
-	handle.Cache = endpoint.Handler.Cache
 
 	if handle.RequestPath == "" {
 		return nil, fmt.Errorf("request handler requires 'request' field")
 	}
 
-	// Build a temporary router for the upstream handler
-	// We need to get the SQL handler factory and invoke it with a dummy endpoint
-	sqlFactory := &sql.Handler{}
-	handler, err := sqlFactory.Handler(conf, endpoint)
-	if err != nil {
-		return nil, fmt.Errorf("error creating upstream handler: %w", err)
-	}
-
-	handle.router = handler
-
 	return handle, nil
 }
 
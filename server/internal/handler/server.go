@@ -14,10 +14,12 @@ import (
 	_ "github.com/titpetric/etl/server/internal/handler/query"
 	_ "github.com/titpetric/etl/server/internal/handler/request"
 	_ "github.com/titpetric/etl/server/internal/handler/sql"
+	_ "github.com/titpetric/etl/server/internal/handler/table"
 )
 
 // Mount uses the []*config.Endpoint arguments to populate routes.
-func Mount(router platform.Router, conf *config.Config, endpoints []*config.Endpoint) error {
+// The options carry the shared server state to the handlers.
+func Mount(router platform.Router, opts *model.Options, endpoints []*config.Endpoint) error {
 	registeredHandlers := model.Handlers()
 
 	for idx, endpoint := range endpoints {
@@ -29,11 +31,18 @@ func Mount(router platform.Router, conf *config.Config, endpoints []*config.Endp
 			continue
 		}
 
-		handler, err := factory.Handler(conf, endpoint)
+		handler, err := factory.Handler(opts, endpoint)
 		if err != nil {
 			return fmt.Errorf("error in handler %s: %w", endpoint.Handler.Type, err)
 		}
 
+		routes := []config.Path{{Methods: endpoint.Methods, Path: endpoint.Path}}
+		if r, ok := handler.(model.Routes); ok {
+			routes = append(routes, r.Routes()...)
+		}
+
+		read, written := endpointTables(endpoint, handler)
+
 		methods := strings.Join(endpoint.Methods, ", ")
 		if methods == "" {
 			methods = "ANY"
@@ -41,11 +50,38 @@ func Mount(router platform.Router, conf *config.Config, endpoints []*config.Endp
 
 		log.Printf("%s (methods: %s, handler: %s, properties: %s)", endpoint.Path, methods, handlerType, string(internal.Marshal(handler)))
 
-		if len(endpoint.Methods) == 0 {
-			router.Handle(endpoint.Path, handler)
-		} else {
-			for _, method := range endpoint.Methods {
-				router.Method(method, endpoint.Path, handler)
+		handler, err = withTimeout(opts, endpoint, handler)
+		if err != nil {
+			return fmt.Errorf("error in endpoint %s: %w", endpoint.Path, err)
+		}
+
+		handler, err = withInputs(opts, endpoint, handler)
+		if err != nil {
+			return fmt.Errorf("error in endpoint %s: %w", endpoint.Path, err)
+		}
+
+		handler, err = withCache(opts, endpoint, read, handler)
+		if err != nil {
+			return fmt.Errorf("error in endpoint %s: %w", endpoint.Path, err)
+		}
+
+		handler = withInvalidate(opts, endpoint, written, handler)
+
+		handler, err = withRateLimit(opts, endpoint, handler)
+		if err != nil {
+			return fmt.Errorf("error in endpoint %s: %w", endpoint.Path, err)
+		}
+
+		for _, route := range routes {
+			if route.Path == "" {
+				continue
+			}
+			if len(route.Methods) == 0 {
+				router.Handle(route.Path, handler)
+				continue
+			}
+			for _, method := range route.Methods {
+				router.Method(method, route.Path, handler)
 			}
 		}
 	}
@@ -0,0 +1,48 @@
+package sql
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// connections selects the connection pool for the queries of a pipeline.
+// Reads go to the replica until the pipeline writes, after which all
+// queries use the primary, so that a pipeline reads its own writes.
+// In a pipeline transaction, all queries use the transaction.
+type connections struct {
+	// ctx is the request context, which cancels the queries.
+	ctx     context.Context
+	primary *sqlx.DB
+	replica *sqlx.DB
+	tx      *sqlx.Tx
+	wrote   bool
+}
+
+// newConnections returns the connections for a single pipeline run.
+func (h *Handler) newConnections(ctx context.Context) *connections {
+	return &connections{
+		ctx:     ctx,
+		primary: h.db,
+		replica: h.replica,
+	}
+}
+
+// pick returns the pool for a read or write query.
+func (c *connections) pick(write bool) *sqlx.DB {
+	if write {
+		c.wrote = true
+	}
+	if c.wrote || c.replica == nil {
+		return c.primary
+	}
+	return c.replica
+}
+
+// ext returns the pipeline transaction, or the pool for a read or write query.
+func (c *connections) ext(write bool) sqlx.ExtContext {
+	if c.tx != nil {
+		return c.tx
+	}
+	return c.pick(write)
+}
@@ -0,0 +1,27 @@
+package sql
+
+import (
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+)
+
+// TestConnectionsWithoutReplica verifies that all queries use the primary without a replica.
+func TestConnectionsWithoutReplica(t *testing.T) {
+	primary := &sqlx.DB{}
+	conns := &connections{primary: primary}
+
+	require.Same(t, primary, conns.pick(false))
+	require.Same(t, primary, conns.pick(true))
+}
+
+// TestConnectionsReadYourWrites verifies that reads use the replica until the pipeline writes.
+func TestConnectionsReadYourWrites(t *testing.T) {
+	primary, replica := &sqlx.DB{}, &sqlx.DB{}
+	conns := &connections{primary: primary, replica: replica}
+
+	require.Same(t, replica, conns.pick(false))
+	require.Same(t, primary, conns.pick(true))
+	require.Same(t, primary, conns.pick(false))
+}
@@ -3,26 +3,32 @@ package sql
 import (
 	"bytes"
 	"context"
-	"crypto/md5"
+	stdsql "database/sql"
 	"encoding/json"
 	"fmt"
 	"io/fs"
 	"log"
 	"net/http"
 	"regexp"
+	"sort"
 	"strings"
-	"sync"
 	"time"
 
-	"github.com/expr-lang/expr"
-	"github.com/go-bridget/mig/db"
 	"github.com/go-chi/chi/v5"
 	"github.com/jmoiron/sqlx"
-	"golang.org/x/time/rate"
 
 	"github.com/titpetric/vuego"
 
+	"github.com/titpetric/etl/drivers"
 	"github.com/titpetric/etl/server/config"
+	"github.com/titpetric/etl/server/internal/db/columns"
+	"github.com/titpetric/etl/server/internal/db/filter"
+	"github.com/titpetric/etl/server/internal/db/named"
+	"github.com/titpetric/etl/server/internal/eval"
+	handlermodel "github.com/titpetric/etl/server/internal/handler/model"
+	"github.com/titpetric/etl/server/internal/input"
+	"github.com/titpetric/etl/server/internal/pagination"
+	"github.com/titpetric/etl/server/internal/problem"
 )
 
 // Handler represents a handler that executes SQL queries from a pipeline.
@@ -36,24 +42,30 @@ type Handler struct {
 
 	// Extensions for advanced features
 	Transaction *config.Transaction
-	Cache       *config.Cache
-	RateLimit   *config.RateLimit
 	Response    *config.Response
+	Filter      *config.Filter
+	Pagination  *config.Pagination
+	Columns     map[string]string
 
 	// Server features for conditional execution
 	Features map[string]bool
 
-	// Internal state for rate limiting and caching
-	limiter    *rate.Limiter
-	cacheStore map[string]*cacheEntry
-	cacheMutex sync.RWMutex
-	tpl        vuego.Template
+	// Internal state for the connection pool
+	db        *sqlx.DB
+	replica   *sqlx.DB
+	txOptions *stdsql.TxOptions
+	tpl       vuego.Template
+	errors    *problem.Mapper
+	dialect   drivers.Dialect
+	filter    *filter.Filter
+	pages     *pagination.Paginator
 }
 
-// cacheEntry stores cached response with expiration time
-type cacheEntry struct {
-	data      interface{}
-	expiresAt time.Time
+// listQuery holds the filters and the page selected by a request.
+// They apply to the query producing the result.
+type listQuery struct {
+	filter *filter.Query
+	page   *pagination.Page
 }
 
 // NewHandler creates a new Handler.
@@ -65,68 +77,36 @@ func NewHandler() *Handler {
 
 // ServeHTTP handles the request and executes the query pipeline.
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	// Check rate limiting if enabled
-	if h.RateLimit != nil && h.RateLimit.Enabled && h.limiter != nil {
-		if !h.limiter.Allow() {
-			http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
-			return
-		}
-	}
-
-	// Build cache key if caching is enabled
-	var cacheKey string
-	if h.Cache != nil && h.Cache.Enabled {
-		cacheKey = h.buildCacheKey(r)
-		if cached, ok := h.getFromCache(cacheKey); ok {
-			w.Header().Set("Content-Type", "application/json")
-			w.Header().Set("X-Cache", "HIT")
-			json.NewEncoder(w).Encode(cached)
-			return
-		}
-	}
+	// Collect parameters from various sources
+	queryParams := h.collectParameters(r)
 
-	// Open the database connection
-	driver, dsn := db.ParseDSN(h.Storage.DSN)
-	sqldb, err := sqlx.Open(driver, dsn)
+	// Read the filters and the page for the result
+	list, err := h.listQuery(r)
 	if err != nil {
-		log.Println(err)
-		http.Error(w, "Error connecting to the database", http.StatusInternalServerError)
+		h.errors.Write(w, err)
 		return
 	}
-	defer sqldb.Close()
-
-	// Collect parameters from various sources
-	queryParams := h.collectParameters(r)
 
 	// Execute query pipeline
-	result, execErr := h.executePipeline(sqldb, queryParams)
+	result, execErr := h.execute(r.Context(), queryParams, list)
+	if execErr == nil && result == nil && h.Single {
+		execErr = problem.ErrNotFound
+	}
 	if execErr != nil {
-		log.Printf("Error executing query pipeline: %v", execErr)
-		http.Error(w, "Error executing query pipeline", http.StatusInternalServerError)
+		h.errors.Write(w, execErr)
 		return
 	}
 
-	// Cache the result if caching is enabled
-	if h.Cache != nil && h.Cache.Enabled && cacheKey != "" {
-		h.setInCache(cacheKey, result)
+	if res, ok := result.(*pagination.Result); ok {
+		result = h.pages.Respond(w, r, res, res.Rows)
 	}
 
 	// Set response headers
 	h.setResponseHeaders(w)
 
-	// Add rate limit headers if rate limiting is enabled
-	if h.RateLimit != nil && h.RateLimit.Enabled {
-		h.setRateLimitHeaders(w)
-	}
-
-	// Add cache headers if caching is enabled
-	if h.Cache != nil && h.Cache.Enabled && cacheKey != "" {
-		w.Header().Set("X-Cache", "MISS")
-	}
-
 	// Render response: template if specified, otherwise JSON
 	if h.Response != nil && h.Response.Template != "" {
-		h.renderTemplateResponse(w, result)
+		h.renderTemplateResponse(r.Context(), w, result)
 	} else {
 		if err := json.NewEncoder(w).Encode(result); err != nil {
 			log.Println("Error encoding json response:", err)
@@ -169,11 +149,48 @@ func (h *Handler) collectParameters(r *http.Request) map[string]interface{} {
 		}
 	}
 
+	// 5. Validated inputs, converted to their declared types
+	for k, v := range input.Values(r.Context()) {
+		params[k] = v
+	}
+
 	return params
 }
 
-// executePipeline executes the query pipeline with conditional and loop support
-func (h *Handler) executePipeline(db *sqlx.DB, baseParams map[string]interface{}) (interface{}, error) {
+// listQuery reads the filters and the page from the request, if the
+// endpoint is filtered or paginated.
+func (h *Handler) listQuery(r *http.Request) (*listQuery, error) {
+	if h.filter == nil && h.pages == nil {
+		return nil, nil
+	}
+
+	result := &listQuery{}
+	if h.filter != nil {
+		var err error
+		if result.filter, err = h.filter.Parse(r.URL.Query()); err != nil {
+			return nil, err
+		}
+	}
+	if h.pages != nil {
+		var err error
+		if result.page, err = h.pages.Page(r); err != nil {
+			return nil, err
+		}
+	}
+
+	// The page query sorts the rows, so the ORDER BY is outermost.
+	if result.filter != nil && result.page != nil {
+		if err := result.page.Sort(result.filter.Order); err != nil {
+			return nil, err
+		}
+		result.filter.Order = nil
+	}
+	return result, nil
+}
+
+// executePipeline executes the query pipeline with conditional and loop support.
+// With a list, the query producing the result is filtered and paginated.
+func (h *Handler) executePipeline(conns *connections, baseParams map[string]interface{}, list *listQuery) (interface{}, error) {
 	// Build scope context with features and base parameters
 	scope := make(map[string]interface{})
 	for k, v := range baseParams {
@@ -209,16 +226,38 @@ func (h *Handler) executePipeline(db *sqlx.DB, baseParams map[string]interface{}
 			}
 		}
 
+		// Stop the pipeline if an assertion fails
+		if qdef.Assert != "" || qdef.Fail {
+			if err := h.executeAssert(qdef, scope); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		// Assign computed values
+		if len(qdef.Set) > 0 {
+			if err := h.executeSet(qdef, scope); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
 		// Handle loop-based execution
 		if qdef.For != "" {
-			if err := h.executeLoop(db, qdef, scope); err != nil {
+			if err := h.executeLoop(conns, qdef, scope); err != nil {
 				return nil, err
 			}
 			continue
 		}
 
 		// Regular query execution
-		queryResult, err := h.executeQuery(db, qdef.Query, scope)
+		var queryResult interface{}
+		var err error
+		if list != nil && qdef.As == "" {
+			queryResult, err = h.executeList(conns.ctx, conns.ext(false), qdef.Query, scope, list)
+		} else {
+			queryResult, err = h.executeQuery(conns, qdef.Query, scope)
+		}
 		if err != nil {
 			return nil, err
 		}
@@ -265,12 +304,7 @@ func (h *Handler) executePipeline(db *sqlx.DB, baseParams map[string]interface{}
 
 // evaluateCondition evaluates an expression against the scope
 func (h *Handler) evaluateCondition(condition string, scope map[string]interface{}) (bool, error) {
-	program, err := expr.Compile(condition)
-	if err != nil {
-		return false, fmt.Errorf("condition compile error: %w", err)
-	}
-
-	result, err := expr.Run(program, scope)
+	result, err := eval.Eval(condition, scope)
 	if err != nil {
 		return false, fmt.Errorf("condition evaluation error: %w", err)
 	}
@@ -286,40 +320,87 @@ func (h *Handler) evaluateCondition(condition string, scope map[string]interface
 	}
 }
 
+// executeSet evaluates the expressions of a set step against the scope,
+// and stores the results at their paths. The expressions see the scope
+// from before the step, so the results can be used by later steps.
+func (h *Handler) executeSet(qdef *config.QueryDef, scope map[string]interface{}) error {
+	paths := make([]string, 0, len(qdef.Set))
+	for path := range qdef.Set {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	values := make([]interface{}, len(paths))
+	for i, path := range paths {
+		value, err := eval.Eval(qdef.Set[path], scope)
+		if err != nil {
+			return fmt.Errorf("error evaluating set %s: %w", path, err)
+		}
+		values[i] = value
+	}
+
+	for i, path := range paths {
+		if err := h.setAtPath(scope, path, values[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// executeAssert returns the error response of a fail step, or of an
+// assert step if its condition isn't true.
+func (h *Handler) executeAssert(qdef *config.QueryDef, scope map[string]interface{}) error {
+	if qdef.Assert != "" {
+		ok, err := h.evaluateCondition(qdef.Assert, scope)
+		if err != nil {
+			return fmt.Errorf("assertion evaluation failed: %w", err)
+		}
+		if ok {
+			return nil
+		}
+	}
+	return &problem.Error{
+		Status: qdef.Status,
+		Detail: qdef.Message,
+	}
+}
+
 // executeQuery executes a single query and returns results
-func (h *Handler) executeQuery(db *sqlx.DB, query string, params map[string]interface{}) (interface{}, error) {
+func (h *Handler) executeQuery(conns *connections, query string, params map[string]interface{}) (interface{}, error) {
+	// In a pipeline transaction, all queries run on the transaction
+	if conns.tx != nil {
+		return h.executeQueryDirect(conns.ctx, conns.tx, query, params)
+	}
+
 	// Check if it's a write operation
 	needsTransaction := h.shouldUseTransaction(query)
 
+	// Writes go to the primary, reads may use the replica
+	db := conns.pick(needsTransaction)
+
 	if needsTransaction && h.Transaction != nil && h.Transaction.Enabled {
-		return h.executeWithTransaction(db, query, params)
+		return h.executeWithTransaction(conns.ctx, db, query, params)
 	}
 
-	return h.executeQueryDirect(db, query, params)
+	return h.executeQueryDirect(conns.ctx, db, query, params)
 }
 
 // executeQueryDirect executes a query directly without transaction
-func (h *Handler) executeQueryDirect(db *sqlx.DB, query string, params map[string]interface{}) (interface{}, error) {
-	rows, err := db.NamedQuery(query, params)
-	if err != nil {
+func (h *Handler) executeQueryDirect(ctx context.Context, db sqlx.ExtContext, query string, params map[string]interface{}) (interface{}, error) {
+	if err := named.Check(query, params); err != nil {
 		return nil, err
 	}
+
+	rows, err := sqlx.NamedQueryContext(ctx, db, query, params)
+	if err != nil {
+		return nil, drivers.NewError(err, query)
+	}
 	defer rows.Close()
 
 	// Process the rows
-	results := []map[string]string{}
-	for rows.Next() {
-		row := map[string]interface{}{}
-		if err := rows.MapScan(row); err != nil {
-			return nil, err
-		}
-
-		result := make(map[string]string, len(row))
-		for k, v := range row {
-			result[strings.ToLower(k)] = dbValue(v)
-		}
-
-		results = append(results, result)
+	results, err := columns.Rows(rows, h.Columns)
+	if err != nil {
+		return nil, drivers.NewError(err, query)
 	}
 
 	if len(results) == 0 {
@@ -331,85 +412,75 @@ func (h *Handler) executeQueryDirect(db *sqlx.DB, query string, params map[strin
 	return results, nil
 }
 
-// executeWithTransaction executes a query with transaction and retries
-func (h *Handler) executeWithTransaction(db *sqlx.DB, query string, params map[string]interface{}) (interface{}, error) {
-	retries := 1
-	if h.Transaction != nil && h.Transaction.Retries > 0 {
-		retries = h.Transaction.Retries + 1
+// executeList filters and sorts a query, and executes it for a page
+// of results if the endpoint is paginated.
+func (h *Handler) executeList(ctx context.Context, db sqlx.ExtContext, query string, params map[string]interface{}, list *listQuery) (interface{}, error) {
+	if list.filter != nil {
+		query, params = list.filter.Apply(h.dialect, query, params)
 	}
+	if list.page != nil {
+		return h.executePage(ctx, db, query, params, list.page)
+	}
+	return h.executeQueryDirect(ctx, db, query, params)
+}
 
-	var lastErr error
-	for attempt := 0; attempt < retries; attempt++ {
-		if attempt > 0 {
-			delayMs := 100
-			if h.Transaction != nil && h.Transaction.RetryDelayMs > 0 {
-				delayMs = h.Transaction.RetryDelayMs
-			}
-			time.Sleep(time.Duration(delayMs) * time.Millisecond)
-			log.Printf("Retrying transaction (attempt %d/%d)\n", attempt+1, retries)
-		}
-
-		tx, err := db.Beginx()
-		if err != nil {
-			lastErr = err
-			continue
-		}
-
-		result, err := h.executeQueryTx(tx, query, params)
-		if err != nil {
-			tx.Rollback()
-			lastErr = err
-			continue
-		}
-
-		if err := tx.Commit(); err != nil {
-			lastErr = err
-			continue
-		}
+// executePage executes a query for a page of results, and counts the
+// rows if the pagination is configured to.
+func (h *Handler) executePage(ctx context.Context, db sqlx.ExtContext, query string, params map[string]interface{}, page *pagination.Page) (*pagination.Result, error) {
+	pageQuery, args := page.Query(h.dialect, query, params)
+	if err := named.Check(pageQuery, args); err != nil {
+		return nil, err
+	}
 
-		return result, nil
+	rows, err := sqlx.NamedQueryContext(ctx, db, pageQuery, args)
+	if err != nil {
+		return nil, drivers.NewError(err, pageQuery)
 	}
+	defer rows.Close()
 
-	return nil, fmt.Errorf("transaction failed after %d attempts: %w", retries, lastErr)
-}
+	// The page is selected by the scanned values, then converted
+	results, types, err := columns.Scan(rows, h.Columns)
+	if err != nil {
+		return nil, drivers.NewError(err, pageQuery)
+	}
 
-// executeQueryTx executes a query within a transaction
-func (h *Handler) executeQueryTx(tx *sqlx.Tx, query string, params map[string]interface{}) (interface{}, error) {
-	rows, err := tx.NamedQuery(query, params)
+	res, err := page.Result(results)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	res.Rows = types.Rows(res.Rows)
 
-	results := []map[string]string{}
-	for rows.Next() {
-		row := map[string]interface{}{}
-		if err := rows.MapScan(row); err != nil {
-			return nil, err
+	if h.pages.Count() {
+		countQuery := h.pages.CountQuery(query)
+		rows, err := sqlx.NamedQueryContext(ctx, db, countQuery, params)
+		if err != nil {
+			return nil, drivers.NewError(err, countQuery)
 		}
+		defer rows.Close()
 
-		result := make(map[string]string, len(row))
-		for k, v := range row {
-			result[strings.ToLower(k)] = dbValue(v)
+		var total int64
+		if rows.Next() {
+			if err := rows.Scan(&total); err != nil {
+				return nil, err
+			}
 		}
-
-		results = append(results, result)
+		if err := rows.Err(); err != nil {
+			return nil, drivers.NewError(err, countQuery)
+		}
+		res.Total = &total
 	}
 
-	if len(results) == 0 {
-		return nil, nil
-	}
-	if h.Single || len(results) == 1 {
-		return results[0], nil
-	}
-	return results, nil
+	return res, nil
 }
 
+// identRe matches loop arrays given by name.
+var identRe = regexp.MustCompile(`^\w+$`)
+
 // executeLoop executes a query for each item in an array
-func (h *Handler) executeLoop(db *sqlx.DB, qdef *config.QueryDef, scope map[string]interface{}) error {
+func (h *Handler) executeLoop(conns *connections, qdef *config.QueryDef, scope map[string]interface{}) error {
 	// Parse loop expression: (idx, item) in items
-	loopRe := regexp.MustCompile(`\(\s*(\w+)\s*,\s*(\w+)\s*\)\s+in\s+(\w+)`)
-	matches := loopRe.FindStringSubmatch(qdef.For)
+	loopRe := regexp.MustCompile(`^\(\s*(\w+)\s*,\s*(\w+)\s*\)\s+in\s+(.+)$`)
+	matches := loopRe.FindStringSubmatch(strings.TrimSpace(qdef.For))
 	if len(matches) != 4 {
 		return fmt.Errorf("invalid for expression: %s", qdef.For)
 	}
@@ -418,41 +489,52 @@ func (h *Handler) executeLoop(db *sqlx.DB, qdef *config.QueryDef, scope map[stri
 	itemVar := matches[2]
 	arrayName := matches[3]
 
-	// Get the array from scope
+	// Get the array from scope, or evaluate an expression like split(tags, ",")
 	arrayVal, ok := scope[arrayName]
-	if !ok {
+	if !identRe.MatchString(arrayName) {
+		var err error
+		if arrayVal, err = eval.Eval(arrayName, scope); err != nil {
+			return fmt.Errorf("error evaluating for expression: %w", err)
+		}
+	} else if !ok {
 		return fmt.Errorf("array %s not found in scope", arrayName)
 	}
 
-	// Convert to array of maps
+	// Convert to array of items
 	var items []interface{}
 	switch v := arrayVal.(type) {
 	case []interface{}:
 		items = v
-	case []map[string]string:
+	case []map[string]any:
 		for _, m := range v {
 			items = append(items, m)
 		}
+	case []string:
+		for _, s := range v {
+			items = append(items, s)
+		}
+	case nil:
 	default:
 		return fmt.Errorf("cannot iterate over %T", arrayVal)
 	}
 
+	loop := &loop{idxVar: idxVar, itemVar: itemVar, items: items}
+	if qdef.Batch != nil {
+		return h.executeBatch(conns, qdef, scope, loop)
+	}
+
 	// Execute query for each item
-	for idx, item := range items {
-		// Build item scope with loop variables
-		itemScope := make(map[string]interface{})
-		itemScope[idxVar] = idx
-		itemScope[itemVar] = item
-
-		// Execute query with item in scope
-		result, err := h.executeQuery(db, qdef.Query, itemScope)
+	for idx := range items {
+		// Execute query with the loop variables in scope
+		itemScope := loop.scope(scope, idx)
+		result, err := h.executeQuery(conns, qdef.Query, itemScope)
 		if err != nil {
 			return err
 		}
 
 		// Store result at specified path
 		if qdef.As != "" {
-			if err := h.setAtPath(scope, qdef.As, result); err != nil {
+			if err := h.setLoopResult(scope, itemScope, qdef.As, result); err != nil {
 				return err
 			}
 		}
@@ -461,6 +543,203 @@ func (h *Handler) executeLoop(db *sqlx.DB, qdef *config.QueryDef, scope map[stri
 	return nil
 }
 
+// loop holds the variable names and the items of a for loop.
+type loop struct {
+	idxVar  string
+	itemVar string
+	items   []interface{}
+}
+
+// scope returns the scope of a loop iteration: the outer scope with
+// the loop variables. The fields of map items are added with dotted
+// names, so that queries can bind them, e.g. :item.id.
+func (l *loop) scope(scope map[string]interface{}, idx int) map[string]interface{} {
+	result := make(map[string]interface{}, len(scope)+2)
+	for k, v := range scope {
+		result[k] = v
+	}
+	result[l.idxVar] = idx
+	result[l.itemVar] = l.items[idx]
+	flatten(result, l.itemVar, l.items[idx])
+	return result
+}
+
+// deepCopy copies the maps and slices of a decoded value, so writes to
+// the copy don't change the original.
+func deepCopy(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			result[k] = deepCopy(item)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i] = deepCopy(item)
+		}
+		return result
+	case []map[string]interface{}:
+		result := make([]map[string]interface{}, len(v))
+		for i, item := range v {
+			result[i] = deepCopy(item).(map[string]interface{})
+		}
+		return result
+	}
+	return value
+}
+
+// flatten adds the fields of nested maps to params as prefix.field.
+func flatten(params map[string]interface{}, prefix string, value interface{}) {
+	fields, ok := value.(map[string]interface{})
+	if !ok {
+		return
+	}
+	for k, v := range fields {
+		name := prefix + "." + k
+		params[name] = v
+		flatten(params, name, v)
+	}
+}
+
+// setLoopResult stores the result of a loop iteration. Indexed paths
+// like "items[idx].user" are resolved in the iteration scope, which
+// shares the arrays of the outer scope. Other paths are set in the
+// outer scope.
+func (h *Handler) setLoopResult(scope, itemScope map[string]interface{}, path string, value interface{}) error {
+	if strings.Contains(path, "[") {
+		return h.setAtPath(itemScope, path, value)
+	}
+	return h.setAtPath(scope, path, value)
+}
+
+// DefaultBatchSize is the number of keys loaded by a batch query, if unset.
+const DefaultBatchSize = 500
+
+// batchKeys is the parameter of a batch query replaced by the keys.
+const batchKeys = "keys"
+
+// executeBatch runs a loop query once for the keys of all the items,
+// instead of once per item. The keys are bound as a list of parameters
+// in place of :keys, and the result rows are stored on the items with
+// a matching key column. Large batches are split into several queries.
+func (h *Handler) executeBatch(conns *connections, qdef *config.QueryDef, scope map[string]interface{}, loop *loop) error {
+	batch := qdef.Batch
+	size := batch.Size
+	if size <= 0 {
+		size = DefaultBatchSize
+	}
+
+	// Collect the distinct keys of the items
+	typ := h.columnType(batch.Column)
+	keys := make([]interface{}, len(loop.items))
+	var values []interface{}
+	seen := map[string]bool{}
+	for idx := range loop.items {
+		value, err := eval.Eval(batch.Key, loop.scope(scope, idx))
+		if err != nil {
+			return fmt.Errorf("error evaluating batch key: %w", err)
+		}
+		if value == nil {
+			continue
+		}
+
+		key := batchKey(typ, value)
+		keys[idx] = key
+		if !seen[key] {
+			seen[key] = true
+			values = append(values, value)
+		}
+	}
+
+	// Load the rows for the keys, grouped by the key column
+	column := strings.ToLower(batch.Column)
+	groups := map[string][]map[string]any{}
+	for start := 0; start < len(values); start += size {
+		chunk := values[start:min(start+size, len(values))]
+		rows, err := h.queryBatch(conns, qdef.Query, scope, chunk)
+		if err != nil {
+			return err
+		}
+		for _, row := range rows {
+			key := batchKey(typ, row[column])
+			groups[key] = append(groups[key], row)
+		}
+	}
+
+	// Store the rows on the items, or the first row with single
+	for idx := range loop.items {
+		var rows []map[string]any
+		if key, ok := keys[idx].(string); ok {
+			rows = groups[key]
+		}
+
+		var result interface{} = rows
+		switch {
+		case batch.Single && len(rows) > 0:
+			result = rows[0]
+		case batch.Single:
+			result = nil
+		case rows == nil:
+			result = []map[string]any{}
+		}
+
+		if err := h.setLoopResult(scope, loop.scope(scope, idx), qdef.As, result); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// columnType returns the declared type of a result column, or an empty string.
+func (h *Handler) columnType(column string) string {
+	for name, typ := range h.Columns {
+		if strings.EqualFold(name, column) {
+			return typ
+		}
+	}
+	return ""
+}
+
+// batchKey returns the key of an item or a row in a batch. The values
+// are converted like the values of the key column, so that keys match
+// when their types differ, e.g. a time and its string.
+func batchKey(typ string, value any) string {
+	return fmt.Sprint(columns.Value(typ, value))
+}
+
+// queryBatch runs a batch query for the keys and returns the rows.
+func (h *Handler) queryBatch(conns *connections, query string, scope map[string]interface{}, keys []interface{}) ([]map[string]any, error) {
+	params := make(map[string]interface{}, len(scope)+len(keys))
+	for k, v := range scope {
+		params[k] = v
+	}
+
+	names := make([]string, 0, len(keys))
+	for i, key := range keys {
+		name := fmt.Sprintf("_keys%d", i)
+		names = append(names, ":"+name)
+		params[name] = key
+	}
+	query = named.Replace(query, batchKeys, strings.Join(names, ", "))
+	if err := named.Check(query, params); err != nil {
+		return nil, err
+	}
+
+	rows, err := sqlx.NamedQueryContext(conns.ctx, conns.ext(false), query, params)
+	if err != nil {
+		return nil, drivers.NewError(err, query)
+	}
+	defer rows.Close()
+
+	result, err := columns.Rows(rows, h.Columns)
+	if err != nil {
+		return nil, drivers.NewError(err, query)
+	}
+	return result, nil
+}
+
 // setAtPath stores a value at a path in the scope
 func (h *Handler) setAtPath(scope map[string]interface{}, path string, value interface{}) error {
 	// Handle array indexing: "items[idx].user"
@@ -519,22 +798,26 @@ func (h *Handler) setAtPathWithIndex(scope map[string]interface{}, path string,
 		return fmt.Errorf("index %s is not an integer", indexVar)
 	}
 
-	// Get the item array
-	items, ok := arrayVal.([]interface{})
-	if !ok {
-		return fmt.Errorf("cannot index non-array type %T", arrayVal)
-	}
-
-	if idx < 0 || idx >= len(items) {
-		return fmt.Errorf("index %d out of bounds", idx)
-	}
+	// Get the item from the array, or from the rows of a query
+	var item map[string]interface{}
+	switch items := arrayVal.(type) {
+	case []interface{}:
+		if idx < 0 || idx >= len(items) {
+			return fmt.Errorf("index %d out of bounds", idx)
+		}
 
-	// Set value in the item
-	item, ok := items[idx].(map[string]interface{})
-	if !ok {
 		// Convert to map if needed
-		item = make(map[string]interface{})
-		items[idx] = item
+		if item, ok = items[idx].(map[string]interface{}); !ok {
+			item = make(map[string]interface{})
+			items[idx] = item
+		}
+	case []map[string]interface{}:
+		if idx < 0 || idx >= len(items) {
+			return fmt.Errorf("index %d out of bounds", idx)
+		}
+		item = items[idx]
+	default:
+		return fmt.Errorf("cannot index non-array type %T", arrayVal)
 	}
 
 	if restPath == "" {
@@ -553,56 +836,15 @@ func (h *Handler) shouldUseTransaction(query string) bool {
 		strings.HasPrefix(query, "DELETE")
 }
 
-// buildCacheKey creates a cache key from the request
-func (h *Handler) buildCacheKey(r *http.Request) string {
-	pattern := "default"
-	if h.Cache != nil && h.Cache.KeyPattern != "" {
-		pattern = h.Cache.KeyPattern
-	}
-
-	keyData := fmt.Sprintf("%s:%s:%s", pattern, r.Method, r.URL.String())
-	hash := md5.Sum([]byte(keyData))
-	return fmt.Sprintf("cache:%x", hash)
-}
-
-// getFromCache retrieves a cached entry if it hasn't expired
-func (h *Handler) getFromCache(key string) (interface{}, bool) {
-	h.cacheMutex.RLock()
-	defer h.cacheMutex.RUnlock()
-
-	entry, exists := h.cacheStore[key]
-	if !exists || time.Now().After(entry.expiresAt) {
-		return nil, false
-	}
-
-	return entry.data, true
-}
-
-// setInCache stores a response in the cache
-func (h *Handler) setInCache(key string, data interface{}) {
-	h.cacheMutex.Lock()
-	defer h.cacheMutex.Unlock()
-
-	ttl := 5 * time.Minute
-	if h.Cache != nil && h.Cache.Expire != "" {
-		if duration, err := time.ParseDuration(h.Cache.Expire); err == nil {
-			ttl = duration
-		}
-	}
-
-	h.cacheStore[key] = &cacheEntry{
-		data:      data,
-		expiresAt: time.Now().Add(ttl),
-	}
-}
-
 // Type returns the handler type
 func (h *Handler) Type() string {
 	return "sql"
 }
 
 // Handler creates a new handler instance from endpoint config
-func (h *Handler) Handler(conf *config.Config, endpoint *config.Endpoint) (http.Handler, error) {
+func (h *Handler) Handler(opts *handlermodel.Options, endpoint *config.Endpoint) (http.Handler, error) {
+	conf := opts.Config
+
 	handle := NewHandler()
 	err := endpoint.Handler.Decode(handle)
 	if err != nil {
@@ -614,69 +856,117 @@ func (h *Handler) Handler(conf *config.Config, endpoint *config.Endpoint) (http.
 		handle.Storage = conf.Storage
 	}
 
+	// Resolve a storage given by name
+	handle.Storage, err = conf.ResolveStorage(handle.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	// Use the shared connection pool for the storage
+	handle.db, err = opts.Storage.Get(handle.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	// Route read-only queries to the replica storage if configured
+	if handle.replica, err = opts.Storage.Replica(conf, handle.Storage); err != nil {
+		return nil, err
+	}
+
 	// Copy handler configuration
 	handle.Transaction = endpoint.Handler.Transaction
-	handle.Cache = endpoint.Handler.Cache
-	handle.RateLimit = endpoint.Handler.RateLimit
+	if handle.txOptions, err = txOptions(handle.Transaction); err != nil {
+		return nil, err
+	}
 	handle.Response = endpoint.Handler.Response
 	handle.Query = endpoint.Handler.Query
 	handle.Queries = endpoint.Handler.Queries
+	if err := checkQueries(handle.Queries); err != nil {
+		return nil, err
+	}
 	handle.Single = endpoint.Handler.Single
 	handle.Parameters = endpoint.Handler.Parameters
+	handle.Pagination = endpoint.Handler.Pagination
+	handle.Columns = endpoint.Handler.Columns
 
-	// Copy features from server config
-	if conf.Server.Features != nil {
-		handle.Features = conf.Server.Features
+	// Convert the result columns to the declared types
+	if err := columns.Check(handle.Columns); err != nil {
+		return nil, err
 	}
 
-	// Initialize cache store
-	handle.cacheStore = make(map[string]*cacheEntry)
-
-	// Initialize rate limiter if enabled
-	if handle.RateLimit != nil && handle.RateLimit.Enabled && handle.RateLimit.Rate > 0 {
-		// Parse the "per" duration (default to "1m" if unset)
-		perDuration := "1m"
-		if handle.RateLimit.Per != "" {
-			perDuration = handle.RateLimit.Per
+	// Filter and sort the query result if configured
+	if handle.Filter = endpoint.Handler.Filter; handle.Filter != nil {
+		if handle.dialect, err = drivers.DialectOf(handle.db); err != nil {
+			return nil, err
 		}
-
-		duration, err := time.ParseDuration(perDuration)
-		if err != nil {
-			log.Printf("Invalid duration for rate limit 'per': %v, using default 1m", err)
-			duration = time.Minute
+		if handle.filter, err = filter.New(handle.Filter); err != nil {
+			return nil, err
 		}
-
-		// Convert requests per duration to requests per second
-		// e.g., 100 requests per 1m = 1.67 req/s
-		rps := float64(handle.RateLimit.Rate) / duration.Seconds()
-		burstSize := handle.RateLimit.Rate
-
-		handle.limiter = rate.NewLimiter(rate.Limit(rps), burstSize)
-		// log.Printf("Rate limiting enabled: %d req/%s (%.2f req/s) with burst size %d", handle.RateLimit.Rate, perDuration, rps, burstSize)
 	}
 
-	// Log cache configuration if enabled
-	if handle.Cache != nil && handle.Cache.Enabled {
-		expire := handle.Cache.Expire
-		if expire == "" {
-			expire = "5m"
+	// Paginate the query result if configured
+	if handle.Pagination != nil {
+		if handle.Single {
+			return nil, fmt.Errorf("pagination can't be used with single")
+		}
+		if handle.pages, err = pagination.New(handle.Pagination); err != nil {
+			return nil, err
+		}
+		if handle.filter != nil {
+			if err := handle.pages.CheckSort(handle.filter.DefaultSort()); err != nil {
+				return nil, fmt.Errorf("invalid defaultSort: %w", err)
+			}
 		}
-		// log.Printf("Cache enabled: TTL %s", expire)
 	}
 
-	// Log transaction configuration if enabled
-	if handle.Transaction != nil && handle.Transaction.Enabled {
-		//retries := handle.Transaction.Retries
-		delayMs := handle.Transaction.RetryDelayMs
-		if delayMs <= 0 {
-			delayMs = 100
-		}
-		// log.Printf("Transaction support enabled: %d retries with %dms delay", retries, delayMs)
+	// Copy features from server config
+	if conf.Server.Features != nil {
+		handle.Features = conf.Server.Features
+	}
+
+	// Map errors to problem responses, with the endpoint overrides
+	handle.errors, err = problem.NewMapper(endpoint.Handler.Errors, conf.Server.Dev)
+	if err != nil {
+		return nil, err
 	}
 
 	return handle, nil
 }
 
+// checkQueries returns an error if a pipeline step is invalid. A step
+// runs a query, or sets values, or asserts a condition, or fails.
+func checkQueries(queries []*config.QueryDef) error {
+	for i, qdef := range queries {
+		steps := 0
+		for _, ok := range []bool{qdef.Query != "", len(qdef.Set) > 0, qdef.Assert != "", qdef.Fail} {
+			if ok {
+				steps++
+			}
+		}
+		if steps != 1 {
+			return fmt.Errorf("query %d: needs one of query, set, assert or fail", i+1)
+		}
+		if qdef.For != "" && qdef.Query == "" {
+			return fmt.Errorf("query %d: for needs a query", i+1)
+		}
+		if batch := qdef.Batch; batch != nil {
+			if qdef.For == "" || qdef.As == "" {
+				return fmt.Errorf("query %d: batch needs for and as", i+1)
+			}
+			if batch.Key == "" || batch.Column == "" {
+				return fmt.Errorf("query %d: batch needs a key and a column", i+1)
+			}
+			if !named.Uses(qdef.Query, batchKeys) {
+				return fmt.Errorf("query %d: batch query needs a :keys parameter", i+1)
+			}
+		}
+		if qdef.Status != 0 && (qdef.Status < 400 || qdef.Status > 599) {
+			return fmt.Errorf("query %d: invalid status %d", i+1, qdef.Status)
+		}
+	}
+	return nil
+}
+
 // setResponseHeaders sets custom response headers
 func (h *Handler) setResponseHeaders(w http.ResponseWriter) {
 	if h.Response == nil {
@@ -704,26 +994,8 @@ func (h *Handler) setResponseHeaders(w http.ResponseWriter) {
 	}
 }
 
-// setRateLimitHeaders adds rate limit information to response headers
-func (h *Handler) setRateLimitHeaders(w http.ResponseWriter) {
-	if h.RateLimit == nil || !h.RateLimit.Enabled {
-		return
-	}
-
-	if h.limiter == nil {
-		return
-	}
-
-	w.Header().Set("X-RateLimit-Limit", fmt.Sprintf("%d", h.RateLimit.Rate))
-	perWindow := h.RateLimit.Per
-	if perWindow == "" {
-		perWindow = "1m"
-	}
-	w.Header().Set("X-RateLimit-Window", perWindow)
-}
-
 // renderTemplateResponse renders the result using VueGo template
-func (h *Handler) renderTemplateResponse(w http.ResponseWriter, result interface{}) {
+func (h *Handler) renderTemplateResponse(ctx context.Context, w http.ResponseWriter, result interface{}) {
 	if h.Response == nil || h.Response.Template == "" {
 		// Fallback to JSON if no template
 		if err := json.NewEncoder(w).Encode(result); err != nil {
@@ -745,7 +1017,7 @@ func (h *Handler) renderTemplateResponse(w http.ResponseWriter, result interface
 	}
 
 	// Load the template and render directly from the template string
-	if err := h.tpl.New().Fill(data).RenderString(context.Background(), w, h.Response.Template); err != nil {
+	if err := h.tpl.New().Fill(data).RenderString(ctx, w, h.Response.Template); err != nil {
 		log.Printf("Error rendering template: %v", err)
 		http.Error(w, "Template rendering failed", http.StatusInternalServerError)
 		return
@@ -1,9 +1,19 @@
 package sql
 
 import (
+	"context"
 	"testing"
+	"time"
 
+	"github.com/jmoiron/sqlx"
 	"github.com/stretchr/testify/require"
+
+	"github.com/titpetric/etl/drivers"
+	"github.com/titpetric/etl/server/config"
+	"github.com/titpetric/etl/server/internal/db/columns"
+	"github.com/titpetric/etl/server/internal/input"
+
+	_ "modernc.org/sqlite"
 )
 
 // TestShouldUseTransactionInsert verifies that INSERT queries are identified as write operations.
@@ -68,3 +78,195 @@ func TestHandlerType(t *testing.T) {
 	h := NewHandler()
 	require.Equal(t, "sql", h.Type())
 }
+
+// TestTimeout verifies that a slow query is cancelled with the request context.
+func TestTimeout(t *testing.T) {
+	db, err := sqlx.Open("sqlite", ":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	h := NewHandler()
+	h.db = db
+	h.Queries = []*config.QueryDef{
+		{Query: "WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n) SELECT count(*) AS total FROM n"},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err = h.execute(ctx, map[string]interface{}{}, nil)
+	require.Error(t, err)
+	require.True(t, drivers.Timeout(err), "expected a timeout, got %v", err)
+	require.Less(t, time.Since(start), 5*time.Second)
+}
+
+// TestMissingParameter verifies that a query parameter without a value
+// is an input error, and the query doesn't run.
+func TestMissingParameter(t *testing.T) {
+	db, err := sqlx.Open("sqlite", ":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	h := NewHandler()
+	h.db = db
+	h.Queries = []*config.QueryDef{
+		{Query: "SELECT :id AS id"},
+	}
+
+	_, err = h.execute(context.Background(), map[string]interface{}{}, nil)
+	var inputErr *input.Error
+	require.ErrorAs(t, err, &inputErr)
+	require.Equal(t, []input.FieldError{{Field: "id", Message: "is required"}}, inputErr.Fields)
+
+	_, err = h.execute(context.Background(), map[string]interface{}{"id": 1}, nil)
+	require.NoError(t, err)
+}
+
+// TestSet verifies that set steps compute values for the later steps.
+func TestSet(t *testing.T) {
+	db, err := sqlx.Open("sqlite", ":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	db.MustExec(`create table post (id integer primary key, slug text, email text, tags integer)`)
+
+	h := NewHandler()
+	h.db = db
+	h.Queries = []*config.QueryDef{
+		{Set: map[string]string{"email": "lower(trim(email))", "slug": "slug(title)", "meta.size": "len(tags)"}},
+		{Set: map[string]string{"email": `"ignored"`}, If: "email == ''"},
+		{Query: "INSERT INTO post (slug, email, tags) VALUES (:slug, :email, 0)"},
+		{Query: "UPDATE post SET tags = tags + 1", For: `(idx, tag) in split(tags, ",")`},
+		{Query: "SELECT slug, email, tags FROM post", As: "post"},
+	}
+
+	result, err := h.execute(context.Background(), map[string]interface{}{"email": " Jane@Example.com ", "title": "Hello, World", "tags": "a,b,c"}, nil)
+	require.NoError(t, err)
+	// Request parameters aren't returned, even if they are set
+	require.Equal(t, map[string]interface{}{
+		"slug": "hello-world",
+		"meta": map[string]interface{}{"size": 5},
+		"post": map[string]any{"slug": "hello-world", "email": "jane@example.com", "tags": int64(3)},
+	}, result)
+
+	h.Queries = []*config.QueryDef{{Set: map[string]string{"x": "unknown(1)"}}}
+	_, err = h.execute(context.Background(), map[string]interface{}{}, nil)
+	require.ErrorContains(t, err, "error evaluating set x")
+}
+
+// testOrders returns a handler for a database with orders and their items.
+func testOrders(t *testing.T) *Handler {
+	t.Helper()
+
+	db, err := sqlx.Open("sqlite", ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	db.MustExec(`
+		create table orders (id integer primary key, customer text);
+		create table order_item (id integer primary key, order_id integer, name text, qty integer);
+		insert into orders values (1, 'alice'), (2, 'bob'), (3, 'carol');
+		insert into order_item values (1, 1, 'tea', 1), (2, 1, 'cake', 2), (3, 3, 'coffee', 3);
+	`)
+
+	h := NewHandler()
+	h.db = db
+	return h
+}
+
+// TestLoopScope verifies that loop queries see the outer scope and the item fields.
+func TestLoopScope(t *testing.T) {
+	h := testOrders(t)
+	h.Queries = []*config.QueryDef{
+		{Query: "SELECT id, customer FROM orders ORDER BY id", As: "orders"},
+		{
+			Query: "SELECT count(*) AS n, :prefix || :order.customer AS label FROM order_item WHERE order_id = :order.id AND qty >= :min",
+			For:   "(idx, order) in orders",
+			As:    "orders[idx].summary",
+		},
+	}
+	require.NoError(t, checkQueries(h.Queries))
+
+	result, err := h.execute(context.Background(), map[string]interface{}{"prefix": "#", "min": 2}, nil)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{
+		"orders": []map[string]any{
+			{"id": int64(1), "customer": "alice", "summary": map[string]any{"n": int64(1), "label": "#alice"}},
+			{"id": int64(2), "customer": "bob", "summary": map[string]any{"n": int64(0), "label": "#bob"}},
+			{"id": int64(3), "customer": "carol", "summary": map[string]any{"n": int64(1), "label": "#carol"}},
+		},
+	}, result)
+}
+
+// TestBatch verifies that a batch loads the rows for all the items with one query per batch.
+func TestBatch(t *testing.T) {
+	h := testOrders(t)
+	h.Queries = []*config.QueryDef{
+		{Query: "SELECT id, customer FROM orders ORDER BY id", As: "orders"},
+		{
+			Query: "SELECT order_id, name FROM order_item WHERE order_id IN (:keys) AND qty >= :min ORDER BY id",
+			For:   "(idx, order) in orders",
+			As:    "orders[idx].items",
+			Batch: &config.Batch{Key: "order.id", Column: "ORDER_ID", Size: 2},
+		},
+		{
+			Query: "SELECT order_id, name FROM order_item WHERE order_id IN (:keys) ORDER BY id DESC",
+			For:   "(idx, order) in orders",
+			As:    "orders[idx].last",
+			Batch: &config.Batch{Key: "order.id", Column: "order_id", Single: true},
+		},
+	}
+	require.NoError(t, checkQueries(h.Queries))
+
+	result, err := h.execute(context.Background(), map[string]interface{}{"min": 1}, nil)
+	require.NoError(t, err)
+
+	tea := map[string]any{"order_id": int64(1), "name": "tea"}
+	cake := map[string]any{"order_id": int64(1), "name": "cake"}
+	coffee := map[string]any{"order_id": int64(3), "name": "coffee"}
+	require.Equal(t, map[string]interface{}{
+		"orders": []map[string]any{
+			{"id": int64(1), "customer": "alice", "items": []map[string]any{tea, cake}, "last": cake},
+			{"id": int64(2), "customer": "bob", "items": []map[string]any{}, "last": nil},
+			{"id": int64(3), "customer": "carol", "items": []map[string]any{coffee}, "last": coffee},
+		},
+	}, result)
+}
+
+// TestBatchQuery verifies the expansion of the keys, and the batch config checks.
+func TestBatchQuery(t *testing.T) {
+	h := testOrders(t)
+	h.Queries = []*config.QueryDef{
+		{Query: "SELECT id, customer FROM orders ORDER BY id", As: "orders"},
+		{
+			Query: "SELECT order_id, name, '::keys' AS note FROM order_item WHERE order_id IN (:keys) -- :keys",
+			For:   "(idx, order) in orders",
+			As:    "orders[idx].first",
+			Batch: &config.Batch{Key: "order.id", Column: "order_id", Single: true},
+		},
+	}
+	require.NoError(t, checkQueries(h.Queries))
+
+	result, err := h.execute(context.Background(), map[string]interface{}{"keys": ":keys"}, nil)
+	require.NoError(t, err)
+	orders := result.(map[string]interface{})["orders"].([]map[string]any)
+	require.Equal(t, map[string]any{"order_id": int64(1), "name": "tea", "note": ":keys"}, orders[0]["first"])
+	require.Nil(t, orders[1]["first"])
+
+	// Keys match when the item and the row have different types
+	ts := time.Date(2024, 1, 15, 10, 30, 0, 0, time.FixedZone("CET", 3600))
+	require.Equal(t, batchKey("", ts), batchKey("", "2024-01-15T09:30:00Z"))
+	require.Equal(t, batchKey(columns.TypeTime, ts), batchKey(columns.TypeTime, "2024-01-15 09:30:00"))
+	require.Equal(t, batchKey("", float64(3)), batchKey("", int64(3)))
+	require.Equal(t, batchKey(columns.TypeInt, "3"), batchKey(columns.TypeInt, int64(3)))
+
+	batch := func(qdef *config.QueryDef) error {
+		return checkQueries([]*config.QueryDef{qdef})
+	}
+	require.ErrorContains(t, batch(&config.QueryDef{Query: "SELECT 1", Batch: &config.Batch{}}), "batch needs for and as")
+	require.ErrorContains(t, batch(&config.QueryDef{Query: "SELECT 1", For: "(i, v) in a", As: "a[i].b", Batch: &config.Batch{Key: "v"}}), "batch needs a key and a column")
+	require.ErrorContains(t, batch(&config.QueryDef{Query: "SELECT 1", For: "(i, v) in a", As: "a[i].b", Batch: &config.Batch{Key: "v", Column: "id"}}), "batch query needs a :keys parameter")
+}
@@ -0,0 +1,162 @@
+package sql
+
+import (
+	"context"
+	stdsql "database/sql"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/titpetric/etl/drivers"
+	"github.com/titpetric/etl/server/config"
+)
+
+// isolationLevels are the isolation levels by name.
+var isolationLevels = map[string]stdsql.IsolationLevel{
+	"read uncommitted": stdsql.LevelReadUncommitted,
+	"read committed":   stdsql.LevelReadCommitted,
+	"repeatable read":  stdsql.LevelRepeatableRead,
+	"snapshot":         stdsql.LevelSnapshot,
+	"serializable":     stdsql.LevelSerializable,
+}
+
+// txOptions checks the transaction config and returns the options
+// to begin transactions with.
+func txOptions(conf *config.Transaction) (*stdsql.TxOptions, error) {
+	if conf == nil {
+		return nil, nil
+	}
+
+	switch conf.Mode {
+	case "", config.TransactionQuery:
+	case config.TransactionPipeline:
+		if !conf.Enabled {
+			return nil, fmt.Errorf("transaction mode %s requires enabled", conf.Mode)
+		}
+	default:
+		return nil, fmt.Errorf("unknown transaction mode %q, supported %q and %q", conf.Mode, config.TransactionQuery, config.TransactionPipeline)
+	}
+
+	result := &stdsql.TxOptions{ReadOnly: conf.ReadOnly}
+	if conf.Isolation != "" {
+		name := strings.NewReplacer("_", " ", "-", " ").Replace(strings.ToLower(conf.Isolation))
+		level, ok := isolationLevels[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown transaction isolation %q", conf.Isolation)
+		}
+		result.Isolation = level
+	}
+	return result, nil
+}
+
+// pipelineTransaction reports if the pipeline runs in one transaction.
+func (h *Handler) pipelineTransaction() bool {
+	return h.Transaction != nil && h.Transaction.Enabled && h.Transaction.Mode == config.TransactionPipeline
+}
+
+// execute runs the query pipeline. In pipeline transaction mode, all
+// queries run in one transaction, and retries replay the pipeline.
+func (h *Handler) execute(ctx context.Context, params map[string]interface{}, list *listQuery) (interface{}, error) {
+	if !h.pipelineTransaction() {
+		return h.executePipeline(h.newConnections(ctx), params, list)
+	}
+
+	db := h.db
+	if h.Transaction.ReadOnly && h.replica != nil {
+		db = h.replica
+	}
+
+	// Each attempt starts from a copy of the params, as loops store
+	// their results in the items of the request.
+	return h.retry(ctx, func() (interface{}, error) {
+		return h.transaction(ctx, db, func(tx *sqlx.Tx) (interface{}, error) {
+			conns := h.newConnections(ctx)
+			conns.tx = tx
+			return h.executePipeline(conns, deepCopy(params).(map[string]interface{}), list)
+		})
+	})
+}
+
+// executeWithTransaction executes a query with transaction and retries
+func (h *Handler) executeWithTransaction(ctx context.Context, db *sqlx.DB, query string, params map[string]interface{}) (interface{}, error) {
+	return h.retry(ctx, func() (interface{}, error) {
+		return h.transaction(ctx, db, func(tx *sqlx.Tx) (interface{}, error) {
+			return h.executeQueryDirect(ctx, tx, query, params)
+		})
+	})
+}
+
+// transaction runs fn in a transaction, committing if it succeeds.
+func (h *Handler) transaction(ctx context.Context, db *sqlx.DB, fn func(tx *sqlx.Tx) (interface{}, error)) (interface{}, error) {
+	tx, err := db.BeginTxx(ctx, h.txOptions)
+	if err != nil {
+		return nil, drivers.NewError(err, "BEGIN")
+	}
+
+	result, err := fn(tx)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, drivers.NewError(err, "COMMIT")
+	}
+	return result, nil
+}
+
+// DefaultRetryDelay is the delay before the first retry, if unset.
+const DefaultRetryDelay = 100 * time.Millisecond
+
+// retry runs fn, and retries it as configured if it fails with a
+// transient error. The delay doubles with each retry, with jitter
+// so that conflicting requests don't retry in step. Retries stop
+// when the request is done, or would take longer than maxElapsedMs.
+func (h *Handler) retry(ctx context.Context, fn func() (interface{}, error)) (interface{}, error) {
+	retries, delay, maxElapsed := 0, DefaultRetryDelay, time.Duration(0)
+	if h.Transaction != nil {
+		retries = h.Transaction.Retries
+		if h.Transaction.RetryDelayMs > 0 {
+			delay = time.Duration(h.Transaction.RetryDelayMs) * time.Millisecond
+		}
+		maxElapsed = time.Duration(h.Transaction.MaxElapsedMs) * time.Millisecond
+	}
+
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		result, err := fn()
+		if err == nil {
+			return result, nil
+		}
+		if !drivers.Retryable(err) || attempt > retries {
+			return nil, err
+		}
+
+		wait := backoff(delay, attempt)
+		if maxElapsed > 0 && time.Since(start)+wait > maxElapsed {
+			return nil, fmt.Errorf("transaction failed after %d attempts: %w", attempt, err)
+		}
+
+		log.Printf("Retrying transaction in %s (attempt %d/%d): %v\n", wait, attempt+1, retries+1, err)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("transaction failed after %d attempts: %w (%w)", attempt, ctx.Err(), err)
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff returns the delay before a retry. It doubles the delay for
+// each attempt, and picks a random duration in its upper half.
+func backoff(delay time.Duration, attempt int) time.Duration {
+	delay <<= min(attempt-1, 16)
+	half := delay / 2
+	return half + rand.N(delay-half+1)
+}
@@ -0,0 +1,296 @@
+package sql
+
+import (
+	"context"
+	stdsql "database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+
+	"github.com/titpetric/etl/drivers"
+	"github.com/titpetric/etl/server/config"
+	"github.com/titpetric/etl/server/internal/problem"
+
+	_ "modernc.org/sqlite"
+)
+
+func testPipeline(t *testing.T, transaction *config.Transaction) (*Handler, *sqlx.DB) {
+	t.Helper()
+
+	db, err := sqlx.Open("sqlite", ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	db.MustExec(`
+		create table orders (id integer primary key, total integer);
+		create table order_item (id integer primary key, pos integer check (pos < 2));
+	`)
+
+	h := NewHandler()
+	h.db = db
+	h.Transaction = transaction
+	h.txOptions, err = txOptions(transaction)
+	require.NoError(t, err)
+	h.Queries = []*config.QueryDef{
+		{Query: "INSERT INTO orders (total) VALUES (:total)"},
+		{Query: "INSERT INTO order_item (pos) VALUES (:idx)", For: "(idx, item) in items"},
+		{Query: "SELECT count(*) AS items FROM order_item"},
+	}
+	return h, db
+}
+
+func count(t *testing.T, db *sqlx.DB, table string) int {
+	t.Helper()
+
+	var n int
+	require.NoError(t, db.Get(&n, "SELECT count(*) FROM "+table))
+	return n
+}
+
+// TestPipelineTransaction verifies that a failing loop rolls back the whole pipeline.
+func TestPipelineTransaction(t *testing.T) {
+	h, db := testPipeline(t, &config.Transaction{Enabled: true, Mode: config.TransactionPipeline, Retries: 2, RetryDelayMs: 1})
+
+	result, err := h.execute(context.Background(), map[string]interface{}{"total": 10, "items": []interface{}{"a", "b"}}, nil)
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{"items": int64(2)}, result)
+
+	// Constraint violations aren't retried
+	_, err = h.execute(context.Background(), map[string]interface{}{"total": 20, "items": []interface{}{"a", "b", "c"}}, nil)
+	require.Equal(t, drivers.ConstraintCheck, drivers.ConstraintKind(err))
+	require.NotContains(t, err.Error(), "attempts")
+
+	require.Equal(t, 1, count(t, db, "orders"))
+	require.Equal(t, 2, count(t, db, "order_item"))
+}
+
+// TestQueryTransaction verifies that query mode commits each write on its own.
+func TestQueryTransaction(t *testing.T) {
+	h, db := testPipeline(t, &config.Transaction{Enabled: true})
+
+	_, err := h.execute(context.Background(), map[string]interface{}{"total": 20, "items": []interface{}{"a", "b", "c"}}, nil)
+	require.Error(t, err)
+
+	require.Equal(t, 1, count(t, db, "orders"))
+	require.Equal(t, 2, count(t, db, "order_item"))
+}
+
+// TestTxOptions verifies the transaction mode and isolation level config.
+func TestTxOptions(t *testing.T) {
+	opts, err := txOptions(&config.Transaction{Enabled: true, Isolation: "Repeatable_Read", ReadOnly: true})
+	require.NoError(t, err)
+	require.Equal(t, &stdsql.TxOptions{Isolation: stdsql.LevelRepeatableRead, ReadOnly: true}, opts)
+
+	_, err = txOptions(&config.Transaction{Enabled: true, Isolation: "eventual"})
+	require.ErrorContains(t, err, `unknown transaction isolation "eventual"`)
+
+	_, err = txOptions(&config.Transaction{Enabled: true, Mode: "request"})
+	require.ErrorContains(t, err, `unknown transaction mode "request"`)
+
+	_, err = txOptions(&config.Transaction{Mode: config.TransactionPipeline})
+	require.ErrorContains(t, err, "transaction mode pipeline requires enabled")
+}
+
+// busyError returns a SQLITE_BUSY error, from writing to a database
+// locked by another connection.
+func busyError(t *testing.T) error {
+	t.Helper()
+
+	dsn := "file:" + filepath.Join(t.TempDir(), "busy.db") + "?_pragma=busy_timeout(0)"
+	locker, err := sqlx.Open("sqlite", dsn)
+	require.NoError(t, err)
+	defer locker.Close()
+
+	db, err := sqlx.Open("sqlite", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+
+	locker.MustExec("create table t (id integer)")
+	tx, err := locker.Beginx()
+	require.NoError(t, err)
+	defer tx.Rollback()
+	tx.MustExec("insert into t values (1)")
+
+	_, err = db.Exec("insert into t values (2)")
+	require.True(t, drivers.Retryable(err), "expected a busy error, got %v", err)
+	return err
+}
+
+// TestRetry verifies that only transient errors are retried, with backoff.
+func TestRetry(t *testing.T) {
+	busy := busyError(t)
+	h := NewHandler()
+	h.Transaction = &config.Transaction{Enabled: true, Retries: 3, RetryDelayMs: 1}
+
+	calls := 0
+	result, err := h.retry(context.Background(), func() (interface{}, error) {
+		if calls++; calls < 3 {
+			return nil, busy
+		}
+		return "ok", nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, "ok", result)
+	require.Equal(t, 3, calls)
+
+	// Retries are limited
+	calls = 0
+	_, err = h.retry(context.Background(), func() (interface{}, error) {
+		calls++
+		return nil, busy
+	})
+	require.ErrorIs(t, err, busy)
+	require.Equal(t, 4, calls)
+
+	// Other errors aren't retried
+	calls = 0
+	failed := errors.New("syntax error")
+	_, err = h.retry(context.Background(), func() (interface{}, error) {
+		calls++
+		return nil, failed
+	})
+	require.Same(t, failed, err)
+	require.Equal(t, 1, calls)
+}
+
+// TestRetryAbort verifies that retries stop at the max elapsed time or when the request is done.
+func TestRetryAbort(t *testing.T) {
+	busy := busyError(t)
+	h := NewHandler()
+	h.Transaction = &config.Transaction{Enabled: true, Retries: 10, RetryDelayMs: 20, MaxElapsedMs: 50}
+
+	calls := 0
+	_, err := h.retry(context.Background(), func() (interface{}, error) {
+		calls++
+		return nil, busy
+	})
+	require.ErrorIs(t, err, busy)
+	require.ErrorContains(t, err, "transaction failed after")
+	require.Less(t, calls, 4)
+
+	h.Transaction.MaxElapsedMs = 0
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err = h.retry(ctx, func() (interface{}, error) {
+		return nil, busy
+	})
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.ErrorIs(t, err, busy)
+	require.Less(t, time.Since(start), time.Second)
+}
+
+// TestBackoff verifies that the delay doubles, with jitter in the upper half.
+func TestBackoff(t *testing.T) {
+	for attempt := 1; attempt <= 4; attempt++ {
+		delay := 10 * time.Millisecond << (attempt - 1)
+		for range 20 {
+			wait := backoff(10*time.Millisecond, attempt)
+			require.GreaterOrEqual(t, wait, delay/2)
+			require.LessOrEqual(t, wait, delay)
+		}
+	}
+}
+
+// TestAssert verifies that a failed assertion stops the pipeline and rolls back the transaction.
+func TestAssert(t *testing.T) {
+	h, db := testPipeline(t, &config.Transaction{Enabled: true, Mode: config.TransactionPipeline, Retries: 2})
+	h.Queries = append(h.Queries[:2],
+		&config.QueryDef{Query: "SELECT count(*) AS n FROM order_item", As: "items"},
+		&config.QueryDef{Assert: "items.n < 2", Status: http.StatusConflict, Message: "Too many items."},
+		&config.QueryDef{Fail: true, If: "total > 100"},
+	)
+	require.NoError(t, checkQueries(h.Queries))
+
+	_, err := h.execute(context.Background(), map[string]interface{}{"total": 10, "items": []interface{}{"a", "b"}}, nil)
+	require.Equal(t, &problem.Error{Status: http.StatusConflict, Detail: "Too many items."}, err)
+
+	_, err = h.execute(context.Background(), map[string]interface{}{"total": 200, "items": []interface{}{"a"}}, nil)
+	require.Equal(t, &problem.Error{}, err)
+
+	require.Equal(t, 0, count(t, db, "orders"))
+	require.Equal(t, 0, count(t, db, "order_item"))
+
+	_, err = h.execute(context.Background(), map[string]interface{}{"total": 10, "items": []interface{}{"a"}}, nil)
+	require.NoError(t, err)
+	require.Equal(t, 1, count(t, db, "orders"))
+}
+
+// TestCheckQueries verifies that each pipeline step does one thing.
+func TestCheckQueries(t *testing.T) {
+	require.ErrorContains(t, checkQueries([]*config.QueryDef{{Query: "SELECT 1", Assert: "true"}}), "query 1: needs one of query, set, assert or fail")
+	require.ErrorContains(t, checkQueries([]*config.QueryDef{{}}), "query 1: needs one of")
+	require.ErrorContains(t, checkQueries([]*config.QueryDef{{Set: map[string]string{"a": "1"}, For: "(i, v) in a"}}), "query 1: for needs a query")
+	require.ErrorContains(t, checkQueries([]*config.QueryDef{{Query: "SELECT 1"}, {Fail: true, Status: 302}}), "query 2: invalid status 302")
+}
+
+// logWriter calls fn with each log line.
+type logWriter func(line string)
+
+func (w logWriter) Write(p []byte) (int, error) {
+	w(string(p))
+	return len(p), nil
+}
+
+// TestPipelineRetryParams verifies that a retried pipeline starts from
+// the request params, not the ones changed by the failed attempt.
+func TestPipelineRetryParams(t *testing.T) {
+	run := func(t *testing.T, locked bool) interface{} {
+		dsn := "file:" + filepath.Join(t.TempDir(), "retry.db") + "?_pragma=busy_timeout(0)"
+		db, err := sqlx.Open("sqlite", dsn)
+		require.NoError(t, err)
+		db.SetMaxOpenConns(1)
+		defer db.Close()
+		db.MustExec("create table log (id integer primary key, name text)")
+
+		transaction := &config.Transaction{Enabled: true, Mode: config.TransactionPipeline, Retries: 3, RetryDelayMs: 50}
+		h := NewHandler()
+		h.db = db
+		h.Transaction = transaction
+		h.txOptions, err = txOptions(transaction)
+		require.NoError(t, err)
+		h.Queries = []*config.QueryDef{
+			{Query: "SELECT upper(:item) AS name", For: "(idx, item) in items", As: "items[idx].row"},
+			{Query: "INSERT INTO log (name) VALUES (:item.row.name)", For: "(idx, item) in items"},
+			{Query: "SELECT name FROM log ORDER BY id"},
+		}
+
+		// Another connection holds the write lock until the first attempt fails
+		if locked {
+			locker, err := sqlx.Open("sqlite", dsn)
+			require.NoError(t, err)
+			defer locker.Close()
+			tx, err := locker.Beginx()
+			require.NoError(t, err)
+			defer tx.Rollback()
+			tx.MustExec("insert into log (name) values ('lock')")
+
+			var once sync.Once
+			log.SetOutput(logWriter(func(line string) {
+				if strings.Contains(line, "Retrying transaction") {
+					once.Do(func() { tx.Rollback() })
+				}
+			}))
+			defer log.SetOutput(os.Stderr)
+		}
+
+		params := map[string]interface{}{"items": []interface{}{"a", "b"}}
+		result, err := h.execute(context.Background(), params, nil)
+		require.NoError(t, err)
+		require.Equal(t, map[string]interface{}{"items": []interface{}{"a", "b"}}, params)
+		return result
+	}
+
+	require.Equal(t, run(t, false), run(t, true))
+}
@@ -0,0 +1,672 @@
+package table
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/titpetric/etl/drivers"
+	"github.com/titpetric/etl/model"
+	"github.com/titpetric/etl/server/config"
+	"github.com/titpetric/etl/server/internal/db/columns"
+	"github.com/titpetric/etl/server/internal/db/filter"
+	"github.com/titpetric/etl/server/internal/db/named"
+	"github.com/titpetric/etl/server/internal/db/tables"
+	handlermodel "github.com/titpetric/etl/server/internal/handler/model"
+	"github.com/titpetric/etl/server/internal/input"
+	"github.com/titpetric/etl/server/internal/pagination"
+	"github.com/titpetric/etl/server/internal/problem"
+)
+
+// Operations of a table endpoint.
+const (
+	OpList   = "list"
+	OpGet    = "get"
+	OpCreate = "create"
+	OpUpdate = "update"
+	OpDelete = "delete"
+)
+
+// Operations lists the operations of a table endpoint.
+var Operations = []string{OpList, OpGet, OpCreate, OpUpdate, OpDelete}
+
+// Operation overrides a generated operation.
+type Operation struct {
+	// Disabled removes the operation. Requests get a 405 response.
+	Disabled bool `yaml:"disabled,omitempty"`
+
+	// Query replaces the generated SQL. It gets the path, query, body
+	// and input parameters.
+	Query string `yaml:"query,omitempty"`
+}
+
+// Handler serves list, get, create, update and delete operations for
+// a table. The statements are generated from the table schema, which
+// is read when the endpoint is mounted.
+type Handler struct {
+	Storage *config.Storage
+
+	// Table is the name of the table.
+	Table string
+
+	// Upsert makes PUT requests insert the row if it doesn't exist.
+	Upsert bool
+
+	// Operations disable or override the generated operations.
+	Operations map[string]*Operation
+
+	path       string
+	db         *sqlx.DB
+	schema     *model.Table
+	statements *statements
+	columns    map[string]string
+	errors     *problem.Mapper
+	filter     *filter.Filter
+	pages      *pagination.Paginator
+}
+
+// NewHandler creates a new Handler.
+func NewHandler() *Handler {
+	return &Handler{}
+}
+
+// Type returns the handler type.
+func (h *Handler) Type() string {
+	return "table"
+}
+
+// Handler reads the table schema and creates the handler for the endpoint.
+func (h *Handler) Handler(opts *handlermodel.Options, endpoint *config.Endpoint) (http.Handler, error) {
+	conf := opts.Config
+
+	handle := NewHandler()
+	if err := endpoint.Handler.Decode(handle); err != nil {
+		return nil, fmt.Errorf("error decoding config: %w", err)
+	}
+	if handle.Table == "" {
+		return nil, fmt.Errorf("table handler requires table")
+	}
+	for name := range handle.Operations {
+		if !slices.Contains(Operations, name) {
+			return nil, fmt.Errorf("unknown operation %q, supported %v", name, Operations)
+		}
+	}
+	handle.path = strings.TrimSuffix(endpoint.Path, "/")
+
+	// Copy global storage config if endpoint has it unset
+	if handle.Storage == nil {
+		handle.Storage = conf.Storage
+	}
+
+	var err error
+	if handle.Storage, err = conf.ResolveStorage(handle.Storage); err != nil {
+		return nil, err
+	}
+	if handle.db, err = opts.Storage.Get(handle.Storage); err != nil {
+		return nil, err
+	}
+
+	if err := handle.introspect(); err != nil {
+		return nil, err
+	}
+
+	handle.columns = endpoint.Handler.Columns
+	if err := columns.Check(handle.columns); err != nil {
+		return nil, err
+	}
+
+	if handle.errors, err = problem.NewMapper(endpoint.Handler.Errors, conf.Server.Dev); err != nil {
+		return nil, err
+	}
+	if endpoint.Handler.Filter != nil {
+		if handle.filter, err = filter.New(endpoint.Handler.Filter); err != nil {
+			return nil, err
+		}
+	}
+
+	// Lists are always paginated, with the defaults if unset,
+	// and sorted by the primary key if the keys are unset.
+	pages := config.Pagination{}
+	if endpoint.Handler.Pagination != nil {
+		pages = *endpoint.Handler.Pagination
+	}
+	if len(pages.Keys) == 0 {
+		pages.Keys = handle.schema.PrimaryKey
+	}
+	if handle.pages, err = pagination.New(&pages); err != nil {
+		return nil, fmt.Errorf("table %s: %w", handle.Table, err)
+	}
+	if handle.filter != nil {
+		if err := handle.pages.CheckSort(handle.filter.DefaultSort()); err != nil {
+			return nil, fmt.Errorf("table %s: invalid defaultSort: %w", handle.Table, err)
+		}
+	}
+
+	return handle, nil
+}
+
+// introspect reads the table schema.
+func (h *Handler) introspect() error {
+	dialect, err := drivers.DialectOf(h.db)
+	if err != nil {
+		return err
+	}
+
+	driver, err := drivers.New(h.db)
+	if err != nil {
+		return err
+	}
+	tables, err := driver.Schema()
+	if err != nil {
+		return fmt.Errorf("error reading schema: %w", err)
+	}
+
+	for _, table := range tables {
+		if table.Name == h.Table {
+			h.schema = table
+		}
+	}
+	if h.schema == nil {
+		return fmt.Errorf("table %s not found", h.Table)
+	}
+	if len(h.schema.PrimaryKey) == 0 {
+		return fmt.Errorf("table %s has no primary key", h.Table)
+	}
+
+	h.statements = &statements{
+		dialect: dialect,
+		table:   h.schema,
+	}
+	return nil
+}
+
+// enabled reports if an operation is enabled.
+func (h *Handler) enabled(name string) bool {
+	op := h.Operations[name]
+	return op == nil || !op.Disabled
+}
+
+// query returns the query overriding an operation.
+func (h *Handler) query(name string) string {
+	if op := h.Operations[name]; op != nil {
+		return op.Query
+	}
+	return ""
+}
+
+// itemPath returns the path of a row, e.g. `/users/{id}`.
+func (h *Handler) itemPath() string {
+	result := h.path
+	for _, column := range h.schema.PrimaryKey {
+		result += "/{" + column + "}"
+	}
+	return result
+}
+
+// Routes returns the route for the rows. The endpoint path serves the list.
+func (h *Handler) Routes() []config.Path {
+	methods := h.itemMethods()
+	if len(methods) == 0 {
+		return nil
+	}
+	return []config.Path{{Methods: methods, Path: h.itemPath()}}
+}
+
+// Tables returns the tables read and written by the generated and
+// custom queries, so cached responses are tagged and invalidated by
+// the table.
+func (h *Handler) Tables() (read, written []string) {
+	read = []string{tables.Name(h.Table)}
+	for _, name := range Operations {
+		query := h.query(name)
+		switch {
+		case query != "":
+			read = append(read, tables.Referenced(query)...)
+			written = append(written, tables.Written(query)...)
+		case name != OpList && name != OpGet && h.enabled(name):
+			written = append(written, tables.Name(h.Table))
+		}
+	}
+	slices.Sort(read)
+	slices.Sort(written)
+	return slices.Compact(read), slices.Compact(written)
+}
+
+func (h *Handler) listMethods() []string {
+	var result []string
+	if h.enabled(OpList) {
+		result = append(result, http.MethodGet)
+	}
+	if h.enabled(OpCreate) {
+		result = append(result, http.MethodPost)
+	}
+	return result
+}
+
+func (h *Handler) itemMethods() []string {
+	var result []string
+	if h.enabled(OpGet) {
+		result = append(result, http.MethodGet)
+	}
+	if h.enabled(OpUpdate) {
+		result = append(result, http.MethodPut, http.MethodPatch)
+	}
+	if h.enabled(OpDelete) {
+		result = append(result, http.MethodDelete)
+	}
+	return result
+}
+
+// operation returns the operation for the request method and path.
+func (h *Handler) operation(r *http.Request) (string, []string) {
+	if chi.URLParam(r, h.schema.PrimaryKey[0]) == "" {
+		switch r.Method {
+		case http.MethodGet:
+			return OpList, h.listMethods()
+		case http.MethodPost:
+			return OpCreate, h.listMethods()
+		}
+		return "", h.listMethods()
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		return OpGet, h.itemMethods()
+	case http.MethodPut, http.MethodPatch:
+		return OpUpdate, h.itemMethods()
+	case http.MethodDelete:
+		return OpDelete, h.itemMethods()
+	}
+	return "", h.itemMethods()
+}
+
+// ServeHTTP runs the operation for the request.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name, allowed := h.operation(r)
+	if name == "" || !h.enabled(name) {
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	var (
+		status = http.StatusOK
+		result any
+		err    error
+	)
+
+	switch {
+	case h.query(name) != "":
+		result, err = h.custom(w, r, name)
+	case name == OpList:
+		result, err = h.list(w, r, h.statements.selectList(), h.params(r, nil))
+	case name == OpGet:
+		result, err = h.get(r)
+	case name == OpCreate:
+		status = http.StatusCreated
+		result, err = h.create(r)
+	case name == OpUpdate:
+		result, err = h.update(r)
+	case name == OpDelete:
+		status = http.StatusNoContent
+		err = h.delete(r)
+	}
+
+	if err != nil {
+		h.errors.Write(w, err)
+		return
+	}
+
+	if status == http.StatusNoContent {
+		w.WriteHeader(status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		log.Println("Error encoding json response:", err)
+	}
+}
+
+// custom runs an overriding query. Lists are filtered and paginated,
+// other operations return the first row.
+func (h *Handler) custom(w http.ResponseWriter, r *http.Request, name string) (any, error) {
+	body, err := readBody(r)
+	if err != nil {
+		return nil, err
+	}
+
+	params := h.params(r, body)
+	if name == OpList {
+		return h.list(w, r, h.query(name), params)
+	}
+
+	rows, types, err := h.queryRows(r.Context(), h.db, h.query(name), params)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		if name == OpGet {
+			return nil, problem.ErrNotFound
+		}
+		return nil, nil
+	}
+	return types.Row(rows[0]), nil
+}
+
+// list returns a filtered page of rows.
+func (h *Handler) list(w http.ResponseWriter, r *http.Request, query string, params map[string]any) (any, error) {
+	page, err := h.pages.Page(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if h.filter != nil {
+		q, err := h.filter.Parse(r.URL.Query())
+		if err != nil {
+			return nil, err
+		}
+
+		// The page query sorts the rows, so the ORDER BY is outermost.
+		if err := page.Sort(q.Order); err != nil {
+			return nil, err
+		}
+		q.Order = nil
+		query, params = q.Apply(h.statements.dialect, query, params)
+	}
+
+	pageQuery, args := page.Query(h.statements.dialect, query, params)
+	rows, types, err := h.queryRows(r.Context(), h.db, pageQuery, args)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := page.Result(rows)
+	if err != nil {
+		return nil, err
+	}
+	res.Rows = types.Rows(res.Rows)
+
+	if h.pages.Count() {
+		countQuery := h.pages.CountQuery(query)
+		var total int64
+		if err := namedGet(r.Context(), h.db, &total, countQuery, params); err != nil {
+			return nil, err
+		}
+		res.Total = &total
+	}
+
+	return h.pages.Respond(w, r, res, res.Rows), nil
+}
+
+// get returns the row with the key in the path.
+func (h *Handler) get(r *http.Request) (any, error) {
+	return h.selectOne(r.Context(), h.db, h.key(r))
+}
+
+// create inserts a row and returns it.
+func (h *Handler) create(r *http.Request) (any, error) {
+	values, err := h.values(r, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.transaction(r.Context(), func(tx *sqlx.Tx) (any, error) {
+		query, args := h.statements.insert(values)
+
+		key := make([]any, len(h.schema.PrimaryKey))
+		for i, column := range h.schema.PrimaryKey {
+			key[i] = values[column]
+		}
+
+		if h.statements.returning() {
+			rows, _, err := h.queryRows(r.Context(), tx, query, args)
+			if err != nil {
+				return nil, err
+			}
+			if len(rows) > 0 {
+				for i, column := range h.schema.PrimaryKey {
+					key[i] = rows[0][strings.ToLower(column)]
+				}
+			}
+		} else {
+			result, err := tx.NamedExecContext(r.Context(), query, map[string]any(args))
+			if err != nil {
+				return nil, drivers.NewError(err, query)
+			}
+			if len(key) == 1 && key[0] == nil {
+				if key[0], err = result.LastInsertId(); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		return h.selectOne(r.Context(), tx, key)
+	})
+}
+
+// update sets the values in the body on the row with the key in the
+// path, and returns the row. With upsert, PUT inserts missing rows.
+func (h *Handler) update(r *http.Request) (any, error) {
+	values, err := h.values(r, true)
+	if err != nil {
+		return nil, err
+	}
+	key := h.key(r)
+
+	return h.transaction(r.Context(), func(tx *sqlx.Tx) (any, error) {
+		var (
+			query string
+			args  args
+		)
+		switch {
+		case h.Upsert && r.Method == http.MethodPut:
+			query, args = h.statements.upsert(key, values)
+		case len(values) > 0:
+			query, args = h.statements.update(key, values)
+		}
+
+		if query != "" {
+			if _, err := tx.NamedExecContext(r.Context(), query, map[string]any(args)); err != nil {
+				return nil, drivers.NewError(err, query)
+			}
+		}
+		return h.selectOne(r.Context(), tx, key)
+	})
+}
+
+// delete deletes the row with the key in the path.
+func (h *Handler) delete(r *http.Request) error {
+	query, args := h.statements.delete(h.key(r))
+	result, err := h.db.NamedExecContext(r.Context(), query, map[string]any(args))
+	if err != nil {
+		return drivers.NewError(err, query)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return problem.ErrNotFound
+	}
+	return nil
+}
+
+// selectOne returns the row with the key, or a not found error.
+func (h *Handler) selectOne(ctx context.Context, db sqlx.ExtContext, key []any) (any, error) {
+	query, args := h.statements.selectOne(key)
+	rows, types, err := h.queryRows(ctx, db, query, args)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, problem.ErrNotFound
+	}
+	return types.Row(rows[0]), nil
+}
+
+// transaction runs fn in a transaction, committing if it succeeds.
+func (h *Handler) transaction(ctx context.Context, fn func(tx *sqlx.Tx) (any, error)) (any, error) {
+	tx, err := h.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := fn(tx)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	return result, drivers.NewError(tx.Commit(), "COMMIT")
+}
+
+// key returns the primary key values from the path.
+func (h *Handler) key(r *http.Request) []any {
+	result := make([]any, 0, len(h.schema.PrimaryKey))
+	for _, column := range h.schema.PrimaryKey {
+		result = append(result, chi.URLParam(r, column))
+	}
+	return result
+}
+
+// values returns the column values from the request body. Keys which
+// aren't columns are rejected. For updates, the primary key columns
+// are ignored, as the row is selected by the path.
+func (h *Handler) values(r *http.Request, update bool) (map[string]any, error) {
+	body, err := readBody(r)
+	if err != nil {
+		return nil, err
+	}
+
+	errs := &input.Error{}
+	result := make(map[string]any, len(body))
+	for name, value := range body {
+		if _, ok := h.schema.Column(name); !ok {
+			errs.Fields = append(errs.Fields, input.FieldError{Field: name, Source: config.InputBody, Message: "is not a column"})
+			continue
+		}
+		if update && slices.Contains(h.schema.PrimaryKey, name) {
+			continue
+		}
+		result[name] = value
+	}
+
+	if len(errs.Fields) > 0 {
+		slices.SortFunc(errs.Fields, func(a, b input.FieldError) int {
+			return strings.Compare(a.Field, b.Field)
+		})
+		return nil, errs
+	}
+	return result, nil
+}
+
+// params collects the parameters for custom queries.
+func (h *Handler) params(r *http.Request, body map[string]any) map[string]any {
+	params := make(map[string]any)
+
+	if rctx := chi.RouteContext(r.Context()); rctx != nil {
+		for i, key := range rctx.URLParams.Keys {
+			if i < len(rctx.URLParams.Values) {
+				params[key] = rctx.URLParams.Values[i]
+			}
+		}
+	}
+	for k, v := range r.URL.Query() {
+		params[k] = v[0]
+	}
+	for k, v := range body {
+		params[k] = v
+	}
+	for k, v := range input.Values(r.Context()) {
+		params[k] = v
+	}
+	return params
+}
+
+// readBody decodes a JSON object body. Numbers are converted to
+// int64 or float64, and objects and arrays are encoded as JSON
+// text for json columns. The body is restored for later reads.
+func readBody(r *http.Request) (map[string]any, error) {
+	if r.Body == nil || r.Method == http.MethodGet || r.Method == http.MethodDelete {
+		return nil, nil
+	}
+
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(data))
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var body map[string]any
+	if err := decoder.Decode(&body); err != nil {
+		return nil, &input.Error{Fields: []input.FieldError{{Field: "body", Message: "must be a JSON object"}}}
+	}
+
+	for k, v := range body {
+		switch v := v.(type) {
+		case json.Number:
+			if n, err := v.Int64(); err == nil {
+				body[k] = n
+			} else {
+				body[k], _ = v.Float64()
+			}
+		case map[string]any, []any:
+			encoded, err := json.Marshal(v)
+			if err != nil {
+				return nil, err
+			}
+			body[k] = string(encoded)
+		}
+	}
+	return body, nil
+}
+
+// queryRows runs a named query, returning the rows with lowercase
+// column names and the values as scanned, and the column types.
+func (h *Handler) queryRows(ctx context.Context, db sqlx.ExtContext, query string, params map[string]any) ([]map[string]any, columns.Columns, error) {
+	if err := named.Check(query, params); err != nil {
+		return nil, nil, err
+	}
+
+	rows, err := sqlx.NamedQueryContext(ctx, db, query, params)
+	if err != nil {
+		return nil, nil, drivers.NewError(err, query)
+	}
+	defer rows.Close()
+
+	result, types, err := columns.Scan(rows, h.columns)
+	return result, types, drivers.NewError(err, query)
+}
+
+// namedGet runs a named query and scans the single value.
+func namedGet(ctx context.Context, db sqlx.ExtContext, dest any, query string, params map[string]any) error {
+	rows, err := sqlx.NamedQueryContext(ctx, db, query, params)
+	if err != nil {
+		return drivers.NewError(err, query)
+	}
+	defer rows.Close()
+
+	if rows.Next() {
+		if err := rows.Scan(dest); err != nil {
+			return err
+		}
+	}
+	return drivers.NewError(rows.Err(), query)
+}
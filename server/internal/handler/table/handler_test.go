@@ -0,0 +1,229 @@
+package table
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+
+	"github.com/titpetric/etl/server/config"
+	"github.com/titpetric/etl/server/internal/db/filter"
+	"github.com/titpetric/etl/server/internal/pagination"
+
+	_ "modernc.org/sqlite"
+)
+
+func testRouter(t *testing.T, h *Handler) http.Handler {
+	t.Helper()
+
+	db, err := sqlx.Open("sqlite", ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	db.MustExec(`
+		create table item (id integer primary key autoincrement, name text not null unique, price real, tags text);
+		insert into item (name, price) values ('apple', 1.5), ('banana', 0.5), ('cherry', 3);
+	`)
+
+	h.db = db
+	h.path = "/items"
+	h.columns = map[string]string{"tags": "json"}
+	if h.Table == "" {
+		h.Table = "item"
+	}
+	require.NoError(t, h.introspect())
+
+	h.pages, err = pagination.New(&config.Pagination{Keys: []string{"id"}, Count: true})
+	require.NoError(t, err)
+	h.filter, err = filter.New(&config.Filter{
+		Fields: map[string][]string{"name": {filter.OpEq, filter.OpLike}},
+		Sort:   []string{"id", "name"},
+	})
+	require.NoError(t, err)
+
+	router := chi.NewRouter()
+	router.Handle(h.path, h)
+	for _, route := range h.Routes() {
+		for _, method := range route.Methods {
+			router.Method(method, route.Path, h)
+		}
+	}
+	return router
+}
+
+func request(t *testing.T, router http.Handler, method, target, body string) (*httptest.ResponseRecorder, map[string]any) {
+	t.Helper()
+
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var result map[string]any
+	if strings.HasPrefix(strings.TrimSpace(w.Body.String()), "{") {
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	}
+	return w, result
+}
+
+// TestCRUD verifies the generated list, get, create, update and delete operations.
+func TestCRUD(t *testing.T) {
+	router := testRouter(t, NewHandler())
+
+	w, _ := request(t, router, http.MethodGet, "/items?name=like:%25an%25", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "1", w.Header().Get("X-Total-Count"))
+	require.Contains(t, w.Body.String(), `"name":"banana"`)
+
+	w, row := request(t, router, http.MethodGet, "/items/2", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "banana", row["name"])
+	require.Contains(t, row, "tags")
+	require.Nil(t, row["tags"])
+
+	w, row = request(t, router, http.MethodPost, "/items", `{"name": "date", "price": 2, "tags": ["sweet"]}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	require.Equal(t, float64(4), row["id"])
+	require.Equal(t, []any{"sweet"}, row["tags"])
+
+	w, row = request(t, router, http.MethodPatch, "/items/4", `{"id": 10, "price": 2.25}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, float64(4), row["id"])
+	require.Equal(t, "date", row["name"])
+	require.Equal(t, 2.25, row["price"])
+
+	w, _ = request(t, router, http.MethodDelete, "/items/4", "")
+	require.Equal(t, http.StatusNoContent, w.Code)
+
+	w, row = request(t, router, http.MethodGet, "/items/4", "")
+	require.Equal(t, http.StatusNotFound, w.Code)
+	require.Equal(t, "not_found", row["kind"])
+
+	w, _ = request(t, router, http.MethodDelete, "/items/4", "")
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	w, _ = request(t, router, http.MethodPatch, "/items/4", `{"price": 1}`)
+	require.Equal(t, http.StatusNotFound, w.Code)
+}
+
+// TestInvalid verifies that unknown columns and constraint errors are mapped.
+func TestInvalid(t *testing.T) {
+	router := testRouter(t, NewHandler())
+
+	w, row := request(t, router, http.MethodPost, "/items", `{"name": "fig", "colour": "purple"}`)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Equal(t, "invalid_input", row["kind"])
+
+	w, row = request(t, router, http.MethodPost, "/items", `{"name": "apple"}`)
+	require.Equal(t, http.StatusConflict, w.Code)
+	require.Equal(t, "unique", row["kind"])
+
+	w, _ = request(t, router, http.MethodPost, "/items", `[1, 2]`)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+// TestUpsert verifies that PUT inserts missing rows with upsert enabled.
+func TestUpsert(t *testing.T) {
+	router := testRouter(t, &Handler{Upsert: true})
+
+	w, row := request(t, router, http.MethodPut, "/items/7", `{"name": "grape", "price": 4}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, float64(7), row["id"])
+	require.Equal(t, "grape", row["name"])
+
+	w, row = request(t, router, http.MethodPut, "/items/7", `{"name": "grape", "price": 5}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, float64(5), row["price"])
+
+	w, row = request(t, router, http.MethodPut, "/items/1", `{"name": "apricot"}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "apricot", row["name"])
+	require.Equal(t, 1.5, row["price"])
+}
+
+// TestOperations verifies that operations can be disabled or overridden.
+func TestOperations(t *testing.T) {
+	router := testRouter(t, &Handler{
+		Operations: map[string]*Operation{
+			OpDelete: {Disabled: true},
+			OpCreate: {Disabled: true},
+			OpGet:    {Query: "SELECT id, upper(name) AS name FROM item WHERE id = :id"},
+			OpList:   {Query: "SELECT id, name FROM item WHERE price > :min"},
+		},
+	})
+
+	w, _ := request(t, router, http.MethodDelete, "/items/1", "")
+	require.Equal(t, http.StatusMethodNotAllowed, w.Code)
+
+	w, _ = request(t, router, http.MethodPost, "/items", `{"name": "fig"}`)
+	require.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	require.Equal(t, "GET", w.Header().Get("Allow"))
+
+	w, row := request(t, router, http.MethodGet, "/items/1", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "APPLE", row["name"])
+
+	w, _ = request(t, router, http.MethodGet, "/items/9", "")
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	w, _ = request(t, router, http.MethodGet, "/items?min=1&sort=-name", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.JSONEq(t, `[{"id": 3, "name": "cherry"}, {"id": 1, "name": "apple"}]`, w.Body.String())
+}
+
+// TestCursorSort verifies that cursor pages reject a sort which
+// doesn't follow the pagination keys.
+func TestCursorSort(t *testing.T) {
+	h := NewHandler()
+	router := testRouter(t, h)
+
+	var err error
+	h.pages, err = pagination.New(&config.Pagination{Mode: config.PaginationCursor, Keys: []string{"id"}, Secret: "s3cret"})
+	require.NoError(t, err)
+
+	w, _ := request(t, router, http.MethodGet, "/items?sort=id", "")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w, row := request(t, router, http.MethodGet, "/items?sort=-name", "")
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Equal(t, "invalid_input", row["kind"])
+}
+
+// TestRoutes verifies the item route and the tables used for cache tags.
+func TestRoutes(t *testing.T) {
+	h := &Handler{Operations: map[string]*Operation{OpUpdate: {Disabled: true}}}
+	testRouter(t, h)
+
+	require.Equal(t, []config.Path{{Methods: []string{"GET", "DELETE"}, Path: "/items/{id}"}}, h.Routes())
+	read, written := h.Tables()
+	require.Equal(t, []string{"item"}, read)
+	require.Equal(t, []string{"item"}, written)
+
+	h = &Handler{Operations: map[string]*Operation{
+		OpList:   {Query: "SELECT i.id, t.name FROM item i JOIN tag t ON t.item_id = i.id"},
+		OpCreate: {Disabled: true},
+		OpUpdate: {Disabled: true},
+		OpDelete: {Query: "UPDATE item SET deleted = 1 WHERE id = :id; DELETE FROM tag WHERE item_id = :id"},
+	}}
+	testRouter(t, h)
+
+	read, written = h.Tables()
+	require.Equal(t, []string{"item", "tag"}, read)
+	require.Equal(t, []string{"item", "tag"}, written)
+
+	h = &Handler{Operations: map[string]*Operation{
+		OpCreate: {Disabled: true},
+		OpUpdate: {Disabled: true},
+		OpDelete: {Disabled: true},
+	}}
+	testRouter(t, h)
+
+	read, written = h.Tables()
+	require.Equal(t, []string{"item"}, read)
+	require.Empty(t, written)
+}
@@ -0,0 +1,155 @@
+package table
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/titpetric/etl/drivers"
+	"github.com/titpetric/etl/model"
+)
+
+// statements generates the SQL for the operations on a table. Values
+// are bound as named parameters, which are numbered, as the column
+// names may not be valid parameter names.
+type statements struct {
+	dialect drivers.Dialect
+	table   *model.Table
+}
+
+// args builds the named parameters for a statement.
+type args map[string]any
+
+// bind adds a value and returns its placeholder.
+func (a args) bind(value any) string {
+	name := fmt.Sprintf("_p%d", len(a))
+	a[name] = value
+	return ":" + name
+}
+
+// returning reports if the dialect supports RETURNING.
+func (s *statements) returning() bool {
+	return s.dialect != drivers.DialectMySQL
+}
+
+func (s *statements) quoteList(columns []string) string {
+	result := make([]string, 0, len(columns))
+	for _, column := range columns {
+		result = append(result, s.dialect.Quote(column))
+	}
+	return strings.Join(result, ", ")
+}
+
+// selectList returns the rows, which are sorted by the paginator.
+func (s *statements) selectList() string {
+	return "SELECT " + s.quoteList(s.table.ColumnNames()) + " FROM " + s.dialect.Quote(s.table.Name)
+}
+
+// selectOne returns the row with the key.
+func (s *statements) selectOne(key []any) (string, args) {
+	a := args{}
+	query := "SELECT " + s.quoteList(s.table.ColumnNames()) + " FROM " + s.dialect.Quote(s.table.Name) + s.where(a, key)
+	return query, a
+}
+
+// insert inserts the values, returning the primary key where supported.
+func (s *statements) insert(values map[string]any) (string, args) {
+	a := args{}
+	columns := sortedKeys(values)
+
+	var query string
+	switch {
+	case len(columns) > 0:
+		placeholders := make([]string, 0, len(columns))
+		for _, column := range columns {
+			placeholders = append(placeholders, a.bind(values[column]))
+		}
+		query = fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", s.dialect.Quote(s.table.Name), s.quoteList(columns), strings.Join(placeholders, ", "))
+	case s.dialect == drivers.DialectMySQL:
+		query = fmt.Sprintf("INSERT INTO %s () VALUES ()", s.dialect.Quote(s.table.Name))
+	default:
+		query = fmt.Sprintf("INSERT INTO %s DEFAULT VALUES", s.dialect.Quote(s.table.Name))
+	}
+
+	if s.returning() {
+		query += " RETURNING " + s.quoteList(s.table.PrimaryKey)
+	}
+	return query, a
+}
+
+// update sets the values on the row with the key.
+func (s *statements) update(key []any, values map[string]any) (string, args) {
+	a := args{}
+	columns := sortedKeys(values)
+
+	set := make([]string, 0, len(columns))
+	for _, column := range columns {
+		set = append(set, s.dialect.Quote(column)+" = "+a.bind(values[column]))
+	}
+	query := "UPDATE " + s.dialect.Quote(s.table.Name) + " SET " + strings.Join(set, ", ") + s.where(a, key)
+	return query, a
+}
+
+// upsert inserts the row with the key, or updates the values if it exists.
+func (s *statements) upsert(key []any, values map[string]any) (string, args) {
+	a := args{}
+	columns := sortedKeys(values)
+
+	all := slices.Concat(s.table.PrimaryKey, columns)
+	placeholders := make([]string, 0, len(all))
+	for _, value := range key {
+		placeholders = append(placeholders, a.bind(value))
+	}
+	for _, column := range columns {
+		placeholders = append(placeholders, a.bind(values[column]))
+	}
+
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", s.dialect.Quote(s.table.Name), s.quoteList(all), strings.Join(placeholders, ", "))
+
+	set := make([]string, 0, len(columns))
+	if s.dialect == drivers.DialectMySQL {
+		for _, column := range columns {
+			set = append(set, s.dialect.Quote(column)+" = VALUES("+s.dialect.Quote(column)+")")
+		}
+		if len(set) == 0 {
+			// Nothing to update, keep the row as is.
+			column := s.dialect.Quote(s.table.PrimaryKey[0])
+			set = append(set, column+" = "+column)
+		}
+		return query + " ON DUPLICATE KEY UPDATE " + strings.Join(set, ", "), a
+	}
+
+	for _, column := range columns {
+		set = append(set, s.dialect.Quote(column)+" = excluded."+s.dialect.Quote(column))
+	}
+	query += " ON CONFLICT (" + s.quoteList(s.table.PrimaryKey) + ")"
+	if len(set) == 0 {
+		return query + " DO NOTHING", a
+	}
+	return query + " DO UPDATE SET " + strings.Join(set, ", "), a
+}
+
+// delete deletes the row with the key.
+func (s *statements) delete(key []any) (string, args) {
+	a := args{}
+	return "DELETE FROM " + s.dialect.Quote(s.table.Name) + s.where(a, key), a
+}
+
+// where matches the primary key.
+func (s *statements) where(a args, key []any) string {
+	cond := make([]string, 0, len(key))
+	for i, column := range s.table.PrimaryKey {
+		cond = append(cond, s.dialect.Quote(column)+" = "+a.bind(key[i]))
+	}
+	return " WHERE " + strings.Join(cond, " AND ")
+}
+
+// sortedKeys returns the map keys in order, for stable statements.
+func sortedKeys(values map[string]any) []string {
+	result := make([]string, 0, len(values))
+	for k := range values {
+		result = append(result, k)
+	}
+	slices.Sort(result)
+	return result
+}
@@ -0,0 +1,9 @@
+package table
+
+import (
+	handlermodel "github.com/titpetric/etl/server/internal/handler/model"
+)
+
+func init() {
+	handlermodel.Register(NewHandler())
+}
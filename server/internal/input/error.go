@@ -0,0 +1,39 @@
+package input
+
+import (
+	"strings"
+)
+
+// FieldError describes an invalid input.
+type FieldError struct {
+	Field   string `json:"field"`
+	Source  string `json:"source,omitempty"`
+	Message string `json:"message"`
+}
+
+// Error lists the invalid inputs of a request.
+type Error struct {
+	Fields []FieldError `json:"fields"`
+}
+
+// Error returns the field errors on one line.
+func (e *Error) Error() string {
+	messages := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		messages = append(messages, f.Field+" "+f.Message)
+	}
+	return "invalid input: " + strings.Join(messages, ", ")
+}
+
+// FieldErrors returns the invalid fields for the problem response.
+func (e *Error) FieldErrors() any {
+	return e.Fields
+}
+
+func (e *Error) add(f *field, message string) {
+	e.Fields = append(e.Fields, FieldError{
+		Field:   f.Name,
+		Source:  f.Source,
+		Message: message,
+	})
+}
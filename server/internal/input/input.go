@@ -0,0 +1,329 @@
+// Package input validates request parameters against the inputs
+// declared on an endpoint, and converts them to the declared types.
+package input
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/titpetric/etl/server/config"
+	"github.com/titpetric/etl/server/internal/problem"
+)
+
+// Schema validates requests against a list of inputs.
+type Schema struct {
+	fields []*field
+	body   bool
+
+	errors *problem.Mapper
+}
+
+type field struct {
+	*config.Input
+
+	key   string
+	enum  []any
+	regex *regexp.Regexp
+}
+
+// NewSchema compiles the inputs, checking sources, types, defaults,
+// enum values and patterns. It returns nil if there are no inputs.
+func NewSchema(inputs []*config.Input) (*Schema, error) {
+	if len(inputs) == 0 {
+		return nil, nil
+	}
+
+	s := &Schema{}
+	for _, in := range inputs {
+		if in.Name == "" {
+			return nil, fmt.Errorf("input without a name")
+		}
+
+		f := &field{Input: in, key: in.Key}
+		if f.key == "" {
+			f.key = in.Name
+		}
+
+		switch in.Source {
+		case "", config.InputBody:
+			s.body = true
+		case config.InputPath, config.InputQuery, config.InputHeader:
+		default:
+			return nil, fmt.Errorf("input %s: unknown source %q", in.Name, in.Source)
+		}
+
+		switch in.Type {
+		case "", config.InputString, config.InputInt, config.InputFloat, config.InputBool:
+		default:
+			return nil, fmt.Errorf("input %s: unknown type %q", in.Name, in.Type)
+		}
+
+		if in.Default != nil {
+			if _, err := f.convert(in.Default); err != nil {
+				return nil, fmt.Errorf("input %s: default %v %s", in.Name, in.Default, err)
+			}
+		}
+		for _, value := range in.Enum {
+			v, err := f.convert(value)
+			if err != nil {
+				return nil, fmt.Errorf("input %s: enum value %v %s", in.Name, value, err)
+			}
+			f.enum = append(f.enum, v)
+		}
+		if in.Regex != "" {
+			var err error
+			if f.regex, err = regexp.Compile(in.Regex); err != nil {
+				return nil, fmt.Errorf("input %s: invalid regex: %w", in.Name, err)
+			}
+		}
+
+		s.fields = append(s.fields, f)
+	}
+	return s, nil
+}
+
+// WithErrors sets the mapper used to write invalid input responses,
+// which applies the endpoint error overrides.
+func (s *Schema) WithErrors(m *problem.Mapper) *Schema {
+	s.errors = m
+	return s
+}
+
+type contextKey struct{}
+
+// Values returns the validated inputs stored in the context by Wrap.
+func Values(ctx context.Context) map[string]any {
+	values, _ := ctx.Value(contextKey{}).(map[string]any)
+	return values
+}
+
+// Wrap validates requests before calling the handler. Invalid requests
+// get a 400 problem response with the field errors. The converted
+// values are available to the handler with Values.
+func (s *Schema) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		values, err := s.Bind(r)
+		if err != nil {
+			s.errors.Write(w, err)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), contextKey{}, values)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Bind reads and validates the inputs from the request. The request
+// body is read and replaced, so the handler can read it again.
+func (s *Schema) Bind(r *http.Request) (map[string]any, error) {
+	body, err := s.readBody(r)
+	if err != nil {
+		return nil, &Error{Fields: []FieldError{{Field: "body", Message: err.Error()}}}
+	}
+
+	values := make(map[string]any, len(s.fields))
+	result := &Error{}
+	for _, f := range s.fields {
+		raw, ok := f.lookup(r, body)
+		if !ok {
+			switch {
+			case f.Default != nil:
+				raw, ok = f.Default, true
+			case f.Required:
+				result.add(f, "is required")
+				continue
+			default:
+				// Optional inputs are bound as NULL
+				values[f.Name] = nil
+				continue
+			}
+		}
+
+		value, err := f.convert(raw)
+		if err != nil {
+			result.add(f, err.Error())
+			continue
+		}
+		if msg := f.check(value); msg != "" {
+			result.add(f, msg)
+			continue
+		}
+		values[f.Name] = value
+	}
+
+	if len(result.Fields) > 0 {
+		return nil, result
+	}
+	return values, nil
+}
+
+// readBody decodes a JSON object body, if inputs are read from the body.
+func (s *Schema) readBody(r *http.Request) (map[string]any, error) {
+	if !s.body || r.Body == nil || r.Method == http.MethodGet || r.Method == http.MethodHead {
+		return nil, nil
+	}
+
+	data, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, nil
+	}
+
+	var body map[string]any
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&body); err != nil {
+		return nil, fmt.Errorf("must be a JSON object")
+	}
+	return body, nil
+}
+
+// lookup returns the raw value of the input. Empty values are missing.
+func (f *field) lookup(r *http.Request, body map[string]any) (any, bool) {
+	present := func(value any, ok bool) (any, bool) {
+		if !ok || value == nil || value == "" {
+			return nil, false
+		}
+		return value, true
+	}
+
+	switch f.Source {
+	case config.InputPath:
+		return present(chi.URLParam(r, f.key), true)
+	case config.InputQuery:
+		return present(r.URL.Query().Get(f.key), true)
+	case config.InputHeader:
+		return present(r.Header.Get(f.key), true)
+	case config.InputBody:
+		value, ok := body[f.key]
+		return present(value, ok)
+	}
+
+	// Like undeclared parameters, the body overrides the query
+	// string, which overrides the path
+	if value, ok := present(body[f.key], true); ok {
+		return value, true
+	}
+	if value, ok := present(r.URL.Query().Get(f.key), true); ok {
+		return value, true
+	}
+	return present(chi.URLParam(r, f.key), true)
+}
+
+// Convert coerces a value to the type of an input checked by NewSchema,
+// like the values of requests are.
+func Convert(in *config.Input, value any) (any, error) {
+	return (&field{Input: in}).convert(value)
+}
+
+// convert coerces a value to the input type.
+func (f *field) convert(value any) (any, error) {
+	if n, ok := value.(json.Number); ok {
+		value = n.String()
+	}
+
+	switch f.Type {
+	case config.InputInt:
+		switch v := value.(type) {
+		case int:
+			return int64(v), nil
+		case int64:
+			return v, nil
+		case uint64:
+			return int64(v), nil
+		case float64:
+			if v == float64(int64(v)) {
+				return int64(v), nil
+			}
+		case string:
+			if n, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64); err == nil {
+				return n, nil
+			}
+		}
+		return nil, fmt.Errorf("must be an integer")
+	case config.InputFloat:
+		switch v := value.(type) {
+		case int:
+			return float64(v), nil
+		case int64:
+			return float64(v), nil
+		case uint64:
+			return float64(v), nil
+		case float64:
+			return v, nil
+		case string:
+			if n, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+				return n, nil
+			}
+		}
+		return nil, fmt.Errorf("must be a number")
+	case config.InputBool:
+		switch v := value.(type) {
+		case bool:
+			return v, nil
+		case string:
+			if b, err := strconv.ParseBool(strings.TrimSpace(v)); err == nil {
+				return b, nil
+			}
+		}
+		return nil, fmt.Errorf("must be a boolean")
+	}
+
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case map[string]any, []any:
+		return nil, fmt.Errorf("must be a string")
+	}
+	return fmt.Sprint(value), nil
+}
+
+// check returns a message if the value violates a constraint.
+func (f *field) check(value any) string {
+	if len(f.enum) > 0 && !slices.Contains(f.enum, value) {
+		values := make([]string, 0, len(f.enum))
+		for _, v := range f.enum {
+			values = append(values, fmt.Sprint(v))
+		}
+		return "must be one of: " + strings.Join(values, ", ")
+	}
+
+	if f.regex != nil && !f.regex.MatchString(fmt.Sprint(value)) {
+		return "must match " + f.Regex
+	}
+
+	var size float64
+	unit := ""
+	switch v := value.(type) {
+	case int64:
+		size = float64(v)
+	case float64:
+		size = v
+	case string:
+		size, unit = float64(utf8.RuneCountInString(v)), " characters"
+	default:
+		return ""
+	}
+	if f.Min != nil && size < *f.Min {
+		return fmt.Sprintf("must be at least %v%s", *f.Min, unit)
+	}
+	if f.Max != nil && size > *f.Max {
+		return fmt.Sprintf("must be at most %v%s", *f.Max, unit)
+	}
+	return ""
+}
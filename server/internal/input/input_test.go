@@ -0,0 +1,143 @@
+package input
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+
+	"github.com/titpetric/etl/server/config"
+)
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// serve routes a request through a schema and returns the values passed to the handler.
+func serve(t *testing.T, inputs []*config.Input, req *http.Request) (*httptest.ResponseRecorder, map[string]any) {
+	t.Helper()
+
+	schema, err := NewSchema(inputs)
+	require.NoError(t, err)
+
+	var values map[string]any
+	router := chi.NewRouter()
+	router.Handle("/users/{id}", schema.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		values = Values(r.Context())
+	})))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w, values
+}
+
+// TestSchemaSources verifies that values are read from each source and converted.
+func TestSchemaSources(t *testing.T) {
+	inputs := []*config.Input{
+		{Name: "id", Source: config.InputPath, Type: config.InputInt},
+		{Name: "page", Source: config.InputQuery, Type: config.InputInt, Default: 1},
+		{Name: "tenant", Source: config.InputHeader, Key: "X-Tenant"},
+		{Name: "active", Source: config.InputBody, Type: config.InputBool},
+		{Name: "score", Type: config.InputFloat},
+		{Name: "note"},
+	}
+
+	req := httptest.NewRequest("PUT", "/users/42?score=1.5", strings.NewReader(`{"active": true}`))
+	req.Header.Set("X-Tenant", "acme")
+
+	w, values := serve(t, inputs, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, map[string]any{
+		"id":     int64(42),
+		"page":   int64(1),
+		"tenant": "acme",
+		"active": true,
+		"score":  1.5,
+		"note":   nil,
+	}, values)
+}
+
+// TestSchemaBodyReadable verifies that the handler can read the body again.
+func TestSchemaBodyReadable(t *testing.T) {
+	schema, err := NewSchema([]*config.Input{{Name: "name", Required: true}})
+	require.NoError(t, err)
+
+	var body map[string]any
+	handler := schema.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+	}))
+
+	req := httptest.NewRequest("POST", "/users", strings.NewReader(`{"name": "Alice", "age": 30}`))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	require.Equal(t, map[string]any{"name": "Alice", "age": float64(30)}, body)
+}
+
+// TestSchemaErrors verifies that invalid requests get a 400 with the field errors.
+func TestSchemaErrors(t *testing.T) {
+	inputs := []*config.Input{
+		{Name: "id", Source: config.InputPath, Type: config.InputInt, Min: ptr(1.0)},
+		{Name: "status", Source: config.InputQuery, Enum: []any{"active", "inactive"}},
+		{Name: "code", Source: config.InputQuery, Regex: `^[A-Z]{3}$`},
+		{Name: "name", Source: config.InputBody, Required: true},
+		{Name: "bio", Source: config.InputBody, Max: ptr(5.0)},
+		{Name: "age", Source: config.InputBody, Type: config.InputInt},
+	}
+
+	req := httptest.NewRequest("POST", "/users/0?status=deleted&code=abc", strings.NewReader(`{"bio": "too long", "age": 1.5}`))
+	w, values := serve(t, inputs, req)
+	require.Nil(t, values)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+
+	var response struct {
+		Kind   string       `json:"kind"`
+		Errors []FieldError `json:"errors"`
+	}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+	require.Equal(t, "invalid_input", response.Kind)
+	require.Equal(t, []FieldError{
+		{Field: "id", Source: "path", Message: "must be at least 1"},
+		{Field: "status", Source: "query", Message: "must be one of: active, inactive"},
+		{Field: "code", Source: "query", Message: "must match ^[A-Z]{3}$"},
+		{Field: "name", Source: "body", Message: "is required"},
+		{Field: "bio", Source: "body", Message: "must be at most 5 characters"},
+		{Field: "age", Source: "body", Message: "must be an integer"},
+	}, response.Errors)
+}
+
+// TestSchemaInvalidBody verifies that a body which isn't a JSON object is rejected.
+func TestSchemaInvalidBody(t *testing.T) {
+	inputs := []*config.Input{{Name: "name", Source: config.InputBody}}
+
+	w, _ := serve(t, inputs, httptest.NewRequest("POST", "/users/1", strings.NewReader(`[1, 2]`)))
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Contains(t, w.Body.String(), "must be a JSON object")
+}
+
+// TestNewSchemaInvalid verifies that invalid inputs are rejected at mount time.
+func TestNewSchemaInvalid(t *testing.T) {
+	tests := []struct {
+		input *config.Input
+		err   string
+	}{
+		{&config.Input{}, "input without a name"},
+		{&config.Input{Name: "id", Source: "cookie"}, `unknown source "cookie"`},
+		{&config.Input{Name: "id", Type: "date"}, `unknown type "date"`},
+		{&config.Input{Name: "id", Type: config.InputInt, Default: "one"}, "default one must be an integer"},
+		{&config.Input{Name: "id", Type: config.InputInt, Enum: []any{1, "two"}}, "enum value two must be an integer"},
+		{&config.Input{Name: "id", Regex: "("}, "invalid regex"},
+	}
+
+	for _, tt := range tests {
+		_, err := NewSchema([]*config.Input{tt.input})
+		require.ErrorContains(t, err, tt.err)
+	}
+
+	schema, err := NewSchema(nil)
+	require.NoError(t, err)
+	require.Nil(t, schema)
+}
@@ -0,0 +1,101 @@
+package pagination
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// cursor holds the sort key values of the row a page starts after.
+type cursor struct {
+	// Prev is set for cursors pointing to the previous page.
+	Prev bool `json:"p,omitempty"`
+
+	// Values are the sort key values of the row.
+	Values []any `json:"v"`
+}
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// encoding is used for the cursor tokens, which go in query strings.
+var encoding = base64.RawURLEncoding
+
+// encode returns a signed token for the cursor.
+func (c *cursor) encode(secret []byte) (string, error) {
+	values := make([]any, len(c.Values))
+	for i, v := range c.Values {
+		switch v := v.(type) {
+		case []byte:
+			values[i] = string(v)
+		case time.Time:
+			values[i] = map[string]string{"t": v.Format(time.RFC3339Nano)}
+		default:
+			values[i] = v
+		}
+	}
+
+	payload, err := json.Marshal(&cursor{Prev: c.Prev, Values: values})
+	if err != nil {
+		return "", err
+	}
+
+	token := encoding.EncodeToString(payload)
+	return token + "." + encoding.EncodeToString(sign(secret, token)), nil
+}
+
+// decodeCursor checks the token signature and decodes the cursor.
+func decodeCursor(secret []byte, token string) (*cursor, error) {
+	payload, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, errInvalidCursor
+	}
+
+	mac, err := encoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, sign(secret, payload)) {
+		return nil, errInvalidCursor
+	}
+
+	data, err := encoding.DecodeString(payload)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	c := &cursor{}
+	if err := decoder.Decode(c); err != nil {
+		return nil, errInvalidCursor
+	}
+
+	for i, v := range c.Values {
+		switch v := v.(type) {
+		case json.Number:
+			if n, err := v.Int64(); err == nil {
+				c.Values[i] = n
+			} else if f, err := v.Float64(); err == nil {
+				c.Values[i] = f
+			}
+		case map[string]any:
+			s, _ := v["t"].(string)
+			t, err := time.Parse(time.RFC3339Nano, s)
+			if err != nil {
+				return nil, errInvalidCursor
+			}
+			c.Values[i] = t
+		}
+	}
+	return c, nil
+}
+
+// sign returns the HMAC of the token payload.
+func sign(secret []byte, payload string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)[:16]
+}
@@ -0,0 +1,415 @@
+// Package pagination pages query results with limit/offset or keyset
+// cursors, and writes the links to the neighbouring pages.
+package pagination
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/titpetric/etl/drivers"
+	"github.com/titpetric/etl/server/config"
+	"github.com/titpetric/etl/server/internal/db/order"
+	"github.com/titpetric/etl/server/internal/input"
+)
+
+const (
+	// DefaultLimit is the page size if the endpoint doesn't set a limit.
+	DefaultLimit = 20
+
+	// DefaultMaxLimit is the largest page size if the endpoint doesn't set maxLimit.
+	DefaultMaxLimit = 100
+)
+
+// Request parameters selecting a page.
+const (
+	ParamLimit  = "limit"
+	ParamOffset = "offset"
+	ParamCursor = "cursor"
+)
+
+var columnRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// key is a sort column.
+type key struct {
+	name string
+	desc bool
+}
+
+// Paginator pages the results of a query.
+type Paginator struct {
+	mode     string
+	keys     []key
+	limit    int
+	maxLimit int
+	count    bool
+	envelope bool
+	secret   []byte
+}
+
+// New creates a paginator, checking the mode, keys and limits.
+func New(conf *config.Pagination) (*Paginator, error) {
+	p := &Paginator{
+		mode:     conf.Mode,
+		limit:    conf.Limit,
+		maxLimit: conf.MaxLimit,
+		count:    conf.Count,
+		envelope: conf.Envelope,
+	}
+
+	if p.mode == "" {
+		p.mode = config.PaginationOffset
+	}
+	if p.mode != config.PaginationOffset && p.mode != config.PaginationCursor {
+		return nil, fmt.Errorf("unknown pagination mode %q", p.mode)
+	}
+	// SQL doesn't keep the order of the wrapped query, so the pages
+	// are always sorted by the keys.
+	if len(conf.Keys) == 0 {
+		return nil, fmt.Errorf("%s pagination requires keys", p.mode)
+	}
+
+	for _, column := range conf.Keys {
+		k := key{name: column}
+		if strings.HasPrefix(column, "-") {
+			k.name, k.desc = column[1:], true
+		}
+		if !columnRe.MatchString(k.name) {
+			return nil, fmt.Errorf("invalid pagination key %q", column)
+		}
+		p.keys = append(p.keys, k)
+	}
+
+	if p.maxLimit <= 0 {
+		p.maxLimit = DefaultMaxLimit
+	}
+	if p.limit <= 0 {
+		p.limit = min(DefaultLimit, p.maxLimit)
+	}
+	if p.limit > p.maxLimit {
+		return nil, fmt.Errorf("pagination limit %d is over maxLimit %d", p.limit, p.maxLimit)
+	}
+
+	// Cursors are only valid for the keys they were created with. The
+	// secret is configured, so cursors stay valid over restarts and
+	// between server instances.
+	if p.mode == config.PaginationCursor {
+		if conf.Secret == "" {
+			return nil, fmt.Errorf("%s pagination requires a secret", p.mode)
+		}
+		p.secret = sign([]byte(conf.Secret), strings.Join(conf.Keys, ","))
+	}
+	return p, nil
+}
+
+// Page is the page selected by a request.
+type Page struct {
+	Limit  int
+	Offset int
+
+	p      *Paginator
+	cursor *cursor
+	sort   []order.Order
+}
+
+// Page reads the page parameters from the request. The limit is capped
+// to the max limit. Invalid parameters return an input error.
+func (p *Paginator) Page(r *http.Request) (*Page, error) {
+	page := &Page{
+		Limit: p.limit,
+		p:     p,
+	}
+
+	errs := &input.Error{}
+	invalid := func(name, message string) {
+		errs.Fields = append(errs.Fields, input.FieldError{Field: name, Source: config.InputQuery, Message: message})
+	}
+
+	query := r.URL.Query()
+	if value := query.Get(ParamLimit); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 {
+			invalid(ParamLimit, "must be a positive integer")
+		}
+		page.Limit = min(limit, p.maxLimit)
+	}
+
+	switch p.mode {
+	case config.PaginationOffset:
+		if value := query.Get(ParamOffset); value != "" {
+			offset, err := strconv.Atoi(value)
+			if err != nil || offset < 0 {
+				invalid(ParamOffset, "must be a non-negative integer")
+			}
+			page.Offset = offset
+		}
+	case config.PaginationCursor:
+		if value := query.Get(ParamCursor); value != "" {
+			c, err := decodeCursor(p.secret, value)
+			if err != nil || len(c.Values) != len(p.keys) {
+				invalid(ParamCursor, "is invalid")
+			}
+			page.cursor = c
+		}
+	}
+
+	if len(errs.Fields) > 0 {
+		return nil, errs
+	}
+	return page, nil
+}
+
+// CheckSort checks that cursor pages can be sorted by orders. Cursor
+// pages are always sorted by the keys, so the orders must be the
+// leading keys, with the same directions.
+func (p *Paginator) CheckSort(orders []order.Order) error {
+	if p.mode != config.PaginationCursor {
+		return nil
+	}
+	matches := len(orders) <= len(p.keys)
+	for i := 0; matches && i < len(orders); i++ {
+		k := p.keys[i]
+		matches = strings.EqualFold(orders[i].Field, k.name) && (orders[i].Order == "DESC") == k.desc
+	}
+	if !matches {
+		return fmt.Errorf("must follow the pagination keys: %s", p.keyList())
+	}
+	return nil
+}
+
+// keyList returns the keys in the sort syntax, like `-created_at,id`.
+func (p *Paginator) keyList() string {
+	result := make([]string, 0, len(p.keys))
+	for _, k := range p.keys {
+		if k.desc {
+			result = append(result, "-"+k.name)
+		} else {
+			result = append(result, k.name)
+		}
+	}
+	return strings.Join(result, ",")
+}
+
+// Sort sets the order of the rows in offset mode, usually the sort of
+// a filter. The keys follow, to keep the order of ties. Cursor pages
+// are always sorted by the keys, and orders which don't follow them
+// return an input error.
+func (pg *Page) Sort(orders []order.Order) error {
+	if err := pg.p.CheckSort(orders); err != nil {
+		return &input.Error{Fields: []input.FieldError{
+			{Field: "sort", Source: config.InputQuery, Message: err.Error()},
+		}}
+	}
+	if pg.p.mode == config.PaginationOffset {
+		pg.sort = orders
+	}
+	return nil
+}
+
+// Count reports if the total number of rows should be counted.
+func (p *Paginator) Count() bool {
+	return p.count
+}
+
+// CountQuery returns a query counting the rows of the query.
+func (p *Paginator) CountQuery(query string) string {
+	return "SELECT COUNT(*) FROM (\n" + trimQuery(query) + "\n) page"
+}
+
+// Query wraps the query to select the page. It fetches one more row
+// than the limit, to tell if there is a next page. The columns are
+// quoted for the dialect, and the page parameters are added to a copy
+// of params.
+func (pg *Page) Query(dialect drivers.Dialect, query string, params map[string]any) (string, map[string]any) {
+	args := make(map[string]any, len(params)+len(pg.p.keys)+2)
+	for k, v := range params {
+		args[k] = v
+	}
+	args["_page_limit"] = pg.Limit + 1
+
+	var sb strings.Builder
+	sb.WriteString("SELECT * FROM (\n" + trimQuery(query) + "\n) page")
+
+	// Going back from a cursor reverses the sort order,
+	// and the rows are reversed again in Result.
+	reverse := pg.cursor != nil && pg.cursor.Prev
+
+	if pg.cursor != nil {
+		// (a > :a) OR (a = :a AND b > :b) ...
+		var or []string
+		for i, k := range pg.p.keys {
+			var and []string
+			for j := range i {
+				and = append(and, fmt.Sprintf("page.%s = :_page_key%d", dialect.Quote(pg.p.keys[j].name), j))
+			}
+			op := ">"
+			if k.desc != reverse {
+				op = "<"
+			}
+			and = append(and, fmt.Sprintf("page.%s %s :_page_key%d", dialect.Quote(k.name), op, i))
+			or = append(or, "("+strings.Join(and, " AND ")+")")
+
+			args[fmt.Sprintf("_page_key%d", i)] = pg.cursor.Values[i]
+		}
+		sb.WriteString("\nWHERE " + strings.Join(or, " OR "))
+	}
+
+	sb.WriteString("\nORDER BY " + pg.orderBy(dialect, reverse))
+
+	sb.WriteString("\nLIMIT :_page_limit")
+	if pg.p.mode == config.PaginationOffset {
+		sb.WriteString(" OFFSET :_page_offset")
+		args["_page_offset"] = pg.Offset
+	}
+	return sb.String(), args
+}
+
+// orderBy returns the ORDER BY list of the page query, optionally reversed.
+func (pg *Page) orderBy(dialect drivers.Dialect, reverse bool) string {
+	var (
+		result []string
+		seen   = map[string]bool{}
+	)
+	for _, o := range pg.sort {
+		result = append(result, "page."+dialect.Quote(o.Field)+" "+o.Order)
+		seen[strings.ToLower(o.Field)] = true
+	}
+	for _, k := range pg.p.keys {
+		if seen[strings.ToLower(k.name)] {
+			continue
+		}
+		dir := "ASC"
+		if k.desc != reverse {
+			dir = "DESC"
+		}
+		result = append(result, "page."+dialect.Quote(k.name)+" "+dir)
+	}
+	return strings.Join(result, ", ")
+}
+
+// Result is a page of rows.
+type Result struct {
+	Rows []map[string]any
+
+	// Next and Prev select the neighbouring pages. They hold a cursor
+	// or an offset, and are nil on the last and first page.
+	Next any
+	Prev any
+
+	// Total is the number of rows, if counted.
+	Total *int64
+}
+
+// Result trims the rows returned by the page query to the page, and
+// sets the cursors or offsets of the neighbouring pages. The rows
+// should have lowercase column names, and the values as scanned.
+func (pg *Page) Result(rows []map[string]any) (*Result, error) {
+	more := len(rows) > pg.Limit
+	if more {
+		rows = rows[:pg.Limit]
+	}
+
+	res := &Result{Rows: rows}
+	if res.Rows == nil {
+		res.Rows = []map[string]any{}
+	}
+
+	if pg.p.mode == config.PaginationOffset {
+		if more {
+			res.Next = pg.Offset + pg.Limit
+		}
+		if pg.Offset > 0 {
+			res.Prev = max(pg.Offset-pg.Limit, 0)
+		}
+		return res, nil
+	}
+
+	// Going back, the extra row means there are earlier pages,
+	// and there is always a next page.
+	hasNext, hasPrev := more, pg.cursor != nil
+	if pg.cursor != nil && pg.cursor.Prev {
+		slices.Reverse(rows)
+		hasNext, hasPrev = true, more
+	}
+	if len(rows) == 0 {
+		return res, nil
+	}
+
+	var err error
+	if hasNext {
+		if res.Next, err = pg.encode(rows[len(rows)-1], false); err != nil {
+			return nil, err
+		}
+	}
+	if hasPrev {
+		if res.Prev, err = pg.encode(rows[0], true); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// encode returns the cursor for the row.
+func (pg *Page) encode(row map[string]any, prev bool) (string, error) {
+	c := &cursor{Prev: prev}
+	for _, k := range pg.p.keys {
+		value, ok := row[strings.ToLower(k.name)]
+		if !ok {
+			return "", fmt.Errorf("pagination key %s is not in the query result", k.name)
+		}
+		c.Values = append(c.Values, value)
+	}
+	return c.encode(pg.p.secret)
+}
+
+// Respond returns the response body for the page, and sets the Link
+// and X-Total-Count headers. The data are the page rows as they
+// should be returned.
+func (p *Paginator) Respond(w http.ResponseWriter, r *http.Request, res *Result, data any) any {
+	if p.envelope {
+		body := map[string]any{
+			"data": data,
+			"next": res.Next,
+			"prev": res.Prev,
+		}
+		if res.Total != nil {
+			body["total"] = *res.Total
+		}
+		return body
+	}
+
+	var links []string
+	if res.Next != nil {
+		links = append(links, fmt.Sprintf(`<%s>; rel="next"`, p.link(r, res.Next)))
+	}
+	if res.Prev != nil {
+		links = append(links, fmt.Sprintf(`<%s>; rel="prev"`, p.link(r, res.Prev)))
+	}
+	if len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+	if res.Total != nil {
+		w.Header().Set("X-Total-Count", strconv.FormatInt(*res.Total, 10))
+	}
+	return data
+}
+
+// link returns the request URL with the page parameter replaced.
+func (p *Paginator) link(r *http.Request, value any) string {
+	param := ParamOffset
+	if p.mode == config.PaginationCursor {
+		param = ParamCursor
+	}
+
+	query := r.URL.Query()
+	query.Set(param, fmt.Sprint(value))
+	return r.URL.Path + "?" + query.Encode()
+}
+
+// trimQuery removes the trailing semicolon, so the query can be wrapped.
+func trimQuery(query string) string {
+	return strings.TrimRight(strings.TrimSpace(query), "; \t\n")
+}
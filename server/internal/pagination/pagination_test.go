@@ -0,0 +1,334 @@
+package pagination
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+
+	"github.com/titpetric/etl/drivers"
+	"github.com/titpetric/etl/server/config"
+	"github.com/titpetric/etl/server/internal/db/order"
+	"github.com/titpetric/etl/server/internal/input"
+
+	_ "modernc.org/sqlite"
+)
+
+const testQuery = `SELECT id, name, score FROM player WHERE score >= :min;`
+
+func testDB(t *testing.T) *sqlx.DB {
+	t.Helper()
+
+	db, err := sqlx.Open("sqlite", ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	db.MustExec(`
+		create table player (id integer primary key, name text, score integer);
+		insert into player (id, name, score) values
+			(1, 'a', 10), (2, 'b', 30), (3, 'c', 20), (4, 'd', 30), (5, 'e', 20), (6, 'f', 0);
+	`)
+	return db
+}
+
+// fetch runs the page query for the request URL and returns the result.
+func fetch(t *testing.T, db *sqlx.DB, p *Paginator, target string) *Result {
+	t.Helper()
+
+	page, err := p.Page(httptest.NewRequest("GET", target, nil))
+	require.NoError(t, err)
+
+	query, args := page.Query(drivers.DialectSQLite, testQuery, map[string]any{"min": 10})
+	rows, err := db.NamedQuery(query, args)
+	require.NoError(t, err)
+	defer rows.Close()
+
+	var result []map[string]any
+	for rows.Next() {
+		row := map[string]any{}
+		require.NoError(t, rows.MapScan(row))
+		result = append(result, row)
+	}
+	require.NoError(t, rows.Err())
+
+	res, err := page.Result(result)
+	require.NoError(t, err)
+	return res
+}
+
+func ids(res *Result) []int64 {
+	result := make([]int64, 0, len(res.Rows))
+	for _, row := range res.Rows {
+		result = append(result, row["id"].(int64))
+	}
+	return result
+}
+
+// TestOffset verifies limit/offset paging and the neighbouring offsets.
+func TestOffset(t *testing.T) {
+	db := testDB(t)
+	p, err := New(&config.Pagination{Keys: []string{"id"}, Limit: 2})
+	require.NoError(t, err)
+
+	res := fetch(t, db, p, "/players")
+	require.Equal(t, []int64{1, 2}, ids(res))
+	require.Equal(t, 2, res.Next)
+	require.Nil(t, res.Prev)
+
+	res = fetch(t, db, p, "/players?offset=3&limit=5")
+	require.Equal(t, []int64{4, 5}, ids(res))
+	require.Nil(t, res.Next)
+	require.Equal(t, 0, res.Prev)
+}
+
+// TestOffsetSort verifies that the sort comes before the keys, in the
+// outermost ORDER BY, also if the query sorts its rows.
+func TestOffsetSort(t *testing.T) {
+	db := testDB(t)
+	p, err := New(&config.Pagination{Keys: []string{"id"}, Limit: 3})
+	require.NoError(t, err)
+
+	page, err := p.Page(httptest.NewRequest("GET", "/players?offset=1", nil))
+	require.NoError(t, err)
+	require.NoError(t, page.Sort([]order.Order{order.Desc("score")}))
+
+	query, args := page.Query(drivers.DialectSQLite, "SELECT id, name, score FROM player ORDER BY name", nil)
+	require.True(t, strings.HasSuffix(query, ") page\nORDER BY page.\"score\" DESC, page.\"id\" ASC\nLIMIT :_page_limit OFFSET :_page_offset"), query)
+
+	var result []int64
+	rows, err := db.NamedQuery(query, args)
+	require.NoError(t, err)
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		var name string
+		var score int64
+		require.NoError(t, rows.Scan(&id, &name, &score))
+		result = append(result, id)
+	}
+	require.NoError(t, rows.Err())
+
+	// 2 and 4 tie on 30, 3 and 5 on 20, with the extra row
+	require.Equal(t, []int64{4, 3, 5, 1}, result)
+}
+
+// TestCursor verifies keyset paging forwards and back, with mixed sort
+// directions and ties on the first key.
+func TestCursor(t *testing.T) {
+	db := testDB(t)
+	p, err := New(&config.Pagination{Mode: config.PaginationCursor, Keys: []string{"-score", "id"}, Limit: 2, Secret: "s3cret"})
+	require.NoError(t, err)
+
+	next := func(value any) string {
+		return "/players?cursor=" + url.QueryEscape(value.(string))
+	}
+
+	first := fetch(t, db, p, "/players")
+	require.Equal(t, []int64{2, 4}, ids(first))
+	require.Nil(t, first.Prev)
+
+	second := fetch(t, db, p, next(first.Next))
+	require.Equal(t, []int64{3, 5}, ids(second))
+	require.NotNil(t, second.Prev)
+
+	last := fetch(t, db, p, next(second.Next))
+	require.Equal(t, []int64{1}, ids(last))
+	require.Nil(t, last.Next)
+
+	back := fetch(t, db, p, next(last.Prev))
+	require.Equal(t, []int64{3, 5}, ids(back))
+	require.NotNil(t, back.Next)
+
+	back = fetch(t, db, p, next(back.Prev))
+	require.Equal(t, []int64{2, 4}, ids(back))
+	require.Nil(t, back.Prev)
+	require.NotNil(t, back.Next)
+}
+
+// TestCursorSort verifies that cursor pages accept a sort following
+// the keys, and reject any other sort.
+func TestCursorSort(t *testing.T) {
+	p, err := New(&config.Pagination{Mode: config.PaginationCursor, Keys: []string{"-score", "id"}, Secret: "s3cret"})
+	require.NoError(t, err)
+
+	tests := []struct {
+		orders []order.Order
+		valid  bool
+	}{
+		{nil, true},
+		{[]order.Order{order.Desc("score")}, true},
+		{[]order.Order{order.Desc("SCORE"), order.Asc("id")}, true},
+		{[]order.Order{order.Asc("score")}, false},
+		{[]order.Order{order.Asc("id")}, false},
+		{[]order.Order{order.Desc("score"), order.Asc("id"), order.Asc("name")}, false},
+	}
+
+	for _, tt := range tests {
+		page, err := p.Page(httptest.NewRequest("GET", "/players", nil))
+		require.NoError(t, err)
+
+		err = page.Sort(tt.orders)
+		if tt.valid {
+			require.NoError(t, err, tt.orders)
+			continue
+		}
+		var inputErr *input.Error
+		require.ErrorAs(t, err, &inputErr, tt.orders)
+		require.Equal(t, []input.FieldError{
+			{Field: "sort", Source: "query", Message: "must follow the pagination keys: -score,id"},
+		}, inputErr.Fields)
+	}
+}
+
+// TestQueryQuoted verifies that the keys keep their case, and are
+// quoted for the dialect.
+func TestQueryQuoted(t *testing.T) {
+	p, err := New(&config.Pagination{Mode: config.PaginationCursor, Keys: []string{"-createdAt", "id"}, Secret: "s3cret"})
+	require.NoError(t, err)
+
+	token, err := (&cursor{Values: []any{"2024-01-01", 1}}).encode(p.secret)
+	require.NoError(t, err)
+	page, err := p.Page(httptest.NewRequest("GET", "/players?cursor="+url.QueryEscape(token), nil))
+	require.NoError(t, err)
+
+	tests := []struct {
+		dialect drivers.Dialect
+		where   string
+		order   string
+	}{
+		{drivers.DialectPostgres, `WHERE (page."createdAt" < :_page_key0) OR (page."createdAt" = :_page_key0 AND page."id" > :_page_key1)`, `ORDER BY page."createdAt" DESC, page."id" ASC`},
+		{drivers.DialectMySQL, "WHERE (page.`createdAt` < :_page_key0) OR (page.`createdAt` = :_page_key0 AND page.`id` > :_page_key1)", "ORDER BY page.`createdAt` DESC, page.`id` ASC"},
+	}
+	for _, tt := range tests {
+		query, _ := page.Query(tt.dialect, "SELECT * FROM events", nil)
+		require.Contains(t, query, tt.where)
+		require.Contains(t, query, tt.order)
+	}
+
+	// Rows are scanned with lowercase column names
+	res, err := page.Result([]map[string]any{{"createdat": "2023-12-31", "id": int64(2)}})
+	require.NoError(t, err)
+	require.NotNil(t, res.Prev)
+}
+
+// TestCursorInvalid verifies that tampered cursors and cursors for
+// other keys are rejected.
+func TestCursorInvalid(t *testing.T) {
+	db := testDB(t)
+	p, err := New(&config.Pagination{Mode: config.PaginationCursor, Keys: []string{"id"}, Limit: 2, Secret: "s3cret"})
+	require.NoError(t, err)
+
+	token := fetch(t, db, p, "/players").Next.(string)
+	payload, signature, _ := strings.Cut(token, ".")
+
+	other, err := New(&config.Pagination{Mode: config.PaginationCursor, Keys: []string{"name"}, Secret: "s3cret"})
+	require.NoError(t, err)
+
+	tampered := encoding.EncodeToString([]byte(`{"v":[100]}`)) + "." + signature
+	tests := []struct {
+		p      *Paginator
+		cursor string
+	}{
+		{p, tampered},
+		{p, payload},
+		{p, "garbage"},
+		{other, token},
+	}
+
+	for _, tt := range tests {
+		_, err := tt.p.Page(httptest.NewRequest("GET", "/players?cursor="+url.QueryEscape(tt.cursor), nil))
+		var inputErr *input.Error
+		require.ErrorAs(t, err, &inputErr, tt.cursor)
+		require.Equal(t, "cursor", inputErr.Fields[0].Field)
+	}
+}
+
+// TestPageLimit verifies the default limit, the max limit and invalid values.
+func TestPageLimit(t *testing.T) {
+	p, err := New(&config.Pagination{Keys: []string{"id"}, MaxLimit: 50})
+	require.NoError(t, err)
+
+	page, err := p.Page(httptest.NewRequest("GET", "/players", nil))
+	require.NoError(t, err)
+	require.Equal(t, DefaultLimit, page.Limit)
+
+	page, err = p.Page(httptest.NewRequest("GET", "/players?limit=500", nil))
+	require.NoError(t, err)
+	require.Equal(t, 50, page.Limit)
+
+	_, err = p.Page(httptest.NewRequest("GET", "/players?limit=0&offset=-1", nil))
+	var inputErr *input.Error
+	require.ErrorAs(t, err, &inputErr)
+	require.Equal(t, []input.FieldError{
+		{Field: "limit", Source: "query", Message: "must be a positive integer"},
+		{Field: "offset", Source: "query", Message: "must be a non-negative integer"},
+	}, inputErr.Fields)
+}
+
+// TestRespond verifies the Link headers and the envelope.
+func TestRespond(t *testing.T) {
+	total := int64(6)
+	res := &Result{Next: 4, Prev: 0, Total: &total}
+	data := []string{"row"}
+
+	p, err := New(&config.Pagination{Keys: []string{"id"}})
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/players?limit=2&offset=2", nil)
+	require.Equal(t, data, p.Respond(w, r, res, data))
+	require.Equal(t, `</players?limit=2&offset=4>; rel="next", </players?limit=2&offset=0>; rel="prev"`, w.Header().Get("Link"))
+	require.Equal(t, "6", w.Header().Get("X-Total-Count"))
+
+	p, err = New(&config.Pagination{Keys: []string{"id"}, Envelope: true})
+	require.NoError(t, err)
+
+	w = httptest.NewRecorder()
+	require.Equal(t, map[string]any{
+		"data":  data,
+		"next":  4,
+		"prev":  0,
+		"total": total,
+	}, p.Respond(w, r, res, data))
+	require.Equal(t, http.Header{}, w.Header())
+}
+
+// TestCountQuery verifies the count query counts all the rows.
+func TestCountQuery(t *testing.T) {
+	db := testDB(t)
+	p, err := New(&config.Pagination{Keys: []string{"id"}, Count: true})
+	require.NoError(t, err)
+	require.True(t, p.Count())
+
+	query, args, err := sqlx.Named(p.CountQuery(testQuery), map[string]any{"min": 10})
+	require.NoError(t, err)
+
+	var total int64
+	require.NoError(t, db.Get(&total, query, args...))
+	require.Equal(t, int64(5), total)
+}
+
+// TestNewInvalid verifies that invalid configuration is rejected at mount time.
+func TestNewInvalid(t *testing.T) {
+	tests := []struct {
+		conf *config.Pagination
+		err  string
+	}{
+		{&config.Pagination{Mode: "page"}, `unknown pagination mode "page"`},
+		{&config.Pagination{Mode: config.PaginationCursor}, "cursor pagination requires keys"},
+		{&config.Pagination{Mode: config.PaginationCursor, Keys: []string{"id"}}, "cursor pagination requires a secret"},
+		{&config.Pagination{}, "offset pagination requires keys"},
+		{&config.Pagination{Keys: []string{"id; drop table player"}}, "invalid pagination key"},
+		{&config.Pagination{Keys: []string{"id"}, Limit: 200}, "pagination limit 200 is over maxLimit 100"},
+	}
+
+	for _, tt := range tests {
+		_, err := New(tt.conf)
+		require.ErrorContains(t, err, tt.err)
+	}
+}
@@ -0,0 +1,214 @@
+// Package problem maps handler errors to `application/problem+json`
+// responses (RFC 9457).
+package problem
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/titpetric/etl/drivers"
+	"github.com/titpetric/etl/model"
+	"github.com/titpetric/etl/server/config"
+)
+
+// ContentType is the media type of problem responses.
+const ContentType = "application/problem+json"
+
+// Kinds of errors, used as the keys of the endpoint `errors` overrides.
+const (
+	KindNotFound     = "not_found"
+	KindInvalidInput = "invalid_input"
+	KindUnique       = drivers.ConstraintUnique
+	KindForeignKey   = drivers.ConstraintForeignKey
+	KindNotNull      = drivers.ConstraintNotNull
+	KindCheck        = drivers.ConstraintCheck
+	KindTimeout      = "timeout"
+	KindAssert       = "assert"
+	KindInternal     = "internal"
+)
+
+// Kinds lists the supported error kinds.
+var Kinds = []string{KindNotFound, KindInvalidInput, KindUnique, KindForeignKey, KindNotNull, KindCheck, KindTimeout, KindAssert, KindInternal}
+
+// defaults holds the status and detail for each kind.
+var defaults = map[string]struct {
+	status int
+	detail string
+}{
+	KindNotFound:     {http.StatusNotFound, "No row matches the request."},
+	KindInvalidInput: {http.StatusBadRequest, "The request parameters are invalid."},
+	KindUnique:       {http.StatusConflict, "The request conflicts with an existing row."},
+	KindForeignKey:   {http.StatusUnprocessableEntity, "The request references a row which doesn't exist, or is still referenced."},
+	KindNotNull:      {http.StatusUnprocessableEntity, "A required value is missing."},
+	KindCheck:        {http.StatusUnprocessableEntity, "A value is not allowed."},
+	KindTimeout:      {http.StatusGatewayTimeout, "The request took too long to complete."},
+	KindAssert:       {http.StatusBadRequest, "The request doesn't meet a condition of the endpoint."},
+	KindInternal:     {http.StatusInternalServerError, "The request could not be completed."},
+}
+
+// ErrNotFound is returned by handlers when no row matches a request.
+var ErrNotFound = errors.New("no rows found")
+
+// Error is an error with the status and detail of its response, like
+// a failed pipeline assertion. Empty fields keep the default values.
+type Error struct {
+	Status int
+	Detail string
+}
+
+// Error returns the status and detail.
+func (e *Error) Error() string {
+	if e.Detail == "" {
+		return fmt.Sprintf("assertion failed (%d)", e.Status)
+	}
+	return fmt.Sprintf("assertion failed (%d): %s", e.Status, e.Detail)
+}
+
+// Problem is a problem details response.
+type Problem struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	Kind   string `json:"kind"`
+
+	// Errors lists the invalid fields for invalid input.
+	Errors any `json:"errors,omitempty"`
+
+	// Query and Error are set in dev mode.
+	Query string `json:"query,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// fieldErrors is implemented by errors which list invalid fields.
+type fieldErrors interface {
+	FieldErrors() any
+}
+
+// Mapper maps errors to problems, applying the endpoint overrides.
+type Mapper struct {
+	overrides map[string]*config.ErrorResponse
+	dev       bool
+}
+
+// NewMapper creates a mapper, checking the override kinds and statuses.
+func NewMapper(overrides map[string]*config.ErrorResponse, dev bool) (*Mapper, error) {
+	for kind, override := range overrides {
+		if !slices.Contains(Kinds, kind) {
+			return nil, fmt.Errorf("unknown error kind %q, supported %v", kind, Kinds)
+		}
+		if override != nil && override.Status != 0 && (override.Status < 400 || override.Status > 599) {
+			return nil, fmt.Errorf("error %s: invalid status %d", kind, override.Status)
+		}
+	}
+	return &Mapper{
+		overrides: overrides,
+		dev:       dev,
+	}, nil
+}
+
+// Map returns the problem for an error.
+func (m *Mapper) Map(err error) *Problem {
+	kind := Kind(err)
+	status, detail := defaults[kind].status, defaults[kind].detail
+
+	p := &Problem{
+		Type:   "about:blank",
+		Status: status,
+		Detail: detail,
+		Kind:   kind,
+	}
+
+	var fields fieldErrors
+	if errors.As(err, &fields) {
+		p.Errors = fields.FieldErrors()
+	}
+
+	if m != nil {
+		if override := m.overrides[kind]; override != nil {
+			if override.Status != 0 {
+				p.Status = override.Status
+			}
+			if override.Type != "" {
+				p.Type = override.Type
+			}
+			if override.Title != "" {
+				p.Title = override.Title
+			}
+			if override.Detail != "" {
+				p.Detail = override.Detail
+			}
+		}
+
+		if m.dev {
+			var modelErr *model.Error
+			if errors.As(err, &modelErr) {
+				p.Query = strings.TrimSpace(modelErr.Statement)
+			}
+			p.Error = err.Error()
+		}
+	}
+
+	// The status and detail of the error take precedence over the kind
+	var problemErr *Error
+	if errors.As(err, &problemErr) {
+		if problemErr.Status != 0 {
+			p.Status = problemErr.Status
+		}
+		if problemErr.Detail != "" {
+			p.Detail = problemErr.Detail
+		}
+	}
+
+	if p.Title == "" {
+		p.Title = http.StatusText(p.Status)
+	}
+	return p
+}
+
+// Write maps the error and writes the problem response.
+// Server errors are logged, unless the client went away.
+func (m *Mapper) Write(w http.ResponseWriter, err error) {
+	p := m.Map(err)
+	if p.Status >= 500 && !errors.Is(err, context.Canceled) {
+		log.Printf("error: %v", err)
+	}
+	Write(w, p)
+}
+
+// Write writes a problem response.
+func Write(w http.ResponseWriter, p *Problem) {
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Del("Content-Length")
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
+
+// Kind returns the kind of an error.
+func Kind(err error) string {
+	var (
+		fields     fieldErrors
+		problemErr *Error
+	)
+	switch {
+	case errors.As(err, &problemErr):
+		return KindAssert
+	case errors.Is(err, ErrNotFound), errors.Is(err, sql.ErrNoRows):
+		return KindNotFound
+	case errors.As(err, &fields):
+		return KindInvalidInput
+	case drivers.Timeout(err), errors.Is(err, context.Canceled):
+		return KindTimeout
+	}
+	if kind := drivers.ConstraintKind(err); kind != "" {
+		return kind
+	}
+	return KindInternal
+}
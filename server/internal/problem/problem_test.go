@@ -0,0 +1,169 @@
+package problem
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+
+	"github.com/titpetric/etl/drivers"
+	"github.com/titpetric/etl/server/config"
+
+	_ "modernc.org/sqlite"
+)
+
+type fieldError struct{}
+
+func (fieldError) Error() string    { return "invalid input" }
+func (fieldError) FieldErrors() any { return []string{"id"} }
+
+// constraintErrors returns the errors for unique, foreign key and
+// not null violations from an in-memory sqlite database.
+func constraintErrors(t *testing.T) map[string]error {
+	t.Helper()
+
+	db, err := sqlx.Open("sqlite", ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	db.MustExec(`
+		pragma foreign_keys = on;
+		create table user (id integer primary key, email text not null unique);
+		create table post (id integer primary key, user_id integer references user(id));
+		insert into user (id, email) values (1, 'alice@example.com');
+	`)
+
+	statements := map[string]string{
+		KindUnique:     `insert into user (email) values ('alice@example.com')`,
+		KindForeignKey: `insert into post (user_id) values (2)`,
+		KindNotNull:    `insert into user (email) values (null)`,
+	}
+
+	result := make(map[string]error)
+	for kind, query := range statements {
+		_, err := db.Exec(query)
+		require.Error(t, err)
+		result[kind] = drivers.NewError(err, query)
+	}
+	return result
+}
+
+// TestMap verifies the kind and status for each class of error.
+func TestMap(t *testing.T) {
+	constraints := constraintErrors(t)
+
+	tests := []struct {
+		err    error
+		kind   string
+		status int
+	}{
+		{ErrNotFound, KindNotFound, http.StatusNotFound},
+		{sql.ErrNoRows, KindNotFound, http.StatusNotFound},
+		{fmt.Errorf("wrapped: %w", fieldError{}), KindInvalidInput, http.StatusBadRequest},
+		{constraints[KindUnique], KindUnique, http.StatusConflict},
+		{constraints[KindForeignKey], KindForeignKey, http.StatusUnprocessableEntity},
+		{constraints[KindNotNull], KindNotNull, http.StatusUnprocessableEntity},
+		{fmt.Errorf("query: %w", context.DeadlineExceeded), KindTimeout, http.StatusGatewayTimeout},
+		{fmt.Errorf("query: %w", context.Canceled), KindTimeout, http.StatusGatewayTimeout},
+		{&Error{}, KindAssert, http.StatusBadRequest},
+		{errors.New("connection refused"), KindInternal, http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		p := (*Mapper)(nil).Map(tt.err)
+		require.Equal(t, tt.kind, p.Kind, tt.err.Error())
+		require.Equal(t, tt.status, p.Status, tt.err.Error())
+		require.Equal(t, http.StatusText(tt.status), p.Title)
+		require.Equal(t, "about:blank", p.Type)
+		require.Empty(t, p.Query)
+		require.Empty(t, p.Error)
+	}
+
+	p := (*Mapper)(nil).Map(fieldError{})
+	require.Equal(t, []string{"id"}, p.Errors)
+}
+
+// TestMapperOverrides verifies that the endpoint overrides replace the defaults.
+func TestMapperOverrides(t *testing.T) {
+	m, err := NewMapper(map[string]*config.ErrorResponse{
+		KindUnique: {
+			Status: http.StatusUnprocessableEntity,
+			Type:   "https://example.com/problems/email-taken",
+			Title:  "Email taken",
+		},
+	}, false)
+	require.NoError(t, err)
+
+	p := m.Map(constraintErrors(t)[KindUnique])
+	require.Equal(t, &Problem{
+		Type:   "https://example.com/problems/email-taken",
+		Title:  "Email taken",
+		Status: http.StatusUnprocessableEntity,
+		Detail: defaults[KindUnique].detail,
+		Kind:   KindUnique,
+	}, p)
+}
+
+// TestMapError verifies that the status and detail of an error are kept.
+func TestMapError(t *testing.T) {
+	m, err := NewMapper(map[string]*config.ErrorResponse{
+		KindAssert: {Type: "https://example.com/problems/assert"},
+	}, false)
+	require.NoError(t, err)
+
+	p := m.Map(fmt.Errorf("pipeline: %w", &Error{Status: http.StatusNotFound, Detail: "User not found."}))
+	require.Equal(t, &Problem{
+		Type:   "https://example.com/problems/assert",
+		Title:  "Not Found",
+		Status: http.StatusNotFound,
+		Detail: "User not found.",
+		Kind:   KindAssert,
+	}, p)
+}
+
+// TestMapperDev verifies that dev mode adds the query and the error.
+func TestMapperDev(t *testing.T) {
+	m, err := NewMapper(nil, true)
+	require.NoError(t, err)
+
+	p := m.Map(constraintErrors(t)[KindUnique])
+	require.Equal(t, `insert into user (email) values ('alice@example.com')`, p.Query)
+	require.Contains(t, p.Error, "UNIQUE constraint failed")
+}
+
+// TestMapperWrite verifies the response headers and body.
+func TestMapperWrite(t *testing.T) {
+	w := httptest.NewRecorder()
+	w.Header().Set("Content-Type", "application/json")
+	(*Mapper)(nil).Write(w, ErrNotFound)
+
+	require.Equal(t, http.StatusNotFound, w.Code)
+	require.Equal(t, ContentType, w.Header().Get("Content-Type"))
+
+	var body map[string]any
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&body))
+	require.Equal(t, map[string]any{
+		"type":   "about:blank",
+		"title":  "Not Found",
+		"status": float64(http.StatusNotFound),
+		"detail": defaults[KindNotFound].detail,
+		"kind":   KindNotFound,
+	}, body)
+}
+
+// TestNewMapperInvalid verifies that invalid overrides are rejected at mount time.
+func TestNewMapperInvalid(t *testing.T) {
+	_, err := NewMapper(map[string]*config.ErrorResponse{"conflict": {Status: 409}}, false)
+	require.ErrorContains(t, err, `unknown error kind "conflict"`)
+
+	_, err = NewMapper(map[string]*config.ErrorResponse{KindUnique: {Status: 200}}, false)
+	require.ErrorContains(t, err, "invalid status 200")
+}
@@ -0,0 +1,401 @@
+// Package redis implements a minimal client for the Redis protocol (RESP),
+// used by the cache and rate limit stores.
+package redis
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultDialTimeout bounds connecting if the context has no deadline.
+const DefaultDialTimeout = 5 * time.Second
+
+// maxIdle is the number of idle connections kept by a client.
+const maxIdle = 8
+
+// Error is an error reply from the server.
+type Error string
+
+// Error returns the error message.
+func (e Error) Error() string {
+	return string(e)
+}
+
+// ErrNil is returned by the typed helpers when the reply is nil.
+var ErrNil = errors.New("redis: nil reply")
+
+// Client sends commands to a Redis protocol server. Connections are
+// pooled, and a client is safe for concurrent use.
+type Client struct {
+	addr     string
+	username string
+	password string
+	db       int
+
+	mu     sync.Mutex
+	idle   []*conn
+	closed bool
+}
+
+type conn struct {
+	net.Conn
+	r *bufio.Reader
+	w *bufio.Writer
+}
+
+// NewClient creates a client for an address, either `host:port` or a
+// `redis://[user:password@]host:port[/db]` URL. No connection is made
+// until the first command.
+func NewClient(addr string) (*Client, error) {
+	client := &Client{addr: addr}
+	if !strings.Contains(addr, "://") {
+		return client, nil
+	}
+
+	u, err := url.Parse(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis address: %w", err)
+	}
+	if u.Scheme != "redis" {
+		return nil, fmt.Errorf("invalid redis address: unsupported scheme %q", u.Scheme)
+	}
+
+	client.addr = u.Host
+	if u.Port() == "" {
+		client.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.User != nil {
+		client.username = u.User.Username()
+		client.password, _ = u.User.Password()
+	}
+	if db := strings.Trim(u.Path, "/"); db != "" {
+		if client.db, err = strconv.Atoi(db); err != nil {
+			return nil, fmt.Errorf("invalid redis database %q", db)
+		}
+	}
+	return client, nil
+}
+
+// Do sends a command and returns the reply. Replies are decoded to
+// string, int64, nil, []any or Error values.
+func (c *Client) Do(ctx context.Context, args ...any) (any, error) {
+	replies, err := c.Pipeline(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	if err, ok := replies[0].(Error); ok {
+		return nil, err
+	}
+	return replies[0], nil
+}
+
+// Pipeline sends several commands at once and returns their replies.
+// Error replies are returned in the result, and not as the error.
+func (c *Client) Pipeline(ctx context.Context, commands ...[]any) ([]any, error) {
+	cn, err := c.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	replies, err := cn.pipeline(ctx, commands)
+	if err != nil {
+		cn.Close()
+		return nil, err
+	}
+
+	c.put(cn)
+	return replies, nil
+}
+
+// Close closes the idle connections.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var errs []error
+	for _, cn := range c.idle {
+		errs = append(errs, cn.Close())
+	}
+	c.idle = nil
+	c.closed = true
+	return errors.Join(errs...)
+}
+
+func (c *Client) get(ctx context.Context) (*conn, error) {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil, errors.New("redis: client is closed")
+	}
+	if n := len(c.idle); n > 0 {
+		cn := c.idle[n-1]
+		c.idle = c.idle[:n-1]
+		c.mu.Unlock()
+		return cn, nil
+	}
+	c.mu.Unlock()
+
+	return c.dial(ctx)
+}
+
+func (c *Client) put(cn *conn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed || len(c.idle) >= maxIdle {
+		cn.Close()
+		return
+	}
+	c.idle = append(c.idle, cn)
+}
+
+func (c *Client) dial(ctx context.Context) (*conn, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, DefaultDialTimeout)
+		defer cancel()
+	}
+
+	var dialer net.Dialer
+	nc, err := dialer.DialContext(ctx, "tcp", c.addr)
+	if err != nil {
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+
+	cn := &conn{
+		Conn: nc,
+		r:    bufio.NewReader(nc),
+		w:    bufio.NewWriter(nc),
+	}
+
+	var setup [][]any
+	if c.password != "" {
+		if c.username != "" {
+			setup = append(setup, []any{"AUTH", c.username, c.password})
+		} else {
+			setup = append(setup, []any{"AUTH", c.password})
+		}
+	}
+	if c.db != 0 {
+		setup = append(setup, []any{"SELECT", c.db})
+	}
+	if len(setup) > 0 {
+		replies, err := cn.pipeline(ctx, setup)
+		if err == nil {
+			for _, reply := range replies {
+				if replyErr, ok := reply.(Error); ok {
+					err = replyErr
+				}
+			}
+		}
+		if err != nil {
+			cn.Close()
+			return nil, fmt.Errorf("redis: %w", err)
+		}
+	}
+	return cn, nil
+}
+
+// pipeline sends the commands and reads the replies. Cancelling the
+// context interrupts reads and writes, and the connection should be
+// closed after any error.
+func (cn *conn) pipeline(ctx context.Context, commands [][]any) ([]any, error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		cn.SetDeadline(deadline)
+	} else {
+		cn.SetDeadline(time.Time{})
+	}
+
+	// A deadline in the past unblocks pending reads and writes.
+	interrupt := context.AfterFunc(ctx, func() {
+		cn.SetDeadline(time.Unix(1, 0))
+	})
+
+	replies, err := cn.roundTrip(commands)
+
+	// If the interrupt ran, the connection can't be reused.
+	if !interrupt() {
+		return nil, ctx.Err()
+	}
+	return replies, err
+}
+
+func (cn *conn) roundTrip(commands [][]any) ([]any, error) {
+	for _, args := range commands {
+		if err := writeCommand(cn.w, args); err != nil {
+			return nil, err
+		}
+	}
+	if err := cn.w.Flush(); err != nil {
+		return nil, err
+	}
+
+	replies := make([]any, 0, len(commands))
+	for range commands {
+		reply, err := readReply(cn.r)
+		if err != nil {
+			return nil, err
+		}
+		replies = append(replies, reply)
+	}
+	return replies, nil
+}
+
+func writeCommand(w *bufio.Writer, args []any) error {
+	fmt.Fprintf(w, "*%d\r\n", len(args))
+	for _, arg := range args {
+		var value string
+		switch v := arg.(type) {
+		case string:
+			value = v
+		case []byte:
+			value = string(v)
+		case int:
+			value = strconv.Itoa(v)
+		case int64:
+			value = strconv.FormatInt(v, 10)
+		default:
+			return fmt.Errorf("redis: unsupported argument type %T", arg)
+		}
+		fmt.Fprintf(w, "$%d\r\n%s\r\n", len(value), value)
+	}
+	return nil
+}
+
+func readReply(r *bufio.Reader) (any, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("redis: empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return Error(line[1:]), nil
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		result := make([]any, 0, n)
+		for range n {
+			item, err := readReply(r)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, item)
+		}
+		return result, nil
+	}
+	return nil, fmt.Errorf("redis: invalid reply %q", line)
+}
+
+// String returns a bulk string reply, or ErrNil.
+func String(reply any, err error) (string, error) {
+	if err != nil {
+		return "", err
+	}
+	switch v := reply.(type) {
+	case string:
+		return v, nil
+	case nil:
+		return "", ErrNil
+	}
+	return "", fmt.Errorf("redis: unexpected reply type %T", reply)
+}
+
+// Int returns an integer reply.
+func Int(reply any, err error) (int64, error) {
+	if err != nil {
+		return 0, err
+	}
+	switch v := reply.(type) {
+	case int64:
+		return v, nil
+	case Error:
+		return 0, v
+	case nil:
+		return 0, ErrNil
+	}
+	return 0, fmt.Errorf("redis: unexpected reply type %T", reply)
+}
+
+// Strings returns an array reply of strings.
+func Strings(reply any, err error) ([]string, error) {
+	if err != nil {
+		return nil, err
+	}
+	values, ok := reply.([]any)
+	if !ok {
+		if reply == nil {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("redis: unexpected reply type %T", reply)
+	}
+
+	result := make([]string, 0, len(values))
+	for _, value := range values {
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("redis: unexpected array item %T", value)
+		}
+		result = append(result, s)
+	}
+	return result, nil
+}
+
+// Scan iterates the keys matching pattern with SCAN and calls fn
+// for each batch of keys.
+func (c *Client) Scan(ctx context.Context, pattern string, fn func(keys []string) error) error {
+	cursor := "0"
+	for {
+		reply, err := c.Do(ctx, "SCAN", cursor, "MATCH", pattern, "COUNT", 500)
+		if err != nil {
+			return err
+		}
+		parts, ok := reply.([]any)
+		if !ok || len(parts) != 2 {
+			return fmt.Errorf("redis: unexpected SCAN reply %v", reply)
+		}
+
+		cursor, _ = parts[0].(string)
+		keys, err := Strings(parts[1], nil)
+		if err != nil {
+			return err
+		}
+		if len(keys) > 0 {
+			if err := fn(keys); err != nil {
+				return err
+			}
+		}
+		if cursor == "0" || cursor == "" {
+			return nil
+		}
+	}
+}
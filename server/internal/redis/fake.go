@@ -0,0 +1,296 @@
+package redis
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Fake is an in-process server for the subset of the Redis protocol
+// used by the stores. It is meant for tests.
+type Fake struct {
+	listener net.Listener
+
+	mu      sync.Mutex
+	strings map[string]string
+	sets    map[string]map[string]struct{}
+	expires map[string]time.Time
+	wg      sync.WaitGroup
+}
+
+// NewFake starts a fake server on a random local port.
+func NewFake() (*Fake, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	f := &Fake{
+		listener: listener,
+		strings:  make(map[string]string),
+		sets:     make(map[string]map[string]struct{}),
+		expires:  make(map[string]time.Time),
+	}
+
+	f.wg.Add(1)
+	go f.serve()
+	return f, nil
+}
+
+// Addr returns the address the fake listens on.
+func (f *Fake) Addr() string {
+	return f.listener.Addr().String()
+}
+
+// Close stops the fake server.
+func (f *Fake) Close() error {
+	err := f.listener.Close()
+	f.wg.Wait()
+	return err
+}
+
+// Keys returns the keys which haven't expired, sorted.
+func (f *Fake) Keys() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var result []string
+	for key := range f.strings {
+		if f.alive(key) {
+			result = append(result, key)
+		}
+	}
+	for key := range f.sets {
+		if f.alive(key) {
+			result = append(result, key)
+		}
+	}
+	slices.Sort(result)
+	return result
+}
+
+func (f *Fake) serve() {
+	defer f.wg.Done()
+	for {
+		nc, err := f.listener.Accept()
+		if err != nil {
+			return
+		}
+		go f.handle(nc)
+	}
+}
+
+func (f *Fake) handle(nc net.Conn) {
+	defer nc.Close()
+
+	r := bufio.NewReader(nc)
+	w := bufio.NewWriter(nc)
+	for {
+		reply, err := readReply(r)
+		if err != nil {
+			return
+		}
+		args, err := Strings(reply, nil)
+		if err != nil || len(args) == 0 {
+			return
+		}
+
+		writeReply(w, f.exec(args))
+		if r.Buffered() == 0 {
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (f *Fake) exec(args []string) any {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	cmd, args := strings.ToUpper(args[0]), args[1:]
+	for key := range f.expires {
+		f.alive(key)
+	}
+
+	switch cmd {
+	case "PING":
+		return "PONG"
+	case "AUTH", "SELECT":
+		return "OK"
+	case "GET":
+		if value, ok := f.strings[args[0]]; ok {
+			return value
+		}
+		return nil
+	case "SET":
+		key, value := args[0], args[1]
+		var ttl time.Duration
+		nx := false
+		for i := 2; i < len(args); i++ {
+			switch strings.ToUpper(args[i]) {
+			case "NX":
+				nx = true
+			case "PX":
+				i++
+				ms, _ := strconv.ParseInt(args[i], 10, 64)
+				ttl = time.Duration(ms) * time.Millisecond
+			case "EX":
+				i++
+				s, _ := strconv.ParseInt(args[i], 10, 64)
+				ttl = time.Duration(s) * time.Second
+			}
+		}
+		if nx && f.exists(key) {
+			return nil
+		}
+		f.del(key)
+		f.strings[key] = value
+		if ttl > 0 {
+			f.expires[key] = time.Now().Add(ttl)
+		}
+		return "OK"
+	case "DEL":
+		var count int64
+		for _, key := range args {
+			if f.exists(key) {
+				count++
+			}
+			f.del(key)
+		}
+		return count
+	case "EXISTS":
+		var count int64
+		for _, key := range args {
+			if f.exists(key) {
+				count++
+			}
+		}
+		return count
+	case "INCR":
+		n, err := strconv.ParseInt(f.strings[args[0]], 10, 64)
+		if _, ok := f.strings[args[0]]; ok && err != nil {
+			return Error("ERR value is not an integer or out of range")
+		}
+		n++
+		f.strings[args[0]] = strconv.FormatInt(n, 10)
+		return n
+	case "PEXPIRE":
+		if !f.exists(args[0]) {
+			return int64(0)
+		}
+		ms, _ := strconv.ParseInt(args[1], 10, 64)
+		f.expires[args[0]] = time.Now().Add(time.Duration(ms) * time.Millisecond)
+		return int64(1)
+	case "PTTL":
+		if !f.exists(args[0]) {
+			return int64(-2)
+		}
+		expires, ok := f.expires[args[0]]
+		if !ok {
+			return int64(-1)
+		}
+		return time.Until(expires).Milliseconds()
+	case "SADD":
+		set := f.sets[args[0]]
+		if set == nil {
+			set = make(map[string]struct{})
+			f.sets[args[0]] = set
+		}
+		var count int64
+		for _, member := range args[1:] {
+			if _, ok := set[member]; !ok {
+				set[member] = struct{}{}
+				count++
+			}
+		}
+		return count
+	case "SREM":
+		set := f.sets[args[0]]
+		var count int64
+		for _, member := range args[1:] {
+			if _, ok := set[member]; ok {
+				delete(set, member)
+				count++
+			}
+		}
+		if len(set) == 0 {
+			f.del(args[0])
+		}
+		return count
+	case "SMEMBERS":
+		var result []any
+		for member := range f.sets[args[0]] {
+			result = append(result, member)
+		}
+		return result
+	case "SCAN":
+		pattern := "*"
+		for i := 1; i+1 < len(args); i += 2 {
+			if strings.ToUpper(args[i]) == "MATCH" {
+				pattern = args[i+1]
+			}
+		}
+		var keys []any
+		for _, m := range []map[string]string{f.strings} {
+			for key := range m {
+				if ok, _ := path.Match(pattern, key); ok {
+					keys = append(keys, key)
+				}
+			}
+		}
+		for key := range f.sets {
+			if ok, _ := path.Match(pattern, key); ok {
+				keys = append(keys, key)
+			}
+		}
+		return []any{"0", keys}
+	}
+	return Error(fmt.Sprintf("ERR unknown command '%s'", cmd))
+}
+
+// alive removes the key if it expired, and reports if it still exists.
+func (f *Fake) alive(key string) bool {
+	if expires, ok := f.expires[key]; ok && time.Now().After(expires) {
+		f.del(key)
+		return false
+	}
+	return true
+}
+
+func (f *Fake) exists(key string) bool {
+	_, isString := f.strings[key]
+	_, isSet := f.sets[key]
+	return isString || isSet
+}
+
+func (f *Fake) del(key string) {
+	delete(f.strings, key)
+	delete(f.sets, key)
+	delete(f.expires, key)
+}
+
+func writeReply(w io.Writer, reply any) {
+	switch v := reply.(type) {
+	case nil:
+		io.WriteString(w, "$-1\r\n")
+	case Error:
+		fmt.Fprintf(w, "-%s\r\n", v)
+	case int64:
+		fmt.Fprintf(w, ":%d\r\n", v)
+	case string:
+		fmt.Fprintf(w, "$%d\r\n%s\r\n", len(v), v)
+	case []any:
+		fmt.Fprintf(w, "*%d\r\n", len(v))
+		for _, item := range v {
+			writeReply(w, item)
+		}
+	}
+}
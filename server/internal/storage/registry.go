@@ -0,0 +1,171 @@
+// Package storage keeps the database connection pools of the server.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-bridget/mig/db"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/titpetric/etl/drivers"
+	"github.com/titpetric/etl/server/config"
+)
+
+// DefaultConnectTimeout bounds the startup ping if the storage
+// doesn't configure a connect timeout.
+const DefaultConnectTimeout = 5 * time.Second
+
+// Registry holds one connection pool for each database DSN and pool
+// settings. Pools are opened with Open, usually at server start, and
+// shared by all the handlers using the same database. The storage name
+// and replica don't change the pool, see Replica.
+type Registry struct {
+	mu    sync.Mutex
+	pools map[config.Storage]*sqlx.DB
+}
+
+// NewRegistry creates an empty storage registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		pools: make(map[config.Storage]*sqlx.DB),
+	}
+}
+
+// Open returns the pool for a storage, opening it if needed. A newly
+// opened pool is pinged, so connection errors surface immediately.
+// The registry isn't locked while connecting, so an unreachable
+// database doesn't block the other pools.
+func (r *Registry) Open(ctx context.Context, storage *config.Storage) (*sqlx.DB, error) {
+	if storage == nil {
+		return nil, errors.New("storage not configured")
+	}
+
+	key := poolKey(storage)
+	if handle, ok := r.lookup(key); ok {
+		return handle, nil
+	}
+
+	handle, err := open(ctx, storage)
+	if err != nil {
+		return nil, fmt.Errorf("storage %s: %w", drivers.RedactDSN(storage.DSN), err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Another caller opened the pool meanwhile
+	if existing, ok := r.pools[key]; ok {
+		handle.Close()
+		return existing, nil
+	}
+
+	r.pools[key] = handle
+	return handle, nil
+}
+
+// lookup returns the open pool for the key.
+func (r *Registry) lookup(key config.Storage) (*sqlx.DB, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	handle, ok := r.pools[key]
+	return handle, ok
+}
+
+// Replica returns the pool for the replica of a storage, resolved by
+// name from conf, or nil if the storage has no replica.
+func (r *Registry) Replica(conf *config.Config, storage *config.Storage) (*sqlx.DB, error) {
+	if storage == nil || storage.Replica == "" {
+		return nil, nil
+	}
+
+	replica, err := conf.ResolveStorage(&config.Storage{Ref: storage.Replica})
+	if err != nil {
+		return nil, err
+	}
+	return r.Get(replica)
+}
+
+// Get returns the pool for a storage, opening it if needed.
+func (r *Registry) Get(storage *config.Storage) (*sqlx.DB, error) {
+	return r.Open(context.Background(), storage)
+}
+
+// Len returns the number of open pools.
+func (r *Registry) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return len(r.pools)
+}
+
+// Close closes all the pools.
+func (r *Registry) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var errs []error
+	for key, handle := range r.pools {
+		errs = append(errs, handle.Close())
+		delete(r.pools, key)
+	}
+	return errors.Join(errs...)
+}
+
+// poolKey returns the settings of the pool for a storage.
+func poolKey(storage *config.Storage) config.Storage {
+	key := *storage
+	key.Ref, key.Replica = "", ""
+	return key
+}
+
+func open(ctx context.Context, storage *config.Storage) (*sqlx.DB, error) {
+	if storage.DSN == "" {
+		return nil, errors.New("dsn is empty, set storage.dsn or ETL_DB_DSN")
+	}
+
+	lifetime, err := parseDuration(storage.ConnMaxLifetime, 0)
+	if err != nil {
+		return nil, fmt.Errorf("invalid connMaxLifetime: %w", err)
+	}
+	timeout, err := parseDuration(storage.ConnectTimeout, DefaultConnectTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("invalid connectTimeout: %w", err)
+	}
+
+	driver, dsn := db.ParseDSN(storage.DSN)
+	handle, err := sqlx.Open(driver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("error opening %s database: %w", driver, err)
+	}
+
+	if storage.MaxOpenConns > 0 {
+		handle.SetMaxOpenConns(storage.MaxOpenConns)
+	}
+	if storage.MaxIdleConns > 0 {
+		handle.SetMaxIdleConns(storage.MaxIdleConns)
+	}
+	if lifetime > 0 {
+		handle.SetConnMaxLifetime(lifetime)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if err := handle.PingContext(ctx); err != nil {
+		handle.Close()
+		return nil, fmt.Errorf("can't connect to %s database: %w", driver, err)
+	}
+	return handle, nil
+}
+
+func parseDuration(value string, fallback time.Duration) (time.Duration, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	return time.ParseDuration(value)
+}
@@ -6,6 +6,8 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -507,3 +509,414 @@ func TestCacheMiddlewareEmptyBody(t *testing.T) {
 		t.Errorf("Expected cached header X-Processed: true")
 	}
 }
+
+func TestCacheInvalidator(t *testing.T) {
+	store := cache.NewMemoryStore()
+	tags := func(r *http.Request) []string {
+		return []string{"users:all"}
+	}
+
+	reads := 0
+	readHandler := cache.NewMiddleware(store, cache.NewDefaultKeyBuilder()).WithTags(tags).
+		Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			reads++
+			fmt.Fprintf(w, "read %d", reads)
+		}))
+
+	status := http.StatusOK
+	writeHandler := cache.NewInvalidator(store, tags).
+		Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+		}))
+
+	get := func() string {
+		w := httptest.NewRecorder()
+		readHandler.ServeHTTP(w, httptest.NewRequest("GET", "/users", nil))
+		return w.Body.String()
+	}
+	write := func(method string) {
+		writeHandler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, "/users", nil))
+	}
+
+	if got := get(); got != "read 1" {
+		t.Fatalf("Expected 'read 1', got %q", got)
+	}
+	if got := get(); got != "read 1" {
+		t.Fatalf("Expected cached 'read 1', got %q", got)
+	}
+
+	// Failed writes and reads don't invalidate
+	status = http.StatusBadRequest
+	write("POST")
+	status = http.StatusOK
+	write("GET")
+	if got := get(); got != "read 1" {
+		t.Fatalf("Expected cached 'read 1', got %q", got)
+	}
+
+	write("POST")
+	if got := get(); got != "read 2" {
+		t.Fatalf("Expected 'read 2' after invalidation, got %q", got)
+	}
+}
+
+func TestCacheInvalidatorDuringRead(t *testing.T) {
+	store := cache.NewMemoryStore()
+	tags := func(r *http.Request) []string {
+		return []string{"users:all"}
+	}
+
+	generations := cache.NewGenerations()
+
+	var reads atomic.Int32
+	started, release := make(chan struct{}), make(chan struct{})
+	readHandler := cache.NewMiddleware(store, cache.NewDefaultKeyBuilder()).WithTags(tags).WithGenerations(generations).
+		Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if reads.Add(1) == 1 {
+				close(started)
+				<-release
+			}
+			fmt.Fprintf(w, "read %d", reads.Load())
+		}))
+	writeHandler := cache.NewInvalidator(store, tags).WithGenerations(generations).
+		Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+
+	get := func() string {
+		w := httptest.NewRecorder()
+		readHandler.ServeHTTP(w, httptest.NewRequest("GET", "/users", nil))
+		return w.Body.String()
+	}
+
+	// The first read is running when the write invalidates its tags
+	done := make(chan string)
+	go func() { done <- get() }()
+	<-started
+	writeHandler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/users", nil))
+	close(release)
+
+	if got := <-done; got != "read 1" {
+		t.Fatalf("Expected 'read 1', got %q", got)
+	}
+	if got := get(); got != "read 2" {
+		t.Fatalf("Expected 'read 2', the first read shouldn't be cached, got %q", got)
+	}
+	if got := get(); got != "read 2" {
+		t.Fatalf("Expected cached 'read 2', got %q", got)
+	}
+}
+
+// slowStore blocks Set until release is closed.
+type slowStore struct {
+	cache.Store
+	setting chan struct{}
+	release chan struct{}
+}
+
+func (s *slowStore) Set(ctx context.Context, key string, entry *cache.Entry, ttl time.Duration) error {
+	close(s.setting)
+	<-s.release
+	return s.Store.Set(ctx, key, entry, ttl)
+}
+
+func TestCacheInvalidatorDuringSet(t *testing.T) {
+	store := &slowStore{Store: cache.NewMemoryStore(), setting: make(chan struct{}), release: make(chan struct{})}
+	tags := func(r *http.Request) []string {
+		return []string{"users:all"}
+	}
+	generations := cache.NewGenerations()
+
+	readHandler := cache.NewMiddleware(store, cache.NewDefaultKeyBuilder()).WithTags(tags).WithGenerations(generations).
+		Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, "read")
+		}))
+	writeHandler := cache.NewInvalidator(store, tags).WithGenerations(generations).
+		Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+
+	done := make(chan struct{})
+	go func() {
+		readHandler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/users", nil))
+		close(done)
+	}()
+
+	// The write doesn't wait for the slow Set, and deletes the tags before it stores
+	<-store.setting
+	writeHandler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/users", nil))
+	close(store.release)
+	<-done
+
+	entry, err := store.Get(context.Background(), cache.NewDefaultKeyBuilder().BuildKey(httptest.NewRequest("GET", "/users", nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry != nil {
+		t.Errorf("Expected the entry stored during invalidation to be deleted")
+	}
+}
+
+func TestCacheMiddlewareCoalescesMisses(t *testing.T) {
+	store := cache.NewLRUStore(0, 0)
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	handler := cache.NewMiddleware(store, cache.NewDefaultKeyBuilder()).
+		Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			<-release
+			fmt.Fprint(w, "data")
+		}))
+
+	var wg sync.WaitGroup
+	bodies := make([]string, 10)
+	for i := range bodies {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/data", nil))
+			bodies[i] = w.Body.String()
+		}()
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls.Load() != 1 {
+		t.Errorf("Expected handler called once, got %d", calls.Load())
+	}
+	for _, body := range bodies {
+		if body != "data" {
+			t.Errorf("Expected body 'data', got %q", body)
+		}
+	}
+}
+
+func TestCacheMiddlewareCoalescedCancel(t *testing.T) {
+	store := cache.NewLRUStore(0, 0)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := cache.NewMiddleware(store, cache.NewDefaultKeyBuilder()).
+		Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			<-release
+			if err := r.Context().Err(); err != nil {
+				http.Error(w, err.Error(), http.StatusServiceUnavailable)
+				return
+			}
+			fmt.Fprint(w, "data")
+		}))
+
+	// The first request starts the shared call, and goes away
+	ctx, cancel := context.WithCancel(context.Background())
+	first := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		handler.ServeHTTP(first, httptest.NewRequest("GET", "/api/data", nil).WithContext(ctx))
+	}()
+	<-started
+
+	second := httptest.NewRecorder()
+	waiting := make(chan struct{})
+	go func() {
+		defer close(waiting)
+		handler.ServeHTTP(second, httptest.NewRequest("GET", "/api/data", nil))
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	close(release)
+	<-done
+	<-waiting
+
+	if second.Code != http.StatusOK || second.Body.String() != "data" {
+		t.Errorf("Expected 'data' for the waiting request, got %d %q", second.Code, second.Body.String())
+	}
+	if entry, _ := store.Get(context.Background(), cache.NewDefaultKeyBuilder().BuildKey(httptest.NewRequest("GET", "/api/data", nil))); entry == nil {
+		t.Error("Expected the shared response to be cached")
+	}
+}
+
+func TestCacheMiddlewareTimeout(t *testing.T) {
+	store := cache.NewLRUStore(0, 0)
+
+	handler := cache.NewMiddleware(store, cache.NewDefaultKeyBuilder()).
+		WithTimeout(10 * time.Millisecond).
+		Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+			http.Error(w, r.Context().Err().Error(), http.StatusGatewayTimeout)
+		}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/data", nil))
+
+	if w.Code != http.StatusGatewayTimeout || !strings.Contains(w.Body.String(), "deadline exceeded") {
+		t.Errorf("Expected the timeout to cancel the handler, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestCacheMiddlewareStaleWhileRevalidate(t *testing.T) {
+	store := cache.NewLRUStore(0, 0)
+
+	var calls atomic.Int32
+	handler := cache.NewMiddleware(store, cache.NewDefaultKeyBuilder()).
+		WithTTL(20 * time.Millisecond).
+		WithStaleWhileRevalidate(time.Minute).
+		Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, "v%d", calls.Add(1))
+		}))
+
+	get := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/data", nil))
+		return w
+	}
+
+	if w := get(); w.Body.String() != "v1" {
+		t.Fatalf("Expected 'v1', got %q", w.Body.String())
+	}
+	time.Sleep(30 * time.Millisecond)
+
+	// The stale response is served, and refreshed in the background
+	w := get()
+	if w.Body.String() != "v1" || w.Header().Get("X-Cache") != "STALE" {
+		t.Fatalf("Expected stale 'v1', got %q (%s)", w.Body.String(), w.Header().Get("X-Cache"))
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		w = get()
+		if w.Header().Get("X-Cache") == "HIT" || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if w.Body.String() != "v2" {
+		t.Errorf("Expected refreshed 'v2', got %q", w.Body.String())
+	}
+	if calls.Load() != 2 {
+		t.Errorf("Expected 2 handler calls, got %d", calls.Load())
+	}
+}
+
+func TestCacheMiddlewareStaleIfError(t *testing.T) {
+	store := cache.NewLRUStore(0, 0)
+
+	var failing atomic.Bool
+	handler := cache.NewMiddleware(store, cache.NewDefaultKeyBuilder()).
+		WithTTL(10 * time.Millisecond).
+		WithStaleIfError(time.Minute).
+		Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if failing.Load() {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			fmt.Fprint(w, "data")
+		}))
+
+	get := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/data", nil))
+		return w
+	}
+
+	get()
+	failing.Store(true)
+	time.Sleep(20 * time.Millisecond)
+
+	w := get()
+	if w.Code != http.StatusOK || w.Body.String() != "data" || w.Header().Get("X-Cache") != "STALE" {
+		t.Errorf("Expected stale 'data', got %d %q (%s)", w.Code, w.Body.String(), w.Header().Get("X-Cache"))
+	}
+}
+
+func TestCacheMiddlewareValidators(t *testing.T) {
+	store := cache.NewLRUStore(0, 0)
+
+	calls := 0
+	handler := cache.NewMiddleware(store, cache.NewDefaultKeyBuilder()).
+		WithTTL(time.Minute).
+		Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			w.Header().Set("Content-Type", "text/plain")
+			fmt.Fprint(w, "data")
+		}))
+
+	get := func(headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/data", nil)
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get(nil)
+	etag := w.Header().Get("ETag")
+	lastModified := w.Header().Get("Last-Modified")
+	if etag != cache.ETag([]byte("data")) {
+		t.Errorf("Expected ETag of the body, got %q", etag)
+	}
+	if lastModified == "" {
+		t.Error("Expected Last-Modified header")
+	}
+	if got := w.Header().Get("Cache-Control"); got != "max-age=60" && got != "max-age=59" {
+		t.Errorf("Expected Cache-Control max-age=60, got %q", got)
+	}
+
+	tests := []struct {
+		headers map[string]string
+		status  int
+	}{
+		{map[string]string{"If-None-Match": etag}, http.StatusNotModified},
+		{map[string]string{"If-None-Match": `"other", W/` + etag}, http.StatusNotModified},
+		{map[string]string{"If-None-Match": "*"}, http.StatusNotModified},
+		{map[string]string{"If-None-Match": `"other"`}, http.StatusOK},
+		{map[string]string{"If-Modified-Since": lastModified}, http.StatusNotModified},
+		{map[string]string{"If-Modified-Since": "Mon, 02 Jan 2006 15:04:05 GMT"}, http.StatusOK},
+		{map[string]string{"If-None-Match": `"other"`, "If-Modified-Since": lastModified}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		w := get(tt.headers)
+		if w.Code != tt.status {
+			t.Errorf("%v: expected status %d, got %d", tt.headers, tt.status, w.Code)
+		}
+		if tt.status == http.StatusNotModified && (w.Body.Len() != 0 || w.Header().Get("ETag") != etag) {
+			t.Errorf("%v: expected empty 304 with ETag, got %q", tt.headers, w.Body.String())
+		}
+	}
+	if calls != 1 {
+		t.Errorf("Expected handler called once, got %d", calls)
+	}
+
+	// no-cache requests skip the cached response
+	w = get(map[string]string{"Cache-Control": "no-cache"})
+	if w.Header().Get("X-Cache") != "MISS" || calls != 2 {
+		t.Errorf("Expected no-cache request to call the handler, got %s with %d calls", w.Header().Get("X-Cache"), calls)
+	}
+}
+
+func TestCacheMiddlewareHandlerValidators(t *testing.T) {
+	handler := cache.NewMiddleware(cache.NewLRUStore(0, 0), cache.NewDefaultKeyBuilder()).
+		Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("ETag", `"v1"`)
+			w.Header().Set("Cache-Control", "private, max-age=10")
+			fmt.Fprint(w, "data")
+		}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/data", nil))
+
+	if got := w.Header().Get("ETag"); got != `"v1"` {
+		t.Errorf("Expected handler ETag, got %q", got)
+	}
+	if got := w.Header().Values("Cache-Control"); len(got) != 1 || got[0] != "private, max-age=10" {
+		t.Errorf("Expected handler Cache-Control, got %q", got)
+	}
+}
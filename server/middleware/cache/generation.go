@@ -0,0 +1,66 @@
+package cache
+
+import (
+	"hash/maphash"
+	"sync/atomic"
+)
+
+// generationSlots is the number of invalidation counters. Tags share
+// counters by hash, so memory doesn't grow with the distinct tags.
+const generationSlots = 4096
+
+// Generations counts the invalidations of tags, so a response read
+// before a write isn't cached after the write deleted its tags. Tags
+// hashing to the same counter invalidate each other's pending reads,
+// which only skips caching a response. Middleware and invalidators
+// sharing a store should share the generations. A nil *Generations
+// doesn't track invalidations.
+type Generations struct {
+	seed     maphash.Seed
+	counters [generationSlots]atomic.Uint64
+}
+
+// NewGenerations creates the invalidation counters for a store.
+func NewGenerations() *Generations {
+	return &Generations{seed: maphash.MakeSeed()}
+}
+
+// counter returns the invalidation counter of a tag.
+func (g *Generations) counter(tag string) *atomic.Uint64 {
+	return &g.counters[maphash.String(g.seed, tag)%generationSlots]
+}
+
+// load returns the current generation of each tag.
+func (g *Generations) load(tags []string) []uint64 {
+	if g == nil {
+		return nil
+	}
+	result := make([]uint64, len(tags))
+	for i, tag := range tags {
+		result[i] = g.counter(tag).Load()
+	}
+	return result
+}
+
+// bump starts a new generation of the tags, before they are deleted.
+func (g *Generations) bump(tags []string) {
+	if g == nil {
+		return
+	}
+	for _, tag := range tags {
+		g.counter(tag).Add(1)
+	}
+}
+
+// current returns true if the tags are still at the loaded generations.
+func (g *Generations) current(tags []string, loaded []uint64) bool {
+	if g == nil {
+		return true
+	}
+	for i, tag := range tags {
+		if g.counter(tag).Load() != loaded[i] {
+			return false
+		}
+	}
+	return true
+}
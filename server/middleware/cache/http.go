@@ -0,0 +1,80 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ETag returns a strong entity tag for a response body.
+func ETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// setValidators adds the ETag and Last-Modified headers to a response,
+// unless the handler has set them.
+func setValidators(header http.Header, body []byte, now time.Time) {
+	if header.Get("ETag") == "" {
+		header.Set("ETag", ETag(body))
+	}
+	if header.Get("Last-Modified") == "" {
+		header.Set("Last-Modified", now.UTC().Format(http.TimeFormat))
+	}
+}
+
+// maxAge returns the Cache-Control value for an entry fresh until the given time.
+func maxAge(freshUntil, now time.Time) string {
+	seconds := int64(freshUntil.Sub(now) / time.Second)
+	return fmt.Sprintf("max-age=%d", max(seconds, 0))
+}
+
+// noCache reports if the request asks for a response from the handler,
+// with `Cache-Control: no-cache` or `Pragma: no-cache`.
+func noCache(r *http.Request) bool {
+	for _, value := range r.Header.Values("Cache-Control") {
+		for _, directive := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(directive), "no-cache") {
+				return true
+			}
+		}
+	}
+	return r.Header.Get("Cache-Control") == "" && strings.EqualFold(r.Header.Get("Pragma"), "no-cache")
+}
+
+// notModified evaluates the If-None-Match and If-Modified-Since request
+// headers against the response validators.
+func notModified(r *http.Request, header http.Header) bool {
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		return etagMatch(match, header.Get("ETag"))
+	}
+
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	modified, err := http.ParseTime(header.Get("Last-Modified"))
+	if err != nil {
+		return false
+	}
+	return !modified.After(since)
+}
+
+// etagMatch reports if any tag in an If-None-Match list matches the
+// ETag, using the weak comparison.
+func etagMatch(list string, etag string) bool {
+	if etag == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, tag := range strings.Split(list, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,117 @@
+package cache
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Invalidator deletes tagged cache entries when a request modifies data.
+// Tags are deleted after the handler writes a successful status, and
+// before the response is sent, so the client doesn't read stale data
+// after the write. Responses to reads running during the write aren't
+// cached, if the Middleware shares the Generations. This only covers
+// middleware in the same process; entries read by other instances sharing the store just
+// before a write may be cached until they expire. GET, HEAD and
+// OPTIONS requests are passed through.
+type Invalidator struct {
+	// Store is the cache storage backend
+	Store Store
+
+	// Tags returns the tags to invalidate for a request
+	Tags func(r *http.Request) []string
+
+	// Generations are bumped before the tags are deleted
+	Generations *Generations
+
+	// Logger is an optional logger for invalidation errors
+	Logger *log.Logger
+}
+
+// NewInvalidator creates a new cache invalidation middleware
+func NewInvalidator(store Store, tags func(r *http.Request) []string) *Invalidator {
+	return &Invalidator{
+		Store: store,
+		Tags:  tags,
+	}
+}
+
+// WithGenerations sets the invalidation counters shared with middleware
+func (m *Invalidator) WithGenerations(generations *Generations) *Invalidator {
+	m.Generations = generations
+	return m
+}
+
+// WithLogger sets the logger
+func (m *Invalidator) WithLogger(logger *log.Logger) *Invalidator {
+	m.Logger = logger
+	return m
+}
+
+// Wrap returns a middleware function that wraps an http.Handler
+func (m *Invalidator) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		next.ServeHTTP(&invalidateWriter{ResponseWriter: w, invalidate: func() {
+			m.invalidate(r)
+		}}, r)
+	})
+}
+
+func (m *Invalidator) invalidate(r *http.Request) {
+	tags := m.Tags(r)
+	if len(tags) == 0 {
+		return
+	}
+
+	m.Generations.bump(tags)
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	if err := m.Store.DeleteTags(ctx, tags...); err != nil && m.Logger != nil {
+		m.Logger.Printf("cache invalidation error: %v", err)
+	}
+}
+
+// invalidateWriter invalidates once when a successful status is written
+type invalidateWriter struct {
+	http.ResponseWriter
+	invalidate func()
+	written    bool
+}
+
+func (w *invalidateWriter) WriteHeader(code int) {
+	if !w.written {
+		w.written = true
+		if code >= 200 && code < 300 {
+			w.invalidate()
+		}
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *invalidateWriter) Write(b []byte) (int, error) {
+	if !w.written {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher if the underlying writer supports it
+func (w *invalidateWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// TableTag returns the tag for cache entries which read from a table.
+func TableTag(table string) string {
+	return "table:" + table
+}
@@ -4,6 +4,7 @@ import (
 	"crypto/md5"
 	"fmt"
 	"net/http"
+	"regexp"
 	"sort"
 	"strings"
 )
@@ -137,3 +138,81 @@ func (ckb *CustomKeyBuilder) BuildKey(r *http.Request) string {
 func NewCustomKeyBuilder(fn func(r *http.Request) string) *CustomKeyBuilder {
 	return &CustomKeyBuilder{BuildFunc: fn}
 }
+
+// PatternKeyBuilder creates readable cache keys from a pattern with
+// placeholders, e.g. `user:{id}`. Placeholders are filled in from the
+// Params function (usually router path parameters), falling back to
+// query parameters. Keys start with the request method and route, so
+// endpoints sharing a pattern are kept apart, and the query string is
+// appended, so that requests for different pages of a resource are too.
+type PatternKeyBuilder struct {
+	// Pattern is the key pattern with {name} placeholders
+	Pattern string
+	// Params returns a named request parameter, e.g. chi.URLParam
+	Params func(r *http.Request, name string) string
+	// Route returns the route pattern of the request, the request path if unset
+	Route func(r *http.Request) string
+	// IncludeHeaders specifies which headers to include in the key
+	IncludeHeaders []string
+}
+
+var patternPlaceholderRe = regexp.MustCompile(`\{(\w+)\}`)
+
+// NewPatternKeyBuilder creates a new pattern key builder
+func NewPatternKeyBuilder(pattern string, params func(r *http.Request, name string) string) *PatternKeyBuilder {
+	return &PatternKeyBuilder{
+		Pattern: pattern,
+		Params:  params,
+	}
+}
+
+// WithRoute sets the function returning the route pattern of a request
+func (kb *PatternKeyBuilder) WithRoute(route func(r *http.Request) string) *PatternKeyBuilder {
+	kb.Route = route
+	return kb
+}
+
+// WithHeaders sets which headers to include in the key
+func (kb *PatternKeyBuilder) WithHeaders(headers ...string) *PatternKeyBuilder {
+	kb.IncludeHeaders = headers
+	return kb
+}
+
+// Fill replaces the placeholders in the pattern with request parameters
+func (kb *PatternKeyBuilder) Fill(r *http.Request) string {
+	return FillPattern(kb.Pattern, r, kb.Params)
+}
+
+// BuildKey creates a cache key from the request
+func (kb *PatternKeyBuilder) BuildKey(r *http.Request) string {
+	route := r.URL.Path
+	if kb.Route != nil {
+		route = kb.Route(r)
+	}
+
+	key := "cache:" + r.Method + " " + route + ":" + kb.Fill(r)
+	if query := r.URL.Query(); len(query) > 0 {
+		// Encode sorts the query by key
+		key += "?" + query.Encode()
+	}
+	for _, name := range kb.IncludeHeaders {
+		if value := r.Header.Get(name); value != "" {
+			key += " " + http.CanonicalHeaderKey(name) + "=" + value
+		}
+	}
+	return key
+}
+
+// FillPattern replaces {name} placeholders in pattern with the values
+// returned by params, or the query parameter of the same name.
+func FillPattern(pattern string, r *http.Request, params func(r *http.Request, name string) string) string {
+	return patternPlaceholderRe.ReplaceAllStringFunc(pattern, func(match string) string {
+		name := match[1 : len(match)-1]
+		if params != nil {
+			if value := params(r, name); value != "" {
+				return value
+			}
+		}
+		return r.URL.Query().Get(name)
+	})
+}
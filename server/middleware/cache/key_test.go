@@ -175,3 +175,54 @@ func TestDefaultKeyBuilderEmptyPath(t *testing.T) {
 	require.NotEmpty(t, key)
 	require.True(t, strings.HasPrefix(key, "cache:"))
 }
+
+// TestPatternKeyBuilder verifies that placeholders are filled from params and the query.
+func TestPatternKeyBuilder(t *testing.T) {
+	params := func(r *http.Request, name string) string {
+		if name == "id" {
+			return "42"
+		}
+		return ""
+	}
+	kb := NewPatternKeyBuilder("user:{id}:{tab}", params)
+
+	req := httptest.NewRequest("GET", "http://example.com/users/42?tab=posts", nil)
+	require.Equal(t, "user:42:posts", kb.Fill(req))
+	require.Equal(t, "cache:GET /users/42:user:42:posts?tab=posts", kb.BuildKey(req))
+
+	req = httptest.NewRequest("HEAD", "http://example.com/users/42", nil)
+	require.Equal(t, "cache:HEAD /users/42:user:42:", kb.BuildKey(req))
+
+	// Endpoints sharing a pattern get different keys
+	kb.WithRoute(func(r *http.Request) string { return "/users/{id}" })
+	req = httptest.NewRequest("GET", "http://example.com/users/42", nil)
+	require.Equal(t, "cache:GET /users/{id}:user:42:", kb.BuildKey(req))
+
+	profile := NewPatternKeyBuilder("user:{id}:{tab}", params).WithRoute(func(r *http.Request) string { return "/profiles/{id}" })
+	require.NotEqual(t, kb.BuildKey(req), profile.BuildKey(req))
+	require.Equal(t, kb.Fill(req), profile.Fill(req))
+}
+
+// TestPatternKeyBuilderQueryOrdering verifies that query ordering doesn't change the key.
+func TestPatternKeyBuilderQueryOrdering(t *testing.T) {
+	kb := NewPatternKeyBuilder("users", nil)
+	req1 := httptest.NewRequest("GET", "http://example.com/users?a=1&b=2", nil)
+	req2 := httptest.NewRequest("GET", "http://example.com/users?b=2&a=1", nil)
+
+	require.Equal(t, kb.BuildKey(req1), kb.BuildKey(req2))
+}
+
+// TestPatternKeyBuilderHeaders verifies that the included headers are part of the key.
+func TestPatternKeyBuilderHeaders(t *testing.T) {
+	kb := NewPatternKeyBuilder("users", nil).WithHeaders("x-tenant")
+
+	req := httptest.NewRequest("GET", "http://example.com/users?a=1", nil)
+	require.Equal(t, "cache:GET /users:users?a=1", kb.BuildKey(req))
+
+	req.Header.Set("X-Tenant", "acme")
+	require.Equal(t, "cache:GET /users:users?a=1 X-Tenant=acme", kb.BuildKey(req))
+
+	other := httptest.NewRequest("GET", "http://example.com/users?a=1", nil)
+	other.Header.Set("X-Tenant", "globex")
+	require.NotEqual(t, kb.BuildKey(req), kb.BuildKey(other))
+}
@@ -0,0 +1,216 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultMaxEntries is the default entry limit of the server memory store.
+	DefaultMaxEntries = 10000
+
+	// DefaultMaxBytes is the default body size limit of the server memory store.
+	DefaultMaxBytes = 64 << 20
+)
+
+// LRUStore is an in-memory cache bounded by the number of entries and
+// the total size of the response bodies. When a limit is reached, the
+// least recently used entries are evicted.
+type LRUStore struct {
+	maxEntries int
+	maxBytes   int64
+
+	mu    sync.Mutex
+	bytes int64
+	order *list.List
+	items map[string]*list.Element
+	tags  map[string]map[string]struct{}
+}
+
+type lruItem struct {
+	key   string
+	entry *Entry
+}
+
+// NewLRUStore creates a bounded in-memory cache store. A limit of zero
+// or less disables that limit.
+func NewLRUStore(maxEntries int, maxBytes int64) *LRUStore {
+	return &LRUStore{
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		order:      list.New(),
+		items:      make(map[string]*list.Element),
+		tags:       make(map[string]map[string]struct{}),
+	}
+}
+
+// Get retrieves a cached entry if it exists and hasn't expired,
+// and marks it as recently used.
+func (s *LRUStore) Get(ctx context.Context, key string) (*Entry, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	el, ok := s.items[key]
+	if !ok {
+		return nil, nil
+	}
+
+	entry := el.Value.(*lruItem).entry
+	if time.Now().After(entry.ExpiresAt) {
+		s.remove(el)
+		return nil, nil
+	}
+
+	s.order.MoveToFront(el)
+	return entry, nil
+}
+
+// Set stores an entry with a TTL, evicting the least recently used
+// entries to stay within the limits. Entries with a body larger than
+// the byte limit are not stored.
+func (s *LRUStore) Set(ctx context.Context, key string, entry *Entry, ttl time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if ttl == 0 {
+		ttl = 5 * time.Minute // default TTL
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if el, ok := s.items[key]; ok {
+		s.remove(el)
+	}
+
+	size := int64(len(entry.Body))
+	if s.maxBytes > 0 && size > s.maxBytes {
+		return nil
+	}
+
+	entry.ExpiresAt = time.Now().Add(ttl)
+	s.items[key] = s.order.PushFront(&lruItem{key: key, entry: entry})
+	s.bytes += size
+	for _, tag := range entry.Tags {
+		if s.tags[tag] == nil {
+			s.tags[tag] = make(map[string]struct{})
+		}
+		s.tags[tag][key] = struct{}{}
+	}
+
+	for s.overLimit() {
+		s.remove(s.order.Back())
+	}
+	return nil
+}
+
+// Delete removes an entry from the cache.
+func (s *LRUStore) Delete(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if el, ok := s.items[key]; ok {
+		s.remove(el)
+	}
+	return nil
+}
+
+// DeleteTags removes all entries tagged with any of the tags.
+func (s *LRUStore) DeleteTags(ctx context.Context, tags ...string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, tag := range tags {
+		for key := range s.tags[tag] {
+			s.remove(s.items[key])
+		}
+	}
+	return nil
+}
+
+// Clear removes all entries from the cache.
+func (s *LRUStore) Clear(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.bytes = 0
+	s.order.Init()
+	s.items = make(map[string]*list.Element)
+	s.tags = make(map[string]map[string]struct{})
+	return nil
+}
+
+// CleanupExpired removes all expired entries from the cache.
+func (s *LRUStore) CleanupExpired(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for el := s.order.Back(); el != nil; {
+		prev := el.Prev()
+		if now.After(el.Value.(*lruItem).entry.ExpiresAt) {
+			s.remove(el)
+		}
+		el = prev
+	}
+	return nil
+}
+
+// Len returns the number of entries in the cache.
+func (s *LRUStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.order.Len()
+}
+
+// Bytes returns the total body size of the entries in the cache.
+func (s *LRUStore) Bytes() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.bytes
+}
+
+func (s *LRUStore) overLimit() bool {
+	if s.order.Len() == 0 {
+		return false
+	}
+	return (s.maxEntries > 0 && s.order.Len() > s.maxEntries) ||
+		(s.maxBytes > 0 && s.bytes > s.maxBytes)
+}
+
+// remove deletes an element and its tag references, the caller holds the lock
+func (s *LRUStore) remove(el *list.Element) {
+	item := el.Value.(*lruItem)
+	for _, tag := range item.entry.Tags {
+		delete(s.tags[tag], item.key)
+		if len(s.tags[tag]) == 0 {
+			delete(s.tags, tag)
+		}
+	}
+	delete(s.items, item.key)
+	s.order.Remove(el)
+	s.bytes -= int64(len(item.entry.Body))
+}
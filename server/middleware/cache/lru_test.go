@@ -0,0 +1,88 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestLRUStoreMaxEntries verifies that the least recently used entries are evicted.
+func TestLRUStoreMaxEntries(t *testing.T) {
+	ctx := context.Background()
+	store := NewLRUStore(2, 0)
+
+	require.NoError(t, store.Set(ctx, "a", &Entry{Body: []byte("a")}, time.Minute))
+	require.NoError(t, store.Set(ctx, "b", &Entry{Body: []byte("b")}, time.Minute))
+
+	// Using a makes b the least recently used
+	entry, err := store.Get(ctx, "a")
+	require.NoError(t, err)
+	require.NotNil(t, entry)
+
+	require.NoError(t, store.Set(ctx, "c", &Entry{Body: []byte("c")}, time.Minute))
+	require.Equal(t, 2, store.Len())
+
+	entry, _ = store.Get(ctx, "b")
+	require.Nil(t, entry)
+	entry, _ = store.Get(ctx, "a")
+	require.NotNil(t, entry)
+	entry, _ = store.Get(ctx, "c")
+	require.NotNil(t, entry)
+}
+
+// TestLRUStoreMaxBytes verifies that entries are evicted to stay within the body size limit.
+func TestLRUStoreMaxBytes(t *testing.T) {
+	ctx := context.Background()
+	store := NewLRUStore(0, 10)
+
+	require.NoError(t, store.Set(ctx, "a", &Entry{Body: []byte("12345")}, time.Minute))
+	require.NoError(t, store.Set(ctx, "b", &Entry{Body: []byte("12345")}, time.Minute))
+	require.Equal(t, int64(10), store.Bytes())
+
+	require.NoError(t, store.Set(ctx, "c", &Entry{Body: []byte("123")}, time.Minute))
+	require.Equal(t, 2, store.Len())
+	require.Equal(t, int64(8), store.Bytes())
+
+	entry, _ := store.Get(ctx, "a")
+	require.Nil(t, entry)
+
+	// Entries larger than the limit are not stored, and replace nothing
+	require.NoError(t, store.Set(ctx, "big", &Entry{Body: make([]byte, 11)}, time.Minute))
+	entry, _ = store.Get(ctx, "big")
+	require.Nil(t, entry)
+	require.Equal(t, 2, store.Len())
+
+	// Overwriting an entry accounts for the new size
+	require.NoError(t, store.Set(ctx, "b", &Entry{Body: []byte("1")}, time.Minute))
+	require.Equal(t, int64(4), store.Bytes())
+}
+
+// TestLRUStoreTags verifies that evicted entries are removed from the tag index.
+func TestLRUStoreTags(t *testing.T) {
+	ctx := context.Background()
+	store := NewLRUStore(1, 0)
+
+	require.NoError(t, store.Set(ctx, "a", &Entry{Tags: []string{"users"}}, time.Minute))
+	require.NoError(t, store.Set(ctx, "b", &Entry{Tags: []string{"pets"}}, time.Minute))
+	require.NotContains(t, store.tags, "users")
+
+	require.NoError(t, store.DeleteTags(ctx, "pets"))
+	require.Equal(t, 0, store.Len())
+	require.Empty(t, store.tags)
+}
+
+// TestLRUStoreCleanupExpired verifies that expired entries are removed.
+func TestLRUStoreCleanupExpired(t *testing.T) {
+	ctx := context.Background()
+	store := NewLRUStore(0, 0)
+
+	require.NoError(t, store.Set(ctx, "a", &Entry{Body: []byte("a")}, time.Millisecond))
+	require.NoError(t, store.Set(ctx, "b", &Entry{Body: []byte("b")}, time.Minute))
+	time.Sleep(5 * time.Millisecond)
+
+	require.NoError(t, store.CleanupExpired(ctx))
+	require.Equal(t, 1, store.Len())
+	require.Equal(t, int64(1), store.Bytes())
+}
@@ -6,6 +6,8 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"time"
+
+	"golang.org/x/sync/singleflight"
 )
 
 // Middleware provides caching capabilities for HTTP handlers
@@ -19,11 +21,32 @@ type Middleware struct {
 	// TTL is the cache time-to-live duration
 	TTL time.Duration
 
+	// Tags returns the tags for a cached response, used for invalidation
+	Tags func(r *http.Request) []string
+
+	// Generations skip caching responses read while an Invalidator
+	// sharing them deleted their tags
+	Generations *Generations
+
+	// StaleWhileRevalidate is how long after the TTL an entry is served
+	// while a background request refreshes it
+	StaleWhileRevalidate time.Duration
+
+	// StaleIfError is how long after the TTL an entry is served
+	// when the handler responds with a server error
+	StaleIfError time.Duration
+
+	// Timeout bounds handler calls shared between requests or run in
+	// the background, which aren't cancelled with the client request
+	Timeout time.Duration
+
 	// Enabled indicates whether caching is active
 	Enabled bool
 
 	// Logger is an optional logger for cache operations
 	Logger *log.Logger
+
+	group singleflight.Group
 }
 
 // NewMiddleware creates a new cache middleware
@@ -42,6 +65,36 @@ func (m *Middleware) WithTTL(ttl time.Duration) *Middleware {
 	return m
 }
 
+// WithTags sets the function returning the tags for cached responses
+func (m *Middleware) WithTags(tags func(r *http.Request) []string) *Middleware {
+	m.Tags = tags
+	return m
+}
+
+// WithGenerations sets the invalidation counters shared with invalidators
+func (m *Middleware) WithGenerations(generations *Generations) *Middleware {
+	m.Generations = generations
+	return m
+}
+
+// WithStaleWhileRevalidate sets how long stale entries are served while revalidating
+func (m *Middleware) WithStaleWhileRevalidate(d time.Duration) *Middleware {
+	m.StaleWhileRevalidate = d
+	return m
+}
+
+// WithStaleIfError sets how long stale entries are served on server errors
+func (m *Middleware) WithStaleIfError(d time.Duration) *Middleware {
+	m.StaleIfError = d
+	return m
+}
+
+// WithTimeout sets the timeout of shared and background handler calls
+func (m *Middleware) WithTimeout(timeout time.Duration) *Middleware {
+	m.Timeout = timeout
+	return m
+}
+
 // WithLogger sets the logger
 func (m *Middleware) WithLogger(logger *log.Logger) *Middleware {
 	m.Logger = logger
@@ -55,7 +108,10 @@ func (m *Middleware) WithEnabled(enabled bool) *Middleware {
 }
 
 // Wrap returns a middleware function that wraps an http.Handler
-// It attempts to serve cached responses and caches successful responses
+// It attempts to serve cached responses and caches successful responses.
+// Concurrent misses for the same key share one call of the handler.
+// Responses carry ETag and Last-Modified validators, and requests with
+// `Cache-Control: no-cache` skip the cached response.
 func (m *Middleware) Wrap(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if !m.Enabled {
@@ -72,75 +128,186 @@ func (m *Middleware) Wrap(next http.Handler) http.Handler {
 		// Generate cache key
 		key := m.KeyBuilder.BuildKey(r)
 
+		// The client asks for a response from the handler
+		if noCache(r) {
+			m.write(w, r, m.fetch(r, key, next), "MISS")
+			return
+		}
+
 		// Try to get from cache
 		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 		defer cancel()
 
 		cached, err := m.Store.Get(ctx, key)
-		if err == nil && cached != nil {
+		if err != nil || cached == nil {
+			m.write(w, r, m.fetch(r, key, next), "MISS")
+			return
+		}
+
+		now := time.Now()
+		if cached.fresh(now) {
 			if m.Logger != nil {
 				m.Logger.Printf("cache hit: %s", key)
 			}
+			m.write(w, r, cached, "HIT")
+			return
+		}
 
-			// Serve from cache
-			w.Header().Set("X-Cache", "HIT")
-			for headerName, headerValues := range cached.Headers {
-				for _, value := range headerValues {
-					w.Header().Add(headerName, value)
-				}
-			}
-			w.WriteHeader(cached.StatusCode)
-			w.Write(cached.Body)
+		// Serve the stale entry and refresh it in the background
+		if now.Before(cached.FreshUntil.Add(m.StaleWhileRevalidate)) {
+			m.revalidate(r, key, next)
+			m.write(w, r, cached, "STALE")
 			return
 		}
 
-		// Request was not cached, capture the response using ResponseRecorder
-		recorder := httptest.NewRecorder()
+		// Serve the stale entry if the handler fails
+		entry := m.fetch(r, key, next)
+		if entry.StatusCode >= 500 && now.Before(cached.FreshUntil.Add(m.StaleIfError)) {
+			m.write(w, r, cached, "STALE")
+			return
+		}
+		m.write(w, r, entry, "MISS")
+	})
+}
+
+// fetch runs the handler, sharing the response with concurrent
+// requests for the same key. The shared call isn't cancelled with
+// the request which started it, so the other requests get a response.
+func (m *Middleware) fetch(r *http.Request, key string, next http.Handler) *Entry {
+	r = r.Clone(context.WithoutCancel(r.Context()))
+	result, _, _ := m.group.Do(key, func() (any, error) {
+		return m.detached(r, key, next), nil
+	})
+	return result.(*Entry)
+}
+
+// revalidate refreshes an entry in the background, unless a refresh
+// for the key is already running.
+func (m *Middleware) revalidate(r *http.Request, key string, next http.Handler) {
+	r = r.Clone(context.WithoutCancel(r.Context()))
+	m.group.DoChan(key, func() (any, error) {
+		if m.Logger != nil {
+			m.Logger.Printf("cache revalidate: %s", key)
+		}
+		return m.detached(r, key, next), nil
+	})
+}
+
+// detached runs record for a request detached from the client, with
+// the context cancelled after Timeout.
+func (m *Middleware) detached(r *http.Request, key string, next http.Handler) *Entry {
+	if m.Timeout <= 0 {
+		return m.record(r, key, next)
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), m.Timeout)
+	defer cancel()
 
-		// Serve the request
-		next.ServeHTTP(recorder, r)
+	return m.record(r.WithContext(ctx), key, next)
+}
 
-		// Set cache miss header first
-		w.Header().Set("X-Cache", "MISS")
+// record runs the handler and caches the response if it was successful.
+// The response isn't cached if its tags were invalidated meanwhile,
+// since it may have been read before the write.
+func (m *Middleware) record(r *http.Request, key string, next http.Handler) *Entry {
+	var tags []string
+	if m.Tags != nil {
+		tags = m.Tags(r)
+	}
+	loaded := m.Generations.load(tags)
 
-		// Copy response headers from recorder to actual writer
-		for key, values := range recorder.Header() {
-			for _, value := range values {
-				w.Header().Add(key, value)
-			}
+	// Capture the response using ResponseRecorder
+	recorder := httptest.NewRecorder()
+	next.ServeHTTP(recorder, r)
+
+	entry := &Entry{
+		StatusCode: recorder.Code,
+		Headers:    recorder.Header(),
+		Body:       recorder.Body.Bytes(),
+	}
+	if recorder.Code < 200 || recorder.Code >= 300 {
+		return entry
+	}
+
+	entry.Tags = tags
+
+	// Keep the entry past its TTL while it may be served stale
+	ttl := m.TTL
+	if ttl == 0 {
+		ttl = 5 * time.Minute
+	}
+	now := time.Now()
+	entry.FreshUntil = now.Add(ttl)
+	setValidators(entry.Headers, entry.Body, now)
+
+	if !m.Generations.current(tags, loaded) {
+		if m.Logger != nil {
+			m.Logger.Printf("cache miss (invalidated): %s", key)
 		}
+		return entry
+	}
 
-		// Write status code to actual response writer
-		w.WriteHeader(recorder.Code)
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
 
-		// Cache the response if it was successful
-		if recorder.Code >= 200 && recorder.Code < 300 {
-			entry := &Entry{
-				StatusCode: recorder.Code,
-				Headers:    recorder.Header(),
-				Body:       recorder.Body.Bytes(),
-			}
+	if err := m.Store.Set(ctx, key, entry, ttl+max(m.StaleWhileRevalidate, m.StaleIfError)); err != nil {
+		if m.Logger != nil {
+			m.Logger.Printf("cache store error: %v", err)
+		}
+		return entry
+	}
 
-			ctx, cancel = context.WithTimeout(r.Context(), 5*time.Second)
-			err = m.Store.Set(ctx, key, entry, m.TTL)
-			cancel()
+	// An invalidation during Set may have deleted the tags before the
+	// entry was stored. Later ones bump after Set, and delete the entry.
+	if !m.Generations.current(tags, loaded) {
+		if err := m.Store.Delete(ctx, key); err != nil && m.Logger != nil {
+			m.Logger.Printf("cache delete error: %v", err)
+		}
+		if m.Logger != nil {
+			m.Logger.Printf("cache miss (invalidated): %s", key)
+		}
+		return entry
+	}
 
-			if err != nil && m.Logger != nil {
-				m.Logger.Printf("cache store error: %v", err)
-			} else if m.Logger != nil {
-				m.Logger.Printf("cache miss (stored): %s", key)
-			}
+	if m.Logger != nil {
+		m.Logger.Printf("cache miss (stored): %s", key)
+	}
+	return entry
+}
+
+// write copies an entry to the response, with the X-Cache header set to status.
+// Cacheable responses get a Cache-Control max-age with the remaining TTL, and
+// a 304 Not Modified if they match the conditional request headers.
+func (m *Middleware) write(w http.ResponseWriter, r *http.Request, entry *Entry, status string) {
+	header := w.Header()
+	header.Set("X-Cache", status)
+	for headerName, headerValues := range entry.Headers {
+		for _, value := range headerValues {
+			header.Add(headerName, value)
 		}
+	}
 
-		// Write response body to actual response writer
-		w.Write(recorder.Body.Bytes())
-	})
+	if !entry.FreshUntil.IsZero() && header.Get("Cache-Control") == "" {
+		header.Set("Cache-Control", maxAge(entry.FreshUntil, time.Now()))
+	}
+
+	if entry.StatusCode == http.StatusOK && notModified(r, header) {
+		header.Del("Content-Type")
+		header.Del("Content-Length")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.WriteHeader(entry.StatusCode)
+	w.Write(entry.Body)
 }
 
 // CleanupExpired removes expired cache entries
 // This should be called periodically
 func (m *Middleware) CleanupExpired(ctx context.Context) error {
-	if cleaner, ok := m.Store.(*MemoryStore); ok {
+	if cleaner, ok := m.Store.(interface {
+		CleanupExpired(ctx context.Context) error
+	}); ok {
 		return cleaner.CleanupExpired(ctx)
 	}
 	return nil
@@ -0,0 +1,171 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/titpetric/etl/server/internal/redis"
+)
+
+// DefaultRedisPrefix is prepended to the keys of the Redis stores.
+const DefaultRedisPrefix = "etl:"
+
+// RedisStore keeps cache entries in a Redis protocol server. Entries
+// expire on the server. Each tag is a set of the keys tagged with it.
+type RedisStore struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisStore creates a cache store using a Redis client.
+func NewRedisStore(client *redis.Client, prefix string) *RedisStore {
+	if prefix == "" {
+		prefix = DefaultRedisPrefix
+	}
+	return &RedisStore{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+// Get retrieves a cached entry if it exists and hasn't expired
+func (rs *RedisStore) Get(ctx context.Context, key string) (*Entry, error) {
+	data, err := redis.String(rs.client.Do(ctx, "GET", rs.entryKey(key)))
+	if errors.Is(err, redis.ErrNil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &Entry{}
+	if err := json.Unmarshal([]byte(data), entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// Set stores an entry in the cache with a TTL
+func (rs *RedisStore) Set(ctx context.Context, key string, entry *Entry, ttl time.Duration) error {
+	if ttl == 0 {
+		ttl = 5 * time.Minute // default TTL
+	}
+
+	entry.ExpiresAt = time.Now().Add(ttl)
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	commands := [][]any{
+		{"SET", rs.entryKey(key), data, "PX", ttl.Milliseconds()},
+	}
+	for _, tag := range entry.Tags {
+		commands = append(commands, []any{"SADD", rs.tagKey(tag), key})
+	}
+	return rs.pipeline(ctx, commands)
+}
+
+// Delete removes an entry from the cache
+func (rs *RedisStore) Delete(ctx context.Context, key string) error {
+	_, err := rs.client.Do(ctx, "DEL", rs.entryKey(key))
+	return err
+}
+
+// DeleteTags removes all entries tagged with any of the tags
+func (rs *RedisStore) DeleteTags(ctx context.Context, tags ...string) error {
+	for _, tag := range tags {
+		keys, err := redis.Strings(rs.client.Do(ctx, "SMEMBERS", rs.tagKey(tag)))
+		if err != nil {
+			return err
+		}
+
+		args := []any{"DEL", rs.tagKey(tag)}
+		for _, key := range keys {
+			args = append(args, rs.entryKey(key))
+		}
+		if _, err := rs.client.Do(ctx, args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Clear removes all entries from the cache
+func (rs *RedisStore) Clear(ctx context.Context) error {
+	for _, pattern := range []string{rs.entryKey("*"), rs.tagKey("*")} {
+		err := rs.client.Scan(ctx, pattern, func(keys []string) error {
+			args := []any{"DEL"}
+			for _, key := range keys {
+				args = append(args, key)
+			}
+			_, err := rs.client.Do(ctx, args...)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CleanupExpired removes expired keys from the tag sets. The
+// entries themselves are expired by the server.
+func (rs *RedisStore) CleanupExpired(ctx context.Context) error {
+	return rs.client.Scan(ctx, rs.tagKey("*"), func(tagKeys []string) error {
+		for _, tagKey := range tagKeys {
+			keys, err := redis.Strings(rs.client.Do(ctx, "SMEMBERS", tagKey))
+			if err != nil {
+				return err
+			}
+
+			commands := make([][]any, 0, len(keys))
+			for _, key := range keys {
+				commands = append(commands, []any{"EXISTS", rs.entryKey(key)})
+			}
+			if len(commands) == 0 {
+				continue
+			}
+			replies, err := rs.client.Pipeline(ctx, commands...)
+			if err != nil {
+				return err
+			}
+
+			args := []any{"SREM", tagKey}
+			for i, reply := range replies {
+				if reply == int64(0) {
+					args = append(args, keys[i])
+				}
+			}
+			if len(args) > 2 {
+				if _, err := rs.client.Do(ctx, args...); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+func (rs *RedisStore) pipeline(ctx context.Context, commands [][]any) error {
+	replies, err := rs.client.Pipeline(ctx, commands...)
+	if err != nil {
+		return err
+	}
+	for _, reply := range replies {
+		if err, ok := reply.(redis.Error); ok {
+			return err
+		}
+	}
+	return nil
+}
+
+func (rs *RedisStore) entryKey(key string) string {
+	return rs.prefix + "entry:" + key
+}
+
+func (rs *RedisStore) tagKey(tag string) string {
+	return rs.prefix + "tag:" + tag
+}
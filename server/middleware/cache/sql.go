@@ -0,0 +1,216 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// DefaultSQLTable is the table name used by the SQL store.
+const DefaultSQLTable = "etl_cache"
+
+var tableNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// SQLStore keeps cache entries in a database table, so they are
+// shared between server instances and survive restarts. Tags are
+// kept in a second table, named after the first with a `_tags` suffix.
+type SQLStore struct {
+	db    *sqlx.DB
+	table string
+	tags  string
+}
+
+// NewSQLStore creates a SQL cache store, creating the tables if needed.
+func NewSQLStore(ctx context.Context, db *sqlx.DB, table string) (*SQLStore, error) {
+	if table == "" {
+		table = DefaultSQLTable
+	}
+	if !tableNameRe.MatchString(table) {
+		return nil, fmt.Errorf("invalid cache table name %q", table)
+	}
+
+	ms := &SQLStore{
+		db:    db,
+		table: table,
+		tags:  table + "_tags",
+	}
+
+	text := "TEXT"
+	if db.DriverName() == "mysql" {
+		text = "LONGTEXT"
+	}
+
+	queries := []string{
+		fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (cache_key VARCHAR(255) NOT NULL PRIMARY KEY, entry %s NOT NULL, expires_at BIGINT NOT NULL)", ms.table, text),
+		fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (tag VARCHAR(255) NOT NULL, cache_key VARCHAR(255) NOT NULL, PRIMARY KEY (tag, cache_key))", ms.tags),
+	}
+	for _, query := range queries {
+		if _, err := db.ExecContext(ctx, query); err != nil {
+			return nil, fmt.Errorf("error creating cache table: %w", err)
+		}
+	}
+	return ms, nil
+}
+
+// Get retrieves a cached entry if it exists and hasn't expired
+func (ms *SQLStore) Get(ctx context.Context, key string) (*Entry, error) {
+	var data string
+	query := ms.db.Rebind(fmt.Sprintf("SELECT entry FROM %s WHERE cache_key = ? AND expires_at > ?", ms.table))
+	err := ms.db.GetContext(ctx, &data, query, sqlKey(key), time.Now().UnixMilli())
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &Entry{}
+	if err := json.Unmarshal([]byte(data), entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// Set stores an entry in the cache with a TTL
+func (ms *SQLStore) Set(ctx context.Context, key string, entry *Entry, ttl time.Duration) error {
+	if ttl == 0 {
+		ttl = 5 * time.Minute // default TTL
+	}
+
+	entry.ExpiresAt = time.Now().Add(ttl)
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	return ms.tx(ctx, func(tx *sqlx.Tx) error {
+		if err := ms.delete(ctx, tx, []string{sqlKey(key)}); err != nil {
+			return err
+		}
+
+		query := tx.Rebind(fmt.Sprintf("INSERT INTO %s (cache_key, entry, expires_at) VALUES (?, ?, ?)", ms.table))
+		if _, err := tx.ExecContext(ctx, query, sqlKey(key), string(data), entry.ExpiresAt.UnixMilli()); err != nil {
+			return err
+		}
+
+		query = tx.Rebind(fmt.Sprintf("INSERT INTO %s (tag, cache_key) VALUES (?, ?)", ms.tags))
+		seen := map[string]bool{}
+		for _, tag := range entry.Tags {
+			if seen[tag] {
+				continue
+			}
+			seen[tag] = true
+			if _, err := tx.ExecContext(ctx, query, sqlKey(tag), sqlKey(key)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Delete removes an entry from the cache
+func (ms *SQLStore) Delete(ctx context.Context, key string) error {
+	return ms.tx(ctx, func(tx *sqlx.Tx) error {
+		return ms.delete(ctx, tx, []string{sqlKey(key)})
+	})
+}
+
+// DeleteTags removes all entries tagged with any of the tags
+func (ms *SQLStore) DeleteTags(ctx context.Context, tags ...string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	hashed := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		hashed = append(hashed, sqlKey(tag))
+	}
+
+	return ms.tx(ctx, func(tx *sqlx.Tx) error {
+		query, args, err := sqlx.In(fmt.Sprintf("SELECT DISTINCT cache_key FROM %s WHERE tag IN (?)", ms.tags), hashed)
+		if err != nil {
+			return err
+		}
+
+		var keys []string
+		if err := tx.SelectContext(ctx, &keys, tx.Rebind(query), args...); err != nil {
+			return err
+		}
+		return ms.delete(ctx, tx, keys)
+	})
+}
+
+// Clear removes all entries from the cache
+func (ms *SQLStore) Clear(ctx context.Context) error {
+	return ms.tx(ctx, func(tx *sqlx.Tx) error {
+		for _, table := range []string{ms.tags, ms.table} {
+			if _, err := tx.ExecContext(ctx, "DELETE FROM "+table); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// CleanupExpired removes all expired entries from the cache
+func (ms *SQLStore) CleanupExpired(ctx context.Context) error {
+	now := time.Now().UnixMilli()
+	return ms.tx(ctx, func(tx *sqlx.Tx) error {
+		queries := []string{
+			fmt.Sprintf("DELETE FROM %s WHERE cache_key IN (SELECT cache_key FROM %s WHERE expires_at <= ?)", ms.tags, ms.table),
+			fmt.Sprintf("DELETE FROM %s WHERE expires_at <= ?", ms.table),
+		}
+		for _, query := range queries {
+			if _, err := tx.ExecContext(ctx, tx.Rebind(query), now); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// delete removes entries and their tags by (hashed) key
+func (ms *SQLStore) delete(ctx context.Context, tx *sqlx.Tx, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	for _, table := range []string{ms.tags, ms.table} {
+		query, args, err := sqlx.In(fmt.Sprintf("DELETE FROM %s WHERE cache_key IN (?)", table), keys)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, tx.Rebind(query), args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ms *SQLStore) tx(ctx context.Context, fn func(tx *sqlx.Tx) error) error {
+	tx, err := ms.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// sqlKey shortens keys which don't fit the key column.
+func sqlKey(key string) string {
+	if len(key) <= 255 {
+		return key
+	}
+	sum := sha256.Sum256([]byte(key))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
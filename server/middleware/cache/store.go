@@ -12,6 +12,11 @@ type Entry struct {
 	Headers    map[string][]string
 	Body       []byte
 	ExpiresAt  time.Time
+	// FreshUntil is when the entry becomes stale. Stale entries are
+	// kept until ExpiresAt, to be served while revalidating or on error.
+	FreshUntil time.Time
+	// Tags group entries for invalidation, see Store.DeleteTags
+	Tags []string
 }
 
 // Store defines the interface for cache storage backends
@@ -25,20 +30,31 @@ type Store interface {
 	// Delete removes an entry from the cache
 	Delete(ctx context.Context, key string) error
 
+	// DeleteTags removes all entries tagged with any of the tags
+	DeleteTags(ctx context.Context, tags ...string) error
+
 	// Clear removes all entries from the cache
 	Clear(ctx context.Context) error
 }
 
-// MemoryStore is a simple in-memory cache implementation using map+mutex
+// fresh reports if the entry can be served without revalidation.
+func (e *Entry) fresh(now time.Time) bool {
+	return e.FreshUntil.IsZero() || now.Before(e.FreshUntil)
+}
+
+// MemoryStore is a simple in-memory cache implementation using map+mutex.
+// It is not bounded, see LRUStore for a store with size limits.
 type MemoryStore struct {
 	mu    sync.RWMutex
 	store map[string]*Entry
+	tags  map[string]map[string]struct{}
 }
 
 // NewMemoryStore creates a new in-memory cache store
 func NewMemoryStore() *MemoryStore {
 	return &MemoryStore{
 		store: make(map[string]*Entry),
+		tags:  make(map[string]map[string]struct{}),
 	}
 }
 
@@ -81,8 +97,16 @@ func (ms *MemoryStore) Set(ctx context.Context, key string, entry *Entry, ttl ti
 	ms.mu.Lock()
 	defer ms.mu.Unlock()
 
+	ms.delete(key)
+
 	entry.ExpiresAt = time.Now().Add(ttl)
 	ms.store[key] = entry
+	for _, tag := range entry.Tags {
+		if ms.tags[tag] == nil {
+			ms.tags[tag] = make(map[string]struct{})
+		}
+		ms.tags[tag][key] = struct{}{}
+	}
 
 	return nil
 }
@@ -98,10 +122,44 @@ func (ms *MemoryStore) Delete(ctx context.Context, key string) error {
 	ms.mu.Lock()
 	defer ms.mu.Unlock()
 
-	delete(ms.store, key)
+	ms.delete(key)
 	return nil
 }
 
+// DeleteTags removes all entries tagged with any of the tags
+func (ms *MemoryStore) DeleteTags(ctx context.Context, tags ...string) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	for _, tag := range tags {
+		for key := range ms.tags[tag] {
+			ms.delete(key)
+		}
+	}
+	return nil
+}
+
+// delete removes an entry and its tag references, the caller holds the lock
+func (ms *MemoryStore) delete(key string) {
+	entry, exists := ms.store[key]
+	if !exists {
+		return
+	}
+	for _, tag := range entry.Tags {
+		delete(ms.tags[tag], key)
+		if len(ms.tags[tag]) == 0 {
+			delete(ms.tags, tag)
+		}
+	}
+	delete(ms.store, key)
+}
+
 // Clear removes all entries from the cache
 func (ms *MemoryStore) Clear(ctx context.Context) error {
 	select {
@@ -114,6 +172,7 @@ func (ms *MemoryStore) Clear(ctx context.Context) error {
 	defer ms.mu.Unlock()
 
 	ms.store = make(map[string]*Entry)
+	ms.tags = make(map[string]map[string]struct{})
 	return nil
 }
 
@@ -132,7 +191,7 @@ func (ms *MemoryStore) CleanupExpired(ctx context.Context) error {
 	now := time.Now()
 	for key, entry := range ms.store {
 		if now.After(entry.ExpiresAt) {
-			delete(ms.store, key)
+			ms.delete(key)
 		}
 	}
 
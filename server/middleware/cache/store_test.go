@@ -206,3 +206,44 @@ func TestMemoryStoreOverwrite(t *testing.T) {
 	require.Equal(t, http.StatusNotFound, retrieved.StatusCode)
 	require.Equal(t, []byte("test2"), retrieved.Body)
 }
+
+// TestMemoryStoreDeleteTags verifies that DeleteTags removes the tagged entries only.
+func TestMemoryStoreDeleteTags(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.Background()
+
+	store.Set(ctx, "user1", &Entry{Body: []byte("1"), Tags: []string{"user:1", "table:users"}}, time.Minute)
+	store.Set(ctx, "user2", &Entry{Body: []byte("2"), Tags: []string{"user:2", "table:users"}}, time.Minute)
+	store.Set(ctx, "orders", &Entry{Body: []byte("3"), Tags: []string{"table:orders"}}, time.Minute)
+
+	require.NoError(t, store.DeleteTags(ctx, "user:1"))
+	entry, _ := store.Get(ctx, "user1")
+	require.Nil(t, entry)
+	entry, _ = store.Get(ctx, "user2")
+	require.NotNil(t, entry)
+
+	require.NoError(t, store.DeleteTags(ctx, "table:users", "unknown"))
+	entry, _ = store.Get(ctx, "user2")
+	require.Nil(t, entry)
+	entry, _ = store.Get(ctx, "orders")
+	require.NotNil(t, entry)
+
+	require.Len(t, store.tags, 1)
+}
+
+// TestMemoryStoreOverwriteTags verifies that overwriting an entry replaces its tags.
+func TestMemoryStoreOverwriteTags(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.Background()
+
+	store.Set(ctx, "key1", &Entry{Tags: []string{"old"}}, time.Minute)
+	store.Set(ctx, "key1", &Entry{Tags: []string{"new"}}, time.Minute)
+
+	require.NoError(t, store.DeleteTags(ctx, "old"))
+	entry, _ := store.Get(ctx, "key1")
+	require.NotNil(t, entry)
+
+	require.NoError(t, store.DeleteTags(ctx, "new"))
+	entry, _ = store.Get(ctx, "key1")
+	require.Nil(t, entry)
+}
@@ -0,0 +1,170 @@
+package cache_test
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+
+	"github.com/titpetric/etl/server/internal/redis"
+	"github.com/titpetric/etl/server/middleware/cache"
+
+	_ "modernc.org/sqlite"
+)
+
+// testStores returns a constructor for each store implementation.
+func testStores() map[string]func(t *testing.T) cache.Store {
+	return map[string]func(t *testing.T) cache.Store{
+		"memory": func(t *testing.T) cache.Store {
+			return cache.NewMemoryStore()
+		},
+		"lru": func(t *testing.T) cache.Store {
+			return cache.NewLRUStore(100, 1<<20)
+		},
+		"sql": func(t *testing.T) cache.Store {
+			db, err := sqlx.Open("sqlite", filepath.Join(t.TempDir(), "cache.db"))
+			require.NoError(t, err)
+			t.Cleanup(func() { db.Close() })
+
+			store, err := cache.NewSQLStore(context.Background(), db, "")
+			require.NoError(t, err)
+			return store
+		},
+		"redis": func(t *testing.T) cache.Store {
+			fake, err := redis.NewFake()
+			require.NoError(t, err)
+			t.Cleanup(func() { fake.Close() })
+
+			client, err := redis.NewClient(fake.Addr())
+			require.NoError(t, err)
+			t.Cleanup(func() { client.Close() })
+
+			return cache.NewRedisStore(client, "")
+		},
+	}
+}
+
+// TestStores runs the same checks against each store implementation.
+func TestStores(t *testing.T) {
+	ctx := context.Background()
+
+	for name, newStore := range testStores() {
+		t.Run(name+"/GetSet", func(t *testing.T) {
+			store := newStore(t)
+
+			entry, err := store.Get(ctx, "missing")
+			require.NoError(t, err)
+			require.Nil(t, entry)
+
+			err = store.Set(ctx, "key", &cache.Entry{
+				StatusCode: http.StatusOK,
+				Headers:    http.Header{"Content-Type": {"application/json"}},
+				Body:       []byte("{\"ok\":true}\x00"),
+			}, time.Minute)
+			require.NoError(t, err)
+
+			entry, err = store.Get(ctx, "key")
+			require.NoError(t, err)
+			require.NotNil(t, entry)
+			require.Equal(t, http.StatusOK, entry.StatusCode)
+			require.Equal(t, "application/json", http.Header(entry.Headers).Get("Content-Type"))
+			require.Equal(t, []byte("{\"ok\":true}\x00"), entry.Body)
+		})
+
+		t.Run(name+"/Expire", func(t *testing.T) {
+			store := newStore(t)
+
+			require.NoError(t, store.Set(ctx, "key", &cache.Entry{Body: []byte("x")}, 20*time.Millisecond))
+			time.Sleep(30 * time.Millisecond)
+
+			entry, err := store.Get(ctx, "key")
+			require.NoError(t, err)
+			require.Nil(t, entry)
+		})
+
+		t.Run(name+"/DeleteTags", func(t *testing.T) {
+			store := newStore(t)
+
+			require.NoError(t, store.Set(ctx, "user1", &cache.Entry{Tags: []string{"user:1", "table:users"}}, time.Minute))
+			require.NoError(t, store.Set(ctx, "user2", &cache.Entry{Tags: []string{"user:2", "table:users"}}, time.Minute))
+			require.NoError(t, store.Set(ctx, "orders", &cache.Entry{Tags: []string{"table:orders"}}, time.Minute))
+
+			require.NoError(t, store.DeleteTags(ctx, "user:1"))
+			entry, _ := store.Get(ctx, "user1")
+			require.Nil(t, entry)
+			entry, _ = store.Get(ctx, "user2")
+			require.NotNil(t, entry)
+
+			require.NoError(t, store.DeleteTags(ctx, "table:users"))
+			entry, _ = store.Get(ctx, "user2")
+			require.Nil(t, entry)
+			entry, _ = store.Get(ctx, "orders")
+			require.NotNil(t, entry)
+		})
+
+		t.Run(name+"/DeleteClear", func(t *testing.T) {
+			store := newStore(t)
+
+			require.NoError(t, store.Set(ctx, "a", &cache.Entry{}, time.Minute))
+			require.NoError(t, store.Set(ctx, "b", &cache.Entry{Tags: []string{"t"}}, time.Minute))
+			require.NoError(t, store.Set(ctx, "c", &cache.Entry{}, time.Minute))
+
+			require.NoError(t, store.Delete(ctx, "a"))
+			entry, _ := store.Get(ctx, "a")
+			require.Nil(t, entry)
+			entry, _ = store.Get(ctx, "b")
+			require.NotNil(t, entry)
+
+			require.NoError(t, store.Clear(ctx))
+			for _, key := range []string{"b", "c"} {
+				entry, _ := store.Get(ctx, key)
+				require.Nil(t, entry)
+			}
+		})
+
+		t.Run(name+"/CleanupExpired", func(t *testing.T) {
+			store := newStore(t)
+			cleaner, ok := store.(interface {
+				CleanupExpired(ctx context.Context) error
+			})
+			require.True(t, ok)
+
+			require.NoError(t, store.Set(ctx, "short", &cache.Entry{Tags: []string{"t"}}, 10*time.Millisecond))
+			require.NoError(t, store.Set(ctx, "long", &cache.Entry{Tags: []string{"t"}}, time.Minute))
+			time.Sleep(20 * time.Millisecond)
+
+			require.NoError(t, cleaner.CleanupExpired(ctx))
+			entry, _ := store.Get(ctx, "long")
+			require.NotNil(t, entry)
+
+			require.NoError(t, store.DeleteTags(ctx, "t"))
+			entry, _ = store.Get(ctx, "long")
+			require.Nil(t, entry)
+		})
+	}
+}
+
+// TestRedisStoreCleanupExpired verifies that expired keys are removed from tag sets.
+func TestRedisStoreCleanupExpired(t *testing.T) {
+	ctx := context.Background()
+
+	fake, err := redis.NewFake()
+	require.NoError(t, err)
+	defer fake.Close()
+
+	client, err := redis.NewClient(fake.Addr())
+	require.NoError(t, err)
+	defer client.Close()
+
+	store := cache.NewRedisStore(client, "test:")
+	require.NoError(t, store.Set(ctx, "short", &cache.Entry{Tags: []string{"t"}}, 10*time.Millisecond))
+	require.Equal(t, []string{"test:entry:short", "test:tag:t"}, fake.Keys())
+
+	time.Sleep(20 * time.Millisecond)
+	require.NoError(t, store.CleanupExpired(ctx))
+	require.Empty(t, fake.Keys())
+}
@@ -1,11 +1,18 @@
 package ratelimit
 
 import (
+	"crypto/hmac"
 	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
+	"net/netip"
 	"sort"
 	"strings"
+	"time"
 )
 
 // KeyBuilder defines the interface for rate limit key generation
@@ -22,6 +29,8 @@ type DefaultKeyBuilder struct {
 	IncludeHeaders []string
 	// IncludeQuery specifies which query parameters to include in the key
 	IncludeQuery []string
+	// TrustedProxies lists the proxies allowed to set X-Forwarded-For and X-Real-IP
+	TrustedProxies []netip.Prefix
 }
 
 // NewDefaultKeyBuilder creates a new default key builder
@@ -49,6 +58,33 @@ func (kb *DefaultKeyBuilder) WithQuery(params ...string) *DefaultKeyBuilder {
 	return kb
 }
 
+// WithTrustedProxies sets the proxies which may forward the client IP address
+func (kb *DefaultKeyBuilder) WithTrustedProxies(proxies ...netip.Prefix) *DefaultKeyBuilder {
+	kb.TrustedProxies = proxies
+	return kb
+}
+
+// ParseTrustedProxies parses a list of CIDR ranges or IP addresses
+func ParseTrustedProxies(proxies []string) ([]netip.Prefix, error) {
+	result := make([]netip.Prefix, 0, len(proxies))
+	for _, proxy := range proxies {
+		if !strings.Contains(proxy, "/") {
+			addr, err := netip.ParseAddr(proxy)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: %w", proxy, err)
+			}
+			result = append(result, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", proxy, err)
+		}
+		result = append(result, prefix.Masked())
+	}
+	return result, nil
+}
+
 // BuildKey creates a rate limit key from the request
 // By default, it uses the client IP address
 func (kb *DefaultKeyBuilder) BuildKey(r *http.Request) string {
@@ -82,29 +118,55 @@ func (kb *DefaultKeyBuilder) BuildKey(r *http.Request) string {
 	return fmt.Sprintf("ratelimit:%x", hash)
 }
 
-// getClientIP extracts the client IP address from the request
+// getClientIP extracts the client IP address from the request.
+// X-Forwarded-For and X-Real-IP are only read from trusted proxies,
+// since clients could otherwise pick a new address for every request.
 func (kb *DefaultKeyBuilder) getClientIP(r *http.Request) string {
-	// Check X-Forwarded-For header (proxy)
+	remote := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		remote = host
+	}
+	if remote == "" {
+		return "unknown"
+	}
+	if !kb.trusted(remote) {
+		return remote
+	}
+
+	// Proxies append the address they received the request from, so
+	// the right-most address which isn't a trusted proxy is the client.
 	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
 		ips := strings.Split(xff, ",")
-		return strings.TrimSpace(ips[0])
+		for i := len(ips) - 1; i >= 0; i-- {
+			if ip := strings.TrimSpace(ips[i]); ip != "" && !kb.trusted(ip) {
+				return ip
+			}
+		}
+		if ip := strings.TrimSpace(ips[0]); ip != "" {
+			return ip
+		}
 	}
 
-	// Check X-Real-IP header (proxy)
-	if xri := r.Header.Get("X-Real-IP"); xri != "" {
-		return strings.TrimSpace(xri)
+	if xri := strings.TrimSpace(r.Header.Get("X-Real-IP")); xri != "" {
+		return xri
 	}
 
-	// Use RemoteAddr
-	if r.RemoteAddr != "" {
-		// Remove port if present
-		if idx := strings.LastIndex(r.RemoteAddr, ":"); idx != -1 {
-			return r.RemoteAddr[:idx]
+	return remote
+}
+
+// trusted returns true if the address belongs to a trusted proxy.
+func (kb *DefaultKeyBuilder) trusted(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, proxy := range kb.TrustedProxies {
+		if proxy.Contains(addr) {
+			return true
 		}
-		return r.RemoteAddr
 	}
-
-	return "unknown"
+	return false
 }
 
 // extractHeaders extracts specified headers from the request
@@ -166,3 +228,140 @@ func (ckb *CustomKeyBuilder) BuildKey(r *http.Request) string {
 func NewCustomKeyBuilder(fn func(r *http.Request) string) *CustomKeyBuilder {
 	return &CustomKeyBuilder{BuildFunc: fn}
 }
+
+// Key sources supported by SourceKeyBuilder
+const (
+	// KeySourceIP keys requests by client IP address
+	KeySourceIP = "ip"
+	// KeySourceHeader keys requests by the value of a request header
+	KeySourceHeader = "header"
+	// KeySourceSubject keys requests by the subject of a verified JWT
+	KeySourceSubject = "subject"
+	// KeySourceGlobal uses a single key for all requests
+	KeySourceGlobal = "global"
+)
+
+// KeySources lists the key sources supported by SourceKeyBuilder
+var KeySources = []string{KeySourceIP, KeySourceHeader, KeySourceSubject, KeySourceGlobal}
+
+// SourceKeyBuilder creates rate limit keys from a single client identifier.
+// Requests without the identifier (e.g. a missing header) fall back to the
+// client IP address, so that they don't share one bucket.
+type SourceKeyBuilder struct {
+	// Pattern scopes the keys, usually to an endpoint
+	Pattern string
+	// Source is one of the KeySource values
+	Source string
+	// Header is the header name for KeySourceHeader
+	Header string
+	// Secret verifies the JWT bearer tokens for KeySourceSubject
+	Secret []byte
+
+	ip DefaultKeyBuilder
+}
+
+// NewSourceKeyBuilder creates a new key builder for a key source. The
+// client IP address is only taken from forwarding headers set by the
+// trusted proxies, given as CIDR ranges or addresses.
+func NewSourceKeyBuilder(pattern, source, header, secret string, trustedProxies []string) (*SourceKeyBuilder, error) {
+	switch source {
+	case "":
+		source = KeySourceIP
+	case KeySourceIP, KeySourceGlobal:
+	case KeySourceHeader:
+		if header == "" {
+			return nil, fmt.Errorf("rate limit key source %q requires a header name", source)
+		}
+	case KeySourceSubject:
+		if secret == "" {
+			return nil, fmt.Errorf("rate limit key source %q requires a secret to verify tokens", source)
+		}
+	default:
+		return nil, fmt.Errorf("unknown rate limit key source %q, supported %v", source, KeySources)
+	}
+	proxies, err := ParseTrustedProxies(trustedProxies)
+	if err != nil {
+		return nil, err
+	}
+	return &SourceKeyBuilder{
+		Pattern: pattern,
+		Source:  source,
+		Header:  header,
+		Secret:  []byte(secret),
+		ip:      DefaultKeyBuilder{TrustedProxies: proxies},
+	}, nil
+}
+
+// BuildKey creates a rate limit key from the request
+func (kb *SourceKeyBuilder) BuildKey(r *http.Request) string {
+	var client string
+	switch kb.Source {
+	case KeySourceGlobal:
+		client = "global"
+	case KeySourceHeader:
+		if value := r.Header.Get(kb.Header); value != "" {
+			client = "h:" + value
+		}
+	case KeySourceSubject:
+		if subject := AuthSubject(r, kb.Secret); subject != "" {
+			client = "s:" + subject
+		}
+	}
+	if client == "" {
+		client = "ip:" + kb.ip.getClientIP(r)
+	}
+
+	hash := md5.Sum([]byte(kb.Pattern + ":" + client))
+	return fmt.Sprintf("ratelimit:%x", hash)
+}
+
+// AuthSubject returns the `sub` claim of a JWT bearer token signed
+// with HS256 and the secret. Tokens which don't verify or are expired
+// return an empty subject, as do other credentials, since clients
+// could pick them freely.
+func AuthSubject(r *http.Request, secret []byte) string {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || len(secret) == 0 {
+		return ""
+	}
+
+	parts := strings.Split(strings.TrimSpace(token), ".")
+	if len(parts) != 3 {
+		return ""
+	}
+
+	var header struct {
+		Algorithm string `json:"alg"`
+	}
+	if !decodeSegment(parts[0], &header) || header.Algorithm != "HS256" {
+		return ""
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return ""
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return ""
+	}
+
+	var claims struct {
+		Subject string `json:"sub"`
+		Expires int64  `json:"exp"`
+	}
+	if !decodeSegment(parts[1], &claims) {
+		return ""
+	}
+	if claims.Expires != 0 && time.Now().Unix() >= claims.Expires {
+		return ""
+	}
+	return claims.Subject
+}
+
+// decodeSegment decodes a base64 encoded JSON segment of a JWT.
+func decodeSegment(segment string, dest any) bool {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	return err == nil && json.Unmarshal(data, dest) == nil
+}
@@ -1,10 +1,16 @@
 package ratelimit
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/netip"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -88,9 +94,9 @@ func TestDefaultKeyBuilderGetClientIPFromRemoteAddr(t *testing.T) {
 	require.True(t, strings.HasPrefix(key, "ratelimit:"))
 }
 
-// TestDefaultKeyBuilderGetClientIPFromXForwardedFor verifies that X-Forwarded-For header is used.
+// TestDefaultKeyBuilderGetClientIPFromXForwardedFor verifies that X-Forwarded-For header is used from trusted proxies.
 func TestDefaultKeyBuilderGetClientIPFromXForwardedFor(t *testing.T) {
-	kb := NewDefaultKeyBuilder()
+	kb := NewDefaultKeyBuilder().WithTrustedProxies(netip.MustParsePrefix("127.0.0.0/8"))
 	req := httptest.NewRequest("GET", "http://example.com/path", nil)
 	req.RemoteAddr = "127.0.0.1:12345"
 	req.Header.Set("X-Forwarded-For", "203.0.113.1, 198.51.100.2")
@@ -99,16 +105,16 @@ func TestDefaultKeyBuilderGetClientIPFromXForwardedFor(t *testing.T) {
 
 	req2 := httptest.NewRequest("GET", "http://example.com/path", nil)
 	req2.RemoteAddr = "127.0.0.1:12345"
-	req2.Header.Set("X-Forwarded-For", "203.0.113.2, 198.51.100.2")
+	req2.Header.Set("X-Forwarded-For", "203.0.113.1, 198.51.100.3")
 
 	key2 := kb.BuildKey(req2)
 
 	require.NotEqual(t, key1, key2)
 }
 
-// TestDefaultKeyBuilderGetClientIPFromXRealIP verifies that X-Real-IP header is used.
+// TestDefaultKeyBuilderGetClientIPFromXRealIP verifies that X-Real-IP header is used from trusted proxies.
 func TestDefaultKeyBuilderGetClientIPFromXRealIP(t *testing.T) {
-	kb := NewDefaultKeyBuilder()
+	kb := NewDefaultKeyBuilder().WithTrustedProxies(netip.MustParsePrefix("127.0.0.1/32"))
 	req := httptest.NewRequest("GET", "http://example.com/path", nil)
 	req.RemoteAddr = "127.0.0.1:12345"
 	req.Header.Set("X-Real-IP", "203.0.113.100")
@@ -234,7 +240,7 @@ func TestDefaultKeyBuilderIPWithIPv6(t *testing.T) {
 
 // TestDefaultKeyBuilderXForwardedForPriority verifies that X-Forwarded-For takes priority over RemoteAddr.
 func TestDefaultKeyBuilderXForwardedForPriority(t *testing.T) {
-	kb := NewDefaultKeyBuilder()
+	kb := NewDefaultKeyBuilder().WithTrustedProxies(netip.MustParsePrefix("127.0.0.1/32"))
 
 	req1 := httptest.NewRequest("GET", "http://example.com/path", nil)
 	req1.RemoteAddr = "127.0.0.1:12345"
@@ -248,3 +254,191 @@ func TestDefaultKeyBuilderXForwardedForPriority(t *testing.T) {
 
 	require.Equal(t, key1, key2)
 }
+
+// TestDefaultKeyBuilderClientIP verifies which address identifies the client.
+func TestDefaultKeyBuilderClientIP(t *testing.T) {
+	proxies, err := ParseTrustedProxies([]string{"10.0.0.0/8", "192.168.1.1"})
+	require.NoError(t, err)
+	kb := NewDefaultKeyBuilder().WithTrustedProxies(proxies...)
+
+	tests := []struct {
+		name    string
+		remote  string
+		headers map[string]string
+		want    string
+	}{
+		{"remote addr", "203.0.113.1:1234", nil, "203.0.113.1"},
+		{"ipv6 remote addr", "[2001:db8::1]:1234", nil, "2001:db8::1"},
+		{"untrusted xff", "203.0.113.1:1234", map[string]string{"X-Forwarded-For": "198.51.100.1"}, "203.0.113.1"},
+		{"untrusted x-real-ip", "203.0.113.1:1234", map[string]string{"X-Real-IP": "198.51.100.1"}, "203.0.113.1"},
+		{"trusted xff", "10.0.0.1:1234", map[string]string{"X-Forwarded-For": "198.51.100.1"}, "198.51.100.1"},
+		{"spoofed xff", "10.0.0.1:1234", map[string]string{"X-Forwarded-For": "1.2.3.4, 198.51.100.1"}, "198.51.100.1"},
+		{"proxy chain", "10.0.0.1:1234", map[string]string{"X-Forwarded-For": "1.2.3.4, 198.51.100.1, 192.168.1.1, 10.0.0.2"}, "198.51.100.1"},
+		{"only proxies", "10.0.0.1:1234", map[string]string{"X-Forwarded-For": "10.0.0.3, 10.0.0.2"}, "10.0.0.3"},
+		{"trusted x-real-ip", "192.168.1.1:1234", map[string]string{"X-Real-IP": "198.51.100.1"}, "198.51.100.1"},
+		{"trusted without headers", "10.0.0.1:1234", nil, "10.0.0.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "http://example.com/path", nil)
+			req.RemoteAddr = tt.remote
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			require.Equal(t, tt.want, kb.getClientIP(req))
+		})
+	}
+
+	_, err = ParseTrustedProxies([]string{"10.0.0.0/33"})
+	require.Error(t, err)
+	_, err = ParseTrustedProxies([]string{"proxy"})
+	require.Error(t, err)
+}
+
+// TestSpoofedForwardedFor verifies that clients can't escape the limit with a new X-Forwarded-For.
+func TestSpoofedForwardedFor(t *testing.T) {
+	kb, err := NewSourceKeyBuilder("/path", KeySourceIP, "", "", []string{"10.0.0.0/8"})
+	require.NoError(t, err)
+
+	handler := NewMiddleware(NewMemoryStore(), kb, 2, 2).Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for _, remote := range []string{"203.0.113.1", "10.0.0.1"} {
+		t.Run(remote, func(t *testing.T) {
+			var codes []int
+			for i := range 3 {
+				req := httptest.NewRequest("GET", "http://example.com/path", nil)
+				req.RemoteAddr = remote + ":12345"
+				forwarded := fmt.Sprintf("198.51.100.%d", i)
+				if remote == "10.0.0.1" {
+					// The proxy appends the connecting client
+					forwarded += ", 203.0.113.2"
+				}
+				req.Header.Set("X-Forwarded-For", forwarded)
+
+				w := httptest.NewRecorder()
+				handler.ServeHTTP(w, req)
+				codes = append(codes, w.Code)
+			}
+			require.Equal(t, []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests}, codes)
+		})
+	}
+}
+
+// TestSourceKeyBuilder verifies the keys for each key source.
+func TestSourceKeyBuilder(t *testing.T) {
+	newRequest := func(ip string, headers map[string]string) *http.Request {
+		req := httptest.NewRequest("GET", "http://example.com/path", nil)
+		req.RemoteAddr = ip + ":12345"
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		return req
+	}
+
+	t.Run("ip", func(t *testing.T) {
+		kb, err := NewSourceKeyBuilder("/path", "", "", "", nil)
+		require.NoError(t, err)
+		require.Equal(t, KeySourceIP, kb.Source)
+		require.NotEqual(t, kb.BuildKey(newRequest("10.0.0.1", nil)), kb.BuildKey(newRequest("10.0.0.2", nil)))
+	})
+
+	t.Run("header", func(t *testing.T) {
+		kb, err := NewSourceKeyBuilder("/path", KeySourceHeader, "X-Api-Key", "", nil)
+		require.NoError(t, err)
+
+		a := kb.BuildKey(newRequest("10.0.0.1", map[string]string{"X-Api-Key": "a"}))
+		b := kb.BuildKey(newRequest("10.0.0.2", map[string]string{"X-Api-Key": "a"}))
+		c := kb.BuildKey(newRequest("10.0.0.1", map[string]string{"X-Api-Key": "c"}))
+		require.Equal(t, a, b)
+		require.NotEqual(t, a, c)
+
+		// Missing header falls back to the client IP
+		require.NotEqual(t, kb.BuildKey(newRequest("10.0.0.1", nil)), kb.BuildKey(newRequest("10.0.0.2", nil)))
+	})
+
+	t.Run("global", func(t *testing.T) {
+		kb, err := NewSourceKeyBuilder("/path", KeySourceGlobal, "", "", nil)
+		require.NoError(t, err)
+		require.Equal(t, kb.BuildKey(newRequest("10.0.0.1", nil)), kb.BuildKey(newRequest("10.0.0.2", nil)))
+	})
+
+	t.Run("pattern", func(t *testing.T) {
+		kb1, _ := NewSourceKeyBuilder("/a", KeySourceGlobal, "", "", nil)
+		kb2, _ := NewSourceKeyBuilder("/b", KeySourceGlobal, "", "", nil)
+		require.NotEqual(t, kb1.BuildKey(newRequest("10.0.0.1", nil)), kb2.BuildKey(newRequest("10.0.0.1", nil)))
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := NewSourceKeyBuilder("/path", "cookie", "", "", nil)
+		require.Error(t, err)
+		_, err = NewSourceKeyBuilder("/path", KeySourceHeader, "", "", nil)
+		require.Error(t, err)
+		_, err = NewSourceKeyBuilder("/path", KeySourceSubject, "", "", nil)
+		require.Error(t, err)
+		_, err = NewSourceKeyBuilder("/path", KeySourceIP, "", "", []string{"proxy"})
+		require.Error(t, err)
+	})
+
+	t.Run("subject", func(t *testing.T) {
+		kb, err := NewSourceKeyBuilder("/path", KeySourceSubject, "", "secret", nil)
+		require.NoError(t, err)
+
+		alice := map[string]string{"Authorization": "Bearer " + testToken(t, "secret", `{"sub":"alice"}`)}
+		forged := map[string]string{"Authorization": "Bearer " + testToken(t, "guess", `{"sub":"alice"}`)}
+
+		a := kb.BuildKey(newRequest("10.0.0.1", alice))
+		require.Equal(t, a, kb.BuildKey(newRequest("10.0.0.2", alice)))
+
+		// Forged tokens don't get alice's bucket, or a fresh one
+		require.NotEqual(t, a, kb.BuildKey(newRequest("10.0.0.1", forged)))
+		require.Equal(t, kb.BuildKey(newRequest("10.0.0.1", nil)), kb.BuildKey(newRequest("10.0.0.1", forged)))
+	})
+}
+
+// testToken returns a HS256 JWT with the claims, signed with secret.
+func testToken(t *testing.T, secret, claims string) string {
+	t.Helper()
+
+	payload := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`)) + "." + base64.RawURLEncoding.EncodeToString([]byte(claims))
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return payload + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// TestAuthSubject verifies the subject is only read from verified JWT tokens.
+func TestAuthSubject(t *testing.T) {
+	secret := []byte("secret")
+	expired := fmt.Sprintf(`{"sub":"user-42","exp":%d}`, time.Now().Add(-time.Minute).Unix())
+	valid := fmt.Sprintf(`{"sub":"user-42","exp":%d}`, time.Now().Add(time.Minute).Unix())
+
+	tests := []struct {
+		name          string
+		authorization string
+		want          string
+	}{
+		{"none", "", ""},
+		{"signed", "Bearer " + testToken(t, "secret", `{"sub":"user-42"}`), "user-42"},
+		{"not expired", "Bearer " + testToken(t, "secret", valid), "user-42"},
+		{"expired", "Bearer " + testToken(t, "secret", expired), ""},
+		{"wrong secret", "Bearer " + testToken(t, "other", `{"sub":"user-42"}`), ""},
+		// {"alg":"none"}.{"sub":"user-42"}.
+		{"unsigned", "Bearer eyJhbGciOiJub25lIn0.eyJzdWIiOiJ1c2VyLTQyIn0.", ""},
+		{"opaque", "Bearer opaque-token", ""},
+		{"basic", "Basic YWxpY2U6c2VjcmV0", ""},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "http://example.com/path", nil)
+		if tt.authorization != "" {
+			req.Header.Set("Authorization", tt.authorization)
+		}
+		require.Equal(t, tt.want, AuthSubject(req, secret), tt.name)
+	}
+
+	req := httptest.NewRequest("GET", "http://example.com/path", nil)
+	req.Header.Set("Authorization", "Bearer "+testToken(t, "", `{"sub":"user-42"}`))
+	require.Equal(t, "", AuthSubject(req, nil))
+}
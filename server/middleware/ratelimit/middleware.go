@@ -5,6 +5,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"math"
 	"net"
 	"net/http"
 	"time"
@@ -18,12 +19,17 @@ type Middleware struct {
 	// KeyBuilder generates rate limit keys from requests
 	KeyBuilder KeyBuilder
 
-	// RequestsPerSecond is the maximum number of requests allowed per second
+	// RequestsPerSecond is the nominal request rate, reported in the
+	// X-RateLimit headers. The limit enforced by the middleware is
+	// BurstSize requests for each Window.
 	RequestsPerSecond int64
 
-	// BurstSize is the maximum number of requests allowed in a burst
+	// BurstSize is the maximum number of requests allowed in a window
 	BurstSize int64
 
+	// Window is the duration of a rate limit window, defaults to a second
+	Window time.Duration
+
 	// Enabled indicates whether rate limiting is active
 	Enabled bool
 
@@ -42,6 +48,12 @@ func NewMiddleware(store Store, keyBuilder KeyBuilder, requestsPerSecond, burstS
 	}
 }
 
+// WithWindow sets the rate limit window
+func (m *Middleware) WithWindow(window time.Duration) *Middleware {
+	m.Window = window
+	return m
+}
+
 // WithLogger sets the logger
 func (m *Middleware) WithLogger(logger *log.Logger) *Middleware {
 	m.Logger = logger
@@ -66,9 +78,14 @@ func (m *Middleware) Wrap(next http.Handler) http.Handler {
 		// Generate rate limit key
 		key := m.KeyBuilder.BuildKey(r)
 
+		window := m.Window
+		if window <= 0 {
+			window = DefaultWindow
+		}
+
 		// Increment the counter
 		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
-		count, err := m.Store.Inc(ctx, key)
+		rl, err := m.Store.Hit(ctx, key, window)
 		cancel()
 
 		if err != nil {
@@ -82,15 +99,15 @@ func (m *Middleware) Wrap(next http.Handler) http.Handler {
 
 		// Set rate limit headers
 		w.Header().Set("X-RateLimit-Limit", fmt.Sprintf("%d", m.RequestsPerSecond))
-		w.Header().Set("X-RateLimit-Remaining", fmt.Sprintf("%d", max(0, m.RequestsPerSecond-count)))
-		w.Header().Set("X-RateLimit-Reset", fmt.Sprintf("%d", time.Now().Add(1*time.Second).Unix()))
+		w.Header().Set("X-RateLimit-Remaining", fmt.Sprintf("%d", max(0, m.RequestsPerSecond-rl.Count)))
+		w.Header().Set("X-RateLimit-Reset", fmt.Sprintf("%d", rl.ResetTime.Unix()))
 
 		// Check if we've exceeded the limit
-		if count > m.BurstSize {
+		if rl.Count > m.BurstSize {
 			if m.Logger != nil {
-				m.Logger.Printf("rate limit exceeded: %s (count=%d, limit=%d)", key, count, m.BurstSize)
+				m.Logger.Printf("rate limit exceeded: %s (count=%d, limit=%d)", key, rl.Count, m.BurstSize)
 			}
-			w.Header().Set("Retry-After", "1")
+			w.Header().Set("Retry-After", fmt.Sprintf("%d", retryAfter(rl.ResetTime)))
 			http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
 			return
 		}
@@ -121,6 +138,12 @@ type responseWrapper struct {
 	http.ResponseWriter
 }
 
+// retryAfter returns the seconds until reset, rounded up
+func retryAfter(reset time.Time) int64 {
+	seconds := int64(math.Ceil(time.Until(reset).Seconds()))
+	return max(1, seconds)
+}
+
 // max returns the larger of two integers
 func max(a, b int64) int64 {
 	if a > b {
@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/titpetric/etl/server/middleware/ratelimit"
 )
@@ -105,8 +107,8 @@ func TestRateLimitHeaders(t *testing.T) {
 		t.Errorf("Expected X-RateLimit-Limit: 10, got %s", w.Header().Get("X-RateLimit-Limit"))
 	}
 
-	if remaining := w.Header().Get("X-RateLimit-Remaining"); remaining == "" {
-		t.Error("Expected X-RateLimit-Remaining header")
+	if remaining := w.Header().Get("X-RateLimit-Remaining"); remaining != "9" {
+		t.Errorf("Expected X-RateLimit-Remaining: 9, got %s", remaining)
 	}
 
 	if reset := w.Header().Get("X-RateLimit-Reset"); reset == "" {
@@ -331,3 +333,39 @@ func TestRateLimitContextCancellation(t *testing.T) {
 		t.Error("Expected error for cancelled context")
 	}
 }
+
+func TestRateLimitWindow(t *testing.T) {
+	store := ratelimit.NewMemoryStore()
+	keyBuilder := ratelimit.NewDefaultKeyBuilder()
+	rateLimitMiddleware := ratelimit.NewMiddleware(store, keyBuilder, 2, 2).
+		WithWindow(time.Minute)
+
+	handler := rateLimitMiddleware.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	var w *httptest.ResponseRecorder
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest("GET", "/api/data", nil)
+		req.RemoteAddr = "127.0.0.1:12345"
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+	}
+
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status 429, got %d", w.Code)
+	}
+	if remaining := w.Header().Get("X-RateLimit-Remaining"); remaining != "0" {
+		t.Errorf("Expected X-RateLimit-Remaining: 0, got %s", remaining)
+	}
+
+	retryAfter, err := strconv.Atoi(w.Header().Get("Retry-After"))
+	if err != nil || retryAfter < 59 || retryAfter > 60 {
+		t.Errorf("Expected Retry-After close to 60, got %q", w.Header().Get("Retry-After"))
+	}
+
+	reset, err := strconv.ParseInt(w.Header().Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil || reset < time.Now().Add(59*time.Second).Unix() {
+		t.Errorf("Expected X-RateLimit-Reset a minute ahead, got %q", w.Header().Get("X-RateLimit-Reset"))
+	}
+}
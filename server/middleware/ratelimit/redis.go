@@ -0,0 +1,121 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/titpetric/etl/server/internal/redis"
+)
+
+// DefaultRedisPrefix is prepended to the keys of the Redis stores.
+const DefaultRedisPrefix = "etl:"
+
+// RedisStore keeps rate limit counters in a Redis protocol server, so
+// that limits are shared between server instances. Counters expire on
+// the server at the end of the window.
+type RedisStore struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisStore creates a rate limit store using a Redis client.
+func NewRedisStore(client *redis.Client, prefix string) *RedisStore {
+	if prefix == "" {
+		prefix = DefaultRedisPrefix
+	}
+	return &RedisStore{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+// Inc increments the counter for a key and returns the new count
+func (rs *RedisStore) Inc(ctx context.Context, key string) (int64, error) {
+	rl, err := rs.Hit(ctx, key, DefaultWindow)
+	return rl.Count, err
+}
+
+// Hit increments the counter for a key within a fixed window
+func (rs *RedisStore) Hit(ctx context.Context, key string, window time.Duration) (RateLimit, error) {
+	key = rs.key(key)
+	ms := max(1, window.Milliseconds())
+
+	// Create the counter with an expiry, increment it and read the expiry
+	replies, err := rs.client.Pipeline(ctx,
+		[]any{"SET", key, "0", "PX", ms, "NX"},
+		[]any{"INCR", key},
+		[]any{"PTTL", key},
+	)
+	if err != nil {
+		return RateLimit{}, err
+	}
+
+	count, err := redis.Int(replies[1], nil)
+	if err != nil {
+		return RateLimit{}, err
+	}
+	ttl, err := redis.Int(replies[2], nil)
+	if err != nil {
+		return RateLimit{}, err
+	}
+
+	// The counter expired between SET and INCR, and has no expiry
+	if ttl < 0 {
+		if _, err := rs.client.Do(ctx, "PEXPIRE", key, ms); err != nil {
+			return RateLimit{}, err
+		}
+		ttl = ms
+	}
+
+	return RateLimit{
+		Count:     count,
+		ResetTime: time.Now().Add(time.Duration(ttl) * time.Millisecond),
+	}, nil
+}
+
+// Rate returns the current count for a key without incrementing
+func (rs *RedisStore) Rate(ctx context.Context, key string) (int64, error) {
+	value, err := redis.String(rs.client.Do(ctx, "GET", rs.key(key)))
+	if errors.Is(err, redis.ErrNil) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	count, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid rate limit counter %q", value)
+	}
+	return count, nil
+}
+
+// Reset resets the counter for a key
+func (rs *RedisStore) Reset(ctx context.Context, key string) error {
+	_, err := rs.client.Do(ctx, "DEL", rs.key(key))
+	return err
+}
+
+// Clear removes all rate limit entries
+func (rs *RedisStore) Clear(ctx context.Context) error {
+	return rs.client.Scan(ctx, rs.key("*"), func(keys []string) error {
+		args := []any{"DEL"}
+		for _, key := range keys {
+			args = append(args, key)
+		}
+		_, err := rs.client.Do(ctx, args...)
+		return err
+	})
+}
+
+// CleanupExpired is a no-op, counters are expired by the server
+func (rs *RedisStore) CleanupExpired(ctx context.Context) error {
+	return nil
+}
+
+func (rs *RedisStore) key(key string) string {
+	return rs.prefix + "rl:" + key
+}
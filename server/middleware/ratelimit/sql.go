@@ -0,0 +1,151 @@
+package ratelimit
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// DefaultSQLTable is the table name used by the SQL store.
+const DefaultSQLTable = "etl_ratelimit"
+
+// sqlRetries bounds retries when concurrent requests create the same key.
+const sqlRetries = 3
+
+var tableNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// SQLStore keeps rate limit counters in a database table, so that
+// limits are shared between server instances.
+type SQLStore struct {
+	db    *sqlx.DB
+	table string
+}
+
+// NewSQLStore creates a SQL rate limit store, creating the table if needed.
+func NewSQLStore(ctx context.Context, db *sqlx.DB, table string) (*SQLStore, error) {
+	if table == "" {
+		table = DefaultSQLTable
+	}
+	if !tableNameRe.MatchString(table) {
+		return nil, fmt.Errorf("invalid rate limit table name %q", table)
+	}
+
+	query := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (rl_key VARCHAR(255) NOT NULL PRIMARY KEY, hits BIGINT NOT NULL, reset_at BIGINT NOT NULL)", table)
+	if _, err := db.ExecContext(ctx, query); err != nil {
+		return nil, fmt.Errorf("error creating rate limit table: %w", err)
+	}
+
+	return &SQLStore{
+		db:    db,
+		table: table,
+	}, nil
+}
+
+// Inc increments the counter for a key and returns the new count
+func (ss *SQLStore) Inc(ctx context.Context, key string) (int64, error) {
+	rl, err := ss.Hit(ctx, key, DefaultWindow)
+	return rl.Count, err
+}
+
+// Hit increments the counter for a key within a fixed window
+func (ss *SQLStore) Hit(ctx context.Context, key string, window time.Duration) (RateLimit, error) {
+	var (
+		rl  RateLimit
+		err error
+	)
+	for range sqlRetries {
+		if rl, err = ss.hit(ctx, sqlKey(key), window); err == nil || ctx.Err() != nil {
+			break
+		}
+	}
+	return rl, err
+}
+
+func (ss *SQLStore) hit(ctx context.Context, key string, window time.Duration) (RateLimit, error) {
+	tx, err := ss.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return RateLimit{}, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	query := tx.Rebind(fmt.Sprintf("UPDATE %s SET hits = hits + 1 WHERE rl_key = ? AND reset_at > ?", ss.table))
+	res, err := tx.ExecContext(ctx, query, key, now.UnixMilli())
+	if err != nil {
+		return RateLimit{}, err
+	}
+
+	rl := RateLimit{Count: 1, ResetTime: now.Add(window)}
+	if affected, err := res.RowsAffected(); err != nil {
+		return RateLimit{}, err
+	} else if affected == 0 {
+		// Start a new window
+		query = tx.Rebind(fmt.Sprintf("DELETE FROM %s WHERE rl_key = ?", ss.table))
+		if _, err := tx.ExecContext(ctx, query, key); err != nil {
+			return RateLimit{}, err
+		}
+		query = tx.Rebind(fmt.Sprintf("INSERT INTO %s (rl_key, hits, reset_at) VALUES (?, ?, ?)", ss.table))
+		if _, err := tx.ExecContext(ctx, query, key, rl.Count, rl.ResetTime.UnixMilli()); err != nil {
+			return RateLimit{}, err
+		}
+	} else {
+		var row struct {
+			Hits    int64 `db:"hits"`
+			ResetAt int64 `db:"reset_at"`
+		}
+		query = tx.Rebind(fmt.Sprintf("SELECT hits, reset_at FROM %s WHERE rl_key = ?", ss.table))
+		if err := tx.GetContext(ctx, &row, query, key); err != nil {
+			return RateLimit{}, err
+		}
+		rl = RateLimit{Count: row.Hits, ResetTime: time.UnixMilli(row.ResetAt)}
+	}
+
+	return rl, tx.Commit()
+}
+
+// Rate returns the current count for a key without incrementing
+func (ss *SQLStore) Rate(ctx context.Context, key string) (int64, error) {
+	var hits int64
+	query := ss.db.Rebind(fmt.Sprintf("SELECT hits FROM %s WHERE rl_key = ? AND reset_at > ?", ss.table))
+	err := ss.db.GetContext(ctx, &hits, query, sqlKey(key), time.Now().UnixMilli())
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return hits, err
+}
+
+// Reset resets the counter for a key
+func (ss *SQLStore) Reset(ctx context.Context, key string) error {
+	query := ss.db.Rebind(fmt.Sprintf("DELETE FROM %s WHERE rl_key = ?", ss.table))
+	_, err := ss.db.ExecContext(ctx, query, sqlKey(key))
+	return err
+}
+
+// Clear removes all rate limit entries
+func (ss *SQLStore) Clear(ctx context.Context) error {
+	_, err := ss.db.ExecContext(ctx, "DELETE FROM "+ss.table)
+	return err
+}
+
+// CleanupExpired removes all expired rate limit entries from the store
+func (ss *SQLStore) CleanupExpired(ctx context.Context) error {
+	query := ss.db.Rebind(fmt.Sprintf("DELETE FROM %s WHERE reset_at <= ?", ss.table))
+	_, err := ss.db.ExecContext(ctx, query, time.Now().UnixMilli())
+	return err
+}
+
+// sqlKey shortens keys which don't fit the key column.
+func sqlKey(key string) string {
+	if len(key) <= 255 {
+		return key
+	}
+	sum := sha256.Sum256([]byte(key))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
@@ -12,11 +12,21 @@ type RateLimit struct {
 	ResetTime time.Time
 }
 
+// DefaultWindow is the rate limit window used by Inc.
+const DefaultWindow = time.Second
+
+// DefaultSweepInterval is how often MemoryStore.Hit deletes expired keys.
+const DefaultSweepInterval = time.Minute
+
 // Store defines the interface for rate limit storage backends
 type Store interface {
 	// Inc increments the counter for a key and returns the current count
 	Inc(ctx context.Context, key string) (int64, error)
 
+	// Hit increments the counter for a key within a fixed window, and
+	// returns the current count and the time the window resets
+	Hit(ctx context.Context, key string, window time.Duration) (RateLimit, error)
+
 	// Rate returns the current count for a key without incrementing
 	Rate(ctx context.Context, key string) (int64, error)
 
@@ -27,10 +37,13 @@ type Store interface {
 	Clear(ctx context.Context) error
 }
 
-// MemoryStore is a simple in-memory rate limit store using map+mutex
+// MemoryStore is a simple in-memory rate limit store using map+mutex.
+// Expired keys are deleted by Hit every DefaultSweepInterval, so that
+// the store doesn't grow with every client seen.
 type MemoryStore struct {
-	mu    sync.RWMutex
-	store map[string]*RateLimit
+	mu      sync.RWMutex
+	store   map[string]*RateLimit
+	sweepAt time.Time
 }
 
 // NewMemoryStore creates a new in-memory rate limit store
@@ -42,32 +55,44 @@ func NewMemoryStore() *MemoryStore {
 
 // Inc increments the counter for a key and returns the new count
 func (ms *MemoryStore) Inc(ctx context.Context, key string) (int64, error) {
+	rl, err := ms.Hit(ctx, key, DefaultWindow)
+	return rl.Count, err
+}
+
+// Hit increments the counter for a key within a fixed window
+func (ms *MemoryStore) Hit(ctx context.Context, key string, window time.Duration) (RateLimit, error) {
 	select {
 	case <-ctx.Done():
-		return 0, ctx.Err()
+		return RateLimit{}, ctx.Err()
 	default:
 	}
 
 	ms.mu.Lock()
 	defer ms.mu.Unlock()
 
+	now := time.Now()
+	if now.After(ms.sweepAt) {
+		ms.sweep(now)
+		ms.sweepAt = now.Add(DefaultSweepInterval)
+	}
+
 	rl, exists := ms.store[key]
 	if !exists {
 		rl = &RateLimit{
 			Count:     0,
-			ResetTime: time.Now().Add(1 * time.Second),
+			ResetTime: now.Add(window),
 		}
 		ms.store[key] = rl
 	}
 
 	// Check if we need to reset the counter
-	if time.Now().After(rl.ResetTime) {
+	if now.After(rl.ResetTime) {
 		rl.Count = 0
-		rl.ResetTime = time.Now().Add(1 * time.Second)
+		rl.ResetTime = now.Add(window)
 	}
 
 	rl.Count++
-	return rl.Count, nil
+	return *rl, nil
 }
 
 // Rate returns the current count for a key without incrementing
@@ -136,12 +161,15 @@ func (ms *MemoryStore) CleanupExpired(ctx context.Context) error {
 	ms.mu.Lock()
 	defer ms.mu.Unlock()
 
-	now := time.Now()
+	ms.sweep(time.Now())
+	return nil
+}
+
+// sweep deletes the keys with windows which reset before now.
+func (ms *MemoryStore) sweep(now time.Time) {
 	for key, rl := range ms.store {
 		if now.After(rl.ResetTime) {
 			delete(ms.store, key)
 		}
 	}
-
-	return nil
 }
@@ -3,6 +3,7 @@ package ratelimit
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -182,3 +183,66 @@ func TestMemoryStoreResetContextCancellation(t *testing.T) {
 	require.Error(t, err)
 	require.Equal(t, context.Canceled, err)
 }
+
+// TestMemoryStoreHitWindow verifies that Hit resets the counter after the window.
+func TestMemoryStoreHitWindow(t *testing.T) {
+	store := NewMemoryStore()
+
+	rl, err := store.Hit(context.Background(), "key1", 20*time.Millisecond)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), rl.Count)
+	require.WithinDuration(t, time.Now().Add(20*time.Millisecond), rl.ResetTime, 10*time.Millisecond)
+
+	rl, _ = store.Hit(context.Background(), "key1", 20*time.Millisecond)
+	require.Equal(t, int64(2), rl.Count)
+
+	time.Sleep(30 * time.Millisecond)
+
+	rl, _ = store.Hit(context.Background(), "key1", 20*time.Millisecond)
+	require.Equal(t, int64(1), rl.Count)
+}
+
+// TestMemoryStoreSweep verifies that Hit deletes expired keys.
+func TestMemoryStoreSweep(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.Background()
+
+	for _, key := range []string{"key1", "key2", "key3"} {
+		_, err := store.Hit(ctx, key, 10*time.Millisecond)
+		require.NoError(t, err)
+	}
+	_, err := store.Hit(ctx, "key4", time.Hour)
+	require.NoError(t, err)
+	require.Len(t, store.store, 4)
+
+	time.Sleep(20 * time.Millisecond)
+
+	// Sweeps wait for the interval
+	_, err = store.Hit(ctx, "key5", time.Hour)
+	require.NoError(t, err)
+	require.Len(t, store.store, 5)
+
+	store.sweepAt = time.Time{}
+	_, err = store.Hit(ctx, "key5", time.Hour)
+	require.NoError(t, err)
+	require.Len(t, store.store, 2)
+	require.Contains(t, store.store, "key4")
+	require.Contains(t, store.store, "key5")
+}
+
+// TestMemoryStoreCleanupExpired verifies that expired keys are deleted.
+func TestMemoryStoreCleanupExpired(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.Background()
+
+	_, err := store.Hit(ctx, "key1", 10*time.Millisecond)
+	require.NoError(t, err)
+	_, err = store.Hit(ctx, "key2", time.Hour)
+	require.NoError(t, err)
+
+	time.Sleep(20 * time.Millisecond)
+
+	require.NoError(t, store.CleanupExpired(ctx))
+	require.Len(t, store.store, 1)
+	require.Contains(t, store.store, "key2")
+}
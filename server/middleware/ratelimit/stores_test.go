@@ -0,0 +1,127 @@
+package ratelimit_test
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+
+	"github.com/titpetric/etl/server/internal/redis"
+	"github.com/titpetric/etl/server/middleware/ratelimit"
+
+	_ "modernc.org/sqlite"
+)
+
+// testStores returns a constructor for each store implementation.
+func testStores() map[string]func(t *testing.T) ratelimit.Store {
+	return map[string]func(t *testing.T) ratelimit.Store{
+		"memory": func(t *testing.T) ratelimit.Store {
+			return ratelimit.NewMemoryStore()
+		},
+		"sql": func(t *testing.T) ratelimit.Store {
+			db, err := sqlx.Open("sqlite", filepath.Join(t.TempDir(), "ratelimit.db")+"?_pragma=busy_timeout(5000)")
+			require.NoError(t, err)
+			t.Cleanup(func() { db.Close() })
+
+			store, err := ratelimit.NewSQLStore(context.Background(), db, "")
+			require.NoError(t, err)
+			return store
+		},
+		"redis": func(t *testing.T) ratelimit.Store {
+			fake, err := redis.NewFake()
+			require.NoError(t, err)
+			t.Cleanup(func() { fake.Close() })
+
+			client, err := redis.NewClient(fake.Addr())
+			require.NoError(t, err)
+			t.Cleanup(func() { client.Close() })
+
+			return ratelimit.NewRedisStore(client, "")
+		},
+	}
+}
+
+// TestStores runs the same checks against each store implementation.
+func TestStores(t *testing.T) {
+	ctx := context.Background()
+
+	for name, newStore := range testStores() {
+		t.Run(name+"/Hit", func(t *testing.T) {
+			store := newStore(t)
+
+			rl, err := store.Hit(ctx, "key", time.Minute)
+			require.NoError(t, err)
+			require.Equal(t, int64(1), rl.Count)
+			require.WithinDuration(t, time.Now().Add(time.Minute), rl.ResetTime, time.Second)
+
+			rl, err = store.Hit(ctx, "key", time.Minute)
+			require.NoError(t, err)
+			require.Equal(t, int64(2), rl.Count)
+
+			count, err := store.Rate(ctx, "key")
+			require.NoError(t, err)
+			require.Equal(t, int64(2), count)
+
+			count, err = store.Rate(ctx, "other")
+			require.NoError(t, err)
+			require.Equal(t, int64(0), count)
+		})
+
+		t.Run(name+"/Window", func(t *testing.T) {
+			store := newStore(t)
+
+			_, err := store.Hit(ctx, "key", 20*time.Millisecond)
+			require.NoError(t, err)
+			_, err = store.Hit(ctx, "key", 20*time.Millisecond)
+			require.NoError(t, err)
+
+			time.Sleep(30 * time.Millisecond)
+
+			rl, err := store.Hit(ctx, "key", 20*time.Millisecond)
+			require.NoError(t, err)
+			require.Equal(t, int64(1), rl.Count)
+		})
+
+		t.Run(name+"/Concurrent", func(t *testing.T) {
+			store := newStore(t)
+
+			var wg sync.WaitGroup
+			for range 20 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, err := store.Hit(ctx, "key", time.Minute)
+					require.NoError(t, err)
+				}()
+			}
+			wg.Wait()
+
+			count, err := store.Rate(ctx, "key")
+			require.NoError(t, err)
+			require.Equal(t, int64(20), count)
+		})
+
+		t.Run(name+"/ResetClear", func(t *testing.T) {
+			store := newStore(t)
+
+			for _, key := range []string{"a", "b"} {
+				_, err := store.Inc(ctx, key)
+				require.NoError(t, err)
+			}
+
+			require.NoError(t, store.Reset(ctx, "a"))
+			count, _ := store.Rate(ctx, "a")
+			require.Equal(t, int64(0), count)
+			count, _ = store.Rate(ctx, "b")
+			require.Equal(t, int64(1), count)
+
+			require.NoError(t, store.Clear(ctx))
+			count, _ = store.Rate(ctx, "b")
+			require.Equal(t, int64(0), count)
+		})
+	}
+}
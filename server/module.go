@@ -2,24 +2,50 @@ package server
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
 
 	"github.com/titpetric/platform"
 
 	"github.com/titpetric/etl/server/config"
 	"github.com/titpetric/etl/server/internal/handler"
+	handlermodel "github.com/titpetric/etl/server/internal/handler/model"
+	"github.com/titpetric/etl/server/internal/redis"
+	"github.com/titpetric/etl/server/internal/storage"
+	"github.com/titpetric/etl/server/middleware/cache"
+	"github.com/titpetric/etl/server/middleware/ratelimit"
 )
 
 var _ platform.Module = (*Module)(nil)
 
 // Module implements platform.Module for the ETL server.
 type Module struct {
-	config *config.Config
+	config  *config.Config
+	storage *storage.Registry
+	cache   cache.Store
+	limits  ratelimit.Store
+
+	// Invalidations of the cache store, replaced with the store.
+	generations *cache.Generations
+
+	// Redis clients and the cleanup of the stores, closed by Stop.
+	clients []*redis.Client
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
 }
 
 // NewModule creates a new ETL server module.
 func NewModule(conf *config.Config) *Module {
 	return &Module{
-		config: conf,
+		config:  conf,
+		storage: storage.NewRegistry(),
+		cache:   cache.NewLRUStore(cache.DefaultMaxEntries, cache.DefaultMaxBytes),
+		limits:  ratelimit.NewMemoryStore(),
+
+		generations: cache.NewGenerations(),
 	}
 }
 
@@ -28,17 +54,65 @@ func (m *Module) Name() string {
 	return "etl"
 }
 
-// Start initializes the module (no-op for ETL server as mounting handles setup).
-func (m *Module) Start(ctx context.Context) error {
+// Start opens a connection pool for each configured storage and
+// checks the database can be reached. It opens the cache and rate
+// limit stores, and starts deleting expired entries in the background.
+// On error, the pools opened so far are closed.
+func (m *Module) Start(ctx context.Context) (err error) {
+	defer func() {
+		if err != nil {
+			m.storage.Close()
+		}
+	}()
+
+	for _, conf := range m.storages() {
+		if _, err := m.storage.Open(ctx, conf); err != nil {
+			return fmt.Errorf("error starting etl: %w", err)
+		}
+	}
+	if err := m.openStores(ctx); err != nil {
+		return fmt.Errorf("error starting etl: %w", err)
+	}
 	return nil
 }
 
-// Stop cleans up the module (no-op for ETL server).
+// Stop stops the store cleanup, and closes the connection pools.
 func (m *Module) Stop(ctx context.Context) error {
-	return nil
+	return errors.Join(m.closeStores(), m.storage.Close())
 }
 
 // Mount registers the ETL routes on the router.
 func (m *Module) Mount(ctx context.Context, r platform.Router) error {
-	return handler.Mount(r, m.config, m.config.Endpoints)
+	opts := &handlermodel.Options{
+		Config:      m.config,
+		Storage:     m.storage,
+		Cache:       m.cache,
+		Generations: m.generations,
+		RateLimit:   m.limits,
+	}
+	return handler.Mount(r, opts, m.config.Endpoints)
+}
+
+// storages returns the storage configs in use. The default storage
+// is only opened if it has a DSN, as not all servers need a database.
+func (m *Module) storages() []*config.Storage {
+	if m.config == nil {
+		return nil
+	}
+
+	var result []*config.Storage
+	if m.config.Storage != nil && m.config.Storage.DSN != "" {
+		result = append(result, m.config.Storage)
+	}
+
+	names := slices.Sorted(maps.Keys(m.config.Storages))
+	for _, name := range names {
+		result = append(result, m.config.Storages[name])
+	}
+	for _, endpoint := range m.config.Endpoints {
+		if endpoint.Handler.Storage != nil {
+			result = append(result, endpoint.Handler.Storage)
+		}
+	}
+	return result
 }
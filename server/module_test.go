@@ -2,11 +2,18 @@ package server
 
 import (
 	"context"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
 	"github.com/titpetric/etl/server/config"
+	"github.com/titpetric/etl/server/internal/redis"
+	"github.com/titpetric/etl/server/middleware/cache"
+	"github.com/titpetric/etl/server/middleware/ratelimit"
+
+	_ "modernc.org/sqlite"
 )
 
 // TestNewModule verifies that NewModule creates a module with the provided config.
@@ -31,7 +38,7 @@ func TestModuleName(t *testing.T) {
 	require.Equal(t, "etl", module.Name())
 }
 
-// TestModuleStart verifies that Start returns nil (no-op).
+// TestModuleStart verifies that Start succeeds without storages or stores.
 func TestModuleStart(t *testing.T) {
 	cfg := &config.Config{}
 	module := NewModule(cfg)
@@ -40,7 +47,8 @@ func TestModuleStart(t *testing.T) {
 	require.NoError(t, err)
 }
 
-// TestModuleStartWithCancelledContext verifies that Start handles cancelled context.
+// TestModuleStartWithCancelledContext verifies that Start succeeds with a
+// cancelled context, when there is nothing to connect to.
 func TestModuleStartWithCancelledContext(t *testing.T) {
 	cfg := &config.Config{}
 	module := NewModule(cfg)
@@ -52,7 +60,7 @@ func TestModuleStartWithCancelledContext(t *testing.T) {
 	require.NoError(t, err)
 }
 
-// TestModuleStop verifies that Stop returns nil (no-op).
+// TestModuleStop verifies that Stop succeeds when nothing was opened.
 func TestModuleStop(t *testing.T) {
 	cfg := &config.Config{}
 	module := NewModule(cfg)
@@ -61,7 +69,8 @@ func TestModuleStop(t *testing.T) {
 	require.NoError(t, err)
 }
 
-// TestModuleStopWithCancelledContext verifies that Stop handles cancelled context.
+// TestModuleStopWithCancelledContext verifies that Stop closes with a
+// cancelled context.
 func TestModuleStopWithCancelledContext(t *testing.T) {
 	cfg := &config.Config{}
 	module := NewModule(cfg)
@@ -112,3 +121,117 @@ func TestModuleNameConsistency(t *testing.T) {
 	require.Equal(t, name1, name2)
 	require.Equal(t, "etl", name1)
 }
+
+// TestModuleStores verifies that the configured cache and rate limit stores are opened.
+func TestModuleStores(t *testing.T) {
+	fake, err := redis.NewFake()
+	require.NoError(t, err)
+	defer fake.Close()
+
+	cfg := &config.Config{
+		Storage: &config.Storage{
+			DSN: "sqlite://file:" + filepath.Join(t.TempDir(), "stores.db"),
+		},
+		Server: config.Server{
+			Cache: &config.Store{
+				Type:    config.StoreSQL,
+				Table:   "http_cache",
+				Cleanup: "10ms",
+			},
+			RateLimit: &config.Store{
+				Type: config.StoreRedis,
+				Addr: fake.Addr(),
+			},
+		},
+	}
+
+	module := NewModule(cfg)
+	require.NoError(t, module.Start(context.Background()))
+	defer module.Stop(context.Background())
+
+	require.IsType(t, &cache.SQLStore{}, module.cache)
+	require.IsType(t, &ratelimit.RedisStore{}, module.limits)
+
+	_, err = module.limits.Hit(context.Background(), "key", time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, []string{"etl:rl:key"}, fake.Keys())
+
+	db, err := module.storage.Get(cfg.Storage)
+	require.NoError(t, err)
+
+	// The background cleanup deletes expired entries
+	require.NoError(t, module.cache.Set(context.Background(), "key", &cache.Entry{}, time.Millisecond))
+	require.Eventually(t, func() bool {
+		var count int
+		return db.Get(&count, "SELECT COUNT(*) FROM http_cache") == nil && count == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+// TestModuleStoresInvalid verifies that invalid store configs fail Start.
+func TestModuleStoresInvalid(t *testing.T) {
+	tests := []struct {
+		store *config.Store
+		err   string
+	}{
+		{&config.Store{Type: "disk"}, `unknown store type "disk"`},
+		{&config.Store{Type: config.StoreRedis}, "redis store requires addr"},
+		{&config.Store{Cleanup: "often"}, `invalid cleanup interval "often"`},
+	}
+
+	for _, tt := range tests {
+		module := NewModule(&config.Config{
+			Server: config.Server{Cache: tt.store},
+		})
+		err := module.Start(context.Background())
+		require.ErrorContains(t, err, tt.err)
+		module.Stop(context.Background())
+	}
+}
+
+// TestModuleStoresClosedOnError verifies that stores and pools opened
+// before a failing store are closed.
+func TestModuleStoresClosedOnError(t *testing.T) {
+	fake, err := redis.NewFake()
+	require.NoError(t, err)
+	defer fake.Close()
+
+	module := NewModule(&config.Config{
+		Storage: &config.Storage{DSN: "sqlite://file:" + filepath.Join(t.TempDir(), "test.db")},
+		Server: config.Server{
+			Cache:     &config.Store{Type: config.StoreRedis, Addr: fake.Addr()},
+			RateLimit: &config.Store{Type: "disk"},
+		},
+	})
+	require.ErrorContains(t, module.Start(context.Background()), `unknown store type "disk"`)
+	require.Empty(t, module.clients)
+	require.Zero(t, module.storage.Len())
+
+	_, err = module.cache.Get(context.Background(), "key")
+	require.ErrorContains(t, err, "client is closed")
+}
+
+// TestModuleStorageUnreachable verifies that Start fails when a storage
+// can't be reached, and closes the pools opened before it.
+func TestModuleStorageUnreachable(t *testing.T) {
+	fake, err := redis.NewFake()
+	require.NoError(t, err)
+	defer fake.Close()
+
+	dir := t.TempDir()
+	module := NewModule(&config.Config{
+		Storages: map[string]*config.Storage{
+			"a": {DSN: "sqlite://file:" + filepath.Join(dir, "test.db")},
+			"b": {DSN: "sqlite://file:" + filepath.Join(dir, "missing", "test.db")},
+		},
+		Server: config.Server{
+			Cache: &config.Store{Type: config.StoreRedis, Addr: fake.Addr()},
+		},
+	})
+
+	err = module.Start(context.Background())
+	require.ErrorContains(t, err, "error starting etl")
+	require.ErrorContains(t, err, "can't connect")
+	require.Zero(t, module.storage.Len())
+	require.Empty(t, module.clients)
+	require.Nil(t, module.cancel)
+}
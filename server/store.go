@@ -0,0 +1,196 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/titpetric/etl/server/config"
+	"github.com/titpetric/etl/server/internal/redis"
+	"github.com/titpetric/etl/server/middleware/cache"
+	"github.com/titpetric/etl/server/middleware/ratelimit"
+)
+
+// DefaultStoreCleanup is the interval for deleting expired entries
+// from the cache and rate limit stores.
+const DefaultStoreCleanup = time.Minute
+
+// cleaner is implemented by stores which keep expired entries.
+type cleaner interface {
+	CleanupExpired(ctx context.Context) error
+}
+
+// openStores creates the cache and rate limit stores from config,
+// and starts the background cleanup of expired entries. On error,
+// the stores opened so far are closed.
+func (m *Module) openStores(ctx context.Context) (err error) {
+	var cacheConf, rateLimitConf *config.Store
+	if m.config != nil {
+		cacheConf, rateLimitConf = m.config.Server.Cache, m.config.Server.RateLimit
+	}
+
+	defer func() {
+		if err != nil {
+			m.closeStores()
+		}
+	}()
+
+	if m.cache, err = m.newCacheStore(ctx, cacheConf); err != nil {
+		return fmt.Errorf("cache store: %w", err)
+	}
+	m.generations = cache.NewGenerations()
+	if m.limits, err = m.newRateLimitStore(ctx, rateLimitConf); err != nil {
+		return fmt.Errorf("rate limit store: %w", err)
+	}
+
+	// The cleanup outlives the start context, and is stopped by Stop.
+	ctx, m.cancel = context.WithCancel(context.WithoutCancel(ctx))
+	if err := m.cleanup(ctx, cacheConf, m.cache); err != nil {
+		return fmt.Errorf("cache store: %w", err)
+	}
+	if err := m.cleanup(ctx, rateLimitConf, m.limits); err != nil {
+		return fmt.Errorf("rate limit store: %w", err)
+	}
+	return nil
+}
+
+// closeStores stops the cleanup, and closes the redis clients.
+func (m *Module) closeStores() error {
+	if m.cancel != nil {
+		m.cancel()
+	}
+	m.wg.Wait()
+
+	var errs []error
+	for _, client := range m.clients {
+		errs = append(errs, client.Close())
+	}
+	m.clients = nil
+	return errors.Join(errs...)
+}
+
+func (m *Module) newCacheStore(ctx context.Context, conf *config.Store) (cache.Store, error) {
+	switch storeType(conf) {
+	case config.StoreMemory:
+		maxEntries, maxBytes := cache.DefaultMaxEntries, int64(cache.DefaultMaxBytes)
+		if conf != nil && conf.MaxEntries != 0 {
+			maxEntries = conf.MaxEntries
+		}
+		if conf != nil && conf.MaxBytes != 0 {
+			maxBytes = conf.MaxBytes
+		}
+		return cache.NewLRUStore(maxEntries, maxBytes), nil
+	case config.StoreSQL:
+		db, err := m.storeDB(ctx, conf)
+		if err != nil {
+			return nil, err
+		}
+		return cache.NewSQLStore(ctx, db, conf.Table)
+	case config.StoreRedis:
+		client, err := m.redisClient(ctx, conf)
+		if err != nil {
+			return nil, err
+		}
+		return cache.NewRedisStore(client, conf.Prefix), nil
+	}
+	return nil, unknownStoreType(conf)
+}
+
+func (m *Module) newRateLimitStore(ctx context.Context, conf *config.Store) (ratelimit.Store, error) {
+	switch storeType(conf) {
+	case config.StoreMemory:
+		return ratelimit.NewMemoryStore(), nil
+	case config.StoreSQL:
+		db, err := m.storeDB(ctx, conf)
+		if err != nil {
+			return nil, err
+		}
+		return ratelimit.NewSQLStore(ctx, db, conf.Table)
+	case config.StoreRedis:
+		client, err := m.redisClient(ctx, conf)
+		if err != nil {
+			return nil, err
+		}
+		return ratelimit.NewRedisStore(client, conf.Prefix), nil
+	}
+	return nil, unknownStoreType(conf)
+}
+
+// storeDB returns the database for a sql store, the server storage if unset.
+func (m *Module) storeDB(ctx context.Context, conf *config.Store) (*sqlx.DB, error) {
+	storage := conf.Storage
+	if storage == nil {
+		storage = m.config.Storage
+	}
+	return m.storage.Open(ctx, storage)
+}
+
+// redisClient connects to the server of a redis store.
+func (m *Module) redisClient(ctx context.Context, conf *config.Store) (*redis.Client, error) {
+	if conf.Addr == "" {
+		return nil, fmt.Errorf("redis store requires addr")
+	}
+
+	client, err := redis.NewClient(conf.Addr)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := client.Do(ctx, "PING"); err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	m.clients = append(m.clients, client)
+	return client, nil
+}
+
+// cleanup periodically deletes expired entries from the store.
+func (m *Module) cleanup(ctx context.Context, conf *config.Store, store any) error {
+	c, ok := store.(cleaner)
+	if !ok {
+		return nil
+	}
+
+	interval := DefaultStoreCleanup
+	if conf != nil && conf.Cleanup != "" {
+		var err error
+		if interval, err = time.ParseDuration(conf.Cleanup); err != nil || interval <= 0 {
+			return fmt.Errorf("invalid cleanup interval %q", conf.Cleanup)
+		}
+	}
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := c.CleanupExpired(ctx); err != nil && ctx.Err() == nil {
+					log.Printf("error deleting expired entries: %v", err)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+func storeType(conf *config.Store) string {
+	if conf == nil || conf.Type == "" {
+		return config.StoreMemory
+	}
+	return conf.Type
+}
+
+func unknownStoreType(conf *config.Store) error {
+	return fmt.Errorf("unknown store type %q, supported %v", conf.Type, []string{config.StoreMemory, config.StoreSQL, config.StoreRedis})
+}
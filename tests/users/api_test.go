@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 	"os"
@@ -69,6 +70,64 @@ func TestCQRSAPIDirect(t *testing.T) {
 		require.Equal(t, "alice@example.com", users[0]["email"])
 	})
 
+	t.Run("Query/JSON/ListUsersPaged", func(t *testing.T) {
+		resp, err := http.Get(baseURL + "/api/users?limit=2")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		require.Equal(t, 200, resp.StatusCode)
+		require.Equal(t, "3", resp.Header.Get("X-Total-Count"))
+		require.Equal(t, `</api/users?limit=2&offset=2>; rel="next"`, resp.Header.Get("Link"))
+
+		var users []map[string]interface{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&users))
+		require.Len(t, users, 2)
+		require.Equal(t, "Alice Johnson", users[0]["name"])
+
+		resp, err = http.Get(baseURL + "/api/users?limit=2&offset=2")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		require.Equal(t, `</api/users?limit=2&offset=0>; rel="prev"`, resp.Header.Get("Link"))
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&users))
+		require.Len(t, users, 1)
+
+		resp, err = http.Get(baseURL + "/api/users?limit=abc")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("Query/JSON/ListUsersFiltered", func(t *testing.T) {
+		resp, err := http.Get(baseURL + "/api/users?name=like:%25O%25&id=in:1,3&sort=-name")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		require.Equal(t, 200, resp.StatusCode)
+		require.Equal(t, "2", resp.Header.Get("X-Total-Count"))
+
+		var users []map[string]interface{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&users))
+		require.Len(t, users, 2)
+		require.Equal(t, "Carol White", users[0]["name"])
+		require.Equal(t, "Alice Johnson", users[1]["name"])
+
+		resp, err = http.Get(baseURL + "/api/users?email=eq:alice@example.com&sort=password")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+		var result struct {
+			Errors []map[string]string `json:"errors"`
+		}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+		require.Equal(t, []map[string]string{
+			{"field": "sort", "source": "query", "message": "can't sort by password"},
+		}, result.Errors)
+	})
+
 	t.Run("Query/JSON/GetUser", func(t *testing.T) {
 		resp, err := http.Get(baseURL + "/api/users/1")
 		require.NoError(t, err)
@@ -80,8 +139,46 @@ func TestCQRSAPIDirect(t *testing.T) {
 		err = json.NewDecoder(resp.Body).Decode(&user)
 		require.NoError(t, err)
 
-		require.Equal(t, "1", user["id"])
+		require.Equal(t, float64(1), user["id"])
 		require.Equal(t, "Alice Johnson", user["name"])
+		require.Regexp(t, `^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}Z$`, user["created_at"])
+	})
+
+	t.Run("Query/JSON/GetUserNotModified", func(t *testing.T) {
+		resp, err := http.Get(baseURL + "/api/users/1")
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		etag := resp.Header.Get("ETag")
+		require.NotEmpty(t, etag)
+		require.Regexp(t, `^max-age=(600|599)$`, resp.Header.Get("Cache-Control"))
+
+		req, err := http.NewRequest("GET", baseURL+"/api/users/1", nil)
+		require.NoError(t, err)
+		req.Header.Set("If-None-Match", etag)
+
+		resp, err = http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		require.Equal(t, http.StatusNotModified, resp.StatusCode)
+	})
+
+	t.Run("Query/JSON/GetUserInvalidID", func(t *testing.T) {
+		resp, err := http.Get(baseURL + "/api/users/abc")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		require.Equal(t, "application/problem+json", resp.Header.Get("Content-Type"))
+
+		var result struct {
+			Errors []map[string]string `json:"errors"`
+		}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+		require.Equal(t, []map[string]string{
+			{"field": "id", "source": "path", "message": "must be an integer"},
+		}, result.Errors)
 	})
 
 	t.Run("Query/JSON/GetUserNotFound", func(t *testing.T) {
@@ -89,9 +186,13 @@ func TestCQRSAPIDirect(t *testing.T) {
 		require.NoError(t, err)
 		defer resp.Body.Close()
 
-		// When no rows found, server typically returns 200 with null
-		body, _ := io.ReadAll(resp.Body)
-		t.Logf("Response status: %d, body: %s", resp.StatusCode, string(body))
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+		require.Equal(t, "application/problem+json", resp.Header.Get("Content-Type"))
+
+		var result map[string]any
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+		require.Equal(t, "not_found", result["kind"])
+		require.Equal(t, float64(http.StatusNotFound), result["status"])
 	})
 
 	t.Run("Query/HTML/ListUsers", func(t *testing.T) {
@@ -154,6 +255,34 @@ func TestCQRSAPIDirect(t *testing.T) {
 		require.Equal(t, "david@example.com", user["email"])
 	})
 
+	t.Run("Command/CreateUserInvalid", func(t *testing.T) {
+		payload := bytes.NewBufferString(`{"email":"not-an-email"}`)
+		resp, err := http.Post(baseURL+"/api/users", "application/json", payload)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+		body, _ := io.ReadAll(resp.Body)
+		require.Contains(t, string(body), `"field":"name","source":"body","message":"is required"`)
+		require.Contains(t, string(body), `"field":"email","source":"body","message":"must match`)
+	})
+
+	t.Run("Command/CreateUserDuplicate", func(t *testing.T) {
+		payload := bytes.NewBufferString(`{"name":"David Again","email":"david@example.com"}`)
+		resp, err := http.Post(baseURL+"/api/users", "application/json", payload)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		require.Equal(t, http.StatusConflict, resp.StatusCode)
+		require.Equal(t, "application/problem+json", resp.Header.Get("Content-Type"))
+
+		var result map[string]any
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+		require.Equal(t, "unique", result["kind"])
+		require.NotContains(t, result, "query")
+	})
+
 	t.Run("Command/UpdateUser", func(t *testing.T) {
 		client := &http.Client{}
 		payload := bytes.NewBufferString(`{"name":"Alice Updated","email":"alice.updated@example.com"}`)
@@ -174,6 +303,15 @@ func TestCQRSAPIDirect(t *testing.T) {
 		require.NoError(t, err)
 
 		require.Equal(t, "Alice Updated", user["name"])
+
+		// The cached user is invalidated by the update
+		verifyResp, err := http.Get(baseURL + "/api/users/1")
+		require.NoError(t, err)
+		defer verifyResp.Body.Close()
+
+		body, _ = io.ReadAll(verifyResp.Body)
+		require.NoError(t, json.Unmarshal(body, &user))
+		require.Equal(t, "Alice Updated", user["name"])
 	})
 
 	t.Run("Command/DeleteUser", func(t *testing.T) {
@@ -226,6 +364,20 @@ func TestCQRSAPIDirect(t *testing.T) {
 		require.Len(t, orders, 2, "expected 2 orders for user 1")
 	})
 
+	t.Run("Query/GetUserOrdersNotFound", func(t *testing.T) {
+		resp, err := http.Get(baseURL + "/users/999/orders")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+		require.Equal(t, "application/problem+json", resp.Header.Get("Content-Type"))
+
+		var result map[string]any
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+		require.Equal(t, "assert", result["kind"])
+		require.Equal(t, "User not found.", result["detail"])
+	})
+
 	t.Run("Command/CreateOrder", func(t *testing.T) {
 		client := &http.Client{}
 		payload := bytes.NewBufferString(`{"user_id":"1","total_amount":"50.00","status":"pending"}`)
@@ -248,6 +400,53 @@ func TestCQRSAPIDirect(t *testing.T) {
 		require.Equal(t, "pending", order["status"])
 	})
 
+	t.Run("Table/Orders", func(t *testing.T) {
+		client := &http.Client{}
+
+		resp, err := http.Get(baseURL + "/api/orders?user_id=1&status=completed&sort=-total_amount")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		require.Equal(t, 200, resp.StatusCode)
+		require.Equal(t, "2", resp.Header.Get("X-Total-Count"))
+
+		var orders []map[string]interface{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&orders))
+		require.Len(t, orders, 2)
+		require.Equal(t, float64(2), orders[0]["id"])
+		require.Equal(t, 149.5, orders[0]["total_amount"])
+
+		req, err := http.NewRequest("POST", baseURL+"/api/orders", bytes.NewBufferString(`{"user_id": 2, "total_amount": 10.5}`))
+		require.NoError(t, err)
+		resp, err = client.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		require.Equal(t, 201, resp.StatusCode)
+
+		var order map[string]interface{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&order))
+		require.Equal(t, "pending", order["status"])
+
+		req, err = http.NewRequest("PATCH", baseURL+"/api/orders/"+fmt.Sprint(order["id"]), bytes.NewBufferString(`{"status": "completed"}`))
+		require.NoError(t, err)
+		resp, err = client.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		require.Equal(t, 200, resp.StatusCode)
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&order))
+		require.Equal(t, "completed", order["status"])
+
+		req, err = http.NewRequest("DELETE", baseURL+"/api/orders/"+fmt.Sprint(order["id"]), nil)
+		require.NoError(t, err)
+		resp, err = client.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		require.Equal(t, 405, resp.StatusCode)
+	})
+
 	// ====================
 	// LOGIN/SESSION TESTS
 	// ====================
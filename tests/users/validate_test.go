@@ -15,11 +15,11 @@ func TestValidateConfig(t *testing.T) {
 		t.Fatalf("Failed to load config: %v", err)
 	}
 
-	// Expected: 14 endpoints
+	// Expected: 15 endpoints
 	// Query side: 4 users + 1 login + 3 orders = 8
 	// Command side: 3 users + 2 login + 1 order = 6
-	// CQRS split: 8 read + 6 write = 14 total endpoints
-	expectedEndpoints := 14
+	// CQRS split: 8 read + 6 write = 14 endpoints, and 1 orders table
+	expectedEndpoints := 15
 	if len(cfg.Endpoints) != expectedEndpoints {
 		t.Fatalf("Expected %d endpoints, got %d", expectedEndpoints, len(cfg.Endpoints))
 	}